@@ -0,0 +1,45 @@
+package pcregexp
+
+// AppendSubmatchIndex appends the index pairs identifying the leftmost match
+// of the regexp in b and the matches of its subexpressions to dst, and
+// returns the extended slice. If there is no match, dst is returned
+// unchanged, so a match is reported by the result being longer than dst.
+//
+// Unlike [PCREgexp.FindSubmatchIndex], it does not allocate the result when
+// dst has enough capacity.
+func (re *PCREgexp) AppendSubmatchIndex(dst []int, b []byte) []int {
+	result := re.match(dst, b)
+	if result == nil {
+		return dst
+	}
+
+	return result
+}
+
+// AppendReplace appends a copy of src to dst, replacing matches of the regexp
+// with repl, and returns the extended slice. Like [PCREgexp.ReplaceAll], repl
+// is substituted literally.
+//
+// If an empty match is encountered, it advances one UTF-8 rune to avoid
+// infinite loop.
+func (re *PCREgexp) AppendReplace(dst, src, repl []byte) []byte {
+	last := 0
+	re.forEachMatch(src, -1, func(loc []int) {
+		dst = append(dst, src[last:loc[0]]...)
+		dst = append(dst, repl...)
+		last = loc[1]
+	})
+
+	return append(dst, src[last:]...)
+}
+
+// Count returns the number of successive, non-overlapping matches of the
+// regexp in b, as found by [PCREgexp.FindAllIndex], without collecting them.
+func (re *PCREgexp) Count(b []byte) int {
+	n := 0
+	re.forEachMatch(b, -1, func([]int) {
+		n++
+	})
+
+	return n
+}
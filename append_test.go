@@ -0,0 +1,102 @@
+package pcregexp_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+func TestRegexp_AppendSubmatchIndex(t *testing.T) {
+	re := pcregexp.MustCompile(`p([a-z]+)ch`)
+	defer re.Close()
+
+	dst := make([]int, 1, 8)
+	dst[0] = 42
+
+	got := re.AppendSubmatchIndex(dst, []byte("a peach"))
+	if want := []int{42, 2, 7, 3, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AppendSubmatchIndex() = %v, want %v", got, want)
+	}
+	if &got[0] != &dst[0] {
+		t.Errorf("AppendSubmatchIndex() reallocated dst with enough capacity")
+	}
+
+	if got := re.AppendSubmatchIndex(dst, []byte("apple")); !reflect.DeepEqual(got, dst) {
+		t.Errorf("AppendSubmatchIndex() without match = %v, want %v", got, dst)
+	}
+}
+
+func TestRegexp_AppendReplace(t *testing.T) {
+	re := pcregexp.MustCompile(`a([a-z])e`)
+	defer re.Close()
+
+	tests := []struct {
+		dst, src, repl string
+		want           string
+	}{
+		{"", "age ace", "X", "X X"},
+		{">> ", "age ace", "$1", ">> $1 $1"},
+		{">> ", "no match", "X", ">> no match"},
+		{"", "", "X", ""},
+	}
+
+	for _, tt := range tests {
+		got := re.AppendReplace([]byte(tt.dst), []byte(tt.src), []byte(tt.repl))
+		if !bytes.Equal(got, []byte(tt.want)) {
+			t.Errorf("AppendReplace(%q, %q, %q) = %q, want %q", tt.dst, tt.src, tt.repl, got, tt.want)
+		}
+	}
+}
+
+func TestRegexp_Count(t *testing.T) {
+	tests := []struct {
+		pattern string
+		input   string
+		want    int
+	}{
+		{`p([a-z]+)ch`, "peach punch pinch", 3},
+		{`p([a-z]+)ch`, "no matches", 0},
+		{`x*`, "abc", 4},
+		{`(?<=\d)\d`, "123", 2},
+		{`^a`, "aaa", 1},
+	}
+
+	for _, tt := range tests {
+		re := pcregexp.MustCompile(tt.pattern)
+		if got := re.Count([]byte(tt.input)); got != tt.want {
+			t.Errorf("Count(%q) with pattern %q = %d, want %d", tt.input, tt.pattern, got, tt.want)
+		}
+		if got := len(re.FindAllIndex([]byte(tt.input), -1)); got != tt.want {
+			t.Errorf("len(FindAllIndex(%q)) with pattern %q = %d, want %d", tt.input, tt.pattern, got, tt.want)
+		}
+		re.Close()
+	}
+}
+
+func TestRegexp_NoAliasing(t *testing.T) {
+	re := pcregexp.MustCompile(`p([a-z]+)ch`)
+	defer re.Close()
+
+	first := re.FindStringIndex("peach")
+	second := re.FindStringIndex("a punch")
+	if want := []int{0, 5}; !reflect.DeepEqual(first, want) {
+		t.Errorf("FindStringIndex() = %v after another match, want %v", first, want)
+	}
+	if want := []int{2, 7}; !reflect.DeepEqual(second, want) {
+		t.Errorf("FindStringIndex() = %v, want %v", second, want)
+	}
+
+	sub := re.FindSubmatchIndex([]byte("peach"))
+	re.FindSubmatchIndex([]byte("a punch"))
+	if want := []int{0, 5, 1, 3}; !reflect.DeepEqual(sub, want) {
+		t.Errorf("FindSubmatchIndex() = %v after another match, want %v", sub, want)
+	}
+
+	text, _ := re.MarshalText()
+	text[0] = 'X'
+	if got := re.String(); got != `p([a-z]+)ch` {
+		t.Errorf("String() = %q after modifying MarshalText result", got)
+	}
+}
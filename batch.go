@@ -0,0 +1,138 @@
+package pcregexp
+
+import (
+	"runtime"
+	"sync"
+)
+
+// MinSubjectsPerWorker is the smallest number of subjects the parallel batch
+// methods hand to a worker goroutine. Smaller batches are matched on the
+// calling goroutine, where the cost of starting workers would dominate.
+const MinSubjectsPerWorker = 64
+
+// MatchEach reports whether the regexp matches each of subjects. The result
+// for subjects[i] is the same as that of [PCREgexp.Match] for it.
+//
+// The match data and match context are resolved once for the whole batch,
+// which amortizes the per-call overhead on short subjects. The result cache
+// is bypassed.
+func (re *PCREgexp) MatchEach(subjects [][]byte) []bool {
+	return re.MatchEachParallel(subjects, 1)
+}
+
+// MatchEachParallel is like [PCREgexp.MatchEach], but spreads the subjects
+// across up to workers goroutines, each with its own match data and, for JIT
+// compiled patterns, its own JIT stack. If workers <= 0, GOMAXPROCS is used.
+// Each worker is given at least [MinSubjectsPerWorker] subjects.
+func (re *PCREgexp) MatchEachParallel(subjects [][]byte, workers int) []bool {
+	results := make([]bool, len(subjects))
+
+	re.runBatch(len(subjects), workers, func(m *matcher, i int) {
+		m.buf, results[i] = m.exec(m.buf[:0], subjects[i], 0, 0)
+	})
+
+	return results
+}
+
+// FindIndexEach appends the location of the leftmost match in each of
+// subjects to dst and returns the extended slice. The location appended for
+// subjects[i] is the same as that returned by [PCREgexp.FindIndex] for it:
+// a two-element slice, or nil if there is no match.
+//
+// Like [PCREgexp.MatchEach], it resolves the match data and match context
+// once for the whole batch. All locations share a single allocation.
+func (re *PCREgexp) FindIndexEach(subjects [][]byte, dst [][]int) [][]int {
+	return re.FindIndexEachParallel(subjects, dst, 1)
+}
+
+// FindIndexEachParallel is like [PCREgexp.FindIndexEach], but spreads the
+// subjects across up to workers goroutines, like
+// [PCREgexp.MatchEachParallel].
+func (re *PCREgexp) FindIndexEachParallel(subjects [][]byte, dst [][]int, workers int) [][]int {
+	start := len(dst)
+	dst = append(dst, make([][]int, len(subjects))...)
+	locs := dst[start:]
+	flat := make([]int, 2*len(subjects))
+
+	re.runBatch(len(subjects), workers, func(m *matcher, i int) {
+		var ok bool
+		if m.buf, ok = m.exec(m.buf[:0], subjects[i], 0, 0); ok {
+			// Cap the capacity, so that appending to one location cannot
+			// overwrite the next.
+			loc := flat[2*i : 2*i+2 : 2*i+2]
+			copy(loc, m.buf)
+			locs[i] = loc
+		}
+	})
+
+	return dst
+}
+
+// runBatch calls fn for every index in [0, n), spread across up to workers
+// goroutines with a matcher each. It does nothing if the regexp matches
+// nothing, such as after [PCREgexp.Close].
+func (re *PCREgexp) runBatch(n, workers int, fn func(m *matcher, i int)) {
+	if re.h == nil || re.h.code == 0 || n == 0 {
+		return
+	}
+
+	// The handle must outlive the PCRE2 calls made by fn.
+	defer runtime.KeepAlive(re.h)
+
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	if limit := n / MinSubjectsPerWorker; workers > limit {
+		workers = limit
+	}
+
+	matchers := make([]*matcher, 0, workers)
+	defer func() {
+		for _, m := range matchers {
+			m.free()
+		}
+	}()
+
+	if workers > 1 {
+		for len(matchers) < workers {
+			m, ok := re.h.newMatcher(re.isJIT)
+			if !ok {
+				break
+			}
+			matchers = append(matchers, m)
+		}
+	}
+
+	if len(matchers) <= 1 {
+		m, ok := re.h.matcher(re.isJIT)
+		if !ok {
+			return
+		}
+
+		for i := 0; i < n; i++ {
+			fn(&m, i)
+		}
+
+		return
+	}
+
+	var wg sync.WaitGroup
+	chunk := (n + len(matchers) - 1) / len(matchers)
+	for w, m := range matchers {
+		lo, hi := w*chunk, (w+1)*chunk
+		if hi > n {
+			hi = n
+		}
+
+		wg.Add(1)
+		go func(m *matcher, lo, hi int) {
+			defer wg.Done()
+
+			for i := lo; i < hi; i++ {
+				fn(m, i)
+			}
+		}(m, lo, hi)
+	}
+	wg.Wait()
+}
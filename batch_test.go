@@ -0,0 +1,106 @@
+package pcregexp_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+func batchSubjects(n int) [][]byte {
+	subjects := make([][]byte, n)
+	for i := range subjects {
+		switch i % 4 {
+		case 0:
+			subjects[i] = []byte(fmt.Sprintf("peach %d", i))
+		case 1:
+			subjects[i] = []byte(fmt.Sprintf("%d punch", i))
+		case 2:
+			subjects[i] = []byte(fmt.Sprintf("apple %d", i))
+		case 3:
+			subjects[i] = nil
+		}
+	}
+
+	return subjects
+}
+
+func TestRegexp_MatchEach(t *testing.T) {
+	re := pcregexp.MustCompile(`p([a-z]+)ch`)
+	defer re.Close()
+
+	subjects := batchSubjects(1000)
+
+	want := make([]bool, len(subjects))
+	for i, s := range subjects {
+		want[i] = re.Match(s)
+	}
+
+	for _, workers := range []int{1, 4, 0} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			if got := re.MatchEachParallel(subjects, workers); !reflect.DeepEqual(got, want) {
+				t.Errorf("MatchEachParallel(%d) differs from Match", workers)
+			}
+		})
+	}
+
+	if got := re.MatchEach(subjects); !reflect.DeepEqual(got, want) {
+		t.Errorf("MatchEach() differs from Match")
+	}
+}
+
+func TestRegexp_FindIndexEach(t *testing.T) {
+	re := pcregexp.MustCompile(`p([a-z]+)ch`)
+	defer re.Close()
+
+	subjects := batchSubjects(1000)
+
+	want := [][]int{{42}}
+	for _, s := range subjects {
+		want = append(want, re.FindIndex(s))
+	}
+
+	for _, workers := range []int{1, 4} {
+		got := re.FindIndexEachParallel(subjects, [][]int{{42}}, workers)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("FindIndexEachParallel(%d) differs from FindIndex", workers)
+		}
+	}
+
+	got := re.FindIndexEach(subjects[:2], nil)
+	got[0] = append(got[0], 99)
+	if want := []int{2, 7}; !reflect.DeepEqual(got[1], want) {
+		t.Errorf("appending to one location changed the next: %v, want %v", got[1], want)
+	}
+}
+
+func TestRegexp_MatchEachParallelStats(t *testing.T) {
+	re := pcregexp.MustCompile(`p([a-z]+)ch`)
+	defer re.Close()
+
+	re.Match(nil) // create the match data of re itself
+
+	before := pcregexp.Stats()
+	re.MatchEachParallel(batchSubjects(1000), 4)
+	after := pcregexp.Stats()
+
+	if before.LiveMatchData != after.LiveMatchData ||
+		before.LiveJITStacks != after.LiveJITStacks ||
+		before.LiveMatchContexts != after.LiveMatchContexts {
+		t.Errorf("worker resources leaked: before %+v, after %+v", before, after)
+	}
+}
+
+func TestRegexp_MatchEachClosed(t *testing.T) {
+	re := pcregexp.MustCompile(`p([a-z]+)ch`)
+	re.Close()
+
+	subjects := batchSubjects(8)
+	if got := re.MatchEach(subjects); !reflect.DeepEqual(got, make([]bool, len(subjects))) {
+		t.Errorf("MatchEach() after Close = %v, want all false", got)
+	}
+	if got := re.FindIndexEach(subjects, nil); !reflect.DeepEqual(got, make([][]int, len(subjects))) {
+		t.Errorf("FindIndexEach() after Close = %v, want all nil", got)
+	}
+}
@@ -0,0 +1,163 @@
+package pcregexp
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// MaxCacheKeyLen is the length of the longest subject whose match result is
+// cached. Longer subjects are always matched, so that the memory used by a
+// cache stays proportional to its size.
+const MaxCacheKeyLen = 1024
+
+// defaultCacheSize is the cache size of newly compiled patterns.
+var defaultCacheSize atomic.Int64
+
+// SetDefaultCacheSize sets the number of match results cached by patterns
+// compiled afterwards, see [PCREgexp.SetCacheSize].
+//
+// The default is 0, which disables caching.
+func SetDefaultCacheSize(size int) {
+	defaultCacheSize.Store(int64(size))
+}
+
+// CacheStats reports the usage of the match result cache of a [PCREgexp].
+type CacheStats struct {
+	// Hits is the number of lookups answered from the cache.
+	Hits uint64
+
+	// Misses is the number of lookups that had to match the subject.
+	Misses uint64
+
+	// Len is the number of results currently cached.
+	Len int
+
+	// Size is the maximum number of results cached.
+	Size int
+}
+
+// resultCache is a bounded, least-recently-used cache of match results keyed
+// by subject. It is safe for concurrent use.
+type resultCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	lru     *list.List // of *cacheEntry, most recently used first
+
+	hits   uint64
+	misses uint64
+}
+
+// cacheEntry is an element of resultCache.lru.
+type cacheEntry struct {
+	key    string
+	result []int
+}
+
+// newResultCache returns a cache holding up to size results, or nil if size
+// is not positive.
+func newResultCache(size int) *resultCache {
+	if size <= 0 {
+		return nil
+	}
+
+	return &resultCache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// get appends the cached result for subject to dst. It reports whether the
+// result was cached; a cached nil result means subject does not match.
+func (c *resultCache) get(dst []int, subject []byte) ([]int, bool) {
+	if len(subject) > MaxCacheKeyLen {
+		return dst, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// The string conversion in a map index expression does not allocate.
+	elem, ok := c.entries[string(subject)]
+	if !ok {
+		c.misses++
+		return dst, false
+	}
+
+	c.hits++
+	c.lru.MoveToFront(elem)
+
+	result := elem.Value.(*cacheEntry).result
+	if result == nil {
+		return nil, true
+	}
+
+	return append(dst, result...), true
+}
+
+// put caches a copy of result for a copy of subject, evicting the least
+// recently used result if the cache is full.
+func (c *resultCache) put(subject []byte, result []int) {
+	if len(subject) > MaxCacheKeyLen {
+		return
+	}
+
+	if result != nil {
+		result = append([]int(nil), result...)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[string(subject)]; ok {
+		elem.Value.(*cacheEntry).result = result
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	if c.lru.Len() >= c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+
+	key := string(subject)
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, result: result})
+}
+
+// stats returns the usage of c.
+func (c *resultCache) stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{
+		Hits:   c.hits,
+		Misses: c.misses,
+		Len:    c.lru.Len(),
+		Size:   c.size,
+	}
+}
+
+// SetCacheSize enables caching of up to size match results, keyed by
+// subject, replacing the current cache. A size of 0 or less disables the
+// cache, which is the default unless changed with [SetDefaultCacheSize].
+//
+// Caching pays off when the same subjects are matched repeatedly. Only
+// subjects up to [MaxCacheKeyLen] bytes long are cached, and the cache keeps
+// its own copies of them. SetCacheSize must not be called concurrently with
+// other methods.
+func (re *PCREgexp) SetCacheSize(size int) {
+	re.cache = newResultCache(size)
+}
+
+// CacheStats returns the usage of the match result cache, or zero values if
+// caching is disabled.
+func (re *PCREgexp) CacheStats() CacheStats {
+	return re.cache.stats()
+}
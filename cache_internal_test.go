@@ -0,0 +1,61 @@
+package pcregexp
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// TestResultCache_Shared exercises a single cache from several goroutines;
+// run it with -race.
+func TestResultCache_Shared(t *testing.T) {
+	const (
+		goroutines = 8
+		lookups    = 1000
+		size       = 16
+	)
+
+	c := newResultCache(size)
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+
+			var dst []int
+			for i := 0; i < lookups; i++ {
+				// More distinct subjects than the cache holds, so that
+				// evictions race with lookups.
+				n := (g + i) % (2 * size)
+				subject := []byte("subject " + strconv.Itoa(n))
+				want := []int{0, len(subject), n, n}
+
+				result, ok := c.get(dst[:0], subject)
+				if !ok {
+					c.put(subject, want)
+
+					// The cache must own its copy of the key.
+					copy(subject, "xxxxxxx")
+					continue
+				}
+
+				if !reflect.DeepEqual(result, want) {
+					t.Errorf("get(%q) = %v, want %v", subject, result, want)
+					return
+				}
+				dst = result
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	stats := c.stats()
+	if stats.Hits+stats.Misses != goroutines*lookups {
+		t.Errorf("stats() = %+v, want %d lookups", stats, goroutines*lookups)
+	}
+	if stats.Len > stats.Size || stats.Size != size {
+		t.Errorf("stats() = %+v, want at most %d results", stats, size)
+	}
+}
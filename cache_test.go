@@ -0,0 +1,104 @@
+package pcregexp_test
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+func TestRegexp_CacheDisabledByDefault(t *testing.T) {
+	re := pcregexp.MustCompile(`p([a-z]+)ch`)
+	defer re.Close()
+
+	re.MatchString("peach")
+	re.MatchString("peach")
+
+	if got := re.CacheStats(); got != (pcregexp.CacheStats{}) {
+		t.Errorf("CacheStats() = %+v, want zero", got)
+	}
+}
+
+func TestRegexp_CacheSize(t *testing.T) {
+	re := pcregexp.MustCompile(`p([a-z]+)ch`)
+	defer re.Close()
+
+	re.SetCacheSize(2)
+
+	for _, s := range []string{"peach", "peach", "punch", "pinch", "peach", "apple", "apple"} {
+		re.MatchString(s)
+	}
+
+	// peach (miss), peach (hit), punch (miss), pinch (miss, evicts peach),
+	// peach (miss, evicts punch), apple (miss, evicts pinch), apple (hit).
+	want := pcregexp.CacheStats{Hits: 2, Misses: 5, Len: 2, Size: 2}
+	if got := re.CacheStats(); got != want {
+		t.Errorf("CacheStats() = %+v, want %+v", got, want)
+	}
+
+	long := strings.Repeat("x", pcregexp.MaxCacheKeyLen) + "peach"
+	if !re.MatchString(long) {
+		t.Errorf("MatchString(long) = false, want true")
+	}
+	if got := re.CacheStats(); got.Hits != want.Hits || got.Misses != want.Misses {
+		t.Errorf("CacheStats() after long subject = %+v, want unchanged %+v", got, want)
+	}
+
+	re.SetCacheSize(0)
+	if got := re.CacheStats(); got != (pcregexp.CacheStats{}) {
+		t.Errorf("CacheStats() after SetCacheSize(0) = %+v, want zero", got)
+	}
+}
+
+func TestRegexp_CacheOwnsKeys(t *testing.T) {
+	re := pcregexp.MustCompile(`p([a-z]+)ch`)
+	defer re.Close()
+
+	re.SetCacheSize(8)
+
+	subject := []byte("peach")
+	if !re.Match(subject) {
+		t.Fatalf("Match(%q) = false, want true", subject)
+	}
+
+	// Mutating the subject must not affect the cached result.
+	copy(subject, "apple")
+	if re.Match(subject) {
+		t.Errorf("Match(%q) = true after mutation, want false", subject)
+	}
+
+	loc := re.FindStringSubmatchIndex("peach")
+	loc[0] = 42
+	if got, want := re.FindStringSubmatchIndex("peach"), []int{0, 5, 1, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindStringSubmatchIndex() = %v, want %v", got, want)
+	}
+}
+
+func TestRegexp_CacheConcurrent(t *testing.T) {
+	pcregexp.SetDefaultCacheSize(16)
+	defer pcregexp.SetDefaultCacheSize(0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// PCREgexp is not safe for concurrent use, so each goroutine
+			// compiles its own.
+			re := pcregexp.MustCompile(`\d+`)
+			defer re.Close()
+
+			for j := 0; j < 100; j++ {
+				re.MatchString(strings.Repeat("a", j%32) + "1")
+			}
+
+			if got := re.CacheStats(); got.Len > got.Size || got.Hits+got.Misses != 100 {
+				t.Errorf("CacheStats() = %+v", got)
+			}
+		}()
+	}
+	wg.Wait()
+}
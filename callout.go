@@ -0,0 +1,156 @@
+package pcregexp
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/ebitengine/purego"
+)
+
+// Callout is the state of a match reaching a callout of the pattern, such as
+// (?C1) or (?C"text"). See [PCREgexp.SetCallout].
+type Callout struct {
+	// Number is the number of a numbered callout, such as 1 for (?C1), or 0
+	// for a string callout.
+	Number int
+
+	// String is the text of a string callout, such as text for (?C"text"),
+	// or "" for a numbered callout.
+	String string
+
+	// Subject is the subject being matched. It is only valid during the
+	// call.
+	Subject []byte
+
+	// Start is the offset in Subject the current match attempt starts at, or
+	// the offset of the last \K passed.
+	Start int
+
+	// Position is the current offset in Subject.
+	Position int
+}
+
+// calloutBlock is pcre2_callout_block, which PCRE2 passes to the callout
+// function.
+type calloutBlock struct {
+	version         uint32
+	number          uint32
+	captureTop      uint32
+	captureLast     uint32
+	ovector         *uintptr
+	mark            *uint8
+	subject         *uint8
+	subjectLength   uintptr
+	startMatch      uintptr
+	currentPosition uintptr
+	patternPosition uintptr
+	nextItemLength  uintptr
+	stringOffset    uintptr
+	stringLength    uintptr
+	str             *uint8
+	flags           uint32
+}
+
+var (
+	// calloutFuncs are the functions set with SetCallout, keyed by the id
+	// PCRE2 passes back as callout data.
+	calloutFuncs sync.Map // uintptr -> func(*Callout) bool
+	calloutIDs   atomic.Uintptr
+
+	// calloutAddr is the address of callout, created once since the number
+	// of callbacks a process may create is limited.
+	calloutAddr     uintptr
+	calloutAddrOnce sync.Once
+)
+
+// callout is the callout function of PCRE2. It returns 0 for the match to
+// go on, and 1 for it to backtrack.
+func callout(block unsafe.Pointer, data uintptr) int32 {
+	f, ok := calloutFuncs.Load(data)
+	if !ok {
+		return 0
+	}
+
+	b := (*calloutBlock)(block)
+	c := Callout{
+		Number:   int(b.number),
+		Start:    int(b.startMatch),
+		Position: int(b.currentPosition),
+	}
+	if b.subjectLength > 0 {
+		c.Subject = unsafe.Slice(b.subject, b.subjectLength)
+	}
+	if b.str != nil {
+		c.String = string(unsafe.Slice(b.str, b.stringLength))
+	}
+
+	if f.(func(*Callout) bool)(&c) {
+		return 0
+	}
+
+	return 1
+}
+
+// SetCallout makes the callouts of the pattern of re call f, which reports
+// whether the match goes on: if f returns false, the match backtracks from
+// the callout, as if the pattern failed to match there. It lets a match be
+// validated by code, such as a checksum, while alternatives to it are still
+// tried. A nil f removes the function, and callouts are then ignored, which
+// is the default.
+//
+// PCRE2 optimizations skip callouts where they tell that the match cannot
+// go on otherwise: \d+ is matched possessively before a callout that
+// follows it, and match attempts may be skipped once one failed. A pattern
+// whose callouts reject matches should thus start with
+// (*NO_AUTO_POSSESS)(*NO_START_OPT). f may also be called more than once
+// at the same point.
+//
+// f is called from the matching goroutine and must not use re. SetCallout
+// must not be called concurrently with other methods.
+func (re *PCREgexp) SetCallout(f func(*Callout) bool) {
+	if re.h == nil || re.h.code == 0 {
+		return
+	}
+
+	h := re.h
+	if h.calloutID != 0 {
+		calloutFuncs.Delete(h.calloutID)
+		h.calloutID = 0
+	}
+
+	if f != nil {
+		calloutAddrOnce.Do(func() {
+			calloutAddr = purego.NewCallback(callout)
+		})
+
+		h.calloutID = calloutIDs.Add(1)
+		calloutFuncs.Store(h.calloutID, f)
+	}
+
+	if h.matchCtx == 0 {
+		if f == nil {
+			return
+		}
+
+		// The callout is set in a match context of h's own, never in the
+		// global one.
+		if h.matchCtx = pcre2_match_context_create(0); h.matchCtx == 0 {
+			return
+		}
+		liveMatchContexts.Add(1)
+		h.ctxGen = 0
+	}
+
+	h.setCallout(h.matchCtx)
+}
+
+// setCallout sets the callout of h in ctx, or removes it if h has none.
+func (h *handle) setCallout(ctx uintptr) {
+	if h.calloutID == 0 {
+		pcre2_set_callout(ctx, 0, 0)
+		return
+	}
+
+	pcre2_set_callout(ctx, calloutAddr, h.calloutID)
+}
@@ -0,0 +1,74 @@
+package pcregexp_test
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+func TestRegexp_SetCallout(t *testing.T) {
+	// A number divisible by 7, found by backtracking from longer ones.
+	re := pcregexp.MustCompile(`(*NO_AUTO_POSSESS)(*NO_START_OPT)\d+(?C1)`)
+	defer re.Close()
+
+	var got []pcregexp.Callout
+	re.SetCallout(func(c *pcregexp.Callout) bool {
+		c.Subject = nil
+		got = append(got, *c)
+
+		n, _ := strconv.Atoi("0" + "123456"[c.Start:c.Position])
+		return n%7 == 0
+	})
+
+	if loc := re.FindStringIndex("123456"); !reflect.DeepEqual(loc, []int{1, 5}) {
+		t.Errorf("FindStringIndex = %v, want [1 5]", loc)
+	}
+	if len(got) == 0 || got[0].Number != 1 || got[0].Start != 0 || got[0].Position != 6 {
+		t.Errorf("first callout = %+v, want number 1 from 0 to 6", got)
+	}
+
+	re.SetCallout(nil)
+	if loc := re.FindStringIndex("123456"); !reflect.DeepEqual(loc, []int{0, 6}) {
+		t.Errorf("FindStringIndex without callout = %v, want [0 6]", loc)
+	}
+}
+
+func TestRegexp_SetCalloutString(t *testing.T) {
+	re := pcregexp.MustCompile(`(?:a(?C"x")|ab(?C"y"))c`)
+	defer re.Close()
+
+	var strs []string
+	re.SetCallout(func(c *pcregexp.Callout) bool {
+		strs = append(strs, c.String)
+		return c.String != "x"
+	})
+
+	if loc := re.FindStringIndex("abc ac"); !reflect.DeepEqual(loc, []int{0, 3}) {
+		t.Errorf("FindStringIndex = %v, want [0 3]", loc)
+	}
+	if !reflect.DeepEqual(strs, []string{"x", "y"}) {
+		t.Errorf("callout strings = %q, want [x y]", strs)
+	}
+}
+
+func TestRegexp_SetCalloutParallel(t *testing.T) {
+	re := pcregexp.MustCompile(`(*NO_AUTO_POSSESS)(*NO_START_OPT)\d+(?C1)`)
+	defer re.Close()
+
+	re.SetCallout(func(c *pcregexp.Callout) bool {
+		return c.Position-c.Start == 3
+	})
+
+	b := bytes.Repeat([]byte("12345 "), 20000)
+	want := re.FindAllIndex(b, -1)
+	if len(want) != 20000 || !reflect.DeepEqual(want[0], []int{0, 3}) {
+		t.Fatalf("FindAllIndex found %d matches, first %v", len(want), want[0])
+	}
+
+	if got := re.FindAllIndexParallel(b, 4); !reflect.DeepEqual(got, want) {
+		t.Errorf("FindAllIndexParallel differs from FindAllIndex")
+	}
+}
@@ -0,0 +1,128 @@
+// Command pcre2test runs test files in the format of pcre2test, the test
+// program of PCRE2, through the pcregexp bindings.
+//
+// Given an input file only, it prints the output pcre2test would print, as
+// far as the bindings go. Given the expected output too, such as a
+// testinput and testoutput pair of the PCRE2 test corpus, it compares the
+// two block by block, prints the differences, and exits with status 1 if a
+// block differs. Blocks using pcre2test features that are not supported
+// are skipped, and listed with -v.
+//
+//	pcre2test testdata/testinput1 testdata/testoutput1
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dwisiswant0/pcregexp/pkg/pcre2test"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// Exit statuses.
+const (
+	exitPass  = 0
+	exitFail  = 1
+	exitError = 2
+)
+
+const usage = `Usage: pcre2test [-v] INPUT [EXPECTED]
+
+Runs the pcre2test INPUT file, and prints its output, or compares it with
+the EXPECTED output file.
+
+Options:
+`
+
+// run runs pcre2test with args, and returns its exit status.
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("pcre2test", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	verbose := fs.Bool("v", false, "list the skipped blocks and why")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitPass
+		}
+		return exitError
+	}
+
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		return exitError
+	}
+
+	blocks, err := runFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "pcre2test: %v\n", err)
+		return exitError
+	}
+
+	if fs.NArg() == 1 {
+		for i, b := range blocks {
+			if i > 0 {
+				fmt.Fprintln(stdout)
+			}
+			fmt.Fprint(stdout, b.Output)
+		}
+		return exitPass
+	}
+
+	f, err := os.Open(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintf(stderr, "pcre2test: %v\n", err)
+		return exitError
+	}
+	defer f.Close()
+
+	report, err := pcre2test.Compare(blocks, f)
+	if err != nil {
+		fmt.Fprintf(stderr, "pcre2test: %v\n", err)
+		return exitError
+	}
+
+	for _, fail := range report.Failures {
+		if fail.Block.Line > 0 {
+			fmt.Fprintf(stdout, "--- %s:%d\n", fs.Arg(0), fail.Block.Line)
+		} else {
+			fmt.Fprintf(stdout, "--- %s: missing block\n", fs.Arg(0))
+		}
+		fmt.Fprint(stdout, fail.Diff())
+	}
+
+	if *verbose {
+		for _, b := range blocks {
+			if b.Skip != "" {
+				fmt.Fprintf(stdout, "skipped %s:%d: %s\n", fs.Arg(0), b.Line, b.Skip)
+			}
+		}
+	}
+
+	fmt.Fprintf(stdout, "%d passed, %d failed, %d skipped\n", report.Passed, report.Failed, report.Skipped)
+	if report.Failed > 0 {
+		return exitFail
+	}
+
+	return exitPass
+}
+
+// runFile runs the pcre2test input file at path.
+func runFile(path string) ([]pcre2test.Block, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return pcre2test.Run(f)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testdata = "../../pkg/pcre2test/testdata"
+
+func TestRun(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{filepath.Join(testdata, "testinput"), filepath.Join(testdata, "testoutput")}, &stdout, &stderr)
+	if code != exitPass || !strings.HasSuffix(stdout.String(), " passed, 0 failed, 2 skipped\n") {
+		t.Errorf("pcre2test = %d:\n%s%s", code, stdout.String(), stderr.String())
+	}
+}
+
+func TestRun_Print(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input")
+	if err := os.WriteFile(input, []byte("/a+/g\n    baab\n\n/(/\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	if code := run([]string{input}, &stdout, nil); code != exitPass {
+		t.Fatalf("status = %d", code)
+	}
+
+	want := "/a+/g\n    baab\n 0: aa\n\n/(/\nFailed: error 114 at offset 1: missing closing parenthesis\n"
+	if stdout.String() != want {
+		t.Errorf("output = %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestRun_Fail(t *testing.T) {
+	dir := t.TempDir()
+	input, expected := filepath.Join(dir, "input"), filepath.Join(dir, "expected")
+	if err := os.WriteFile(input, []byte("/a/\n    a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(expected, []byte("/a/\n    a\n 0: b\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	if code := run([]string{input, expected}, &stdout, nil); code != exitFail || !strings.Contains(stdout.String(), "- 0: b\n+ 0: a\n") {
+		t.Errorf("pcre2test = %d:\n%s", code, stdout.String())
+	}
+
+	var stderr bytes.Buffer
+	if code := run([]string{filepath.Join(dir, "missing")}, &stdout, &stderr); code != exitError || stderr.Len() == 0 {
+		t.Errorf("pcre2test on a missing file = %d, %q", code, stderr.String())
+	}
+}
@@ -0,0 +1,133 @@
+// Command pcregrep searches files for lines matching a PCRE2 pattern, like
+// grep and the pcregrep shipped with PCRE2, using the pcregexp bindings.
+//
+// It supports the usual grep options, such as -r, -o, -c, -l, -n, -v, -w,
+// -x, -i and the context options -A, -B and -C, along with multiline
+// matching (-M), highlighting of matches and their capture groups (--color),
+// and JSON output (--json). Files are searched in parallel, and output in
+// the order they were given or found. Binary files, detected by a NUL byte
+// near their start, are reported as matching rather than printed, unless -a
+// is given.
+//
+// Matches are subject to PCRE2 match and depth limits, settable with
+// --match-limit and --depth-limit, so that a pathological pattern fails
+// quickly instead of hanging. Run pcregrep --help for the full list of
+// options.
+//
+//	pcregrep -rn --include='*.go' 'func\s+(\w+)\(' .
+//	pcregrep -M -o '(?s)BEGIN.*?END' build.log
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// Exit statuses.
+const (
+	exitMatch   = 0
+	exitNoMatch = 1
+	exitError   = 2
+)
+
+// run runs pcregrep with args, and returns its exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	o, err := parseArgs(args)
+	if errors.Is(err, errHelp) {
+		fmt.Fprint(stdout, usage)
+		return exitMatch
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "pcregrep: %v\n%s", err, strings.SplitN(usage, "\n", 2)[0]+"\n")
+		return exitError
+	}
+
+	if err := pcregexp.SetMatchContext(pcregexp.MatchContext{
+		MatchLimit: o.matchLimit,
+		DepthLimit: o.depthLimit,
+	}); err != nil {
+		fmt.Fprintf(stderr, "pcregrep: %v\n", err)
+		return exitError
+	}
+	defer pcregexp.SetMatchContext(pcregexp.MatchContext{})
+
+	patterns := buildPatterns(o)
+
+	// PCREgexp is not safe for concurrent use: every worker gets its own.
+	res := make([][]*pcregexp.PCREgexp, o.jobs)
+	for i := range res {
+		res[i] = make([]*pcregexp.PCREgexp, len(patterns))
+		for j, pattern := range patterns {
+			if res[i][j], err = pcregexp.Compile(pattern); err != nil {
+				fmt.Fprintf(stderr, "pcregrep: %v\n", err)
+				return exitError
+			}
+			defer res[i][j].Close()
+		}
+	}
+
+	colors := o.color == "always" || o.color == "auto" && isTerminal(stdout)
+
+	g := &grep{o: o, stdin: stdin, colors: colors && !o.json}
+	return g.run(res, stdout, stderr)
+}
+
+// verbs matches the option-setting verbs at the start of a pattern, like
+// (*UTF) or (*LIMIT_MATCH=1000).
+var verbs = regexp.MustCompile(`^(?:\(\*[A-Z_]+(?:=\d+)?\))+`)
+
+// buildPatterns returns the patterns to compile from the patterns and
+// matching options of o. Each pattern is compiled on its own, so that its
+// groups keep their numbers.
+func buildPatterns(o *options) []string {
+	var flags string
+	if o.ignoreCase {
+		flags += "i"
+	}
+	if o.multiline {
+		flags += "m"
+	}
+
+	patterns := make([]string, len(o.patterns))
+	for i, p := range o.patterns {
+		prefix := verbs.FindString(p)
+		body := p[len(prefix):]
+
+		switch {
+		case o.line:
+			body = "^(?:" + body + ")$"
+		case o.word:
+			body = `(?<!\w)(?:` + body + `)(?!\w)`
+		}
+
+		if flags != "" {
+			body = "(?" + flags + ")" + body
+		}
+
+		patterns[i] = prefix + body
+	}
+
+	return patterns
+}
+
+// isTerminal reports whether w is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+
+	fi, err := f.Stat()
+
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
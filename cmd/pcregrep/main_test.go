@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// files creates the files of a test tree in a temporary directory, changes
+// to it for the duration of the test, and returns it.
+func files(t *testing.T, tree map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range tree {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	return dir
+}
+
+// grepOutput runs pcregrep with args and stdin, and returns its output and
+// exit status.
+func grepOutput(t *testing.T, stdin string, args ...string) (string, int) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	if code == exitError {
+		return stderr.String(), code
+	}
+
+	return stdout.String(), code
+}
+
+const poem = `The quick brown fox
+jumps over the lazy dog.
+The dog sleeps;
+the fox runs away.
+Quick, quick!
+`
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+		code int
+	}{
+		{[]string{"fox"}, "The quick brown fox\nthe fox runs away.\n", exitMatch},
+		{[]string{"-n", "dog"}, "2:jumps over the lazy dog.\n3:The dog sleeps;\n", exitMatch},
+		{[]string{"-in", "^quick"}, "5:Quick, quick!\n", exitMatch},
+		{[]string{"-c", "(?i)the"}, "4\n", exitMatch},
+		{[]string{"-cv", "(?i)the"}, "1\n", exitMatch},
+		{[]string{"-o", `\b[qQ]\w+`}, "quick\nQuick\nquick\n", exitMatch},
+		{[]string{"-w", "row"}, "", exitNoMatch},
+		{[]string{"-w", "brown"}, "The quick brown fox\n", exitMatch},
+		{[]string{"-x", "The dog sleeps;"}, "The dog sleeps;\n", exitMatch},
+		{[]string{"-x", "dog"}, "", exitNoMatch},
+		{[]string{"-e", "lazy", "-e", "runs"}, "jumps over the lazy dog.\nthe fox runs away.\n", exitMatch},
+		{[]string{"-q", "fox"}, "", exitMatch},
+		{[]string{"-H", "lazy"}, "(standard input):jumps over the lazy dog.\n", exitMatch},
+		{[]string{"-n", "-A1", "lazy"}, "2:jumps over the lazy dog.\n3-The dog sleeps;\n", exitMatch},
+		{[]string{"-n", "-B", "1", "runs"}, "3-The dog sleeps;\n4:the fox runs away.\n", exitMatch},
+		{[]string{"-nC0", "brown|Quick"}, "1:The quick brown fox\n--\n5:Quick, quick!\n", exitMatch},
+		{[]string{"-n", "--context=1", "sleeps"}, "2-jumps over the lazy dog.\n3:The dog sleeps;\n4-the fox runs away.\n", exitMatch},
+		{[]string{"-Mn", `dog\.\nThe`}, "2:jumps over the lazy dog.\n3:The dog sleeps;\n", exitMatch},
+		{[]string{"-Mo", `(?s)lazy.*?fox`}, "lazy dog.\nThe dog sleeps;\nthe fox\n", exitMatch},
+		{[]string{"-M", "-c", `^the`}, "1\n", exitMatch},
+		{[]string{"--", "-v"}, "", exitNoMatch},
+	}
+
+	for _, tt := range tests {
+		got, code := grepOutput(t, poem, tt.args...)
+		if got != tt.want || code != tt.code {
+			t.Errorf("pcregrep %q = %q, %d, want %q, %d", tt.args, got, code, tt.want, tt.code)
+		}
+	}
+}
+
+func TestRun_Patterns(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		// Every pattern keeps its own group numbers.
+		{[]string{"-e", `(a)\1`, "-e", `(b)\1`}, "bb\naa\n"},
+		{[]string{"-o", "-e", `(b)\1`, "-e", `a+`}, "bb\naa\n"},
+		{[]string{"-c", "-x", "-e", "b", "-e", `(a)\1`}, "1\n"},
+	}
+
+	for _, tt := range tests {
+		got, code := grepOutput(t, "bb\naa\n", tt.args...)
+		if got != tt.want || code != exitMatch {
+			t.Errorf("pcregrep %q = %q, %d, want %q, %d", tt.args, got, code, tt.want, exitMatch)
+		}
+	}
+
+	// The leftmost match of any pattern is found first.
+	if got, _ := grepOutput(t, "xaby\n", "-o", "-e", "b.", "-e", "a"); got != "a\nby\n" {
+		t.Errorf("pcregrep -o -e b. -e a = %q, want %q", got, "a\nby\n")
+	}
+}
+
+func TestRun_Files(t *testing.T) {
+	files(t, map[string]string{
+		"a.txt":          "alpha\nbeta\n",
+		"b.go":           "package b\n// alpha\n",
+		"sub/c.txt":      "gamma alpha\n",
+		"vendor/d.txt":   "alpha\n",
+		"bin.dat":        "alpha\x00\x01\n",
+		"noeol.txt":      "delta alpha",
+		"sub/deep/e.log": "alpha\n",
+	})
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-r", "alpha"}, "a.txt:alpha\nb.go:// alpha\nbin.dat matches\nnoeol.txt:delta alpha\nsub/c.txt:gamma alpha\nsub/deep/e.log:alpha\nvendor/d.txt:alpha\n"},
+		{[]string{"-rl", "--include=*.txt", "--exclude-dir=vendor", "alpha", "."}, "a.txt\nnoeol.txt\nsub/c.txt\n"},
+		{[]string{"-rc", "--exclude=*.txt", "--exclude=*.dat", "alpha"}, "b.go:1\nsub/deep/e.log:1\n"},
+		{[]string{"-rI", "-j1", "alpha", "bin.dat", "a.txt"}, "a.txt:alpha\n"},
+		{[]string{"-a", "alpha", "bin.dat"}, "alpha\x00\x01\n"},
+		{[]string{"-h", "alpha", "a.txt", "sub/c.txt"}, "alpha\ngamma alpha\n"},
+	}
+
+	for _, tt := range tests {
+		got, code := grepOutput(t, "", tt.args...)
+		got = strings.ReplaceAll(got, "Binary file ", "")
+		if got := filepath.ToSlash(got); got != tt.want || code != exitMatch {
+			t.Errorf("pcregrep %q = %q, %d, want %q, 0", tt.args, got, code, tt.want)
+		}
+	}
+
+	if got, code := grepOutput(t, "", "alpha", "missing.txt", "a.txt"); code != exitError || !strings.Contains(got, "missing.txt") {
+		t.Errorf("pcregrep on a missing file = %q, %d, want an error about it and status 2", got, code)
+	}
+
+	if got, code := grepOutput(t, "", "alpha", "sub"); code != exitError || !strings.Contains(got, "is a directory") {
+		t.Errorf("pcregrep on a directory without -r = %q, %d, want an error and status 2", got, code)
+	}
+}
+
+func TestRun_Parallel(t *testing.T) {
+	tree := make(map[string]string)
+	var want strings.Builder
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("f%02d.txt", i)
+		tree[name] = strings.Repeat("filler\n", i*100) + fmt.Sprintf("match %d\n", i)
+		fmt.Fprintf(&want, "%s:%d:match %d\n", name, i*100+1, i)
+	}
+	files(t, tree)
+
+	got, code := grepOutput(t, "", "-rn", "-j8", `^match \d+$`)
+	if got != want.String() || code != exitMatch {
+		t.Errorf("parallel output is out of order or wrong:\n%s", got)
+	}
+}
+
+func TestRun_Multiline(t *testing.T) {
+	// A match spanning many lines is found across the partial matches.
+	var b strings.Builder
+	b.WriteString("start\nBEGIN\n")
+	for i := 0; i < 5000; i++ {
+		b.WriteString("line\n")
+	}
+	b.WriteString("END\nafter\nBEGIN\nnever ends\n")
+
+	got, code := grepOutput(t, b.String(), "-Mc", `(?s)^BEGIN\n.*?^END$`)
+	if got != "5002\n" || code != exitMatch {
+		t.Errorf("pcregrep -Mc = %q, %d, want 5002 lines", got, code)
+	}
+
+	got, _ = grepOutput(t, b.String(), "-Mv", `(?s)^BEGIN\n.*?^END$`)
+	if want := "start\nafter\nBEGIN\nnever ends\n"; got != want {
+		t.Errorf("pcregrep -Mv = %q, want %q", got, want)
+	}
+
+	got, _ = grepOutput(t, "a\nb\n\nc", "-Mn", `^$`)
+	if want := "3:\n"; got != want {
+		t.Errorf("pcregrep -Mn '^$' = %q, want %q", got, want)
+	}
+}
+
+func TestRun_Color(t *testing.T) {
+	got, _ := grepOutput(t, "key=value\n", "--color=always", `(\w+)=(\w+)`)
+	want := "\x1b[01;32m\x1b[Kkey\x1b[m\x1b[K\x1b[01;31m\x1b[K=\x1b[m\x1b[K\x1b[01;33m\x1b[Kvalue\x1b[m\x1b[K\n"
+	if got != want {
+		t.Errorf("colored output = %q, want %q", got, want)
+	}
+
+	if got, _ := grepOutput(t, "key=value\n", "--color=never", `=`); got != "key=value\n" {
+		t.Errorf("uncolored output = %q", got)
+	}
+}
+
+func TestRun_JSON(t *testing.T) {
+	got, code := grepOutput(t, "x 10 y\nnone\n", "--json", "-A1", `(?<num>\d+)|(?<word>z)`)
+	if code != exitMatch {
+		t.Fatalf("status = %d", code)
+	}
+
+	lines := strings.Split(strings.TrimSpace(got), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d JSON lines, want 2:\n%s", len(lines), got)
+	}
+
+	var m struct {
+		Type    string
+		Line    int
+		Text    string
+		Matches []struct {
+			Text   string
+			Start  int
+			End    int
+			Groups []*struct {
+				Name string
+				Text string
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &m); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Type != "match" || m.Line != 1 || m.Text != "x 10 y" || len(m.Matches) != 1 {
+		t.Fatalf("match object = %+v", m)
+	}
+
+	if mm := m.Matches[0]; mm.Text != "10" || mm.Start != 2 || mm.End != 4 || len(mm.Groups) != 2 ||
+		mm.Groups[0] == nil || mm.Groups[0].Name != "num" || mm.Groups[0].Text != "10" || mm.Groups[1] != nil {
+		t.Errorf("match = %+v", mm)
+	}
+
+	if !strings.Contains(lines[1], `"type":"context"`) {
+		t.Errorf("context object = %s", lines[1])
+	}
+}
+
+func TestRun_Limits(t *testing.T) {
+	// Without limits, this takes exponential time on a line of x.
+	input := strings.Repeat("x", 40) + "\nxxy\n"
+
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{nil, "xxy\n"},
+		{[]string{"-M"}, "xxy\n"},
+		// The line that cannot be matched is not selected either way.
+		{[]string{"-v"}, ""},
+		{[]string{"-c"}, "1\n"},
+	} {
+		args := append(tt.args, "--match-limit=10000", `(x+x+)+[yz]`)
+
+		var stdout, stderr bytes.Buffer
+		start := time.Now()
+		code := run(args, strings.NewReader(input), &stdout, &stderr)
+
+		if d := time.Since(start); d > 5*time.Second {
+			t.Errorf("pcregrep %q took %v despite the match limit", args, d)
+		}
+		if code != exitError || stdout.String() != tt.want {
+			t.Errorf("pcregrep %q = %q, %d, want %q, %d", args, stdout.String(), code, tt.want, exitError)
+		}
+		if got := stderr.String(); !strings.HasPrefix(got, "pcregrep: (standard input):1: ") || !strings.Contains(got, "match limit") {
+			t.Errorf("pcregrep %q warned %q, want a match limit error on line 1", args, got)
+		}
+	}
+}
+
+func TestParseArgs_Errors(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"--nope", "x"},
+		{"-Z", "x"},
+		{"-A"},
+		{"-A", "x", "y"},
+		{"--color=sometimes", "x"},
+		{"--count=1", "x"},
+		{"--include=[", "x"},
+		{"-j0", "x"},
+	} {
+		if got, code := grepOutput(t, "", args...); code != exitError || !strings.HasPrefix(got, "pcregrep: ") {
+			t.Errorf("pcregrep %q = %q, %d, want a usage error", args, got, code)
+		}
+	}
+
+	if got, code := grepOutput(t, "", "(unclosed"); code != exitError || got == "" {
+		t.Errorf("pcregrep with a bad pattern = %q, %d, want an error", got, code)
+	}
+
+	var stdout bytes.Buffer
+	if code := run([]string{"--help"}, nil, &stdout, nil); code != exitMatch || !strings.HasPrefix(stdout.String(), "Usage:") {
+		t.Errorf("--help = %q, %d", stdout.String(), code)
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const usage = `Usage: pcregrep [OPTION]... PATTERN [FILE]...
+Search for PATTERN, a PCRE2 regular expression, in each FILE.
+With no FILE, read standard input, or the current directory with -r.
+A FILE of "-" is standard input.
+
+Matching:
+  -e, --regexp=PATTERN      use PATTERN; may be given several times
+  -i, --ignore-case         ignore case distinctions
+  -w, --word-regexp         match only whole words
+  -x, --line-regexp         match only whole lines
+  -v, --invert-match        select non-matching lines
+  -M, --multiline           let matches span lines; ^ and $ match at lines
+      --match-limit=NUM     limit the backtracking of a match (default 1000000)
+      --depth-limit=NUM     limit the backtracking depth of a match
+                            (default 0, for the PCRE2 default)
+
+Output:
+  -o, --only-matching       print only the matched parts of lines
+  -c, --count               print only a count of selected lines per file
+  -l, --files-with-matches  print only the names of files with selected lines
+  -q, --quiet               print nothing; exit 0 on the first match
+  -n, --line-number         print line numbers
+  -H, --with-filename       print the file name for each match
+  -h, --no-filename         never print file names
+  -A, --after-context=NUM   print NUM lines of trailing context
+  -B, --before-context=NUM  print NUM lines of leading context
+  -C, --context=NUM         print NUM lines of context around matches
+      --color[=WHEN]        highlight matches and their groups; WHEN is
+                            always, never or auto (the default)
+      --json                print results as JSON objects, one per line
+  -s, --no-messages         suppress messages about unreadable files
+
+Files:
+  -r, --recursive           search directories recursively
+      --include=GLOB        search only files whose name matches GLOB
+      --exclude=GLOB        skip files whose name matches GLOB
+      --exclude-dir=GLOB    skip directories whose name matches GLOB
+  -a, --text                search binary files as text
+  -I                        skip binary files
+  -j, --jobs=NUM            search NUM files in parallel (default: CPUs)
+      --help                print this help
+
+Matching stops at the match and depth limits, so that pathological patterns
+cannot hang; a line whose matching exceeds them is not selected.
+Exit status is 0 if a line is selected, 1 if none is, and 2 on error.
+`
+
+// errHelp is returned by parseArgs for --help.
+var errHelp = errors.New("help requested")
+
+// options are the parsed command line.
+type options struct {
+	patterns []string
+	paths    []string
+
+	ignoreCase bool
+	word       bool
+	line       bool
+	invert     bool
+	multiline  bool
+	matchLimit uint32
+	depthLimit uint32
+
+	onlyMatching bool
+	count        bool
+	list         bool
+	quiet        bool
+	lineNumber   bool
+	withFilename bool
+	noFilename   bool
+	after        int
+	before       int
+	context      bool // whether context was requested, even of 0 lines
+	color        string
+	json         bool
+	noMessages   bool
+
+	recursive   bool
+	includes    []string
+	excludes    []string
+	excludeDirs []string
+	text        bool
+	skipBinary  bool
+	jobs        int
+}
+
+// option describes a command line option.
+type option struct {
+	short byte
+	long  string
+
+	// arg tells whether the option takes an argument; optional arguments
+	// are only given with --long=value.
+	arg      bool
+	optional bool
+
+	set func(o *options, v string) error
+}
+
+func boolOption(short byte, long string, set func(o *options)) option {
+	return option{short: short, long: long, set: func(o *options, _ string) error {
+		set(o)
+		return nil
+	}}
+}
+
+func intArg(v string) (int, error) {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid number %q", v)
+	}
+
+	return n, nil
+}
+
+func limitArg(v string) (uint32, error) {
+	n, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid limit %q", v)
+	}
+
+	return uint32(n), nil
+}
+
+func globArg(v string) error {
+	if _, err := pathMatch(v, ""); err != nil {
+		return fmt.Errorf("invalid glob %q", v)
+	}
+
+	return nil
+}
+
+var optionTable = []option{
+	{short: 'e', long: "regexp", arg: true, set: func(o *options, v string) error {
+		o.patterns = append(o.patterns, v)
+		return nil
+	}},
+	boolOption('i', "ignore-case", func(o *options) { o.ignoreCase = true }),
+	boolOption('w', "word-regexp", func(o *options) { o.word = true }),
+	boolOption('x', "line-regexp", func(o *options) { o.line = true }),
+	boolOption('v', "invert-match", func(o *options) { o.invert = true }),
+	boolOption('M', "multiline", func(o *options) { o.multiline = true }),
+	{long: "match-limit", arg: true, set: func(o *options, v string) (err error) {
+		o.matchLimit, err = limitArg(v)
+		return err
+	}},
+	{long: "depth-limit", arg: true, set: func(o *options, v string) (err error) {
+		o.depthLimit, err = limitArg(v)
+		return err
+	}},
+
+	boolOption('o', "only-matching", func(o *options) { o.onlyMatching = true }),
+	boolOption('c', "count", func(o *options) { o.count = true }),
+	boolOption('l', "files-with-matches", func(o *options) { o.list = true }),
+	boolOption('q', "quiet", func(o *options) { o.quiet = true }),
+	boolOption('n', "line-number", func(o *options) { o.lineNumber = true }),
+	boolOption('H', "with-filename", func(o *options) { o.withFilename, o.noFilename = true, false }),
+	boolOption('h', "no-filename", func(o *options) { o.noFilename, o.withFilename = true, false }),
+	{short: 'A', long: "after-context", arg: true, set: func(o *options, v string) (err error) {
+		o.context = true
+		o.after, err = intArg(v)
+		return err
+	}},
+	{short: 'B', long: "before-context", arg: true, set: func(o *options, v string) (err error) {
+		o.context = true
+		o.before, err = intArg(v)
+		return err
+	}},
+	{short: 'C', long: "context", arg: true, set: func(o *options, v string) (err error) {
+		o.context = true
+		o.after, err = intArg(v)
+		o.before = o.after
+		return err
+	}},
+	{long: "color", arg: true, optional: true, set: setColor},
+	{long: "colour", arg: true, optional: true, set: setColor},
+	boolOption(0, "json", func(o *options) { o.json = true }),
+	boolOption('s', "no-messages", func(o *options) { o.noMessages = true }),
+
+	boolOption('r', "recursive", func(o *options) { o.recursive = true }),
+	{long: "include", arg: true, set: func(o *options, v string) error {
+		o.includes = append(o.includes, v)
+		return globArg(v)
+	}},
+	{long: "exclude", arg: true, set: func(o *options, v string) error {
+		o.excludes = append(o.excludes, v)
+		return globArg(v)
+	}},
+	{long: "exclude-dir", arg: true, set: func(o *options, v string) error {
+		o.excludeDirs = append(o.excludeDirs, v)
+		return globArg(v)
+	}},
+	boolOption('a', "text", func(o *options) { o.text = true }),
+	boolOption('I', "", func(o *options) { o.skipBinary = true }),
+	{short: 'j', long: "jobs", arg: true, set: func(o *options, v string) (err error) {
+		if o.jobs, err = intArg(v); err == nil && o.jobs == 0 {
+			err = fmt.Errorf("invalid number of jobs %q", v)
+		}
+		return err
+	}},
+	{long: "help", set: func(*options, string) error { return errHelp }},
+}
+
+func setColor(o *options, v string) error {
+	switch v {
+	case "":
+		o.color = "auto"
+	case "always", "never", "auto":
+		o.color = v
+	default:
+		return fmt.Errorf("invalid color mode %q", v)
+	}
+
+	return nil
+}
+
+// parseArgs parses the command line like getopt_long: short options may be
+// grouped, as in -rn, options and operands may be mixed, and "--" ends the
+// options.
+func parseArgs(args []string) (*options, error) {
+	o := &options{color: "never", matchLimit: 1000000, jobs: runtime.NumCPU()}
+
+	var operands []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		switch {
+		case arg == "--":
+			operands = append(operands, args[i+1:]...)
+			i = len(args)
+
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := strings.Cut(arg[2:], "=")
+
+			opt := lookupLong(name)
+			if opt == nil {
+				return nil, fmt.Errorf("unrecognized option '--%s'", name)
+			}
+
+			switch {
+			case !opt.arg && hasValue:
+				return nil, fmt.Errorf("option '--%s' doesn't allow an argument", name)
+			case opt.arg && !opt.optional && !hasValue:
+				if i++; i == len(args) {
+					return nil, fmt.Errorf("option '--%s' requires an argument", name)
+				}
+				value = args[i]
+			}
+
+			if err := opt.set(o, value); err != nil {
+				return nil, err
+			}
+
+		case len(arg) > 1 && arg[0] == '-':
+			for j := 1; j < len(arg); j++ {
+				opt := lookupShort(arg[j])
+				if opt == nil {
+					return nil, fmt.Errorf("invalid option -- '%c'", arg[j])
+				}
+
+				var value string
+				if opt.arg {
+					if value = arg[j+1:]; value == "" {
+						if i++; i == len(args) {
+							return nil, fmt.Errorf("option requires an argument -- '%c'", arg[j])
+						}
+						value = args[i]
+					}
+					j = len(arg)
+				}
+
+				if err := opt.set(o, value); err != nil {
+					return nil, err
+				}
+			}
+
+		default:
+			operands = append(operands, arg)
+		}
+	}
+
+	if len(o.patterns) == 0 {
+		if len(operands) == 0 {
+			return nil, errors.New("no pattern given")
+		}
+		o.patterns, operands = operands[:1], operands[1:]
+	}
+	o.paths = operands
+
+	return o, nil
+}
+
+func lookupLong(name string) *option {
+	for i := range optionTable {
+		if name != "" && optionTable[i].long == name {
+			return &optionTable[i]
+		}
+	}
+
+	return nil
+}
+
+func lookupShort(c byte) *option {
+	for i := range optionTable {
+		if optionTable[i].short == c && c != 0 {
+			return &optionTable[i]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// SGR sequences of the highlighted parts of the output, as used by GNU grep.
+const (
+	colorFile  = "35"
+	colorLine  = "32"
+	colorSep   = "36"
+	colorMatch = "01;31"
+)
+
+// groupColors are the colors of the capture groups within matches, cycled
+// through by group number.
+var groupColors = []string{"01;32", "01;33", "01;34", "01;35", "01;36"}
+
+// printer formats the lines of one input, as selected by a searcher.
+type printer struct {
+	o        *options
+	out      *bytes.Buffer
+	warn     *bytes.Buffer // warnings, written to stderr
+	name     string
+	showName bool
+	colors   bool
+	binary   bool // whether the input is binary
+
+	num      int // number of the last line
+	selected int // number of selected lines
+
+	// Context.
+	before    []contextLine // lines kept for leading context
+	afterLeft int           // lines of trailing context left to print
+	lastOut   int           // number of the last line printed
+}
+
+type contextLine struct {
+	num  int
+	text []byte
+}
+
+func newPrinter(o *options, out, warn *bytes.Buffer, name string, showName, colors bool) *printer {
+	return &printer{o: o, out: out, warn: warn, name: name, showName: showName, colors: colors}
+}
+
+// summary tells whether only a summary of the input is printed, rather than
+// its lines.
+func (p *printer) summary() bool {
+	return p.o.count || p.o.list || p.o.quiet
+}
+
+// line handles the next line of the input and the matches it holds. It
+// returns errStop once the rest of the input does not matter.
+//
+// A line that could not be matched because of err is warned about and is
+// not selected, whether or not the selection is inverted.
+func (p *printer) line(text []byte, matches []match, err error) error {
+	p.num++
+
+	selected := len(matches) > 0
+	if p.o.invert {
+		selected, matches = !selected, nil
+	}
+	if err != nil {
+		fmt.Fprintf(p.warn, "pcregrep: %s:%d: %v\n", p.name, p.num, err)
+		selected, matches = false, nil
+	}
+
+	if !selected {
+		switch {
+		case p.summary() || p.binary:
+		case p.afterLeft > 0:
+			p.afterLeft--
+			p.print(p.num, text, nil, '-')
+		case p.o.before > 0:
+			if len(p.before) == p.o.before {
+				p.before = p.before[:copy(p.before, p.before[1:])]
+			}
+			p.before = append(p.before, contextLine{p.num, text})
+		}
+
+		return nil
+	}
+
+	p.selected++
+
+	switch {
+	case p.o.quiet || p.o.list:
+		return errStop
+	case p.o.count:
+		return nil
+	case p.binary:
+		if p.o.json {
+			p.json(map[string]any{"type": "binary", "file": p.name})
+		} else {
+			p.out.WriteString("Binary file " + p.name + " matches\n")
+		}
+		return errStop
+	}
+
+	for _, c := range p.before {
+		p.print(c.num, c.text, nil, '-')
+	}
+	p.before = p.before[:0]
+
+	p.print(p.num, text, matches, ':')
+	p.afterLeft = p.o.after
+
+	return nil
+}
+
+// finish prints the summary of the input, if any.
+func (p *printer) finish() {
+	switch {
+	case p.o.quiet:
+	case p.o.list:
+		if p.selected > 0 {
+			if p.o.json {
+				p.json(map[string]any{"type": "file", "file": p.name})
+			} else {
+				p.out.WriteString(p.paint(colorFile, p.name) + "\n")
+			}
+		}
+	case p.o.count:
+		if p.o.json {
+			p.json(map[string]any{"type": "count", "file": p.name, "count": p.selected})
+			return
+		}
+
+		if p.showName {
+			p.out.WriteString(p.paint(colorFile, p.name) + p.paint(colorSep, ":"))
+		}
+		p.out.WriteString(strconv.Itoa(p.selected) + "\n")
+	}
+}
+
+// print prints line num with its matches; sep is ':' for selected lines and
+// '-' for context.
+func (p *printer) print(num int, text []byte, matches []match, sep byte) {
+	if p.o.json {
+		p.jsonLine(num, text, matches, sep)
+		return
+	}
+
+	if p.o.onlyMatching {
+		for _, m := range matches {
+			if len(m.text) == 0 {
+				continue
+			}
+
+			p.prefix(num, sep)
+			if lo, hi := m.loc[0], m.loc[1]; p.colors && hi-lo == len(m.text) {
+				// Highlight the groups of a match within the line.
+				loc := make([]int, len(m.loc))
+				for i, x := range m.loc {
+					loc[i] = x - lo
+					if x < 0 {
+						loc[i] = -1
+					}
+				}
+				p.out.WriteString(highlight(text[lo:hi], []match{{loc: loc}}))
+			} else {
+				p.out.WriteString(p.paint(colorMatch, string(m.text)))
+			}
+			p.out.WriteByte('\n')
+		}
+		return
+	}
+
+	if p.o.context && p.lastOut > 0 && num > p.lastOut+1 {
+		p.out.WriteString(p.paint(colorSep, "--") + "\n")
+	}
+	p.lastOut = num
+
+	p.prefix(num, sep)
+	if p.colors && len(matches) > 0 {
+		p.out.WriteString(highlight(text, matches))
+	} else {
+		p.out.Write(text)
+	}
+	p.out.WriteByte('\n')
+}
+
+// prefix prints the file name and line number of line num, as requested.
+func (p *printer) prefix(num int, sep byte) {
+	if p.showName {
+		p.out.WriteString(p.paint(colorFile, p.name) + p.paint(colorSep, string(sep)))
+	}
+
+	if p.o.lineNumber {
+		p.out.WriteString(p.paint(colorLine, strconv.Itoa(num)) + p.paint(colorSep, string(sep)))
+	}
+}
+
+// paint returns s in the color with SGR parameters sgr, if colors are on.
+func (p *printer) paint(sgr, s string) string {
+	if !p.colors {
+		return s
+	}
+
+	return "\x1b[" + sgr + "m\x1b[K" + s + "\x1b[m\x1b[K"
+}
+
+// highlight returns text with its matches, and the groups within them, in
+// color. Nested groups are painted over the groups holding them.
+func highlight(text []byte, matches []match) string {
+	// paint holds the color of every byte: 0 for none, 1 for the match, and
+	// 2 + i for group i + 1.
+	paint := make([]int, len(text))
+	for _, m := range matches {
+		for i := 0; i+1 < len(m.loc); i += 2 {
+			lo, hi := m.loc[i], m.loc[i+1]
+			for j := lo; lo >= 0 && j < hi; j++ {
+				paint[j] = 1 + i/2
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	for i := 0; i < len(text); {
+		j := i
+		for j < len(text) && paint[j] == paint[i] {
+			j++
+		}
+
+		switch c := paint[i]; c {
+		case 0:
+			b.Write(text[i:j])
+		default:
+			sgr := colorMatch
+			if c > 1 {
+				sgr = groupColors[(c-2)%len(groupColors)]
+			}
+			b.WriteString("\x1b[" + sgr + "m\x1b[K")
+			b.Write(text[i:j])
+			b.WriteString("\x1b[m\x1b[K")
+		}
+		i = j
+	}
+
+	return b.String()
+}
+
+// jsonGroup is a capture group in JSON output.
+type jsonGroup struct {
+	Name  string `json:"name,omitempty"`
+	Text  string `json:"text"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// jsonMatch is the part of a match within a line in JSON output, with
+// offsets relative to the line.
+type jsonMatch struct {
+	Text   string       `json:"text"`
+	Start  int          `json:"start"`
+	End    int          `json:"end"`
+	Groups []*jsonGroup `json:"groups,omitempty"`
+}
+
+// jsonLine prints line num as a JSON object.
+func (p *printer) jsonLine(num int, text []byte, matches []match, sep byte) {
+	obj := map[string]any{"type": "match", "file": p.name, "line": num, "text": string(text)}
+	if sep == '-' {
+		obj["type"] = "context"
+	}
+
+	if len(matches) > 0 {
+		jm := make([]jsonMatch, len(matches))
+		for i, m := range matches {
+			jm[i] = jsonMatch{Text: string(text[m.loc[0]:m.loc[1]]), Start: m.loc[0], End: m.loc[1]}
+			for g := 1; 2*g+1 < len(m.loc); g++ {
+				var group *jsonGroup
+				if lo, hi := m.loc[2*g], m.loc[2*g+1]; lo >= 0 {
+					group = &jsonGroup{Name: m.names[g], Text: string(text[lo:hi]), Start: lo, End: hi}
+				}
+				jm[i].Groups = append(jm[i].Groups, group)
+			}
+		}
+		obj["matches"] = jm
+	}
+
+	p.json(obj)
+}
+
+// json prints v as a line of JSON.
+func (p *printer) json(v any) {
+	b, _ := json.Marshal(v)
+	p.out.Write(b)
+	p.out.WriteByte('\n')
+}
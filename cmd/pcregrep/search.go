@@ -0,0 +1,392 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+const (
+	// binaryPeek is how much of the start of an input is checked for NUL
+	// bytes to tell whether it is binary.
+	binaryPeek = 8 * 1024
+
+	// maxWindow is the most input buffered in multiline mode waiting for a
+	// partial match to complete. Past that, the match is decided on the
+	// input at hand.
+	maxWindow = 8 * 1024 * 1024
+)
+
+// errStop stops searching an input once its result is known.
+var errStop = errors.New("stop")
+
+// match is a match within a line.
+type match struct {
+	// loc holds the index pairs of the match and its groups within the
+	// line, clipped to it; -1 marks groups outside of the line or that did
+	// not take part in the match.
+	loc []int
+
+	// text is the whole text of the match, which may span several lines in
+	// multiline mode, set on the line the match starts on.
+	text []byte
+
+	// names are the names of the groups of the pattern that matched.
+	names []string
+}
+
+// searcher searches inputs with one regexp per pattern. It is used by one
+// worker.
+type searcher struct {
+	o   *options
+	res []*pcregexp.PCREgexp
+}
+
+// find returns the leftmost match of the patterns in b from pos, as
+// [pcregexp.PCREgexp.Exec] does, and the regexp that found it. When several
+// patterns match at the same position, the one given first wins, as in an
+// alternation of the patterns. It returns the error of the first pattern
+// that fails to match, such as by exceeding the match limit.
+func (s *searcher) find(b []byte, pos int, flags pcregexp.MatchFlag) (loc []int, partial bool, re *pcregexp.PCREgexp, err error) {
+	for _, r := range s.res {
+		l, p, err := r.Exec(b, pos, flags)
+		if err != nil {
+			return nil, false, nil, err
+		}
+
+		if l != nil && (loc == nil || l[0] < loc[0]) {
+			loc, partial, re = l, p, r
+		}
+	}
+
+	return loc, partial, re, nil
+}
+
+// newMatch returns the match at loc, found by re, within the line at
+// [start, end).
+func newMatch(re *pcregexp.PCREgexp, loc []int, start, end int) match {
+	return match{loc: clip(loc, 2*(re.NumSubexp()+1), start, end), names: re.SubexpNames()}
+}
+
+// search searches r, and reports whether a line was selected.
+func (s *searcher) search(r io.Reader, p *printer) (bool, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+
+	if head, _ := br.Peek(binaryPeek); bytes.IndexByte(head, 0) >= 0 && !s.o.text {
+		if s.o.skipBinary {
+			return false, nil
+		}
+		p.binary = true
+	}
+
+	var err error
+	if s.o.multiline {
+		err = s.scanMultiline(br, p.line)
+	} else {
+		err = s.scanLines(br, p.line)
+	}
+
+	if errors.Is(err, errStop) {
+		err = nil
+	}
+	p.finish()
+
+	return p.selected > 0, err
+}
+
+// readLine reads a line from br, including its newline if any. It returns
+// io.EOF at the end of the input only.
+func readLine(br *bufio.Reader) ([]byte, error) {
+	line, err := br.ReadBytes('\n')
+	if err == io.EOF && len(line) > 0 {
+		err = nil
+	}
+
+	return line, err
+}
+
+// trimNewline returns line without its trailing newline.
+func trimNewline(line []byte) []byte {
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		return line[:n-1]
+	}
+
+	return line
+}
+
+// emitFunc handles a line of the input with the matches it holds, or the
+// error matching it failed with.
+type emitFunc func(text []byte, matches []match, err error) error
+
+// scanLines matches every line of br on its own and passes it on to emit.
+func (s *searcher) scanLines(br *bufio.Reader, emit emitFunc) error {
+	for {
+		line, err := readLine(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		text := trimNewline(line)
+
+		var (
+			matches []match
+			merr    error
+		)
+		for pos := 0; pos <= len(text); {
+			loc, _, re, err := s.find(text, pos, 0)
+			if err != nil {
+				matches, merr = nil, err
+				break
+			}
+			if loc == nil {
+				break
+			}
+
+			m := newMatch(re, loc, 0, len(text))
+			m.text = text[loc[0]:loc[1]]
+			matches = append(matches, m)
+
+			pos = loc[1]
+			if loc[0] == loc[1] {
+				if pos == len(text) {
+					break
+				}
+
+				_, size := utf8.DecodeRune(text[pos:])
+				pos += size
+			}
+		}
+
+		if err := emit(text, matches, merr); err != nil {
+			return err
+		}
+	}
+}
+
+// clip returns the n indexes of loc relative to the line at [start, end).
+// loc may be shorter than n, when the last groups did not take part in the
+// match.
+func clip(loc []int, n, start, end int) []int {
+	c := make([]int, n)
+	for i := 0; i < n; i += 2 {
+		c[i], c[i+1] = -1, -1
+		if i+1 >= len(loc) || loc[i] < 0 {
+			continue
+		}
+
+		lo, hi := loc[i], loc[i+1]
+		if hi < start || lo > end || hi == start && lo < start {
+			continue
+		}
+		if lo < start {
+			lo = start
+		}
+		if hi > end {
+			hi = end
+		}
+		c[i], c[i+1] = lo-start, hi-start
+	}
+
+	return c
+}
+
+// window holds the lines of an input that multiline matches are looked for
+// in.
+type window struct {
+	buf     []byte    // text of the lines, with their newlines
+	starts  []int     // start of each line in buf
+	matches [][]match // matches of each line
+	errs    []error   // error matching each line, if any
+	eof     bool
+}
+
+// read appends the next line of br, and sets w.eof at the end of the input.
+func (w *window) read(br *bufio.Reader) error {
+	line, err := readLine(br)
+	if err == io.EOF || err == nil && line[len(line)-1] != '\n' {
+		w.eof = true
+	}
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	w.starts = append(w.starts, len(w.buf))
+	w.buf = append(w.buf, line...)
+	w.matches = append(w.matches, nil)
+	w.errs = append(w.errs, nil)
+
+	return nil
+}
+
+// lineAt returns the index of the line holding offset off.
+func (w *window) lineAt(off int) int {
+	return sort.Search(len(w.starts), func(i int) bool { return w.starts[i] > off }) - 1
+}
+
+// text returns the text of line i, without its newline.
+func (w *window) text(i int) []byte {
+	end := len(w.buf)
+	if i+1 < len(w.starts) {
+		end = w.starts[i+1]
+	}
+
+	return trimNewline(w.buf[w.starts[i]:end])
+}
+
+// flush passes the first n lines on to emit and drops them. It returns the
+// length of the text dropped.
+func (w *window) flush(n int, emit emitFunc) (int, error) {
+	if n == 0 {
+		return 0, nil
+	}
+
+	for i := 0; i < n; i++ {
+		// The text of the line must outlive the window for context lines.
+		text := append([]byte(nil), w.text(i)...)
+		if err := emit(text, w.matches[i], w.errs[i]); err != nil {
+			return 0, err
+		}
+	}
+
+	shift := len(w.buf)
+	if n < len(w.starts) {
+		shift = w.starts[n]
+	}
+
+	w.buf = w.buf[:copy(w.buf, w.buf[shift:])]
+	w.starts = w.starts[:copy(w.starts, w.starts[n:])]
+	for i := range w.starts {
+		w.starts[i] -= shift
+	}
+	w.matches = w.matches[:copy(w.matches, w.matches[n:])]
+	w.errs = w.errs[:copy(w.errs, w.errs[n:])]
+
+	return shift, nil
+}
+
+// scanMultiline matches br as a whole, so that matches may span lines, and
+// passes every line on to emit with the parts of the matches it holds.
+//
+// Lines are buffered in a window from the line where the next match may
+// start on. When a match may continue past the end of the window, as
+// reported by partial matching, more lines are read and the match retried.
+//
+// If matching fails, the line where the search was at is passed on with the
+// error, and the search resumes at the next line.
+func (s *searcher) scanMultiline(br *bufio.Reader, emit emitFunc) error {
+	var (
+		w   window
+		pos int // offset in w.buf to match from
+	)
+
+	for {
+		if len(w.starts) == 0 {
+			if w.eof {
+				return nil
+			}
+			if err := w.read(br); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var flags pcregexp.MatchFlag
+		if !w.eof && len(w.buf) <= maxWindow {
+			flags = pcregexp.MatchPartialHard
+		}
+
+		loc, partial, re, err := s.find(w.buf, pos, flags)
+		if err != nil {
+			i := w.lineAt(pos)
+			w.errs[i] = err
+
+			if _, err := w.flush(i+1, emit); err != nil {
+				return err
+			}
+			pos = 0
+			continue
+		}
+
+		start, end := len(w.buf), len(w.buf)
+		if loc != nil {
+			start, end = loc[0], loc[1]
+		}
+
+		if partial || flags != 0 && start == end && end == len(w.buf) && loc != nil {
+			// The match may change with the next lines. Double the window,
+			// so that a match spanning many lines is retried a few times.
+			for size := len(w.buf); !w.eof && len(w.buf) < 2*size; {
+				if err := w.read(br); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if loc == nil || start == len(w.buf) && w.buf[len(w.buf)-1] == '\n' {
+			// No match before the end of the window, or an empty match after
+			// its last newline, which is not a line.
+			if _, err := w.flush(len(w.starts), emit); err != nil {
+				return err
+			}
+
+			pos = 0
+			if w.eof {
+				return nil
+			}
+			if err := w.read(br); err != nil {
+				return err
+			}
+			continue
+		}
+
+		first, last := w.lineAt(start), w.lineAt(start)
+		if end > start {
+			last = w.lineAt(end - 1)
+		}
+
+		for i := first; i <= last; i++ {
+			lineStart := w.starts[i]
+			m := newMatch(re, loc, lineStart, lineStart+len(w.text(i)))
+			if i == first {
+				m.text = append([]byte(nil), w.buf[start:end]...)
+			}
+			w.matches[i] = append(w.matches[i], m)
+		}
+
+		pos = end
+		if start == end {
+			if pos == len(w.buf) {
+				// At the end of a last line without newline.
+				_, err := w.flush(len(w.starts), emit)
+				return err
+			}
+
+			_, size := utf8.DecodeRune(w.buf[pos:])
+			pos += size
+		}
+
+		// The lines before the one holding pos cannot be matched further.
+		done := len(w.starts)
+		if pos < len(w.buf) {
+			done = w.lineAt(pos)
+		}
+
+		shift, err := w.flush(done, emit)
+		if err != nil {
+			return err
+		}
+		pos -= shift
+	}
+}
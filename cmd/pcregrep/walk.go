@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+// stdinName is the name standard input is shown with.
+const stdinName = "(standard input)"
+
+// grep searches a set of inputs.
+type grep struct {
+	o      *options
+	stdin  io.Reader
+	colors bool
+}
+
+// job is the search of one input.
+type job struct {
+	path string // "" for standard input
+	name string
+
+	out     bytes.Buffer
+	warn    bytes.Buffer // warnings about lines that could not be matched
+	matched bool
+	err     error
+	done    chan struct{}
+}
+
+// run searches the inputs of g.o with one worker per set of regexps of res,
+// writes the results to stdout in order, and returns the exit status.
+func (g *grep) run(res [][]*pcregexp.PCREgexp, stdout, stderr io.Writer) int {
+	var (
+		work    = make(chan *job)
+		results = make(chan *job, 4*len(res))
+		wg      sync.WaitGroup
+	)
+
+	for _, re := range res {
+		wg.Add(1)
+		go func(s *searcher) {
+			defer wg.Done()
+			for j := range work {
+				j.matched, j.err = g.searchJob(s, j)
+				close(j.done)
+			}
+		}(&searcher{o: g.o, res: re})
+	}
+
+	walkErrs := make(chan error, 1)
+	go func() {
+		walkErrs <- g.walk(func(path, name string) {
+			j := &job{path: path, name: name, done: make(chan struct{})}
+			results <- j
+			work <- j
+		}, stderr)
+		close(work)
+		close(results)
+	}()
+
+	matched, failed := false, false
+	for j := range results {
+		<-j.done
+
+		if j.err != nil {
+			failed = true
+			if !g.o.noMessages {
+				fmt.Fprintf(stderr, "pcregrep: %s: %v\n", j.name, j.err)
+			}
+		}
+
+		if j.warn.Len() > 0 {
+			failed = true
+			stderr.Write(j.warn.Bytes())
+		}
+
+		if j.matched {
+			matched = true
+		}
+
+		if _, err := stdout.Write(j.out.Bytes()); err != nil {
+			failed = true
+		}
+	}
+
+	wg.Wait()
+	if <-walkErrs != nil {
+		failed = true
+	}
+
+	switch {
+	case matched && (g.o.quiet || !failed):
+		return exitMatch
+	case failed:
+		return exitError
+	default:
+		return exitNoMatch
+	}
+}
+
+// searchJob searches the input of j into j.out.
+func (g *grep) searchJob(s *searcher, j *job) (bool, error) {
+	showName := g.o.withFilename || !g.o.noFilename && (g.o.recursive || len(g.o.paths) > 1)
+	p := newPrinter(g.o, &j.out, &j.warn, j.name, showName, g.colors)
+
+	if j.path == "" {
+		return s.search(g.stdin, p)
+	}
+
+	f, err := os.Open(j.path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	return s.search(f, p)
+}
+
+// walk calls fn with the path and display name of every input, in order. It
+// reports the inputs it cannot list to stderr, and returns an error if there
+// were any.
+func (g *grep) walk(fn func(path, name string), stderr io.Writer) error {
+	paths := g.o.paths
+	if len(paths) == 0 {
+		if !g.o.recursive {
+			fn("", stdinName)
+			return nil
+		}
+		paths = []string{"."}
+	}
+
+	var failed error
+	report := func(err error) {
+		failed = err
+		if !g.o.noMessages {
+			fmt.Fprintf(stderr, "pcregrep: %v\n", err)
+		}
+	}
+
+	for _, path := range paths {
+		if path == "-" {
+			fn("", stdinName)
+			continue
+		}
+
+		fi, err := os.Stat(path)
+		if err != nil {
+			report(err)
+			continue
+		}
+
+		if !fi.IsDir() {
+			if g.included(path) {
+				fn(path, path)
+			}
+			continue
+		}
+
+		if !g.o.recursive {
+			report(fmt.Errorf("%s: is a directory", path))
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			switch {
+			case err != nil:
+				report(err)
+			case d.IsDir():
+				if p != path && matchAny(g.o.excludeDirs, d.Name()) {
+					return filepath.SkipDir
+				}
+			case d.Type().IsRegular() && g.included(p):
+				fn(p, p)
+			}
+
+			return nil
+		})
+		if err != nil {
+			report(err)
+		}
+	}
+
+	return failed
+}
+
+// included reports whether the file at path passes the include and exclude
+// globs.
+func (g *grep) included(path string) bool {
+	name := filepath.Base(path)
+	if len(g.o.includes) > 0 && !matchAny(g.o.includes, name) {
+		return false
+	}
+
+	return !matchAny(g.o.excludes, name)
+}
+
+// matchAny reports whether name matches any of globs.
+func matchAny(globs []string, name string) bool {
+	for _, glob := range globs {
+		if ok, _ := pathMatch(glob, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pathMatch matches a file name against a shell glob.
+func pathMatch(glob, name string) (bool, error) {
+	return filepath.Match(glob, name)
+}
@@ -0,0 +1,278 @@
+package pcregexp
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// CompileFlag is a set of PCRE2 compile options, see [CompileWithOptions].
+// Ref: https://pcre2project.github.io/pcre2/doc/pcre2_compile/
+type CompileFlag uint32
+
+const (
+	// CompileAllowEmptyClass lets ] at the start of a class close it, so
+	// that [] never matches (PCRE2_ALLOW_EMPTY_CLASS).
+	CompileAllowEmptyClass CompileFlag = 0x00000001
+
+	// CompileAltBSUX makes \U, \u and \x behave as in JavaScript
+	// (PCRE2_ALT_BSUX).
+	CompileAltBSUX CompileFlag = 0x00000002
+
+	// CompileCaseless matches letters regardless of case (PCRE2_CASELESS),
+	// like (?i).
+	CompileCaseless CompileFlag = 0x00000008
+
+	// CompileDollarEndOnly makes $ match only at the very end of the
+	// subject, not before a final newline (PCRE2_DOLLAR_ENDONLY).
+	CompileDollarEndOnly CompileFlag = 0x00000010
+
+	// CompileDotAll makes . match newlines too (PCRE2_DOTALL), like (?s).
+	CompileDotAll CompileFlag = 0x00000020
+
+	// CompileDupNames allows groups to share names (PCRE2_DUPNAMES), like
+	// (?J).
+	CompileDupNames CompileFlag = 0x00000040
+
+	// CompileExtended ignores whitespace and # comments in the pattern
+	// (PCRE2_EXTENDED), like (?x).
+	CompileExtended CompileFlag = 0x00000080
+
+	// CompileFirstLine requires matches to start before the first newline
+	// of the subject (PCRE2_FIRSTLINE).
+	CompileFirstLine CompileFlag = 0x00000100
+
+	// CompileMatchUnsetBackref makes backreferences to unset groups match
+	// the empty string (PCRE2_MATCH_UNSET_BACKREF).
+	CompileMatchUnsetBackref CompileFlag = 0x00000200
+
+	// CompileMultiline makes ^ and $ match at newlines within the subject
+	// (PCRE2_MULTILINE), like (?m).
+	CompileMultiline CompileFlag = 0x00000400
+
+	// CompileNeverUCP forbids (*UCP) in the pattern (PCRE2_NEVER_UCP).
+	CompileNeverUCP CompileFlag = 0x00000800
+
+	// CompileNeverUTF forbids (*UTF) in the pattern (PCRE2_NEVER_UTF).
+	CompileNeverUTF CompileFlag = 0x00001000
+
+	// CompileNoAutoCapture makes plain parentheses non-capturing
+	// (PCRE2_NO_AUTO_CAPTURE), like (?n).
+	CompileNoAutoCapture CompileFlag = 0x00002000
+
+	// CompileNoAutoPossess disables the automatic possessification of
+	// quantifiers (PCRE2_NO_AUTO_POSSESS).
+	CompileNoAutoPossess CompileFlag = 0x00004000
+
+	// CompileNoDotStarAnchor disables the implicit anchoring of patterns
+	// starting with .* (PCRE2_NO_DOTSTAR_ANCHOR).
+	CompileNoDotStarAnchor CompileFlag = 0x00008000
+
+	// CompileNoStartOptimize disables the optimizations that skip start
+	// positions that cannot match (PCRE2_NO_START_OPTIMIZE).
+	CompileNoStartOptimize CompileFlag = 0x00010000
+
+	// CompileUCP makes \d, \w, \s, \b and POSIX classes use Unicode
+	// properties (PCRE2_UCP).
+	CompileUCP CompileFlag = 0x00020000
+
+	// CompileUngreedy inverts the greediness of quantifiers
+	// (PCRE2_UNGREEDY), like (?U).
+	CompileUngreedy CompileFlag = 0x00040000
+
+	// CompileUTF treats the pattern and subjects as UTF-8 (PCRE2_UTF), like
+	// (*UTF).
+	CompileUTF CompileFlag = 0x00080000
+
+	// CompileNeverBackslashC forbids \C in the pattern
+	// (PCRE2_NEVER_BACKSLASH_C).
+	CompileNeverBackslashC CompileFlag = 0x00100000
+
+	// CompileAltCircumflex makes ^ in multiline mode also match after a
+	// final newline (PCRE2_ALT_CIRCUMFLEX).
+	CompileAltCircumflex CompileFlag = 0x00200000
+
+	// CompileAltVerbNames processes escapes in the names of verbs such as
+	// (*MARK:NAME) (PCRE2_ALT_VERBNAMES).
+	CompileAltVerbNames CompileFlag = 0x00400000
+
+	// CompileExtendedMore is like [CompileExtended], and also ignores spaces
+	// and tabs in classes (PCRE2_EXTENDED_MORE), like (?xx).
+	CompileExtendedMore CompileFlag = 0x01000000
+
+	// CompileLiteral treats the pattern as a literal string
+	// (PCRE2_LITERAL).
+	CompileLiteral CompileFlag = 0x02000000
+
+	// CompileMatchInvalidUTF, with [CompileUTF], lets subjects hold invalid
+	// UTF-8, which no match includes (PCRE2_MATCH_INVALID_UTF).
+	CompileMatchInvalidUTF CompileFlag = 0x04000000
+
+	// CompileAnchored anchors every match at the start offset
+	// (PCRE2_ANCHORED).
+	CompileAnchored CompileFlag = 0x80000000
+
+	// CompileEndAnchored anchors every match at the end of the subject
+	// (PCRE2_ENDANCHORED).
+	CompileEndAnchored CompileFlag = 0x20000000
+)
+
+// ExtraFlag is a set of PCRE2 extra compile options, see
+// [CompileWithOptions].
+// Ref: https://pcre2project.github.io/pcre2/doc/pcre2_set_compile_extra_options/
+type ExtraFlag uint32
+
+const (
+	// ExtraAllowSurrogateEscapes allows \x{d800} to \x{dfff} outside of
+	// UTF mode (PCRE2_EXTRA_ALLOW_SURROGATE_ESCAPES).
+	ExtraAllowSurrogateEscapes ExtraFlag = 0x00000001
+
+	// ExtraBadEscapeIsLiteral treats unknown escapes as literals
+	// (PCRE2_EXTRA_BAD_ESCAPE_IS_LITERAL).
+	ExtraBadEscapeIsLiteral ExtraFlag = 0x00000002
+
+	// ExtraMatchWord only matches whole words, as if the pattern was
+	// wrapped in \b(?:...)\b (PCRE2_EXTRA_MATCH_WORD).
+	ExtraMatchWord ExtraFlag = 0x00000004
+
+	// ExtraMatchLine only matches whole lines, as if the pattern was
+	// wrapped in ^(?:...)$ (PCRE2_EXTRA_MATCH_LINE).
+	ExtraMatchLine ExtraFlag = 0x00000008
+
+	// ExtraEscapedCRIsLF makes \r in the pattern match a newline
+	// (PCRE2_EXTRA_ESCAPED_CR_IS_LF).
+	ExtraEscapedCRIsLF ExtraFlag = 0x00000010
+
+	// ExtraAltBSUX is like [CompileAltBSUX], and also supports \u{hhh..}
+	// (PCRE2_EXTRA_ALT_BSUX).
+	ExtraAltBSUX ExtraFlag = 0x00000020
+
+	// ExtraAllowLookaroundBSK allows \K in lookarounds
+	// (PCRE2_EXTRA_ALLOW_LOOKAROUND_BSK).
+	ExtraAllowLookaroundBSK ExtraFlag = 0x00000040
+)
+
+// CompileOptions configures [CompileWithOptions].
+type CompileOptions struct {
+	// Flags are the compile options.
+	Flags CompileFlag
+
+	// Extra are the extra compile options.
+	Extra ExtraFlag
+
+	// Newline is the newline convention, for ^, $ and . among others; 0
+	// keeps the convention the library was built with.
+	Newline Newline
+
+	// ParensNestLimit is the maximum nesting depth of parentheses in the
+	// pattern; 0 keeps the library default.
+	ParensNestLimit uint32
+
+	// NoJIT skips the JIT compilation of the pattern, whatever the option
+	// set with [SetJITOption].
+	NoJIT bool
+}
+
+// CompileError is returned when PCRE2 fails to compile a pattern.
+type CompileError struct {
+	// Pattern is the pattern that failed to compile.
+	Pattern string
+
+	// Offset is the byte offset in the pattern where the error was found.
+	Offset int
+
+	// Code is the PCRE2 error code.
+	Code int
+}
+
+// Error implements the error interface.
+func (e *CompileError) Error() string {
+	return fmt.Sprintf("pcre2_compile failed at offset %d: %s (error code %d)", e.Offset, ErrorMessage(e.Code), e.Code)
+}
+
+// ErrorMessage returns the message PCRE2 describes an error code with, for
+// compile errors as well as negative match and substitution errors.
+func ErrorMessage(code int) string {
+	if err := Init(); err != nil {
+		return fmt.Sprintf("error code %d", code)
+	}
+
+	var buf [256]byte
+	n := pcre2_get_error_message(int32(code), &buf[0], uint64(len(buf)))
+	if n < 0 {
+		return fmt.Sprintf("unknown error code %d", code)
+	}
+
+	return string(buf[:n])
+}
+
+// CompileWithOptions is like [Compile], but compiles pattern with the PCRE2
+// options of opts, for behavior that inline settings such as (?i) cannot
+// select. Unlike with Compile, an empty pattern is compiled, and matches
+// the empty string everywhere as in PCRE2.
+func CompileWithOptions(pattern string, opts CompileOptions) (*PCREgexp, error) {
+	if err := Init(); err != nil {
+		return nil, err
+	}
+
+	var ctx uintptr
+	if opts.Extra != 0 || opts.Newline != 0 || opts.ParensNestLimit != 0 {
+		ctx = pcre2_compile_context_create(0)
+		if ctx == 0 {
+			return nil, fmt.Errorf("could not create compile context")
+		}
+		defer pcre2_compile_context_free(ctx)
+
+		if opts.Extra != 0 {
+			pcre2_set_compile_extra_options(ctx, uint32(opts.Extra))
+		}
+
+		if opts.Newline != 0 {
+			if pcre2_set_newline(ctx, uint32(opts.Newline)) != 0 {
+				return nil, fmt.Errorf("invalid newline convention %d", opts.Newline)
+			}
+		}
+
+		if opts.ParensNestLimit != 0 {
+			pcre2_set_parens_nest_limit(ctx, opts.ParensNestLimit)
+		}
+	}
+
+	return compile(pattern, uint32(opts.Flags), ctx, opts.NoJIT)
+}
+
+// compile compiles pattern with the PCRE2 compile options and compile
+// context, which may be 0, and JIT compiles it unless noJIT.
+func compile(pattern string, options uint32, ctx uintptr, noJIT bool) (*PCREgexp, error) {
+	var errcode int32
+	var errOffset uint64
+
+	re := &PCREgexp{
+		pattern: pattern,
+		names:   []string{""},
+		cache:   newResultCache(int(defaultCacheSize.Load())),
+	}
+
+	patPtr := &emptySubject[0]
+	if len(pattern) > 0 {
+		patPtr = (*uint8)(unsafe.StringData(pattern))
+	}
+
+	code := pcre2_compile(patPtr, uint64(len(pattern)), options, &errcode, &errOffset, ctx)
+	if code == 0 {
+		return nil, &CompileError{Pattern: pattern, Offset: int(errOffset), Code: int(errcode)}
+	}
+	re.h = newHandle(code)
+
+	if !noJIT && defaultJITOption != JITNoJit && libConfig.JIT {
+		res := pcre2_jit_compile(code, uint32(defaultJITOption))
+		if res == 0 {
+			re.isJIT = true
+			re.h.attachJITStack(defaultJITStackStartSize, defaultJITStackMaxSize)
+		}
+	}
+
+	re.h.track(pattern)
+	re.names = subexpNames(code)
+
+	return re, nil
+}
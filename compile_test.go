@@ -0,0 +1,98 @@
+package pcregexp_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+func TestCompileWithOptions(t *testing.T) {
+	tests := []struct {
+		pattern string
+		opts    pcregexp.CompileOptions
+		input   string
+		want    []string
+	}{
+		{`abc`, pcregexp.CompileOptions{Flags: pcregexp.CompileCaseless}, "ABC abc", []string{"ABC", "abc"}},
+		{`^\w+`, pcregexp.CompileOptions{Flags: pcregexp.CompileMultiline}, "one\ntwo", []string{"one", "two"}},
+		{`a.c`, pcregexp.CompileOptions{Flags: pcregexp.CompileDotAll}, "a\nc", []string{"a\nc"}},
+		{`a+?`, pcregexp.CompileOptions{Flags: pcregexp.CompileUngreedy}, "aaa", []string{"aaa"}},
+		{`a.b`, pcregexp.CompileOptions{Flags: pcregexp.CompileLiteral}, "axb a.b", []string{"a.b"}},
+		{`x$`, pcregexp.CompileOptions{Flags: pcregexp.CompileDollarEndOnly}, "x\n", nil},
+		{`cat`, pcregexp.CompileOptions{Extra: pcregexp.ExtraMatchWord}, "cats cat", []string{"cat"}},
+		{`b+`, pcregexp.CompileOptions{Flags: pcregexp.CompileMultiline, Extra: pcregexp.ExtraMatchLine}, "abb\nbb", []string{"bb"}},
+		{`^b`, pcregexp.CompileOptions{Flags: pcregexp.CompileMultiline, Newline: pcregexp.NewlineCR}, "a\nb\rb", []string{"b"}},
+		{`\w+`, pcregexp.CompileOptions{Flags: pcregexp.CompileUTF | pcregexp.CompileUCP}, "héllo wörld", []string{"héllo", "wörld"}},
+		{``, pcregexp.CompileOptions{}, "ab", []string{"", "", ""}},
+	}
+
+	for _, tt := range tests {
+		re, err := pcregexp.CompileWithOptions(tt.pattern, tt.opts)
+		if err != nil {
+			t.Errorf("CompileWithOptions(%q, %+v): %v", tt.pattern, tt.opts, err)
+			continue
+		}
+
+		got := re.FindAllString(tt.input, -1)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+			t.Errorf("CompileWithOptions(%q, %+v).FindAllString(%q) = %q, want %q", tt.pattern, tt.opts, tt.input, got, tt.want)
+		}
+		re.Close()
+	}
+}
+
+func TestCompileWithOptions_Errors(t *testing.T) {
+	_, err := pcregexp.CompileWithOptions(`((a))`, pcregexp.CompileOptions{ParensNestLimit: 1})
+	var cerr *pcregexp.CompileError
+	if !errors.As(err, &cerr) || cerr.Code == 0 {
+		t.Fatalf("CompileWithOptions over the parentheses nest limit = %v, want a CompileError", err)
+	}
+
+	_, err = pcregexp.CompileWithOptions(`(*UTF)a`, pcregexp.CompileOptions{Flags: pcregexp.CompileNeverUTF})
+	if !errors.As(err, &cerr) {
+		t.Errorf("(*UTF) with CompileNeverUTF = %v, want a CompileError", err)
+	}
+
+	if _, err := pcregexp.CompileWithOptions(`a`, pcregexp.CompileOptions{Newline: 42}); err == nil {
+		t.Error("CompileWithOptions with an invalid newline convention succeeded")
+	}
+}
+
+func TestCompileError(t *testing.T) {
+	_, err := pcregexp.Compile(`ab(c`)
+
+	var cerr *pcregexp.CompileError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("Compile(%q) error = %v, want a CompileError", `ab(c`, err)
+	}
+
+	if cerr.Pattern != `ab(c` || cerr.Offset != 4 || cerr.Code != 114 {
+		t.Errorf("CompileError = %+v, want offset 4 and code 114", cerr)
+	}
+
+	want := "pcre2_compile failed at offset 4: missing closing parenthesis (error code 114)"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	tests := []struct {
+		code int
+		want string
+	}{
+		{101, "\\ at end of pattern"},
+		{-1, "no match"},
+		{-2, "partial match"},
+		{-49, "unknown substring"},
+		{12345, "unknown error code 12345"},
+	}
+
+	for _, tt := range tests {
+		if got := pcregexp.ErrorMessage(tt.code); got != tt.want {
+			t.Errorf("ErrorMessage(%d) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
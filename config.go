@@ -0,0 +1,236 @@
+package pcregexp
+
+import (
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// pcre2_config() "what" values.
+// Ref: https://pcre2project.github.io/pcre2/doc/pcre2_config/
+const (
+	configBSR            = 0
+	configJIT            = 1
+	configJITTarget      = 2
+	configLinkSize       = 3
+	configMatchLimit     = 4
+	configNewline        = 5
+	configParensLimit    = 6
+	configDepthLimit     = 7
+	configUnicode        = 9
+	configUnicodeVersion = 10
+	configVersion        = 11
+	configHeapLimit      = 12
+)
+
+var (
+	// libConfig is the build configuration of the loaded PCRE2 library.
+	libConfig LibraryConfig
+
+	// libFeatures records which optional functions the loaded library exports.
+	libFeatures map[Feature]bool
+)
+
+// Newline is a PCRE2 newline convention.
+type Newline uint32
+
+const (
+	NewlineCR      Newline = iota + 1 // carriage return only
+	NewlineLF                         // linefeed only
+	NewlineCRLF                       // CR followed by LF only
+	NewlineAny                        // any Unicode newline sequence
+	NewlineAnyCRLF                    // any of CR, LF, or CRLF
+	NewlineNUL                        // the NUL character (binary zero)
+)
+
+// String returns the name PCRE2 uses for the newline convention.
+func (n Newline) String() string {
+	switch n {
+	case NewlineCR:
+		return "CR"
+	case NewlineLF:
+		return "LF"
+	case NewlineCRLF:
+		return "CRLF"
+	case NewlineAny:
+		return "ANY"
+	case NewlineAnyCRLF:
+		return "ANYCRLF"
+	case NewlineNUL:
+		return "NUL"
+	default:
+		return "Newline(" + strconv.Itoa(int(n)) + ")"
+	}
+}
+
+// LibraryConfig describes how the loaded PCRE2 library was built, as reported
+// by pcre2_config.
+type LibraryConfig struct {
+	// Version is the PCRE2 version string, e.g. "10.42 2022-12-11".
+	Version string
+
+	// Major and Minor are the numeric components of Version.
+	Major, Minor int
+
+	// Unicode reports whether Unicode support is available.
+	Unicode bool
+
+	// UnicodeVersion is the supported Unicode version, if any.
+	UnicodeVersion string
+
+	// JIT reports whether JIT compilation is available.
+	JIT bool
+
+	// JITTarget describes the JIT target architecture, if JIT is available.
+	JITTarget string
+
+	// Newline is the default newline convention.
+	Newline Newline
+
+	// BSRAnyCRLF reports whether \R matches only CR, LF, or CRLF by default,
+	// rather than any Unicode line ending.
+	BSRAnyCRLF bool
+
+	// LinkSize is the internal link size in bytes (2, 3, or 4).
+	LinkSize int
+
+	// MatchLimit is the default match limit.
+	MatchLimit uint32
+
+	// DepthLimit is the default depth limit.
+	DepthLimit uint32
+
+	// HeapLimit is the default heap limit in kibibytes (KiB).
+	HeapLimit uint32
+
+	// ParensLimit is the maximum depth of nested parentheses in a pattern.
+	ParensLimit uint32
+}
+
+// Feature is an optional PCRE2 capability that depends on how, or which
+// release of, the library was built.
+type Feature int
+
+const (
+	// FeatureJIT is JIT compilation support.
+	FeatureJIT Feature = iota
+
+	// FeatureUnicode is Unicode support (UTF and UCP).
+	FeatureUnicode
+
+	// FeatureNextMatch is pcre2_next_match, available since PCRE2 10.46.
+	FeatureNextMatch
+
+	// FeatureMaxVarLookbehind is pcre2_set_max_varlookbehind, available since
+	// PCRE2 10.43.
+	FeatureMaxVarLookbehind
+
+	// FeatureSubstituteCaseCallout is pcre2_set_substitute_case_callout,
+	// available since PCRE2 10.45.
+	FeatureSubstituteCaseCallout
+)
+
+// configUint32 returns the numeric configuration value for what.
+func configUint32(what uint32) uint32 {
+	var v uint32
+	if pcre2_config(what, ptr(&v)) < 0 {
+		return 0
+	}
+
+	return v
+}
+
+// configString returns the string configuration value for what.
+func configString(what uint32) string {
+	// With a NULL "where", the length in code units, including the
+	// terminating zero, is returned.
+	n := pcre2_config(what, nil)
+	if n <= 0 {
+		return ""
+	}
+
+	buf := make([]byte, n)
+	if pcre2_config(what, ptr(unsafe.SliceData(buf))) < 0 {
+		return ""
+	}
+
+	return strings.TrimRight(string(buf), "\x00")
+}
+
+// readConfig queries the build configuration of the loaded PCRE2 library.
+func readConfig() LibraryConfig {
+	cfg := LibraryConfig{
+		Version:     configString(configVersion),
+		Unicode:     configUint32(configUnicode) == 1,
+		JIT:         configUint32(configJIT) == 1,
+		Newline:     Newline(configUint32(configNewline)),
+		BSRAnyCRLF:  configUint32(configBSR) == 2,
+		LinkSize:    int(configUint32(configLinkSize)),
+		MatchLimit:  configUint32(configMatchLimit),
+		DepthLimit:  configUint32(configDepthLimit),
+		HeapLimit:   configUint32(configHeapLimit),
+		ParensLimit: configUint32(configParensLimit),
+	}
+
+	if cfg.Unicode {
+		cfg.UnicodeVersion = configString(configUnicodeVersion)
+	}
+
+	// PCRE2_CONFIG_JITTARGET returns PCRE2_ERROR_BADOPTION without JIT.
+	if cfg.JIT {
+		cfg.JITTarget = configString(configJITTarget)
+	}
+
+	// The version string looks like "10.42 2022-12-11".
+	number, _, _ := strings.Cut(cfg.Version, " ")
+	major, minor, _ := strings.Cut(number, ".")
+	cfg.Major, _ = strconv.Atoi(major)
+	cfg.Minor, _ = strconv.Atoi(minor)
+
+	return cfg
+}
+
+// Config returns the build configuration of the PCRE2 library, loading it
+// first if needed.
+func Config() (LibraryConfig, error) {
+	if err := Init(); err != nil {
+		return LibraryConfig{}, err
+	}
+
+	libMu.Lock()
+	defer libMu.Unlock()
+
+	return libConfig, nil
+}
+
+// Version returns the version string of the PCRE2 library, or an empty
+// string if it cannot be loaded.
+func Version() string {
+	cfg, err := Config()
+	if err != nil {
+		return ""
+	}
+
+	return cfg.Version
+}
+
+// Supports reports whether the PCRE2 library provides feature. It reports
+// false if the library cannot be loaded.
+func Supports(feature Feature) bool {
+	cfg, err := Config()
+	if err != nil {
+		return false
+	}
+
+	switch feature {
+	case FeatureJIT:
+		return cfg.JIT
+	case FeatureUnicode:
+		return cfg.Unicode
+	}
+
+	libMu.Lock()
+	defer libMu.Unlock()
+
+	return libFeatures[feature]
+}
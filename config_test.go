@@ -0,0 +1,76 @@
+package pcregexp_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+func TestConfig(t *testing.T) {
+	cfg, err := pcregexp.Config()
+	if err != nil {
+		t.Fatalf("Config() error = %v", err)
+	}
+
+	if cfg.Major != 10 {
+		t.Errorf("Config().Major = %d, want 10", cfg.Major)
+	}
+
+	if !strings.HasPrefix(cfg.Version, "10.") {
+		t.Errorf("Config().Version = %q, want a 10.x version", cfg.Version)
+	}
+
+	if got := pcregexp.Version(); got != cfg.Version {
+		t.Errorf("Version() = %q, want %q", got, cfg.Version)
+	}
+
+	if cfg.LinkSize < 2 || cfg.LinkSize > 4 {
+		t.Errorf("Config().LinkSize = %d, want 2, 3 or 4", cfg.LinkSize)
+	}
+
+	if cfg.Newline < pcregexp.NewlineCR || cfg.Newline > pcregexp.NewlineNUL {
+		t.Errorf("Config().Newline = %v, want a known convention", cfg.Newline)
+	}
+
+	if cfg.MatchLimit == 0 || cfg.DepthLimit == 0 {
+		t.Errorf("Config() limits = %d/%d, want non-zero defaults", cfg.MatchLimit, cfg.DepthLimit)
+	}
+
+	if cfg.JIT != (cfg.JITTarget != "") {
+		t.Errorf("Config().JIT = %v with JITTarget %q", cfg.JIT, cfg.JITTarget)
+	}
+
+	t.Logf("PCRE2 %s, Unicode %s, JIT %v (%s), newline %v", cfg.Version, cfg.UnicodeVersion, cfg.JIT, cfg.JITTarget, cfg.Newline)
+}
+
+func TestSupports(t *testing.T) {
+	cfg, err := pcregexp.Config()
+	if err != nil {
+		t.Fatalf("Config() error = %v", err)
+	}
+
+	atLeast := func(minor int) bool {
+		return cfg.Major > 10 || (cfg.Major == 10 && cfg.Minor >= minor)
+	}
+
+	tests := []struct {
+		name    string
+		feature pcregexp.Feature
+		want    bool
+	}{
+		{"JIT", pcregexp.FeatureJIT, cfg.JIT},
+		{"Unicode", pcregexp.FeatureUnicode, cfg.Unicode},
+		{"NextMatch", pcregexp.FeatureNextMatch, atLeast(46)},
+		{"MaxVarLookbehind", pcregexp.FeatureMaxVarLookbehind, atLeast(43)},
+		{"SubstituteCaseCallout", pcregexp.FeatureSubstituteCaseCallout, atLeast(45)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pcregexp.Supports(tt.feature); got != tt.want {
+				t.Errorf("Supports(%s) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package pcregexp
+
+// MatchFlag is a set of PCRE2 match options applied to a single match, see
+// [PCREgexp.FindIndexAt].
+// Ref: https://pcre2project.github.io/pcre2/doc/pcre2_match/
+type MatchFlag uint32
+
+const (
+	// MatchAnchored only allows a match starting at the start offset
+	// (PCRE2_ANCHORED).
+	MatchAnchored MatchFlag = 0x80000000
+
+	// MatchEndAnchored only allows a match ending at the end of the subject
+	// (PCRE2_ENDANCHORED).
+	MatchEndAnchored MatchFlag = 0x20000000
+
+	// MatchNotBOL makes ^ and \A not match at the start of the subject
+	// (PCRE2_NOTBOL).
+	MatchNotBOL MatchFlag = 0x00000001
+
+	// MatchNotEOL makes $ not match at the end of the subject (PCRE2_NOTEOL).
+	MatchNotEOL MatchFlag = 0x00000002
+
+	// MatchNotEmpty rejects empty matches (PCRE2_NOTEMPTY).
+	MatchNotEmpty MatchFlag = 0x00000004
+
+	// MatchNotEmptyAtStart rejects an empty match at the start offset
+	// (PCRE2_NOTEMPTY_ATSTART).
+	MatchNotEmptyAtStart MatchFlag = 0x00000008
+
+	// MatchNoUTFCheck skips checking the subject for valid UTF-8, for
+	// patterns compiled in UTF mode (PCRE2_NO_UTF_CHECK). The subject must
+	// then be valid UTF-8.
+	MatchNoUTFCheck MatchFlag = 0x40000000
+
+	// MatchPartialSoft enables partial matching, see
+	// [PCREgexp.FindIndexPartialAt]: a partial match is reported if the end
+	// of the subject is reached before a match completes, but only if no
+	// complete match can be found (PCRE2_PARTIAL_SOFT).
+	MatchPartialSoft MatchFlag = 0x00000010
+
+	// MatchPartialHard enables partial matching, see
+	// [PCREgexp.FindIndexPartialAt]: a partial match is reported as soon as
+	// the end of the subject is reached before a match completes, or before a
+	// complete match could be extended by more text (PCRE2_PARTIAL_HARD).
+	MatchPartialHard MatchFlag = 0x00000020
+
+	// MatchCopyMatchedSubject makes PCRE2 copy the subject into its match data
+	// (PCRE2_COPY_MATCHED_SUBJECT). Results never refer to that copy, so this
+	// only costs time; it exists for parity with PCRE2.
+	MatchCopyMatchedSubject MatchFlag = 0x00004000
+)
+
+// FindIndexAt is like [PCREgexp.FindIndex], but starts searching b at byte
+// offset off and applies the match flags. The text before off is still
+// visible to lookbehind assertions, \b and ^ (unless [MatchNotBOL] is
+// given), and the returned offsets are relative to the start of b.
+//
+// It returns nil if there is no match, or if off is not within [0, len(b)].
+// Combined with [MatchAnchored], it matches at a cursor position without
+// copying the subject.
+func (re *PCREgexp) FindIndexAt(b []byte, off int, flags MatchFlag) []int {
+	indexes := re.execAt(b, off, flags)
+	if indexes == nil {
+		return nil
+	}
+
+	return []int{indexes[0], indexes[1]}
+}
+
+// FindStringIndexAt is like [PCREgexp.FindIndexAt] but for a string.
+func (re *PCREgexp) FindStringIndexAt(s string, off int, flags MatchFlag) []int {
+	return re.FindIndexAt(string2BytesUnsafe(s), off, flags)
+}
+
+// FindSubmatchIndexAt is like [PCREgexp.FindSubmatchIndex], but starts
+// searching b at byte offset off and applies the match flags, like
+// [PCREgexp.FindIndexAt].
+func (re *PCREgexp) FindSubmatchIndexAt(b []byte, off int, flags MatchFlag) []int {
+	indexes := re.execAt(b, off, flags)
+	if indexes == nil {
+		return nil
+	}
+
+	return append([]int(nil), indexes...)
+}
+
+// FindStringSubmatchIndexAt is like [PCREgexp.FindSubmatchIndexAt] but for a
+// string.
+func (re *PCREgexp) FindStringSubmatchIndexAt(s string, off int, flags MatchFlag) []int {
+	return re.FindSubmatchIndexAt(string2BytesUnsafe(s), off, flags)
+}
+
+// execAt matches b from off with flags into re.buf. It returns nil if there
+// is no match or off is out of range. The result cache is bypassed.
+func (re *PCREgexp) execAt(b []byte, off int, flags MatchFlag) []int {
+	if off < 0 || off > len(b) {
+		return nil
+	}
+
+	indexes, ok := re.exec(re.buf[:0], b, off, uint32(flags))
+	if !ok {
+		return nil
+	}
+	re.buf = indexes
+
+	return indexes
+}
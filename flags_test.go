@@ -0,0 +1,71 @@
+package pcregexp_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+func TestRegexp_FindStringIndexAt(t *testing.T) {
+	tests := []struct {
+		pattern string
+		input   string
+		off     int
+		flags   pcregexp.MatchFlag
+		want    []int
+	}{
+		{`\d+`, "ab 12 34", 0, 0, []int{3, 5}},
+		{`\d+`, "ab 12 34", 4, 0, []int{4, 5}},
+		{`\d+`, "ab 12 34", 5, 0, []int{6, 8}},
+		{`\d+`, "ab 12 34", 8, 0, nil},
+		{`\d+`, "ab 12 34", 9, 0, nil},
+		{`\d+`, "ab 12 34", -1, 0, nil},
+
+		// Anchored at a cursor position.
+		{`\d+`, "ab 12 34", 2, pcregexp.MatchAnchored, nil},
+		{`\d+`, "ab 12 34", 3, pcregexp.MatchAnchored, []int{3, 5}},
+		{`\w+`, "ab 12 34", 0, pcregexp.MatchEndAnchored, []int{6, 8}},
+		{`\w+`, "ab 12 34", 3, pcregexp.MatchAnchored | pcregexp.MatchEndAnchored, nil},
+
+		// The text before the offset is visible.
+		{`(?<=a)b`, "ab", 1, pcregexp.MatchAnchored, []int{1, 2}},
+		{`\bb`, "ab", 1, 0, nil},
+		{`^a`, "aa", 1, 0, nil},
+
+		{`^a`, "ab", 0, pcregexp.MatchNotBOL, nil},
+		{`b$`, "ab", 0, pcregexp.MatchNotEOL, nil},
+		{`x*`, "ab", 0, pcregexp.MatchNotEmpty, nil},
+		{`x*`, "ab", 0, pcregexp.MatchNotEmptyAtStart, []int{1, 1}},
+		{`b`, "ab", 0, pcregexp.MatchNoUTFCheck | pcregexp.MatchCopyMatchedSubject, []int{1, 2}},
+	}
+
+	for _, tt := range tests {
+		re := pcregexp.MustCompile(tt.pattern)
+
+		if got := re.FindStringIndexAt(tt.input, tt.off, tt.flags); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FindStringIndexAt(%q, %d, %#x) with pattern %q = %v, want %v", tt.input, tt.off, tt.flags, tt.pattern, got, tt.want)
+		}
+
+		if got := re.FindIndexAt([]byte(tt.input), tt.off, tt.flags); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FindIndexAt(%q, %d, %#x) with pattern %q = %v, want %v", tt.input, tt.off, tt.flags, tt.pattern, got, tt.want)
+		}
+
+		re.Close()
+	}
+}
+
+func TestRegexp_FindStringSubmatchIndexAt(t *testing.T) {
+	re := pcregexp.MustCompile(`(\w+)=(\d+)?`)
+	defer re.Close()
+
+	input := "a=1 b= c=3"
+
+	if got, want := re.FindStringSubmatchIndexAt(input, 4, pcregexp.MatchAnchored), []int{4, 6, 4, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindStringSubmatchIndexAt(%q, 4) = %v, want %v", input, got, want)
+	}
+
+	if got, want := re.FindSubmatchIndexAt([]byte(input), 5, 0), []int{7, 10, 7, 8, 9, 10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindSubmatchIndexAt(%q, 5) = %v, want %v", input, got, want)
+	}
+}
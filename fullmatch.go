@@ -0,0 +1,18 @@
+package pcregexp
+
+// FullMatch reports whether the regexp matches the whole of b.
+//
+// Unlike wrapping the pattern in ^(?:...)$, whose $ also matches before a
+// trailing newline, it uses PCRE2_ANCHORED and PCRE2_ENDANCHORED, and it does
+// not change the pattern or the numbering of its groups. PCRE2 backtracks
+// into alternatives and quantifiers to reach the end of the subject, so
+// `a|ab` fully matches "ab".
+func (re *PCREgexp) FullMatch(b []byte) bool {
+	return re.execAt(b, 0, MatchAnchored|MatchEndAnchored) != nil
+}
+
+// FullMatchString reports whether the regexp matches the whole of s, like
+// [PCREgexp.FullMatch].
+func (re *PCREgexp) FullMatchString(s string) bool {
+	return re.FullMatch(string2BytesUnsafe(s))
+}
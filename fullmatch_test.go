@@ -0,0 +1,41 @@
+package pcregexp_test
+
+import (
+	"testing"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+func TestRegexp_FullMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		input   string
+		want    bool
+	}{
+		{`\d+`, "12345", true},
+		{`\d+`, "123a", false},
+		{`\d+`, "a123", false},
+		{`\d+`, "123\n", false},
+		{`a|ab`, "ab", true},
+		{`(a+)(b*)`, "aab", true},
+		{`x*`, "", true},
+		{`x+`, "", false},
+		{`(?<=a)b`, "b", false},
+		{`foo(?=bar)`, "foo", false},
+		{`(?i)hello`, "HELLO", true},
+	}
+
+	for _, tt := range tests {
+		re := pcregexp.MustCompile(tt.pattern)
+
+		if got := re.FullMatchString(tt.input); got != tt.want {
+			t.Errorf("FullMatchString(%q) with pattern %q = %v, want %v", tt.input, tt.pattern, got, tt.want)
+		}
+
+		if got := re.FullMatch([]byte(tt.input)); got != tt.want {
+			t.Errorf("FullMatch(%q) with pattern %q = %v, want %v", tt.input, tt.pattern, got, tt.want)
+		}
+
+		re.Close()
+	}
+}
@@ -0,0 +1,83 @@
+package pcregexp
+
+import "sync"
+
+// GoCompatible returns the compile options under which PCRE2 follows the
+// semantics of the standard library regexp package for the syntax the two
+// share, so that a pattern matches the same text whichever engine runs it:
+//
+//   - [CompileUTF]: patterns and subjects are UTF-8, and . or [^a] match a
+//     character rather than a byte.
+//   - [CompileMatchInvalidUTF]: subjects may hold invalid UTF-8.
+//   - [CompileDollarEndOnly]: $ only matches at the end of the subject, not
+//     before a final newline.
+//   - [CompileAltCircumflex]: ^ in multiline mode also matches after a final
+//     newline.
+//   - [NewlineLF]: only \n is a newline, for ., ^ and $.
+//   - NoJIT, if the JIT of the loaded PCRE2 does not match \D, \S or \W
+//     against non-ASCII characters with PCRE2_MATCH_INVALID_UTF, as in PCRE2
+//     10.42: patterns are interpreted instead.
+//
+// [CompileUCP] is left out: \d, \w, \s, \b and the POSIX classes of the
+// standard library only match ASCII characters, as PCRE2 does without it.
+// Unicode classes such as \pL work in UTF mode alone.
+//
+// The options change how PCRE2 matches, not how PCREgexp iterates over
+// matches or returns them, and PCRE2 cannot match some constructs the way
+// Go does. The differences left are:
+//
+//	Construct                          std regexp                PCREgexp
+//	\s                                 [\t\n\f\r ]               also \v
+//	invalid UTF-8 in the subject       each byte reads as        never part of a
+//	                                   U+FFFD, matched by .      match
+//	empty match right after a match,   skipped                   found
+//	as a* on "baaa" in FindAll,
+//	ReplaceAll and Split
+//	groups after the last one set      -1 or "" up to            left out
+//	in Find*Submatch*                  NumSubexp
+//	$1 in ReplaceAll templates         expanded                  inserted literally
+//	group repeated with an empty last  text of the last          empty
+//	iteration, as ([^a]*)* on "baa"    non-empty iteration
+//	repetition with an empty           goes on with the next     ends the match
+//	iteration, as (?:[^a]*|a)* on      alternative
+//	"bab"
+//
+// Options may be added to the result, for instance [CompileCaseless].
+func GoCompatible() CompileOptions {
+	return CompileOptions{
+		Flags:   CompileUTF | CompileMatchInvalidUTF | CompileDollarEndOnly | CompileAltCircumflex,
+		Newline: NewlineLF,
+		NoJIT:   jitMissesNonASCII(),
+	}
+}
+
+// jitProbe holds the result of jitMissesNonASCII for a library version.
+var jitProbe struct {
+	sync.Mutex
+	version string
+	misses  bool
+}
+
+// jitMissesNonASCII reports whether the JIT of the loaded PCRE2 fails to
+// match \D against a non-ASCII character with PCRE2_MATCH_INVALID_UTF. It
+// returns true if the library cannot be loaded.
+func jitMissesNonASCII() bool {
+	version := Version()
+
+	jitProbe.Lock()
+	defer jitProbe.Unlock()
+
+	if version != "" && version == jitProbe.version {
+		return jitProbe.misses
+	}
+
+	re, err := CompileWithOptions(`\D`, CompileOptions{Flags: CompileUTF | CompileMatchInvalidUTF})
+	if err != nil {
+		return true
+	}
+	defer re.Close()
+
+	jitProbe.version, jitProbe.misses = version, !re.MatchString("é")
+
+	return jitProbe.misses
+}
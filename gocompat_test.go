@@ -0,0 +1,145 @@
+package pcregexp_test
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+// goEngine holds the methods the GoCompatible tests call on both engines.
+type goEngine interface {
+	FindAllStringSubmatchIndex(s string, n int) [][]int
+	FindStringSubmatch(s string) []string
+	ReplaceAllString(src, repl string) string
+}
+
+// goCall calls a method on an engine, and formats its result.
+type goCall func(e goEngine, s string) string
+
+func findAll(e goEngine, s string) string {
+	return fmt.Sprint(e.FindAllStringSubmatchIndex(s, -1))
+}
+
+func findSubmatch(e goEngine, s string) string {
+	return fmt.Sprintf("%q", e.FindStringSubmatch(s))
+}
+
+func replaceAll(e goEngine, s string) string {
+	return e.ReplaceAllString(s, "<$1>")
+}
+
+// compileGoCompatible compiles pattern with both engines.
+func compileGoCompatible(t *testing.T, pattern string) (*regexp.Regexp, *pcregexp.PCREgexp) {
+	t.Helper()
+
+	re, err := pcregexp.CompileWithOptions(pattern, pcregexp.GoCompatible())
+	if err != nil {
+		t.Fatalf("CompileWithOptions(%q, GoCompatible()) error = %v", pattern, err)
+	}
+	t.Cleanup(re.Close)
+
+	return regexp.MustCompile(pattern), re
+}
+
+func TestGoCompatible(t *testing.T) {
+	tests := []struct {
+		name             string
+		pattern, subject string
+	}{
+		{"dollar before final newline", `a$`, "a\n"},
+		{"multiline dollar", `(?m)a$`, "a\na\n"},
+		{"multiline circumflex after final newline", `(?m)^`, "a\n"},
+		{"dot on multi-byte character", `.`, "é\n"},
+		{"negated class on multi-byte character", `[^a]`, "aé"},
+		{"negated types on multi-byte character", `\D\S\W`, "ééé"},
+		{"ASCII digits", `\d+`, "1٣2"},
+		{"ASCII word characters", `\w+`, "héllo"},
+		{"ASCII word boundary", `\bé`, "aé é"},
+		{"ASCII POSIX class", `[[:^alpha:]]+`, "aé1"},
+		{"Unicode class", `\pL+`, "héllo wörld"},
+		{"Unicode script", `\p{Greek}+`, "aαβ"},
+		{"code point escape", `\x{e9}`, "é"},
+		{"caseless multi-byte character", `(?i)é`, "É"},
+		{"caseless Kelvin sign", `(?i)k+`, "kKK"},
+		{"carriage return is no newline", `(?m)a$`, "a\r\na\n"},
+		{"dot and carriage return", `a.`, "a\r"},
+		{"invalid UTF-8 between matches", `a+`, "a\xffa"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			std, re := compileGoCompatible(t, tt.pattern)
+
+			for name, call := range map[string]goCall{"FindAllStringSubmatchIndex": findAll, "FindStringSubmatch": findSubmatch} {
+				if want, got := call(std, tt.subject), call(re, tt.subject); got != want {
+					t.Errorf("%s(%q) on %q = %s, std %s", name, tt.subject, tt.pattern, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestGoCompatible_Differences checks the differences documented by
+// GoCompatible, so that the table is updated when one goes away.
+func TestGoCompatible_Differences(t *testing.T) {
+	tests := []struct {
+		name             string
+		pattern, subject string
+		call             goCall
+		std, pcre        string
+	}{
+		{"\\s and vertical tab", `\s`, "\v", findAll, "[]", "[[0 1]]"},
+		{"invalid UTF-8 in the subject", `.`, "\xff", findAll, "[[0 1]]", "[]"},
+		{"empty match right after a match", `a*`, "baaa", findAll, "[[0 0] [1 4]]", "[[0 0] [1 4] [4 4]]"},
+		{"groups after the last one set", `(a)|(b)`, "a", findSubmatch, `["a" "a" ""]`, `["a" "a"]`},
+		{"templates in ReplaceAll", `(a)`, "xay", replaceAll, "x<a>y", "x<$1>y"},
+		{"group repeated with an empty last iteration", `(?:([^a]*))*`, "baa", findSubmatch, `["b" "b"]`, `["b" ""]`},
+		{"repetition with an empty iteration", `(?:[^a]*|a)*`, "bab", findSubmatch, `["bab"]`, `["b"]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			std, re := compileGoCompatible(t, tt.pattern)
+
+			if got := tt.call(std, tt.subject); got != tt.std {
+				t.Errorf("std on %q with %q = %s, want %s", tt.pattern, tt.subject, got, tt.std)
+			}
+			if got := tt.call(re, tt.subject); got != tt.pcre {
+				t.Errorf("pcre on %q with %q = %s, want %s", tt.pattern, tt.subject, got, tt.pcre)
+			}
+		})
+	}
+}
+
+func TestGoCompatible_NoJIT(t *testing.T) {
+	// The JIT is only turned off where it misses non-ASCII characters.
+	opts := pcregexp.GoCompatible()
+	opts.NoJIT = false
+
+	re, err := pcregexp.CompileWithOptions(`\D\S\W`, opts)
+	if err != nil {
+		t.Fatalf("CompileWithOptions() error = %v", err)
+	}
+	defer re.Close()
+
+	if misses := !re.MatchString("ééé"); pcregexp.GoCompatible().NoJIT != misses {
+		t.Errorf("GoCompatible().NoJIT = %v, want %v", !misses, misses)
+	}
+}
+
+func TestGoCompatible_ExtraFlags(t *testing.T) {
+	opts := pcregexp.GoCompatible()
+	opts.Flags |= pcregexp.CompileCaseless
+
+	re, err := pcregexp.CompileWithOptions(`é$`, opts)
+	if err != nil {
+		t.Fatalf("CompileWithOptions() error = %v", err)
+	}
+	defer re.Close()
+
+	if !re.MatchString("É") || re.MatchString("É\n") {
+		t.Errorf("caseless GoCompatible `é$` does not match like std")
+	}
+}
@@ -0,0 +1,254 @@
+package pcregexp
+
+import "runtime"
+
+// handle owns the PCRE2 objects backing a compiled pattern.
+//
+// It is allocated separately from [PCREgexp] so that a finalizer can always
+// be attached to it, even when the PCREgexp is embedded in another value or
+// overwritten by [PCREgexp.UnmarshalText]. The finalizer frees the PCRE2
+// objects of patterns that are never closed.
+type handle struct {
+	code      uintptr // pointer to compiled pcre2_code
+	matchData uintptr // cached match data
+	ovector   *uint64 // ovector of matchData, which never moves
+	jitStack  uintptr // pointer to JIT stack
+	matchCtx  uintptr // match context the JIT stack is assigned to
+	ctxGen    uint64  // matchCtxGen the limits of matchCtx were copied at
+	size      int64   // compiled size accounted in compiledBytes
+	leakID    uint64  // id of the recorded creation site, if tracked
+	calloutID uintptr // id of the function set with SetCallout, if any
+}
+
+// newHandle wraps code in a handle that frees it once unreachable.
+func newHandle(code uintptr) *handle {
+	h := &handle{code: code}
+	runtime.SetFinalizer(h, (*handle).finalize)
+
+	liveRegexps.Add(1)
+	totalCompiles.Add(1)
+
+	return h
+}
+
+// track accounts the compiled size of h, including JIT code, and records its
+// creation site if leak tracking is enabled. It is called once compilation
+// is complete.
+func (h *handle) track(pattern string) {
+	h.size = int64(patternInfoSize(h.code, infoSize) + patternInfoSize(h.code, infoJITSize))
+	compiledBytes.Add(h.size)
+
+	if leakTracking.Load() {
+		h.leakID = trackLeak(pattern)
+	}
+}
+
+// finalize frees h on behalf of the garbage collector. The creation site of
+// h, if tracked, is kept, since h was never closed.
+func (h *handle) finalize() {
+	if h.code != 0 {
+		totalFinalized.Add(1)
+	}
+
+	if h.leakID != 0 {
+		finalizeLeak(h.leakID)
+		h.leakID = 0
+	}
+
+	h.free()
+}
+
+// free releases the PCRE2 objects owned by h. It is safe to call more than
+// once; later calls are no-ops.
+func (h *handle) free() {
+	runtime.SetFinalizer(h, nil)
+
+	if h.matchData != 0 {
+		pcre2_match_data_free(h.matchData)
+		h.matchData = 0
+		h.ovector = nil
+		liveMatchData.Add(-1)
+	}
+
+	if h.calloutID != 0 {
+		calloutFuncs.Delete(h.calloutID)
+		h.calloutID = 0
+	}
+
+	if h.matchCtx != 0 {
+		pcre2_match_context_free(h.matchCtx)
+		h.matchCtx = 0
+		liveMatchContexts.Add(-1)
+	}
+
+	if h.jitStack != 0 {
+		pcre2_jit_stack_free(h.jitStack)
+		h.jitStack = 0
+		liveJITStacks.Add(-1)
+	}
+
+	if h.code != 0 {
+		pcre2_code_free(h.code)
+		h.code = 0
+
+		liveRegexps.Add(-1)
+		totalFrees.Add(1)
+		compiledBytes.Add(-h.size)
+
+		if h.leakID != 0 {
+			untrackLeak(h.leakID)
+			h.leakID = 0
+		}
+	}
+}
+
+// matchDataPtr returns the match data of h, creating it on first use.
+func (h *handle) matchDataPtr() uintptr {
+	if h.matchData == 0 {
+		h.matchData = pcre2_match_data_create_from_pattern(h.code, 0)
+		if h.matchData != 0 {
+			liveMatchData.Add(1)
+			h.ovector = pcre2_get_ovector_pointer(h.matchData)
+		}
+	}
+
+	return h.matchData
+}
+
+// attachJITStack gives h its own JIT stack and a match context to carry it.
+//
+// A JIT stack must not be shared: assigning it to the global match context
+// would leave that context pointing at freed memory once the pattern that
+// owns the stack is closed or finalized.
+func (h *handle) attachJITStack(startSize, maxSize uint64) {
+	h.jitStack, h.matchCtx = newJITContext(startSize, maxSize)
+	h.ctxGen = 0
+}
+
+// matchContext returns the match context to match with.
+//
+// When h carries its own match context, the limits of the global match
+// context set with [SetMatchContext] are copied into it first, whenever they
+// changed.
+func (h *handle) matchContext() uintptr {
+	if h.matchCtx == 0 {
+		return globalMatchContext()
+	}
+
+	if h.ctxGen != matchCtxGen {
+		applyLimits(h.matchCtx)
+		h.ctxGen = matchCtxGen
+	}
+
+	return h.matchCtx
+}
+
+// matcher returns a matcher using the match data and match context of h.
+// It reports false if the match data cannot be created.
+func (h *handle) matcher(jit bool) (matcher, bool) {
+	md := h.matchDataPtr()
+	if md == 0 || h.ovector == nil {
+		return matcher{}, false
+	}
+
+	return matcher{
+		code:      h.code,
+		jit:       jit,
+		matchData: md,
+		ovector:   h.ovector,
+		matchCtx:  h.matchContext(),
+	}, true
+}
+
+// newMatcher returns a matcher with its own match data and, for JIT compiled
+// patterns, its own JIT stack and match context, which patterns with a
+// callout get as well, so that it can run
+// concurrently with other matchers of h. It must be released with
+// [matcher.free].
+func (h *handle) newMatcher(jit bool) (*matcher, bool) {
+	md := pcre2_match_data_create_from_pattern(h.code, 0)
+	if md == 0 {
+		return nil, false
+	}
+	liveMatchData.Add(1)
+
+	m := &matcher{
+		code:      h.code,
+		jit:       jit,
+		matchData: md,
+		ovector:   pcre2_get_ovector_pointer(md),
+		matchCtx:  globalMatchContext(),
+		owned:     true,
+	}
+
+	// Like attachJITStack, fall back to PCRE2's default JIT stack if a
+	// dedicated one cannot be created.
+	if jit && h.jitStack != 0 {
+		m.jitStack, m.ownCtx = newJITContext(defaultJITStackStartSize, defaultJITStackMaxSize)
+	}
+
+	// The callout, if any, needs a match context too.
+	if m.ownCtx == 0 && h.calloutID != 0 {
+		if m.ownCtx = pcre2_match_context_create(0); m.ownCtx != 0 {
+			liveMatchContexts.Add(1)
+		}
+	}
+
+	if m.ownCtx != 0 {
+		applyLimits(m.ownCtx)
+		h.setCallout(m.ownCtx)
+		m.matchCtx = m.ownCtx
+	}
+
+	return m, true
+}
+
+// newJITContext creates a JIT stack and a match context that carries it. It
+// returns zeros if either cannot be created.
+func newJITContext(startSize, maxSize uint64) (jitStack, matchCtx uintptr) {
+	jitStack = pcre2_jit_stack_create(startSize, maxSize, 0)
+	if jitStack == 0 {
+		return 0, 0
+	}
+	liveJITStacks.Add(1)
+
+	matchCtx = pcre2_match_context_create(0)
+	if matchCtx == 0 {
+		pcre2_jit_stack_free(jitStack)
+		liveJITStacks.Add(-1)
+		return 0, 0
+	}
+	liveMatchContexts.Add(1)
+
+	pcre2_jit_stack_assign(matchCtx, 0, jitStack)
+
+	return jitStack, matchCtx
+}
+
+// globalMatchContext returns the match context set with [SetMatchContext],
+// or 0 if there is none.
+func globalMatchContext() uintptr {
+	if defaultMatchCtx != nil {
+		return defaultMatchCtx.ptr
+	}
+
+	return 0
+}
+
+// applyLimits copies the limits of the global match context into ctx, or the
+// library defaults for the limits it does not set.
+func applyLimits(ctx uintptr) {
+	matchLimit, depthLimit := libConfig.MatchLimit, libConfig.DepthLimit
+	if defaultMatchCtx != nil {
+		if defaultMatchCtx.MatchLimit > 0 {
+			matchLimit = defaultMatchCtx.MatchLimit
+		}
+
+		if defaultMatchCtx.DepthLimit > 0 {
+			depthLimit = defaultMatchCtx.DepthLimit
+		}
+	}
+
+	pcre2_set_match_limit(ctx, matchLimit)
+	pcre2_set_depth_limit(ctx, depthLimit)
+}
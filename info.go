@@ -0,0 +1,75 @@
+package pcregexp
+
+import "unsafe"
+
+// pcre2_pattern_info() "what" values.
+// Ref: https://pcre2project.github.io/pcre2/doc/pcre2_pattern_info/
+const (
+	infoCaptureCount  = 4
+	infoJITSize       = 10
+	infoNameCount     = 17
+	infoNameEntrySize = 18
+	infoNameTable     = 19
+	infoSize          = 22
+)
+
+// patternInfoSize returns the size_t pattern information what for code, or 0
+// if it is unavailable.
+func patternInfoSize(code uintptr, what uint32) uint64 {
+	var v uint64
+	if pcre2_pattern_info(code, what, ptr(&v)) != 0 {
+		return 0
+	}
+
+	return v
+}
+
+// patternInfoUint32 returns the uint32_t pattern information what for code,
+// or 0 if it is unavailable.
+func patternInfoUint32(code uintptr, what uint32) uint32 {
+	var v uint32
+	if pcre2_pattern_info(code, what, ptr(&v)) != 0 {
+		return 0
+	}
+
+	return v
+}
+
+// subexpNames returns the names of the capture groups of code, indexed by
+// group number, as read from the name table. Index 0, the whole match, and
+// unnamed groups have an empty name.
+func subexpNames(code uintptr) []string {
+	names := make([]string, patternInfoUint32(code, infoCaptureCount)+1)
+
+	count := patternInfoUint32(code, infoNameCount)
+	entrySize := patternInfoUint32(code, infoNameEntrySize)
+
+	var table *byte
+	if count == 0 || pcre2_pattern_info(code, infoNameTable, ptr(&table)) != 0 || table == nil {
+		return names
+	}
+
+	// Each entry holds the group number in two bytes, most significant
+	// first, followed by the zero-terminated name, padded to entrySize.
+	entries := unsafe.Slice(table, int(count*entrySize))
+	for i := 0; i < int(count); i++ {
+		entry := entries[i*int(entrySize) : (i+1)*int(entrySize)]
+
+		group := int(entry[0])<<8 | int(entry[1])
+		name := entry[2:]
+		for j, c := range name {
+			if c == 0 {
+				name = name[:j]
+				break
+			}
+		}
+
+		// Entries are sorted by name, so with duplicate names the first
+		// entry for a group is kept.
+		if group < len(names) && names[group] == "" {
+			names[group] = string(name)
+		}
+	}
+
+	return names
+}
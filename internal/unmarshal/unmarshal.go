@@ -0,0 +1,84 @@
+// Package unmarshal converts the text of regexp matches to the types of
+// struct fields, for PCREgexp.Unmarshal and the grok package.
+package unmarshal
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Text converts text to the type of f and stores it in f.
+//
+// f may be a string, byte slice, integer, floating-point number, boolean (as
+// parsed by [strconv.ParseBool]), [time.Duration] (as parsed by
+// [time.ParseDuration]), a type implementing [encoding.TextUnmarshaler], or a
+// pointer to any of these.
+func Text(f reflect.Value, text string) error {
+	if f.Kind() == reflect.Pointer {
+		if f.IsNil() {
+			f.Set(reflect.New(f.Type().Elem()))
+		}
+
+		return Text(f.Elem(), text)
+	}
+
+	if reflect.PointerTo(f.Type()).Implements(textUnmarshalerType) {
+		return f.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text))
+	}
+
+	if f.Type() == durationType {
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(d))
+
+		return nil
+	}
+
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(text)
+	case reflect.Slice:
+		if f.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported type %s", f.Type())
+		}
+		f.SetBytes([]byte(text))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(text, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(text, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(text, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", f.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,342 @@
+package pcregexp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ebitengine/purego"
+)
+
+// EnvLibraryPath is the name of the environment variable that overrides the
+// PCRE2 shared library discovery. When it is set, only the path it holds is
+// attempted.
+const EnvLibraryPath = "PCREGEXP_LIBRARY"
+
+var (
+	// libMu serializes loading of the PCRE2 shared library.
+	libMu sync.Mutex
+
+	// libLoaded reports whether the PCRE2 functions have been registered.
+	libLoaded atomic.Bool
+
+	// libErr holds the error of the last failed automatic load.
+	libErr error
+
+	// libPath is the path of the currently loaded PCRE2 shared library.
+	libPath string
+)
+
+// LoadAttempt records a single attempt to load the PCRE2 shared library.
+type LoadAttempt struct {
+	// Path is the path (or bare file name) passed to the dynamic loader.
+	Path string
+
+	// Err is the error reported by the dynamic loader, or the error listing
+	// the required PCRE2 functions the library does not export.
+	Err error
+}
+
+// LoadError is returned when the PCRE2 shared library could not be loaded
+// from any of the candidate paths.
+type LoadError struct {
+	// Attempts lists every path that was tried, in order.
+	Attempts []LoadAttempt
+}
+
+// Error implements the error interface.
+func (e *LoadError) Error() string {
+	var b strings.Builder
+
+	b.WriteString("pcregexp: could not load the PCRE2 library")
+	if len(e.Attempts) == 0 {
+		b.WriteString(": no candidate paths")
+		return b.String()
+	}
+
+	b.WriteString(", tried:")
+	for _, a := range e.Attempts {
+		fmt.Fprintf(&b, "\n\t%s: %v", a.Path, a.Err)
+	}
+
+	return b.String()
+}
+
+// libraryNames returns the file names the PCRE2 8-bit library is known to be
+// installed under on the current GOOS, unversioned names first.
+func libraryNames() ([]string, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"libpcre2-8.dylib", "libpcre2-8.0.dylib"}, nil
+	case "linux", "freebsd":
+		return []string{"libpcre2-8.so", "libpcre2-8.so.0"}, nil
+	case "windows":
+		return []string{"pcre2-8.dll", "libpcre2-8.dll", "libpcre2-8-0.dll"}, nil
+	default:
+		return nil, fmt.Errorf("GOOS=%s is not supported", runtime.GOOS)
+	}
+}
+
+// multiarchTriplets maps GOARCH to the Debian multiarch directory name.
+var multiarchTriplets = map[string]string{
+	"386":     "i386-linux-gnu",
+	"amd64":   "x86_64-linux-gnu",
+	"arm":     "arm-linux-gnueabihf",
+	"arm64":   "aarch64-linux-gnu",
+	"ppc64le": "powerpc64le-linux-gnu",
+	"riscv64": "riscv64-linux-gnu",
+	"s390x":   "s390x-linux-gnu",
+}
+
+// librarySearchDirs returns the directories searched after the dynamic
+// loader's own search path has failed.
+func librarySearchDirs() []string {
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"/opt/homebrew/lib", "/usr/local/lib", "/opt/local/lib", "/usr/lib"}
+	case "freebsd":
+		return []string{"/usr/local/lib", "/usr/lib"}
+	case "linux":
+		dirs := []string{"/usr/local/lib"}
+		if triplet, ok := multiarchTriplets[runtime.GOARCH]; ok {
+			dirs = append(dirs, "/usr/lib/"+triplet, "/lib/"+triplet)
+		}
+
+		return append(dirs, "/usr/lib64", "/lib64", "/usr/lib", "/lib")
+	default:
+		// LoadLibrary already searches the application directory and PATH.
+		return nil
+	}
+}
+
+// libraryCandidates returns every path that is tried, in order, when no
+// explicit library path has been configured.
+func libraryCandidates() ([]string, error) {
+	names, err := libraryNames()
+	if err != nil {
+		return nil, err
+	}
+
+	// Bare names first, so the dynamic loader's search path (LD_LIBRARY_PATH,
+	// DYLD_LIBRARY_PATH, ld.so.cache, PATH, ...) takes precedence.
+	candidates := append([]string(nil), names...)
+	for _, dir := range librarySearchDirs() {
+		for _, name := range names {
+			candidates = append(candidates, filepath.Join(dir, name))
+		}
+	}
+
+	return candidates, nil
+}
+
+// loadLibrary loads the PCRE2 shared library from the first of paths that
+// both loads and exports every required function, and registers its
+// functions. If paths is empty, the [EnvLibraryPath] environment variable is
+// honored, falling back to [libraryCandidates].
+//
+// A library that is too old does not end the search, so that it cannot hide
+// a newer one found later.
+func loadLibrary(paths ...string) (string, error) {
+	if len(paths) == 0 {
+		if path := os.Getenv(EnvLibraryPath); path != "" {
+			paths = []string{path}
+		} else {
+			var err error
+			if paths, err = libraryCandidates(); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	loadErr := &LoadError{}
+	for _, path := range paths {
+		lib, err := openLibrary(path)
+		if err == nil {
+			err = registerFunctions(lib)
+		}
+		if err == nil {
+			return path, nil
+		}
+
+		loadErr.Attempts = append(loadErr.Attempts, LoadAttempt{Path: path, Err: err})
+	}
+
+	return "", loadErr
+}
+
+// libFunc associates a function variable with the PCRE2 symbol it binds to.
+// A *uintptr receives the address of the symbol instead.
+type libFunc struct {
+	fptr   any
+	symbol string
+}
+
+// requiredFuncs lists the PCRE2 functions every supported release exports.
+//
+// The symbols are the 8-bit versions, hence suffixed with "_8".
+var requiredFuncs = []libFunc{
+	{&pcre2_config, "pcre2_config_8"},
+	{&pcre2_compile, "pcre2_compile_8"},
+	{&pcre2_get_error_message, "pcre2_get_error_message_8"},
+	{&pcre2_compile_context_create, "pcre2_compile_context_create_8"},
+	{&pcre2_compile_context_free, "pcre2_compile_context_free_8"},
+	{&pcre2_set_compile_extra_options, "pcre2_set_compile_extra_options_8"},
+	{&pcre2_set_newline, "pcre2_set_newline_8"},
+	{&pcre2_set_parens_nest_limit, "pcre2_set_parens_nest_limit_8"},
+	{&pcre2_code_free, "pcre2_code_free_8"},
+	{&pcre2_pattern_info, "pcre2_pattern_info_8"},
+	{&pcre2_match, "pcre2_match_8"},
+	{&pcre2_match_addr, "pcre2_match_8"},
+	{&pcre2_match_data_create_from_pattern, "pcre2_match_data_create_from_pattern_8"},
+	{&pcre2_match_data_free, "pcre2_match_data_free_8"},
+	{&pcre2_get_ovector_pointer, "pcre2_get_ovector_pointer_8"},
+	{&pcre2_get_mark, "pcre2_get_mark_8"},
+	{&pcre2_substitute, "pcre2_substitute_8"},
+	// JIT-related functions
+	{&pcre2_jit_compile, "pcre2_jit_compile_8"},
+	{&pcre2_jit_match, "pcre2_jit_match_8"},
+	{&pcre2_jit_match_addr, "pcre2_jit_match_8"},
+	{&pcre2_jit_stack_create, "pcre2_jit_stack_create_8"},
+	{&pcre2_jit_stack_free, "pcre2_jit_stack_free_8"},
+	{&pcre2_jit_stack_assign, "pcre2_jit_stack_assign_8"},
+	// Match context functions
+	{&pcre2_match_context_create, "pcre2_match_context_create_8"},
+	{&pcre2_match_context_free, "pcre2_match_context_free_8"},
+	// {&pcre2_set_offset_limit, "pcre2_set_offset_limit_8"},
+	// {&pcre2_set_heap_limit, "pcre2_set_heap_limit_8"},
+	{&pcre2_set_match_limit, "pcre2_set_match_limit_8"},
+	{&pcre2_set_depth_limit, "pcre2_set_depth_limit_8"},
+	{&pcre2_set_callout, "pcre2_set_callout_8"},
+}
+
+// optionalFuncs lists the PCRE2 functions that are bound only if the loaded
+// library exports them, keyed by the [Feature] they provide.
+var optionalFuncs = map[Feature]libFunc{
+	FeatureNextMatch:             {&pcre2_next_match, "pcre2_next_match_8"},
+	FeatureMaxVarLookbehind:      {&pcre2_set_max_varlookbehind, "pcre2_set_max_varlookbehind_8"},
+	FeatureSubstituteCaseCallout: {&pcre2_set_substitute_case_callout, "pcre2_set_substitute_case_callout_8"},
+}
+
+// registerFunctions binds the PCRE2 functions exported by lib and reads its
+// build configuration.
+//
+// Nothing is bound unless lib exports every required function, so a library
+// that is too old leaves the current bindings intact.
+func registerFunctions(lib uintptr) error {
+	addrs := make([]uintptr, len(requiredFuncs))
+
+	var missing []string
+	for i, f := range requiredFuncs {
+		addr, err := lookupSymbol(lib, f.symbol)
+		if err != nil || addr == 0 {
+			missing = append(missing, f.symbol)
+			continue
+		}
+
+		addrs[i] = addr
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required symbols: %s", strings.Join(missing, ", "))
+	}
+
+	for i, f := range requiredFuncs {
+		if p, ok := f.fptr.(*uintptr); ok {
+			*p = addrs[i]
+			continue
+		}
+
+		purego.RegisterFunc(f.fptr, addrs[i])
+	}
+
+	features := make(map[Feature]bool, len(optionalFuncs))
+	for feature, f := range optionalFuncs {
+		addr, err := lookupSymbol(lib, f.symbol)
+		if err != nil || addr == 0 {
+			// Reset a binding left over from a previously loaded library.
+			reflect.ValueOf(f.fptr).Elem().SetZero()
+			continue
+		}
+
+		purego.RegisterFunc(f.fptr, addr)
+		features[feature] = true
+	}
+
+	libConfig = readConfig()
+	libFeatures = features
+
+	return nil
+}
+
+// Init loads the PCRE2 shared library if it has not been loaded yet, using
+// [EnvLibraryPath] or the default search.
+//
+// Calling Init is optional: the library is loaded on the first call that
+// needs it, such as [Compile]. A failed load is remembered and returned by
+// subsequent calls, unless [SetLibraryPath] succeeds in the meantime.
+func Init() error {
+	if libLoaded.Load() {
+		return nil
+	}
+
+	libMu.Lock()
+	defer libMu.Unlock()
+
+	if libLoaded.Load() {
+		return nil
+	}
+
+	if libErr != nil {
+		return libErr
+	}
+
+	path, err := loadLibrary()
+	if err != nil {
+		libErr = err
+		return err
+	}
+
+	libPath = path
+	libLoaded.Store(true)
+
+	return nil
+}
+
+// Available reports whether the PCRE2 shared library is loaded, loading it
+// first if needed.
+func Available() bool {
+	return Init() == nil
+}
+
+// SetLibraryPath loads the PCRE2 shared library from path, replacing the one
+// loaded previously. It takes precedence over [EnvLibraryPath] and the default
+// search, and must be called before any pattern is compiled.
+func SetLibraryPath(path string) error {
+	libMu.Lock()
+	defer libMu.Unlock()
+
+	path, err := loadLibrary(path)
+	if err != nil {
+		return err
+	}
+
+	libPath = path
+	libErr = nil
+	libLoaded.Store(true)
+
+	return nil
+}
+
+// LibraryPath returns the path the PCRE2 shared library was loaded from, or
+// an empty string if it has not been loaded yet.
+func LibraryPath() string {
+	libMu.Lock()
+	defer libMu.Unlock()
+
+	return libPath
+}
@@ -0,0 +1,111 @@
+package pcregexp_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+func TestSetLibraryPath(t *testing.T) {
+	if err := pcregexp.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	loaded := pcregexp.LibraryPath()
+	if loaded == "" {
+		t.Fatal("LibraryPath() is empty after Init")
+	}
+
+	t.Run("invalid path", func(t *testing.T) {
+		path := "/nonexistent/libpcre2-8.so"
+		err := pcregexp.SetLibraryPath(path)
+
+		var loadErr *pcregexp.LoadError
+		if !errors.As(err, &loadErr) {
+			t.Fatalf("SetLibraryPath(%q) error = %v, want *LoadError", path, err)
+		}
+		if len(loadErr.Attempts) != 1 || loadErr.Attempts[0].Path != path {
+			t.Errorf("LoadError.Attempts = %v, want a single attempt for %q", loadErr.Attempts, path)
+		}
+		if !strings.Contains(err.Error(), path) {
+			t.Errorf("error %q does not mention %q", err, path)
+		}
+		if got := pcregexp.LibraryPath(); got != loaded {
+			t.Errorf("LibraryPath() = %q after failed load, want %q", got, loaded)
+		}
+	})
+
+	t.Run("missing symbols", func(t *testing.T) {
+		if runtime.GOOS != "linux" {
+			t.Skip("libc.so.6 is specific to linux")
+		}
+
+		path := "libc.so.6"
+		err := pcregexp.SetLibraryPath(path)
+
+		var loadErr *pcregexp.LoadError
+		if !errors.As(err, &loadErr) {
+			t.Fatalf("SetLibraryPath(%q) error = %v, want *LoadError", path, err)
+		}
+		if len(loadErr.Attempts) != 1 || !strings.Contains(fmt.Sprint(loadErr.Attempts[0].Err), "pcre2_compile_8") {
+			t.Errorf("LoadError.Attempts = %v, want a single attempt missing pcre2_compile_8", loadErr.Attempts)
+		}
+		if got := pcregexp.LibraryPath(); got != loaded {
+			t.Errorf("LibraryPath() = %q after failed load, want %q", got, loaded)
+		}
+	})
+
+	t.Run("reload", func(t *testing.T) {
+		if err := pcregexp.SetLibraryPath(loaded); err != nil {
+			t.Fatalf("SetLibraryPath(%q) error = %v", loaded, err)
+		}
+
+		re := pcregexp.MustCompile(`p([a-z]+)ch`)
+		defer re.Close()
+
+		if !re.MatchString("peach") {
+			t.Error("MatchString() = false after reload, want true")
+		}
+	})
+}
+
+func TestEnvLibraryPath(t *testing.T) {
+	path := "/nonexistent/libpcre2-8.so.0"
+
+	if os.Getenv("PCREGEXP_TEST_ENV_CHILD") == "1" {
+		if pcregexp.Available() {
+			t.Fatalf("Available() = true with %s=%s", pcregexp.EnvLibraryPath, path)
+		}
+
+		if err := pcregexp.Init(); err == nil || !strings.Contains(err.Error(), path) {
+			t.Errorf("Init() error = %v, want an error mentioning %q", err, path)
+		}
+
+		if _, err := pcregexp.Compile(`a+`); err == nil {
+			t.Error("Compile() error = nil without the library")
+		}
+
+		// The empty pattern never reaches PCRE2.
+		re, err := pcregexp.Compile("")
+		if err != nil {
+			t.Fatalf("Compile(\"\") error = %v", err)
+		}
+		if re.MatchString("any") {
+			t.Error("MatchString() = true for the empty pattern")
+		}
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestEnvLibraryPath$", "-test.v")
+	cmd.Env = append(os.Environ(), "PCREGEXP_TEST_ENV_CHILD=1", pcregexp.EnvLibraryPath+"="+path)
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("child process failed: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,35 @@
+package pcregexp
+
+import "runtime"
+
+// FindIndexMarkAt is like [PCREgexp.FindIndexAt], and also returns the name
+// of the last (*MARK:NAME) verb passed on the matching path, or an empty
+// string if there is none. Both are zero if there is no match.
+//
+// Tagging the alternatives of a pattern with marks, as in
+// `\d+(*:num)|\w+(*:word)`, tells which one matched without capture groups.
+func (re *PCREgexp) FindIndexMarkAt(b []byte, off int, flags MatchFlag) (loc []int, mark string) {
+	if off < 0 || off > len(b) || re.h == nil || re.h.code == 0 {
+		return nil, ""
+	}
+
+	defer runtime.KeepAlive(re.h)
+
+	m, ok := re.h.matcher(re.isJIT)
+	if !ok {
+		return nil, ""
+	}
+
+	indexes, ok := m.exec(re.buf[:0], b, off, uint32(flags))
+	if !ok {
+		return nil, ""
+	}
+	re.buf = indexes
+
+	return []int{indexes[0], indexes[1]}, m.mark()
+}
+
+// FindStringIndexMarkAt is like [PCREgexp.FindIndexMarkAt] but for a string.
+func (re *PCREgexp) FindStringIndexMarkAt(s string, off int, flags MatchFlag) (loc []int, mark string) {
+	return re.FindIndexMarkAt(string2BytesUnsafe(s), off, flags)
+}
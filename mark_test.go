@@ -0,0 +1,42 @@
+package pcregexp_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+func TestRegexp_FindStringIndexMarkAt(t *testing.T) {
+	re := pcregexp.MustCompile(`\d+(*:num)|[a-z]+(*:word)|(*:op)[-+*/]|\s+`)
+	defer re.Close()
+
+	tests := []struct {
+		input    string
+		off      int
+		flags    pcregexp.MatchFlag
+		wantLoc  []int
+		wantMark string
+	}{
+		{"abc + 42", 0, pcregexp.MatchAnchored, []int{0, 3}, "word"},
+		{"abc + 42", 3, pcregexp.MatchAnchored, []int{3, 4}, ""},
+		{"abc + 42", 4, pcregexp.MatchAnchored, []int{4, 5}, "op"},
+		{"abc + 42", 5, 0, []int{5, 6}, ""},
+		{"abc + 42", 6, 0, []int{6, 8}, "num"},
+		{"abc + 42", 8, 0, nil, ""},
+		{"abc ! 42", 4, pcregexp.MatchAnchored, nil, ""},
+		{"abc", 9, 0, nil, ""},
+	}
+
+	for _, tt := range tests {
+		loc, mark := re.FindStringIndexMarkAt(tt.input, tt.off, tt.flags)
+		if !reflect.DeepEqual(loc, tt.wantLoc) || mark != tt.wantMark {
+			t.Errorf("FindStringIndexMarkAt(%q, %d, %#x) = %v, %q, want %v, %q", tt.input, tt.off, tt.flags, loc, mark, tt.wantLoc, tt.wantMark)
+		}
+
+		loc, mark = re.FindIndexMarkAt([]byte(tt.input), tt.off, tt.flags)
+		if !reflect.DeepEqual(loc, tt.wantLoc) || mark != tt.wantMark {
+			t.Errorf("FindIndexMarkAt(%q, %d, %#x) = %v, %q, want %v, %q", tt.input, tt.off, tt.flags, loc, mark, tt.wantLoc, tt.wantMark)
+		}
+	}
+}
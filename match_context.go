@@ -22,17 +22,26 @@ type MatchContext struct {
 	ptr uintptr
 }
 
-// Default match context used by all regex operations unless overridden
-var defaultMatchCtx *MatchContext
+var (
+	// Default match context used by all regex operations unless overridden
+	defaultMatchCtx *MatchContext
+
+	// matchCtxGen is incremented whenever the default match context changes,
+	// so that per-pattern match contexts know to copy its limits again.
+	matchCtxGen uint64 = 1
+)
 
 // SetMatchContext sets the global match context used by all regex operations.
 //
 // This is useful to globally limit regex matching complexity to prevent ReDoS
 // attacks. If the context is not set, no limits are enforced.
 func SetMatchContext(ctx MatchContext) error {
+	matchCtxGen++
+
 	if defaultMatchCtx != nil {
 		// Free the old context
 		pcre2_match_context_free(defaultMatchCtx.ptr)
+		liveMatchContexts.Add(-1)
 		defaultMatchCtx = nil
 	}
 
@@ -40,14 +49,20 @@ func SetMatchContext(ctx MatchContext) error {
 		return nil
 	}
 
+	if err := Init(); err != nil {
+		return err
+	}
+
 	ctx.ptr = pcre2_match_context_create(0)
 	if ctx.ptr == 0 {
 		return fmt.Errorf("could not create match context")
 	}
+	liveMatchContexts.Add(1)
 
 	if ctx.MatchLimit > 0 {
 		if result := pcre2_set_match_limit(ctx.ptr, ctx.MatchLimit); result != 0 {
 			pcre2_match_context_free(ctx.ptr)
+			liveMatchContexts.Add(-1)
 			return fmt.Errorf("could not set match limit, error code: %d", result)
 		}
 	}
@@ -55,6 +70,7 @@ func SetMatchContext(ctx MatchContext) error {
 	if ctx.DepthLimit > 0 {
 		if result := pcre2_set_depth_limit(ctx.ptr, ctx.DepthLimit); result != 0 {
 			pcre2_match_context_free(ctx.ptr)
+			liveMatchContexts.Add(-1)
 			return fmt.Errorf("could not set recursion limit, error code: %d", result)
 		}
 	}
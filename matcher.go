@@ -0,0 +1,134 @@
+package pcregexp
+
+import (
+	"unsafe"
+
+	"github.com/ebitengine/purego"
+)
+
+// jitMatchOptions are the match options supported by pcre2_jit_match:
+// PCRE2_NOTBOL, PCRE2_NOTEOL, PCRE2_NOTEMPTY and PCRE2_NOTEMPTY_ATSTART.
+// Partial matching is left to pcre2_match, since pcre2_jit_match fails
+// unless the pattern was JIT compiled for the same partial mode, while
+// pcre2_match falls back to the interpreter.
+// Ref: https://pcre2project.github.io/pcre2/doc/pcre2_jit_match/
+const jitMatchOptions = 0x1 | 0x2 | 0x4 | 0x8
+
+const (
+	// errorNoMatch is PCRE2_ERROR_NOMATCH, returned when there is no match.
+	errorNoMatch = -1
+
+	// errorPartial is PCRE2_ERROR_PARTIAL, returned for a partial match.
+	errorPartial = -2
+)
+
+// emptySubject backs the subject pointer of empty subjects, which older PCRE2
+// releases reject when NULL.
+var emptySubject [1]byte
+
+// matcher holds the PCRE2 objects needed to match a compiled pattern,
+// resolved once so that they are not looked up again for every subject.
+//
+// A matcher must not be used concurrently: its match data and JIT stack are
+// written by every match.
+type matcher struct {
+	code      uintptr // compiled pattern, owned by the handle
+	jit       bool    // whether code has been JIT compiled
+	matchData uintptr
+	ovector   *uint64 // ovector of matchData
+	matchCtx  uintptr // match context to pass, possibly ownCtx
+	buf       []int   // scratch ovector copy
+
+	// Objects created for this matcher only, see [handle.newMatcher].
+	owned    bool
+	jitStack uintptr
+	ownCtx   uintptr
+}
+
+// exec matches subject starting at offset with the PCRE2 match options and
+// appends the ovector of the match to dst. It reports whether there was a
+// match; if not, dst is returned unchanged.
+//
+// Callers must keep the handle the matcher was created from alive.
+func (m *matcher) exec(dst []int, subject []byte, offset int, options uint32) ([]int, bool) {
+	ret := m.run(subject, offset, options)
+	if ret < 0 {
+		return dst, false
+	}
+
+	return m.appendOvector(dst, int(ret)), true
+}
+
+// run matches subject starting at offset with the PCRE2 match options and
+// returns the result of pcre2_match: the number of captured pairs in the
+// ovector, or a negative error code such as [errorPartial].
+func (m *matcher) run(subject []byte, offset int, options uint32) int32 {
+	subjectPtr := &emptySubject[0]
+	if len(subject) > 0 {
+		subjectPtr = &subject[0]
+	}
+
+	fn := pcre2_match_addr
+	if m.jit && options&^jitMatchOptions == 0 {
+		fn = pcre2_jit_match_addr
+	}
+
+	r1, _, _ := purego.SyscallN(fn, m.code, uintptr(ptr(subjectPtr)), uintptr(len(subject)),
+		uintptr(offset), uintptr(options), m.matchData, m.matchCtx)
+
+	return int32(r1)
+}
+
+// appendOvector appends the first n offset pairs of the ovector to dst.
+func (m *matcher) appendOvector(dst []int, n int) []int {
+	// Unset groups are PCRE2_UNSET (~0), which converts to -1.
+	for _, v := range unsafe.Slice(m.ovector, 2*n) {
+		dst = append(dst, int(v))
+	}
+
+	return dst
+}
+
+// mark returns the name of the last (*MARK) passed by the last match, or an
+// empty string if there is none.
+func (m *matcher) mark() string {
+	p := pcre2_get_mark(m.matchData)
+	if p == nil {
+		return ""
+	}
+
+	// The name is NUL-terminated and lives in the compiled pattern.
+	n := 0
+	for *(*uint8)(unsafe.Add(unsafe.Pointer(p), n)) != 0 {
+		n++
+	}
+
+	return string(unsafe.Slice(p, n))
+}
+
+// free releases the objects created for m by [handle.newMatcher]. It is a
+// no-op for matchers borrowing the objects of their handle.
+func (m *matcher) free() {
+	if !m.owned {
+		return
+	}
+
+	if m.matchData != 0 {
+		pcre2_match_data_free(m.matchData)
+		m.matchData = 0
+		m.ovector = nil
+		liveMatchData.Add(-1)
+	}
+
+	if m.ownCtx != 0 {
+		pcre2_match_context_free(m.ownCtx)
+		m.ownCtx = 0
+		liveMatchContexts.Add(-1)
+	}
+
+	if m.jitStack != 0 {
+		pcre2_jit_stack_free(m.jitStack)
+		m.jitStack = 0
+		liveJITStacks.Add(-1)
+	}
+}
@@ -0,0 +1,164 @@
+package pcregexp
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/dwisiswant0/pcregexp/internal/unmarshal"
+)
+
+// ErrNoMatch is returned by [PCREgexp.Unmarshal] when the regexp does not
+// match.
+var ErrNoMatch = errors.New("pcregexp: no match")
+
+// Groups is the leftmost match of a regexp in a string, giving access to its
+// subexpressions by name. See [PCREgexp.FindStringGroups].
+type Groups struct {
+	subject string
+	loc     []int
+	names   []string
+}
+
+// FindStringGroups returns the leftmost match in s, or nil if there is none.
+func (re *PCREgexp) FindStringGroups(s string) *Groups {
+	loc := re.match(nil, string2BytesUnsafe(s))
+	if loc == nil {
+		return nil
+	}
+
+	return &Groups{subject: s, loc: loc, names: re.names}
+}
+
+// String returns the text of the whole match.
+func (g *Groups) String() string {
+	return g.subject[g.loc[0]:g.loc[1]]
+}
+
+// Group returns the text matched by the subexpression called name. It returns
+// an empty string if there is no such subexpression or it did not take part
+// in the match; use [Groups.Lookup] to tell these apart.
+func (g *Groups) Group(name string) string {
+	text, _ := g.Lookup(name)
+	return text
+}
+
+// Lookup returns the text matched by the subexpression called name, and
+// whether it took part in the match. If several subexpressions share the
+// name, the first one that took part is used.
+func (g *Groups) Lookup(name string) (string, bool) {
+	return lookupGroup(g.subject, g.loc, g.names, name)
+}
+
+// lookupGroup returns the text of the first subexpression called name that
+// is set in loc.
+func lookupGroup(s string, loc []int, names []string, name string) (string, bool) {
+	if name == "" {
+		return "", false
+	}
+
+	for i, n := range names {
+		if n == name && 2*i+1 < len(loc) && loc[2*i] >= 0 {
+			return s[loc[2*i]:loc[2*i+1]], true
+		}
+	}
+
+	return "", false
+}
+
+// FindStringSubmatchMap returns the text of the named subexpressions of the
+// leftmost match in s, keyed by name, or nil if there is no match.
+//
+// Every named subexpression has an entry; those that did not take part in
+// the match map to an empty string.
+func (re *PCREgexp) FindStringSubmatchMap(s string) map[string]string {
+	loc := re.scratch(string2BytesUnsafe(s))
+	if loc == nil {
+		return nil
+	}
+
+	return re.submatchMap(s, loc)
+}
+
+// FindAllStringSubmatchMap is like [PCREgexp.FindStringSubmatchMap] but
+// returns successive matches, at most n of them if n >= 0, as found by
+// [PCREgexp.FindAllStringSubmatch].
+func (re *PCREgexp) FindAllStringSubmatchMap(s string, n int) []map[string]string {
+	var results []map[string]string
+	re.forEachMatch(string2BytesUnsafe(s), n, func(loc []int) {
+		results = append(results, re.submatchMap(s, loc))
+	})
+
+	return results
+}
+
+// submatchMap returns the text of the named subexpressions in loc.
+func (re *PCREgexp) submatchMap(s string, loc []int) map[string]string {
+	m := make(map[string]string)
+	for _, name := range re.names {
+		if name == "" {
+			continue
+		}
+
+		if _, ok := m[name]; !ok {
+			m[name], _ = lookupGroup(s, loc, re.names, name)
+		}
+	}
+
+	return m
+}
+
+// Unmarshal matches s and stores the text of the named subexpressions of the
+// leftmost match in the fields of the struct v points to. A field receives
+// the subexpression named by its `pcre:"name"` tag; untagged fields and
+// fields tagged "-" are left alone, as are fields whose subexpression did not
+// take part in the match.
+//
+// Fields may be strings, byte slices, integers, floating-point numbers,
+// booleans (as parsed by [strconv.ParseBool]), [time.Duration] values (as
+// parsed by [time.ParseDuration]), types implementing
+// [encoding.TextUnmarshaler], or pointers to any of these.
+//
+// Unmarshal returns [ErrNoMatch] if the regexp does not match s, and an error
+// if a tag names an unknown subexpression or a value cannot be converted.
+func (re *PCREgexp) Unmarshal(s string, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("pcregexp: Unmarshal needs a non-nil pointer to a struct, got %T", v)
+	}
+
+	loc := re.scratch(string2BytesUnsafe(s))
+	if loc == nil {
+		return ErrNoMatch
+	}
+
+	rv = rv.Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+
+		name, ok := field.Tag.Lookup("pcre")
+		if !ok || name == "-" {
+			continue
+		}
+
+		if re.SubexpIndex(name) < 0 {
+			return fmt.Errorf("pcregexp: field %s: pattern has no subexpression named %q", field.Name, name)
+		}
+
+		if !field.IsExported() {
+			return fmt.Errorf("pcregexp: field %s: cannot set unexported field", field.Name)
+		}
+
+		text, ok := lookupGroup(s, loc, re.names, name)
+		if !ok {
+			continue
+		}
+
+		if err := unmarshal.Text(rv.Field(i), text); err != nil {
+			return fmt.Errorf("pcregexp: field %s: cannot unmarshal %q: %w", field.Name, text, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package pcregexp_test
+
+import (
+	"errors"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+func TestRegexp_SubexpNames(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{``, []string{""}},
+		{`abc`, []string{""}},
+		{`(a)(?<second>b)(c)`, []string{"", "", "second", ""}},
+		{`(?<year>\d{4})-(?P<month>\d{2})-(?'day'\d{2})`, []string{"", "year", "month", "day"}},
+		{`(?J)(?<n>a)|(?<n>b)`, []string{"", "n", "n"}},
+		{`(?:a)(?=b)`, []string{""}},
+	}
+
+	for _, tt := range tests {
+		re := pcregexp.MustCompile(tt.pattern)
+
+		got := re.SubexpNames()
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SubexpNames() with pattern %q = %q, want %q", tt.pattern, got, tt.want)
+		}
+		if got, want := re.NumSubexp(), len(tt.want)-1; got != want {
+			t.Errorf("NumSubexp() with pattern %q = %d, want %d", tt.pattern, got, want)
+		}
+
+		got[0] = "modified"
+		if re.SubexpNames()[0] != "" {
+			t.Errorf("SubexpNames() returned internal storage")
+		}
+
+		re.Close()
+	}
+}
+
+func TestRegexp_SubexpIndex(t *testing.T) {
+	re := pcregexp.MustCompile(`(?J)(?<year>\d{4})-(?<month>\d{2})|(?<year>\d{2})`)
+	defer re.Close()
+
+	for name, want := range map[string]int{"year": 1, "month": 2, "day": -1, "": -1} {
+		if got := re.SubexpIndex(name); got != want {
+			t.Errorf("SubexpIndex(%q) = %d, want %d", name, got, want)
+		}
+	}
+}
+
+func TestRegexp_FindStringSubmatchMap(t *testing.T) {
+	re := pcregexp.MustCompile(`(?<key>\w+)=(?<value>\d+)?(?<unit>ms)?`)
+	defer re.Close()
+
+	got := re.FindStringSubmatchMap("timeout=30")
+	want := map[string]string{"key": "timeout", "value": "30", "unit": ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindStringSubmatchMap() = %v, want %v", got, want)
+	}
+
+	if got := re.FindStringSubmatchMap("nothing here"); got != nil {
+		t.Errorf("FindStringSubmatchMap() without match = %v, want nil", got)
+	}
+
+	all := re.FindAllStringSubmatchMap("a=1 b= c=3ms", -1)
+	wantAll := []map[string]string{
+		{"key": "a", "value": "1", "unit": ""},
+		{"key": "b", "value": "", "unit": ""},
+		{"key": "c", "value": "3", "unit": "ms"},
+	}
+	if !reflect.DeepEqual(all, wantAll) {
+		t.Errorf("FindAllStringSubmatchMap() = %v, want %v", all, wantAll)
+	}
+
+	dup := pcregexp.MustCompile(`(?J)(?<n>a)|(?<n>b)`)
+	defer dup.Close()
+
+	if got, want := dup.FindStringSubmatchMap("b"), map[string]string{"n": "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindStringSubmatchMap() with duplicate names = %v, want %v", got, want)
+	}
+}
+
+func TestRegexp_FindStringGroups(t *testing.T) {
+	re := pcregexp.MustCompile(`(?<user>\w+)@(?<host>[\w.]+)(?::(?<port>\d+))?`)
+	defer re.Close()
+
+	g := re.FindStringGroups("mail alice@example.com now")
+	if g == nil {
+		t.Fatal("FindStringGroups() = nil, want a match")
+	}
+
+	if got := g.String(); got != "alice@example.com" {
+		t.Errorf("String() = %q", got)
+	}
+	if got := g.Group("user"); got != "alice" {
+		t.Errorf("Group(user) = %q, want alice", got)
+	}
+	if got, ok := g.Lookup("port"); ok || got != "" {
+		t.Errorf("Lookup(port) = %q, %v, want unset", got, ok)
+	}
+	if _, ok := g.Lookup("unknown"); ok {
+		t.Errorf("Lookup(unknown) reported a match")
+	}
+
+	if g := re.FindStringGroups("no address"); g != nil {
+		t.Errorf("FindStringGroups() without match = %v, want nil", g)
+	}
+}
+
+type logLine struct {
+	Addr     netip.Addr    `pcre:"addr"`
+	Method   string        `pcre:"method"`
+	Status   int           `pcre:"status"`
+	Bytes    uint64        `pcre:"bytes"`
+	Ratio    float64       `pcre:"ratio"`
+	Cached   bool          `pcre:"cached"`
+	Took     time.Duration `pcre:"took"`
+	Referrer *string       `pcre:"referrer"`
+	Raw      []byte        `pcre:"method"`
+	Ignored  string
+	Skipped  string `pcre:"-"`
+}
+
+func TestRegexp_Unmarshal(t *testing.T) {
+	re := pcregexp.MustCompile(`^(?<addr>\S+) (?<method>[A-Z]+) (?<status>\d+) (?<bytes>\d+) (?<ratio>[\d.]+) (?<cached>\w+) (?<took>\S+)(?: (?<referrer>\S+))?$`)
+	defer re.Close()
+
+	var got logLine
+	got.Ignored = "kept"
+	if err := re.Unmarshal("10.0.0.1 GET 200 5120 0.75 true 1.5ms", &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	want := logLine{
+		Addr:    netip.MustParseAddr("10.0.0.1"),
+		Method:  "GET",
+		Status:  200,
+		Bytes:   5120,
+		Ratio:   0.75,
+		Cached:  true,
+		Took:    1500 * time.Microsecond,
+		Raw:     []byte("GET"),
+		Ignored: "kept",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal() = %+v, want %+v", got, want)
+	}
+
+	if err := re.Unmarshal("10.0.0.1 GET 200 5120 0.75 true 1.5ms https://example.com/", &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if got.Referrer == nil || *got.Referrer != "https://example.com/" {
+		t.Errorf("Unmarshal() Referrer = %v", got.Referrer)
+	}
+
+	if err := re.Unmarshal("garbage", &got); !errors.Is(err, pcregexp.ErrNoMatch) {
+		t.Errorf("Unmarshal() without match error = %v, want ErrNoMatch", err)
+	}
+
+	if err := re.Unmarshal("10.0.0.1 GET 99999999999999999999 1 1 true 1s", &got); err == nil || !strings.Contains(err.Error(), "Status") {
+		t.Errorf("Unmarshal() with overflowing status error = %v", err)
+	}
+
+	if err := re.Unmarshal("not-an-ip GET 200 1 1 true 1s", &got); err == nil || !strings.Contains(err.Error(), "Addr") {
+		t.Errorf("Unmarshal() with bad address error = %v", err)
+	}
+
+	var unknown struct {
+		Name string `pcre:"name"`
+	}
+	if err := re.Unmarshal("10.0.0.1 GET 200 5120 0.75 true 1.5ms", &unknown); err == nil {
+		t.Errorf("Unmarshal() with unknown group succeeded")
+	}
+
+	if err := re.Unmarshal("10.0.0.1 GET 200 5120 0.75 true 1.5ms", got); err == nil {
+		t.Errorf("Unmarshal() into a non-pointer succeeded")
+	}
+}
@@ -0,0 +1,316 @@
+package pcregexp
+
+import (
+	"bytes"
+	"runtime"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/dwisiswant0/pcregexp/pkg/pcresyntax"
+)
+
+const (
+	// MinParallelChunk is the smallest number of bytes
+	// [PCREgexp.FindAllIndexParallel] hands to a worker goroutine. Smaller
+	// buffers are searched on the calling goroutine.
+	MinParallelChunk = 64 * 1024
+
+	// DefaultParallelOverlap is the number of bytes past the end of its chunk
+	// a worker of [PCREgexp.FindAllIndexParallel] may read to complete a
+	// match, see [PCREgexp.FindAllIndexParallelOverlap].
+	DefaultParallelOverlap = 4 * 1024
+)
+
+// FindAllIndexParallel returns the same index pairs as
+// [PCREgexp.FindAllIndex] with n < 0, but searches large buffers with up to
+// workers goroutines. If workers <= 0, GOMAXPROCS is used.
+//
+// It is meant for searching large inputs, such as memory-mapped files, with
+// one pattern; see [PCREgexp.FindAllIndexParallelOverlap] for how the buffer
+// is split.
+func (re *PCREgexp) FindAllIndexParallel(b []byte, workers int) [][]int {
+	return re.FindAllIndexParallelOverlap(b, workers, DefaultParallelOverlap)
+}
+
+// FindAllIndexParallelOverlap is like [PCREgexp.FindAllIndexParallel], with
+// overlap bounding how far past the end of its chunk a worker may read to
+// complete a match.
+//
+// The buffer is split into one chunk per worker, preferably just after a
+// newline. Every worker matches against the whole buffer up to the end of
+// its chunk plus overlap, so lookbehind assertions see the text of the
+// preceding chunks and matches may cross chunk boundaries. Matches that
+// might extend past that window are detected with PCRE2 partial matching.
+// The results of the workers are then merged in order; wherever they cannot
+// be proven to coincide with a sequential search, such as after a match
+// longer than overlap, the search is continued sequentially. The result is
+// therefore always exactly that of FindAllIndex, and overlap only affects
+// how much work is done in parallel.
+//
+// Patterns whose matches depend on where a search starts, namely those
+// containing \G or a backtracking control verb such as (*SKIP), and those
+// containing \K, which moves the reported start of a match, are always
+// searched sequentially.
+func (re *PCREgexp) FindAllIndexParallelOverlap(b []byte, workers, overlap int) [][]int {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	if limit := len(b) / MinParallelChunk; workers > limit {
+		workers = limit
+	}
+
+	if workers <= 1 || re.h == nil || re.h.code == 0 || !parallelSafe(re.pattern) {
+		return re.FindAllIndex(b, -1)
+	}
+
+	// The handle must outlive the PCRE2 calls made by the workers.
+	defer runtime.KeepAlive(re.h)
+
+	matchers := make([]*matcher, 0, workers)
+	defer func() {
+		for _, m := range matchers {
+			m.free()
+		}
+	}()
+
+	for len(matchers) < workers {
+		m, ok := re.h.newMatcher(re.isJIT)
+		if !ok {
+			break
+		}
+		matchers = append(matchers, m)
+	}
+
+	bounds := chunkBounds(b, len(matchers), overlap)
+	if len(matchers) <= 1 || len(bounds) <= 2 {
+		return re.FindAllIndex(b, -1)
+	}
+
+	chains := make([][]chainLink, len(bounds)-1)
+
+	var wg sync.WaitGroup
+	for w := range chains {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			chains[w] = searchChunk(matchers[w], b, bounds[w], bounds[w+1], overlap)
+		}(w)
+	}
+	wg.Wait()
+
+	m, ok := re.h.matcher(re.isJIT)
+	if !ok {
+		return nil
+	}
+
+	return mergeChains(&m, b, bounds, chains)
+}
+
+// parallelSafe reports whether the leftmost match found when searching from
+// any position up to its start is the same, which the merge of
+// FindAllIndexParallelOverlap relies on. This is not so for \G, which
+// asserts the start offset, nor for backtracking control verbs, which may
+// skip start positions depending on where the search began. Nor is it for
+// \K: the reported start of a match is then not where it was found, so
+// the chunk a match belongs to and the positions it covers are unknown.
+//
+// Patterns that do not parse are not considered safe.
+func parallelSafe(pattern string) bool {
+	tree, err := pcresyntax.Parse(pattern)
+	if err != nil {
+		return false
+	}
+
+	safe := true
+	pcresyntax.Inspect(tree, func(n pcresyntax.Node) bool {
+		switch n := n.(type) {
+		case *pcresyntax.Assertion:
+			safe = n.Type != 'G' && n.Type != 'K'
+		case *pcresyntax.Verb:
+			safe = false
+		}
+		return safe
+	})
+
+	return safe
+}
+
+// chunkBounds splits b into up to n chunks and returns their boundaries,
+// starting with 0 and ending with len(b). Boundaries are moved just after a
+// newline found within overlap bytes, or else to the start of a UTF-8
+// sequence.
+func chunkBounds(b []byte, n, overlap int) []int {
+	bounds := []int{0}
+	size := len(b) / n
+
+	for i := 1; i < n; i++ {
+		c := i * size
+
+		window := b[c:]
+		if len(window) > overlap {
+			window = window[:overlap]
+		}
+
+		if j := bytes.IndexByte(window, '\n'); j >= 0 {
+			c += j + 1
+		} else {
+			for c < len(b) && !utf8.RuneStart(b[c]) {
+				c++
+			}
+		}
+
+		if c > bounds[len(bounds)-1] && c < len(b) {
+			bounds = append(bounds, c)
+		}
+	}
+
+	return append(bounds, len(b))
+}
+
+// chainLink records the leftmost match found by searching from pos. Since
+// the search skips the start positions that do not match, the same match is
+// found when searching from any position in [pos, loc[0]].
+//
+// A nil loc means there is no match starting in [pos, end), and none at all
+// if end > len(b).
+type chainLink struct {
+	pos int
+	loc []int
+	end int
+}
+
+// covers reports whether searching from q is known to yield l.loc.
+func (l chainLink) covers(q int) bool {
+	if q < l.pos {
+		return false
+	}
+
+	if l.loc == nil {
+		return q < l.end
+	}
+
+	return q <= l.loc[0]
+}
+
+// last returns the last position covered by l.
+func (l chainLink) last() int {
+	if l.loc == nil {
+		return l.end - 1
+	}
+
+	return l.loc[0]
+}
+
+// searchChunk performs the sequential search of FindAllIndex from lo, until
+// it finds a match starting at or after hi, and returns what it found.
+//
+// The subject is truncated overlap bytes past hi and matched with
+// PCRE2_PARTIAL_HARD, so a complete match is one that does not depend on the
+// text past the truncation. The search stops at the first partial match or
+// matching error, leaving the rest to the sequential merge.
+func searchChunk(m *matcher, b []byte, lo, hi, overlap int) []chainLink {
+	limit := len(b)
+	if hi+overlap < limit {
+		limit = hi + overlap
+	}
+
+	subject := b[:limit]
+
+	var options uint32
+	if limit < len(b) {
+		options = uint32(MatchPartialHard)
+	}
+
+	var chain []chainLink
+	for pos := lo; pos < hi || (hi == len(b) && pos <= hi); {
+		ret := m.run(subject, pos, options)
+		if ret < 0 && ret != errorNoMatch {
+			// A partial match, or an error such as exceeding the match
+			// limit, which the sequential merge runs into again.
+			break
+		}
+
+		if ret == errorNoMatch {
+			end := limit
+			if limit == len(b) {
+				end = len(b) + 1
+			}
+			chain = append(chain, chainLink{pos: pos, end: end})
+			break
+		}
+
+		loc := m.appendOvector(make([]int, 0, 2), 1)
+		chain = append(chain, chainLink{pos: pos, loc: loc})
+
+		if loc[0] >= hi {
+			break
+		}
+
+		pos = nextSearch(b, loc)
+	}
+
+	return chain
+}
+
+// nextSearch returns where the search of FindAllIndex resumes after a match
+// at loc; after an empty match it skips one UTF-8 sequence.
+func nextSearch(b []byte, loc []int) int {
+	if loc[0] != loc[1] {
+		return loc[1]
+	}
+
+	if loc[1] >= len(b) {
+		return len(b) + 1
+	}
+
+	_, size := utf8.DecodeRune(b[loc[1]:])
+
+	return loc[1] + size
+}
+
+// mergeChains replays the sequential search of FindAllIndex over b, taking
+// each match from the chains of the workers when one of them covers the
+// current position, and matching with m otherwise.
+func mergeChains(m *matcher, b []byte, bounds []int, chains [][]chainLink) [][]int {
+	var results [][]int
+
+	w, next := 0, make([]int, len(chains))
+	for q := 0; q <= len(b); {
+		for w+1 < len(chains) && bounds[w+1] <= q {
+			w++
+		}
+
+		chain := chains[w]
+		for next[w] < len(chain) && chain[next[w]].last() < q {
+			next[w]++
+		}
+
+		var loc []int
+		if i := next[w]; i < len(chain) && chain[i].covers(q) {
+			if chain[i].loc == nil {
+				if chain[i].end > len(b) {
+					break
+				}
+
+				// No match starts before end, so searching from q finds
+				// what searching from end does.
+				q = chain[i].end
+				continue
+			}
+			loc = chain[i].loc
+		} else {
+			ret := m.run(b, q, 0)
+			if ret < 0 {
+				break
+			}
+			loc = m.appendOvector(make([]int, 0, 2), 1)
+		}
+
+		results = append(results, loc)
+		q = nextSearch(b, loc)
+	}
+
+	return results
+}
@@ -0,0 +1,121 @@
+package pcregexp_test
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+func parallelInput(size int) []byte {
+	words := []string{"peach", "punch", "pinch", "apple", "12", "345", "BEGIN", "END", "é", "x", ""}
+	rng := rand.New(rand.NewSource(1))
+
+	var sb strings.Builder
+	for sb.Len() < size {
+		sb.WriteString(words[rng.Intn(len(words))])
+		switch rng.Intn(8) {
+		case 0:
+			sb.WriteByte('\n')
+		case 1:
+			// no separator
+		default:
+			sb.WriteByte(' ')
+		}
+	}
+
+	return []byte(sb.String())
+}
+
+func TestRegexp_FindAllIndexParallel(t *testing.T) {
+	b := parallelInput(1 << 18)
+
+	patterns := []string{
+		`p([a-z]+)ch`,
+		`\bp[a-z]+\b`,
+		`(?<=\d)\d`,
+		`x*`,
+		`(?m)^\w+$`,
+		`(?s)BEGIN.*?END`,
+		`(?s)BEGIN.{0,5000}END`,
+		`é|\n`,
+		`\G\w`,
+		`(*SKIP)p`,
+		`p\Kunch`,
+		`nomatch`,
+	}
+
+	for _, pattern := range patterns {
+		t.Run(pattern, func(t *testing.T) {
+			re := pcregexp.MustCompile(pattern)
+			defer re.Close()
+
+			want := re.FindAllIndex(b, -1)
+
+			if got := re.FindAllIndexParallel(b, 4); !reflect.DeepEqual(got, want) {
+				t.Errorf("FindAllIndexParallel() returned %d matches, want %d", len(got), len(want))
+			}
+
+			if got := re.FindAllIndexParallelOverlap(b, 8, 16); !reflect.DeepEqual(got, want) {
+				t.Errorf("FindAllIndexParallelOverlap(16) returned %d matches, want %d", len(got), len(want))
+			}
+		})
+	}
+}
+
+func TestRegexp_FindAllIndexParallelSmall(t *testing.T) {
+	re := pcregexp.MustCompile(`p([a-z]+)ch`)
+	defer re.Close()
+
+	b := []byte("peach punch pinch")
+	if got, want := re.FindAllIndexParallel(b, 4), re.FindAllIndex(b, -1); !reflect.DeepEqual(got, want) {
+		t.Errorf("FindAllIndexParallel() = %v, want %v", got, want)
+	}
+}
+
+// A match reported past the chunk boundary by \K must not be followed by
+// another match starting inside it.
+func TestRegexp_FindAllIndexParallelKeep(t *testing.T) {
+	re := pcregexp.MustCompile(`Z[^Z]*?a|a\Kb`)
+	defer re.Close()
+
+	b := []byte(strings.Repeat("y", 131072))
+	b[65530], b[65537], b[65538] = 'Z', 'a', 'b'
+
+	want := [][]int{{65530, 65538}}
+	if got := re.FindAllIndex(b, -1); !reflect.DeepEqual(got, want) {
+		t.Fatalf("FindAllIndex() = %v, want %v", got, want)
+	}
+
+	if got := re.FindAllIndexParallel(b, 2); !reflect.DeepEqual(got, want) {
+		t.Errorf("FindAllIndexParallel() = %v, want %v", got, want)
+	}
+}
+
+func TestRegexp_FindAllIndexParallelMatchLimit(t *testing.T) {
+	if err := pcregexp.SetMatchContext(pcregexp.MatchContext{MatchLimit: 1000}); err != nil {
+		t.Fatalf("SetMatchContext() error = %v", err)
+	}
+	defer pcregexp.SetMatchContext(pcregexp.MatchContext{})
+
+	re := pcregexp.MustCompile(`(x+x+)+[yz]`)
+	defer re.Close()
+
+	// The run of x exceeds the match limit, which ends the search.
+	b := bytes.Repeat([]byte("."), 131072)
+	copy(b[10:], "xxy")
+	copy(b[100:], strings.Repeat("x", 30))
+	copy(b[100000:], "xxy")
+
+	want := [][]int{{10, 13}}
+	if got := re.FindAllIndex(b, -1); !reflect.DeepEqual(got, want) {
+		t.Fatalf("FindAllIndex() = %v, want %v", got, want)
+	}
+
+	if got := re.FindAllIndexParallel(b, 2); !reflect.DeepEqual(got, want) {
+		t.Errorf("FindAllIndexParallel() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,107 @@
+package pcregexp
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// FindIndexPartialAt is like [PCREgexp.FindIndexAt], but also reports partial
+// matches, as requested by [MatchPartialSoft] or [MatchPartialHard] in flags.
+//
+// A partial match means that b ends before a match starting at loc[0] could
+// complete, so that more text appended to b may turn it into a match. loc[1]
+// is then len(b), and partial is true. This lets input arriving in chunks be
+// matched without splitting matches at chunk boundaries: the text from
+// loc[0] on is kept and matched again once more text has arrived.
+//
+// With [MatchPartialHard], a complete match is only reported if more text
+// could not change it, for instance by extending a greedy quantifier, which
+// is usually what streaming needs. Lookbehind assertions may also inspect
+// text before loc[0], which must then be kept as well.
+func (re *PCREgexp) FindIndexPartialAt(b []byte, off int, flags MatchFlag) (loc []int, partial bool) {
+	indexes, partial := re.execPartial(b, off, flags)
+	if indexes == nil {
+		return nil, false
+	}
+
+	return []int{indexes[0], indexes[1]}, partial
+}
+
+// FindSubmatchIndexPartialAt is like [PCREgexp.FindIndexPartialAt], but
+// returns the submatches of a complete match, like
+// [PCREgexp.FindSubmatchIndexAt]. A partial match has no submatches: loc then
+// only holds its bounds.
+func (re *PCREgexp) FindSubmatchIndexPartialAt(b []byte, off int, flags MatchFlag) (loc []int, partial bool) {
+	indexes, partial := re.execPartial(b, off, flags)
+	if indexes == nil {
+		return nil, false
+	}
+
+	return append([]int(nil), indexes...), partial
+}
+
+// MatchError is returned by [PCREgexp.Exec] when PCRE2 fails to match for a
+// reason other than the subject not matching, for instance because a limit
+// of the match context is exceeded.
+type MatchError struct {
+	// Code is the PCRE2 error code.
+	Code int
+}
+
+// Error implements the error interface.
+func (e *MatchError) Error() string {
+	return fmt.Sprintf("pcre2_match failed: %s (error code %d)", ErrorMessage(e.Code), e.Code)
+}
+
+// Exec is like [PCREgexp.FindSubmatchIndexPartialAt], but returns a
+// *[MatchError] if off is out of range or PCRE2 fails to match, such as when
+// a limit set with [SetMatchContext] is exceeded or the subject is not valid
+// UTF-8 in UTF mode. The other match methods report no match then.
+func (re *PCREgexp) Exec(b []byte, off int, flags MatchFlag) (loc []int, partial bool, err error) {
+	if off < 0 || off > len(b) {
+		return nil, false, &MatchError{Code: -33} // PCRE2_ERROR_BADOFFSET
+	}
+
+	indexes, partial, err := re.execResult(b, off, flags)
+	if indexes == nil {
+		return nil, false, err
+	}
+
+	return append([]int(nil), indexes...), partial, nil
+}
+
+// execPartial matches b from off with flags into re.buf, and reports whether
+// the match is partial. It returns nil if there is no match or off is out of
+// range. The result cache is bypassed.
+func (re *PCREgexp) execPartial(b []byte, off int, flags MatchFlag) ([]int, bool) {
+	indexes, partial, _ := re.execResult(b, off, flags)
+	return indexes, partial
+}
+
+// execResult is like execPartial, but also returns the error of PCRE2 when
+// it fails for a reason other than no match.
+func (re *PCREgexp) execResult(b []byte, off int, flags MatchFlag) ([]int, bool, error) {
+	if off < 0 || off > len(b) || re.h == nil || re.h.code == 0 {
+		return nil, false, nil
+	}
+
+	defer runtime.KeepAlive(re.h)
+
+	m, ok := re.h.matcher(re.isJIT)
+	if !ok {
+		return nil, false, nil
+	}
+
+	switch ret := m.run(b, off, uint32(flags)); {
+	case ret == errorPartial:
+		re.buf = m.appendOvector(re.buf[:0], 1)
+		return re.buf, true, nil
+	case ret == errorNoMatch:
+		return nil, false, nil
+	case ret < 0:
+		return nil, false, &MatchError{Code: int(ret)}
+	default:
+		re.buf = m.appendOvector(re.buf[:0], int(ret))
+		return re.buf, false, nil
+	}
+}
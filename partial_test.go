@@ -0,0 +1,100 @@
+package pcregexp_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+func TestRegexp_FindIndexPartialAt(t *testing.T) {
+	tests := []struct {
+		pattern     string
+		input       string
+		off         int
+		flags       pcregexp.MatchFlag
+		wantLoc     []int
+		wantPartial bool
+	}{
+		{`\d{4}`, "ab 12", 0, 0, nil, false},
+		{`\d{4}`, "ab 12", 0, pcregexp.MatchPartialHard, []int{3, 5}, true},
+		{`\d{4}`, "ab 12", 0, pcregexp.MatchPartialSoft, []int{3, 5}, true},
+		{`\d{4}`, "ab 1234", 0, pcregexp.MatchPartialHard, []int{3, 7}, false},
+
+		// A greedy match reaching the end may grow with more text.
+		{`\d+`, "ab 12", 0, pcregexp.MatchPartialHard, []int{3, 5}, true},
+		{`\d+`, "ab 12", 0, pcregexp.MatchPartialSoft, []int{3, 5}, false},
+		{`\d+`, "ab 12 ", 0, pcregexp.MatchPartialHard, []int{3, 5}, false},
+
+		// A partial match must reach the end of the subject.
+		{`abc|x`, "x ab", 0, pcregexp.MatchPartialHard, []int{0, 1}, false},
+		{`abc|x`, "x ab", 1, pcregexp.MatchPartialHard, []int{2, 4}, true},
+		{`abc|x`, "ab x", 0, pcregexp.MatchPartialHard, []int{3, 4}, false},
+		{`abc`, "xyz", 0, pcregexp.MatchPartialHard, nil, false},
+		{`abc`, "xyz", 4, pcregexp.MatchPartialHard, nil, false},
+
+		{`ab`, "xa", 1, pcregexp.MatchPartialHard | pcregexp.MatchAnchored, []int{1, 2}, true},
+		{`ab`, "ax", 0, pcregexp.MatchPartialHard | pcregexp.MatchAnchored, nil, false},
+	}
+
+	for _, tt := range tests {
+		re := pcregexp.MustCompile(tt.pattern)
+
+		loc, partial := re.FindIndexPartialAt([]byte(tt.input), tt.off, tt.flags)
+		if !reflect.DeepEqual(loc, tt.wantLoc) || partial != tt.wantPartial {
+			t.Errorf("FindIndexPartialAt(%q, %d, %#x) with pattern %q = %v, %v, want %v, %v", tt.input, tt.off, tt.flags, tt.pattern, loc, partial, tt.wantLoc, tt.wantPartial)
+		}
+
+		re.Close()
+	}
+}
+
+func TestRegexp_FindSubmatchIndexPartialAt(t *testing.T) {
+	re := pcregexp.MustCompile(`(\w+)=(\d+);`)
+	defer re.Close()
+
+	loc, partial := re.FindSubmatchIndexPartialAt([]byte("a=1; b=2"), 0, pcregexp.MatchPartialHard)
+	if want := []int{0, 4, 0, 1, 2, 3}; !reflect.DeepEqual(loc, want) || partial {
+		t.Errorf("FindSubmatchIndexPartialAt() = %v, %v, want %v, false", loc, partial, want)
+	}
+
+	loc, partial = re.FindSubmatchIndexPartialAt([]byte("a=1; b=2"), 4, pcregexp.MatchPartialHard)
+	if want := []int{5, 8}; !reflect.DeepEqual(loc, want) || !partial {
+		t.Errorf("FindSubmatchIndexPartialAt() = %v, %v, want %v, true", loc, partial, want)
+	}
+}
+
+func TestRegexp_Exec(t *testing.T) {
+	re := pcregexp.MustCompile(`(x+x+)+[yz]`)
+	defer re.Close()
+
+	loc, partial, err := re.Exec([]byte("axxy"), 0, 0)
+	if want := []int{1, 4, 1, 3}; !reflect.DeepEqual(loc, want) || partial || err != nil {
+		t.Errorf("Exec() = %v, %v, %v, want %v, false, nil", loc, partial, err, want)
+	}
+
+	if loc, _, err := re.Exec([]byte("axx"), 0, 0); loc != nil || err != nil {
+		t.Errorf("Exec() without a match = %v, %v, want nil, nil", loc, err)
+	}
+
+	if _, _, err := re.Exec([]byte("axx"), 4, 0); err == nil {
+		t.Error("Exec() with an offset out of range returned no error")
+	}
+
+	if err := pcregexp.SetMatchContext(pcregexp.MatchContext{MatchLimit: 1000}); err != nil {
+		t.Fatalf("SetMatchContext() error = %v", err)
+	}
+	defer pcregexp.SetMatchContext(pcregexp.MatchContext{})
+
+	subject := []byte("xxxxxxxxxxxxxxxxxxxxxxxxxxxxxx")
+
+	var merr *pcregexp.MatchError
+	if _, _, err := re.Exec(subject, 0, 0); !errors.As(err, &merr) || merr.Code != -47 {
+		t.Errorf("Exec() past the match limit error = %v, want PCRE2_ERROR_MATCHLIMIT", err)
+	}
+
+	if loc := re.FindIndex(subject); loc != nil {
+		t.Errorf("FindIndex() past the match limit = %v, want nil", loc)
+	}
+}
@@ -2,119 +2,57 @@ package pcregexp
 
 import (
 	"bytes"
-	"fmt"
 	"io"
 	"runtime"
+	"strconv"
 	"strings"
+	"unicode"
 	"unicode/utf8"
-	"unsafe"
-
-	"github.com/ebitengine/purego"
 )
 
 func init() {
-	var libPath string
-
-	switch runtime.GOOS {
-	case "darwin":
-		libPath = "libpcre2-8.dylib"
-	case "linux", "freebsd":
-		libPath = "libpcre2-8.so"
-	case "windows":
-		libPath = "pcre2-8.dll"
-	default:
-		panic(fmt.Errorf("GOOS=%s is not supported", runtime.GOOS))
-	}
-
-	lib, err := openLibrary(libPath)
-	if err != nil {
-		panic(fmt.Errorf("failed to load %s: %w", libPath, err))
-	}
-
-	// Register the functions by their PCRE2 symbol names.
-	// (For the 8-bit versions, the symbols are suffixed with "_8".)
-	funcs := [][2]any{
-		{&pcre2_compile, "pcre2_compile_8"},
-		{&pcre2_code_free, "pcre2_code_free_8"},
-		{&pcre2_pattern_info, "pcre2_pattern_info_8"},
-		{&pcre2_match, "pcre2_match_8"},
-		{&pcre2_match_data_create_from_pattern, "pcre2_match_data_create_from_pattern_8"},
-		{&pcre2_match_data_free, "pcre2_match_data_free_8"},
-		{&pcre2_get_ovector_pointer, "pcre2_get_ovector_pointer_8"},
-		// JIT-related functions
-		{&pcre2_jit_compile, "pcre2_jit_compile_8"},
-		{&pcre2_jit_match, "pcre2_jit_match_8"},
-		{&pcre2_jit_stack_create, "pcre2_jit_stack_create_8"},
-		{&pcre2_jit_stack_free, "pcre2_jit_stack_free_8"},
-		{&pcre2_jit_stack_assign, "pcre2_jit_stack_assign_8"},
-		// Match context functions
-		{&pcre2_match_context_create, "pcre2_match_context_create_8"},
-		{&pcre2_match_context_free, "pcre2_match_context_free_8"},
-		// {&pcre2_set_offset_limit, "pcre2_set_offset_limit_8"},
-		// {&pcre2_set_heap_limit, "pcre2_set_heap_limit_8"},
-		{&pcre2_set_match_limit, "pcre2_set_match_limit_8"},
-		{&pcre2_set_depth_limit, "pcre2_set_depth_limit_8"},
-	}
-
-	for _, f := range funcs {
-		purego.RegisterLibFunc(f[0], lib, f[1].(string))
-	}
-
 	runtime.SetFinalizer(globalFinalizerObject, func(_ *int) {
 		if defaultMatchCtx != nil {
 			pcre2_match_context_free(defaultMatchCtx.ptr)
+			liveMatchContexts.Add(-1)
 			defaultMatchCtx = nil
 		}
 	})
 }
 
 // PCREgexp is a compiled regular expression.
+//
+// The PCRE2 resources of a PCREgexp are freed by [PCREgexp.Close], or by the
+// garbage collector once it becomes unreachable if Close is never called.
 type PCREgexp struct {
-	pattern   string           // original pattern
-	buf       []int            // cached match offsets
-	code      uintptr          // pointer to compiled pcre2_code
-	matchData uintptr          // cached match data
-	isJIT     bool             // whether pattern has been JIT compiled
-	jitStack  uintptr          // pointer to JIT stack
-	cache     map[string][]int // cache for matches
+	pattern string       // original pattern
+	buf     []int        // cached match offsets
+	h       *handle      // PCRE2 objects, nil for the empty pattern
+	isJIT   bool         // whether pattern has been JIT compiled
+	names   []string     // subexpression names, indexed by group number
+	cache   *resultCache // opt-in match result cache, nil if disabled
 }
 
 // Compile creates a new PCREgexp from pattern.
 //
 // Note: an empty pattern is considered valid but will match nothing. :shrug:
 // In this case, calling [Close] is unnecessary.
+//
+// The PCRE2 shared library is loaded on the first call, see [Init].
 func Compile(pattern string) (*PCREgexp, error) {
-	var errcode int32
-	var errOffset uint64
-
-	re := &PCREgexp{code: 0, pattern: pattern, cache: make(map[string][]int)}
-
 	if len(pattern) == 0 {
-		return re, nil
+		return &PCREgexp{
+			pattern: pattern,
+			names:   []string{""},
+			cache:   newResultCache(int(defaultCacheSize.Load())),
+		}, nil
 	}
 
-	patPtr := (*uint8)(unsafe.StringData(pattern))
-
-	// PCRE2_UTF = 0x00080000
-	// PCRE2_NO_UTF_CHECK = 0x40000000
-	code := pcre2_compile(patPtr, uint64(len(pattern)), 0, &errcode, &errOffset, 0)
-	if code == 0 {
-		return nil, fmt.Errorf("pcre2_compile failed at offset %d, error code %d", errOffset, errcode)
-	}
-	re.code = code
-
-	if defaultJITOption != JITNoJit {
-		res := pcre2_jit_compile(code, uint32(defaultJITOption))
-		if res == 0 {
-			re.isJIT = true
-			re.jitStack = pcre2_jit_stack_create(defaultJITStackStartSize, defaultJITStackMaxSize, 0)
-			if re.jitStack != 0 && defaultMatchCtx != nil {
-				pcre2_jit_stack_assign(defaultMatchCtx.ptr, 0, re.jitStack)
-			}
-		}
+	if err := Init(); err != nil {
+		return nil, err
 	}
 
-	return re, nil
+	return compile(pattern, 0, 0, false)
 }
 
 // MustCompile is like [Compile] but panics on error.
@@ -173,122 +111,116 @@ func MatchString(pattern string, s string) (matched bool, err error) {
 }
 
 // Close frees the resources associated with the compiled pattern.
+//
+// Close is idempotent. Once closed, the regexp matches nothing: match methods
+// report false or return nil.
 func (re *PCREgexp) Close() {
-	if re.matchData != 0 {
-		pcre2_match_data_free(re.matchData)
-		re.matchData = 0
+	if re.h != nil {
+		re.h.free()
 	}
 
-	if re.jitStack != 0 {
-		pcre2_jit_stack_free(re.jitStack)
-		re.jitStack = 0
-	}
+	re.cache = nil
+}
 
-	if re.code != 0 {
-		pcre2_code_free(re.code)
-		re.code = 0
+// exec matches subject starting at offset with the PCRE2 match options and
+// appends the ovector of the match to dst. It reports whether there was a
+// match; if not, dst is returned unchanged.
+//
+// Offsets in the ovector are relative to the start of subject, so lookbehind
+// assertions, \b and ^ see the text before offset.
+func (re *PCREgexp) exec(dst []int, subject []byte, offset int, options uint32) ([]int, bool) {
+	if re.h == nil || re.h.code == 0 {
+		return dst, false
 	}
 
-	if re.cache != nil {
-		for k := range re.cache {
-			delete(re.cache, k)
-		}
-	}
-}
+	// The handle must outlive the PCRE2 calls below; otherwise its finalizer
+	// could free the pattern while it is being matched.
+	defer runtime.KeepAlive(re.h)
 
-// saveMatchData creates a new match data object if it doesn't exist yet.
-//
-// It returns the pointer to the match data object. The match data object is
-// used to store the results of a match.
-func (re *PCREgexp) saveMatchData() uintptr {
-	if re.matchData == 0 {
-		re.matchData = pcre2_match_data_create_from_pattern(re.code, 0)
+	m, ok := re.h.matcher(re.isJIT)
+	if !ok {
+		return dst, false
 	}
 
-	return re.matchData
+	return m.exec(dst, subject, offset, options)
 }
 
-// match performs a PCRE2 match on the given subject.
-//
-// It returns a slice of start/end indexes as returned by PCRE2.
-func (re *PCREgexp) match(subject []byte) []int {
-	if re.code == 0 || len(subject) == 0 {
+// match appends the offsets of the leftmost match in subject to dst, as
+// returned by PCRE2, consulting the result cache if enabled. It returns nil
+// if there is no match.
+func (re *PCREgexp) match(dst []int, subject []byte) []int {
+	if re.h == nil || re.h.code == 0 {
 		return nil
 	}
 
-	if result, ok := re.cache[bytes2StringUnsafe(subject)]; ok {
-		return result
+	if re.cache != nil {
+		if result, ok := re.cache.get(dst, subject); ok {
+			return result
+		}
 	}
 
-	md := re.saveMatchData()
-	if md == 0 {
+	result, ok := re.exec(dst, subject, 0, 0)
+	if !ok {
+		if re.cache != nil {
+			re.cache.put(subject, nil)
+		}
 		return nil
 	}
 
-	var subjectPtr *uint8
-
-	if len(subject) > 0 {
-		subjectPtr = (*uint8)(ptr(&subject[0]))
-	}
-
-	// Use the default match context if available
-	var matchCtxPtr uintptr
-	if defaultMatchCtx != nil {
-		matchCtxPtr = defaultMatchCtx.ptr
+	if re.cache != nil {
+		re.cache.put(subject, result[len(dst):])
 	}
 
-	matchFunc := pcre2_match
-	if re.isJIT {
-		matchFunc = pcre2_jit_match
-	}
+	return result
+}
 
-	ret := matchFunc(re.code, subjectPtr, uint64(len(subject)), 0, 0, md, matchCtxPtr)
-	if ret < 0 {
-		re.cache[bytes2StringUnsafe(subject)] = nil
-		return nil
+// scratch is like match, but reuses re.buf. The result is only valid until
+// the next match; it must not be returned to callers.
+func (re *PCREgexp) scratch(subject []byte) []int {
+	result := re.match(re.buf[:0], subject)
+	if result != nil {
+		re.buf = result
 	}
 
-	n := int(ret)
-	reqLen := n * 2
+	return result
+}
 
-	if cap(re.buf) < reqLen {
-		newCap := reqLen * 2
-		if newCap < 20 { // start with a reasonable minimum size
-			newCap = 20
+// forEachMatch calls fn with the offsets of successive matches in subject,
+// at most n of them if n >= 0. The slice passed to fn is only valid during
+// the call.
+//
+// After an empty match, searching resumes one UTF-8 sequence further to
+// avoid an infinite loop.
+func (re *PCREgexp) forEachMatch(subject []byte, n int, fn func(loc []int)) {
+	for pos := 0; n != 0 && pos <= len(subject); n-- {
+		loc, ok := re.exec(re.buf[:0], subject, pos, 0)
+		if !ok {
+			break
 		}
+		re.buf = loc
 
-		re.buf = make([]int, reqLen, newCap)
-	} else {
-		re.buf = re.buf[:reqLen]
-	}
+		fn(loc)
 
-	ovector := pcre2_get_ovector_pointer(md)
-	if ovector == nil {
-		re.cache[bytes2StringUnsafe(subject)] = nil
-		return nil
-	}
+		pos = loc[1]
+		if loc[0] == loc[1] {
+			if pos >= len(subject) {
+				break
+			}
 
-	size := unsafe.Sizeof(uint64(0))
-	for i := 0; i < reqLen; i++ {
-		ptr := (*uint64)(ptr(uintptr(ptr(ovector)) + uintptr(i)*size))
-		re.buf[i] = int(*ptr)
+			_, size := utf8.DecodeRune(subject[pos:])
+			pos += size
+		}
 	}
-
-	result := make([]int, len(re.buf))
-	copy(result, re.buf)
-	re.cache[bytes2StringUnsafe(subject)] = result
-
-	return re.buf
 }
 
 // MatchString reports whether the Regexp matches the given string.
 func (re *PCREgexp) MatchString(s string) bool {
-	return re.match(string2BytesUnsafe(s)) != nil
+	return re.scratch(string2BytesUnsafe(s)) != nil
 }
 
 // FindString returns the text of the leftmost match in s.
 func (re *PCREgexp) FindString(s string) string {
-	indexes := re.match(string2BytesUnsafe(s))
+	indexes := re.scratch(string2BytesUnsafe(s))
 	if len(indexes) < 2 {
 		return ""
 	}
@@ -299,18 +231,23 @@ func (re *PCREgexp) FindString(s string) string {
 // FindStringIndex returns a two-element slice of integers defining the start
 // and end of the leftmost match in s.
 func (re *PCREgexp) FindStringIndex(s string) []int {
-	return re.match(string2BytesUnsafe(s))
+	return re.FindIndex(string2BytesUnsafe(s))
 }
 
 // FindStringSubmatch returns a slice holding the text of the leftmost match and
 // its submatches. It uses the actual number of captured groups as returned by
 // PCRE2.
 func (re *PCREgexp) FindStringSubmatch(s string) []string {
-	indexes := re.match(string2BytesUnsafe(s))
+	indexes := re.scratch(string2BytesUnsafe(s))
 	if len(indexes) < 2 {
 		return nil
 	}
 
+	return submatchStrings(s, indexes)
+}
+
+// submatchStrings returns the text of the index pairs in indexes.
+func submatchStrings(s string, indexes []int) []string {
 	n := len(indexes) / 2
 	submatches := make([]string, n)
 
@@ -333,48 +270,12 @@ func (re *PCREgexp) FindStringSubmatch(s string) []string {
 // If an empty match is encountered, it advances one UTF-8 rune to avoid
 // infinite loop.
 func (re *PCREgexp) ReplaceAllString(src, repl string) string {
-	if src == "" {
-		return ""
-	}
-
-	var b strings.Builder
-	b.Grow(len(src))
-
-	remaining := src
-	for {
-		indexes := re.match(string2BytesUnsafe(remaining))
-		if len(indexes) < 2 {
-			b.WriteString(remaining)
-			break
-		}
-
-		b.WriteString(remaining[:indexes[0]])
-		b.WriteString(repl)
-
-		if indexes[0] == indexes[1] {
-			if indexes[1] < len(remaining) {
-				r, size := utf8.DecodeRuneInString(remaining[indexes[1]:])
-				if r == utf8.RuneError || size == 0 {
-					b.WriteString(remaining[indexes[1]:])
-					break
-				}
-
-				b.WriteString(remaining[indexes[1] : indexes[1]+size])
-				remaining = remaining[indexes[1]+size:]
-			} else {
-				remaining = ""
-			}
-		} else {
-			remaining = remaining[indexes[1]:]
-		}
-	}
-
-	return b.String()
+	return string(re.AppendReplace(make([]byte, 0, len(src)), string2BytesUnsafe(src), string2BytesUnsafe(repl)))
 }
 
 // Find returns a slice holding the text of the leftmost match in b.
 func (re *PCREgexp) Find(b []byte) []byte {
-	indexes := re.match(b)
+	indexes := re.scratch(b)
 	if len(indexes) < 2 {
 		return nil
 	}
@@ -385,23 +286,33 @@ func (re *PCREgexp) Find(b []byte) []byte {
 
 // Match reports whether the regexp matches the byte slice b.
 func (re *PCREgexp) Match(b []byte) bool {
-	return re.match(b) != nil
+	return re.scratch(b) != nil
 }
 
 // FindIndex returns a two-element slice of integers defining the location of
 // the leftmost match in b.
 func (re *PCREgexp) FindIndex(b []byte) []int {
-	return re.match(b)
+	indexes := re.scratch(b)
+	if len(indexes) < 2 {
+		return nil
+	}
+
+	return []int{indexes[0], indexes[1]}
 }
 
 // FindSubmatch returns a slice of slices holding the text of the leftmost
 // match and the matches of any subexpressions.
 func (re *PCREgexp) FindSubmatch(b []byte) [][]byte {
-	indexes := re.match(b)
+	indexes := re.scratch(b)
 	if len(indexes) < 2 {
 		return nil
 	}
 
+	return submatchBytes(b, indexes)
+}
+
+// submatchBytes returns copies of the text of the index pairs in indexes.
+func submatchBytes(b []byte, indexes []int) [][]byte {
 	matches := make([][]byte, len(indexes)/2)
 	for i := 0; i < len(matches); i++ {
 		start := indexes[2*i]
@@ -419,7 +330,7 @@ func (re *PCREgexp) FindSubmatch(b []byte) [][]byte {
 // FindSubmatchIndex returns a slice holding the index pairs identifying the
 // leftmost match and the matches of any subexpressions.
 func (re *PCREgexp) FindSubmatchIndex(b []byte) []int {
-	return re.match(b)
+	return re.match(nil, b)
 }
 
 // FindReaderIndex returns a two-element slice of integers defining the location
@@ -484,15 +395,12 @@ func (re *PCREgexp) MatchReader(r io.RuneReader) bool {
 
 // ReplaceAll returns a copy of src, replacing matches of the regexp with repl.
 func (re *PCREgexp) ReplaceAll(src, repl []byte) []byte {
-	return string2BytesUnsafe(re.ReplaceAllString(bytes2StringUnsafe(src), bytes2StringUnsafe(repl)))
+	return re.AppendReplace(make([]byte, 0, len(src)), src, repl)
 }
 
 // NumSubexp returns the number of parenthesized subexpressions in this regexp.
-//
-// TODO(dwisiswant0): Implement this method.
 func (re *PCREgexp) NumSubexp() int {
-	// TODO(dwisiswant0): Implement this method.
-	return 0
+	return len(re.names) - 1
 }
 
 // String returns the source text used to compile the regexp.
@@ -504,33 +412,10 @@ func (re *PCREgexp) String() string {
 // If n < 0, the return value contains all matches. If n >= 0, the return value
 // contains at most n matches.
 func (re *PCREgexp) FindAllString(s string, n int) []string {
-	if n == 0 {
-		return nil
-	}
-
 	var matches []string
-	remaining := s
-
-	for n != 0 {
-		indexes := re.match(string2BytesUnsafe(remaining))
-		if len(indexes) < 2 {
-			break
-		}
-
-		matches = append(matches, remaining[indexes[0]:indexes[1]])
-
-		if indexes[0] == indexes[1] {
-			if indexes[1] >= len(remaining) {
-				break
-			}
-			_, size := utf8.DecodeRuneInString(remaining[indexes[1]:])
-			remaining = remaining[indexes[1]+size:]
-		} else {
-			remaining = remaining[indexes[1]:]
-		}
-
-		n--
-	}
+	re.forEachMatch(string2BytesUnsafe(s), n, func(loc []int) {
+		matches = append(matches, s[loc[0]:loc[1]])
+	})
 
 	return matches
 }
@@ -538,32 +423,10 @@ func (re *PCREgexp) FindAllString(s string, n int) []string {
 // FindAllStringSubmatch is like [FindStringSubmatch] but returns successive
 // matches.
 func (re *PCREgexp) FindAllStringSubmatch(s string, n int) [][]string {
-	if n == 0 {
-		return nil
-	}
-
 	var results [][]string
-	remaining := s
-
-	for n != 0 {
-		match := re.FindStringSubmatch(remaining)
-		if match == nil {
-			break
-		}
-		results = append(results, match)
-
-		if len(match[0]) == 0 {
-			if len(remaining) == 0 {
-				break
-			}
-			_, size := utf8.DecodeRuneInString(remaining)
-			remaining = remaining[size:]
-		} else {
-			remaining = remaining[len(match[0]):]
-		}
-
-		n--
-	}
+	re.forEachMatch(string2BytesUnsafe(s), n, func(loc []int) {
+		results = append(results, submatchStrings(s, loc))
+	})
 
 	return results
 }
@@ -571,80 +434,23 @@ func (re *PCREgexp) FindAllStringSubmatch(s string, n int) [][]string {
 // FindAllStringIndex returns a slice of index pairs identifying successive
 // matches of the regexp in s.
 func (re *PCREgexp) FindAllStringIndex(s string, n int) [][]int {
-	if n == 0 {
-		return nil
-	}
-
-	var results [][]int
-	remaining := s
-	offset := 0
-
-	for n != 0 {
-		indexes := re.match(string2BytesUnsafe(remaining))
-		if len(indexes) < 2 {
-			break
-		}
-
-		// Adjust indexes for the offset
-		adjIndexes := make([]int, 2)
-		adjIndexes[0] = indexes[0] + offset
-		adjIndexes[1] = indexes[1] + offset
-		results = append(results, adjIndexes)
-
-		if indexes[0] == indexes[1] {
-			if indexes[1] >= len(remaining) {
-				break
-			}
-			_, size := utf8.DecodeRuneInString(remaining[indexes[1]:])
-			remaining = remaining[indexes[1]+size:]
-			offset += indexes[1] + size
-		} else {
-			remaining = remaining[indexes[1]:]
-			offset += indexes[1]
-		}
-
-		n--
-	}
-
-	return results
+	return re.FindAllIndex(string2BytesUnsafe(s), n)
 }
 
 // ReplaceAllFunc returns a copy of src in which all matches of the regexp
 // have been replaced by the return value of function repl applied to the
 // matched byte slice.
 func (re *PCREgexp) ReplaceAllFunc(src []byte, repl func([]byte) []byte) []byte {
-	var b strings.Builder
-	remaining := src
-	lastMatchEnd := 0
-
-	for {
-		indexes := re.match(remaining)
-		if len(indexes) < 2 {
-			b.Write(remaining[lastMatchEnd:])
-			break
-		}
-
-		b.Write(remaining[:indexes[0]])
-		match := remaining[indexes[0]:indexes[1]]
-		b.Write(repl(match))
+	dst := make([]byte, 0, len(src))
+	last := 0
 
-		if indexes[0] == indexes[1] {
-			if indexes[1] >= len(remaining) {
-				break
-			}
-			r, size := utf8.DecodeRune(remaining[indexes[1]:])
-			if r == utf8.RuneError {
-				b.Write(remaining[indexes[1]:])
-				break
-			}
-			b.Write(remaining[indexes[1] : indexes[1]+size])
-			remaining = remaining[indexes[1]+size:]
-		} else {
-			remaining = remaining[indexes[1]:]
-		}
-	}
+	re.forEachMatch(src, -1, func(loc []int) {
+		dst = append(dst, src[last:loc[0]]...)
+		dst = append(dst, repl(src[loc[0]:loc[1]])...)
+		last = loc[1]
+	})
 
-	return string2BytesUnsafe(b.String())
+	return append(dst, src[last:]...)
 }
 
 // Split slices s into substrings separated by matches of the regexp.
@@ -655,57 +461,39 @@ func (re *PCREgexp) Split(s string, n int) []string {
 		return nil
 	}
 
+	if len(re.pattern) > 0 && len(s) == 0 {
+		return []string{""}
+	}
+
 	var parts []string
-	pos := 0
-	for {
-		indexes := re.FindStringIndex(s[pos:])
-		if indexes == nil || indexes[0] < 0 {
-			parts = append(parts, s[pos:])
+	beg, end := 0, 0
+	for _, match := range re.FindAllStringIndex(s, n) {
+		if n > 0 && len(parts) == n-1 {
 			break
 		}
 
-		parts = append(parts, s[pos:pos+indexes[0]])
-		pos += indexes[1]
-
-		if n > 0 && len(parts) == n-1 {
-			parts = append(parts, s[pos:])
-			break
+		end = match[0]
+		if match[1] != 0 {
+			parts = append(parts, s[beg:end])
 		}
+		beg = match[1]
+	}
+
+	if end != len(s) {
+		parts = append(parts, s[beg:])
 	}
+
 	return parts
 }
 
 // FindAll returns a slice of all successive matches of the regexp in b.
 func (re *PCREgexp) FindAll(b []byte, n int) [][]byte {
-	if n == 0 {
-		return nil
-	}
-
 	var matches [][]byte
-	remaining := b
-
-	for n != 0 {
-		indexes := re.match(remaining)
-		if len(indexes) < 2 {
-			break
-		}
-
-		match := make([]byte, indexes[1]-indexes[0])
-		copy(match, remaining[indexes[0]:indexes[1]])
+	re.forEachMatch(b, n, func(loc []int) {
+		match := make([]byte, loc[1]-loc[0])
+		copy(match, b[loc[0]:loc[1]])
 		matches = append(matches, match)
-
-		if indexes[0] == indexes[1] {
-			if indexes[1] >= len(remaining) {
-				break
-			}
-			_, size := utf8.DecodeRune(remaining[indexes[1]:])
-			remaining = remaining[indexes[1]+size:]
-		} else {
-			remaining = remaining[indexes[1]:]
-		}
-
-		n--
-	}
+	})
 
 	return matches
 }
@@ -713,39 +501,10 @@ func (re *PCREgexp) FindAll(b []byte, n int) [][]byte {
 // FindAllIndex returns a slice of index pairs identifying successive matches of
 // the regexp in b.
 func (re *PCREgexp) FindAllIndex(b []byte, n int) [][]int {
-	if n == 0 {
-		return nil
-	}
-
 	var results [][]int
-	remaining := b
-	offset := 0
-
-	for n != 0 {
-		indexes := re.match(remaining)
-		if len(indexes) < 2 {
-			break
-		}
-
-		adjIndexes := make([]int, 2)
-		adjIndexes[0] = indexes[0] + offset
-		adjIndexes[1] = indexes[1] + offset
-		results = append(results, adjIndexes)
-
-		if indexes[0] == indexes[1] {
-			if indexes[1] >= len(remaining) {
-				break
-			}
-			_, size := utf8.DecodeRune(remaining[indexes[1]:])
-			remaining = remaining[indexes[1]+size:]
-			offset += indexes[1] + size
-		} else {
-			remaining = remaining[indexes[1]:]
-			offset += indexes[1]
-		}
-
-		n--
-	}
+	re.forEachMatch(b, n, func(loc []int) {
+		results = append(results, []int{loc[0], loc[1]})
+	})
 
 	return results
 }
@@ -767,35 +526,14 @@ func (re *PCREgexp) ReplaceAllLiteralString(src, repl string) string {
 // matched text.
 func (re *PCREgexp) ReplaceAllStringFunc(src string, repl func(string) string) string {
 	var b strings.Builder
-	remaining := src
-	lastMatchEnd := 0
-
-	for {
-		indexes := re.match(string2BytesUnsafe(remaining))
-		if len(indexes) < 2 {
-			b.WriteString(remaining[lastMatchEnd:])
-			break
-		}
-
-		b.WriteString(remaining[:indexes[0]])
-		match := remaining[indexes[0]:indexes[1]]
-		b.WriteString(repl(match))
+	last := 0
 
-		if indexes[0] == indexes[1] {
-			if indexes[1] >= len(remaining) {
-				break
-			}
-			r, size := utf8.DecodeRuneInString(remaining[indexes[1]:])
-			if r == utf8.RuneError {
-				b.WriteString(remaining[indexes[1]:])
-				break
-			}
-			b.WriteString(remaining[indexes[1] : indexes[1]+size])
-			remaining = remaining[indexes[1]+size:]
-		} else {
-			remaining = remaining[indexes[1]:]
-		}
-	}
+	re.forEachMatch(string2BytesUnsafe(src), -1, func(loc []int) {
+		b.WriteString(src[last:loc[0]])
+		b.WriteString(repl(src[loc[0]:loc[1]]))
+		last = loc[1]
+	})
+	b.WriteString(src[last:])
 
 	return b.String()
 }
@@ -803,84 +541,22 @@ func (re *PCREgexp) ReplaceAllStringFunc(src string, repl func(string) string) s
 // FindStringSubmatchIndex returns a slice holding the index pairs identifying
 // the leftmost match of the regexp in s and the matches of its subexpressions.
 func (re *PCREgexp) FindStringSubmatchIndex(s string) []int {
-	return re.match(string2BytesUnsafe(s))
+	return re.match(nil, string2BytesUnsafe(s))
 }
 
 // FindAllStringSubmatchIndex returns a slice of slices holding the index pairs
 // identifying the successive matches of the regexp in s and their
 // subexpressions.
 func (re *PCREgexp) FindAllStringSubmatchIndex(s string, n int) [][]int {
-	if n == 0 {
-		return nil
-	}
-
-	var results [][]int
-	remaining := s
-	offset := 0
-
-	for n != 0 {
-		indexes := re.match(string2BytesUnsafe(remaining))
-		if len(indexes) < 2 {
-			break
-		}
-
-		// Adjust indexes for the offset
-		adjIndexes := make([]int, len(indexes))
-		for i := 0; i < len(indexes); i++ {
-			if indexes[i] >= 0 {
-				adjIndexes[i] = indexes[i] + offset
-			} else {
-				adjIndexes[i] = indexes[i]
-			}
-		}
-		results = append(results, adjIndexes)
-
-		if indexes[0] == indexes[1] {
-			if indexes[1] >= len(remaining) {
-				break
-			}
-			_, size := utf8.DecodeRuneInString(remaining[indexes[1]:])
-			remaining = remaining[indexes[1]+size:]
-			offset += indexes[1] + size
-		} else {
-			remaining = remaining[indexes[1]:]
-			offset += indexes[1]
-		}
-
-		n--
-	}
-
-	return results
+	return re.FindAllSubmatchIndex(string2BytesUnsafe(s), n)
 }
 
 // FindAllSubmatch returns a slice of successive matches of the regexp in b.
 func (re *PCREgexp) FindAllSubmatch(b []byte, n int) [][][]byte {
-	if n == 0 {
-		return nil
-	}
-
 	var results [][][]byte
-	remaining := b
-
-	for n != 0 {
-		match := re.FindSubmatch(remaining)
-		if match == nil {
-			break
-		}
-		results = append(results, match)
-
-		if len(match[0]) == 0 {
-			if len(remaining) == 0 {
-				break
-			}
-			_, size := utf8.DecodeRune(remaining)
-			remaining = remaining[size:]
-		} else {
-			remaining = remaining[len(match[0]):]
-		}
-
-		n--
-	}
+	re.forEachMatch(b, n, func(loc []int) {
+		results = append(results, submatchBytes(b, loc))
+	})
 
 	return results
 }
@@ -888,52 +564,32 @@ func (re *PCREgexp) FindAllSubmatch(b []byte, n int) [][][]byte {
 // FindAllSubmatchIndex returns a slice of successive matches indexes of the
 // regexp in b.
 func (re *PCREgexp) FindAllSubmatchIndex(b []byte, n int) [][]int {
-	if n == 0 {
-		return nil
-	}
-
 	var results [][]int
-	remaining := b
-	offset := 0
-
-	for n != 0 {
-		indexes := re.match(remaining)
-		if len(indexes) < 2 {
-			break
-		}
-
-		// Adjust indexes for the offset
-		adjIndexes := make([]int, len(indexes))
-		for i := 0; i < len(indexes); i++ {
-			if indexes[i] >= 0 {
-				adjIndexes[i] = indexes[i] + offset
-			} else {
-				adjIndexes[i] = indexes[i]
-			}
-		}
-		results = append(results, adjIndexes)
-
-		if indexes[0] == indexes[1] {
-			if indexes[1] >= len(remaining) {
-				break
-			}
-			_, size := utf8.DecodeRune(remaining[indexes[1]:])
-			remaining = remaining[indexes[1]+size:]
-			offset += indexes[1] + size
-		} else {
-			remaining = remaining[indexes[1]:]
-			offset += indexes[1]
-		}
-
-		n--
-	}
+	re.forEachMatch(b, n, func(loc []int) {
+		results = append(results, append([]int(nil), loc...))
+	})
 
 	return results
 }
 
 // Expand appends template to dst and returns the result; during the
 // append, Expand replaces variables in the template with corresponding
-// matches drawn from src.
+// matches drawn from src. The match slice should have been returned by
+// [PCREgexp.FindSubmatchIndex].
+//
+// A variable is denoted by $ followed by digits, such as $1, which refers to
+// the submatch with the corresponding index, or by ${name}, where name is a
+// non-empty sequence of letters, digits, and underscores. A purely numeric
+// name like ${1} refers to the submatch with that index; other names refer to
+// named subexpressions, the first one that took part in the match if several
+// share the name. The digits after $ are taken to be as long as possible:
+// $1x is $1 followed by x, and $12 is ${12}. A reference to an out of range
+// or unmatched index or a name that is not present in the regular expression
+// is replaced with an empty slice. To insert a literal $ in the output, use
+// $$ in the template.
+//
+// Unlike the standard library, $name without braces is not a variable and is
+// copied to the output as is.
 func (re *PCREgexp) Expand(dst, template, src []byte, match []int) []byte {
 	return re.expand(dst, bytes2StringUnsafe(template), src, match, false)
 }
@@ -956,14 +612,20 @@ func (re *PCREgexp) expand(dst []byte, template string, src []byte, match []int,
 				group := 0
 				i++
 				for i < len(template) && template[i] >= '0' && template[i] <= '9' {
-					group = group*10 + int(template[i]-'0')
+					if group < 1e8 {
+						group = group*10 + int(template[i]-'0')
+					}
 					i++
 				}
 				i--
-				if 2*group < len(match) {
-					dst = append(dst, src[match[2*group]:match[2*group+1]]...)
-				}
+				dst = appendGroup(dst, src, match, group)
 				continue
+			case '{':
+				if name, ok := braced(template[i+2:]); ok {
+					dst = appendGroup(dst, src, match, re.groupIndex(name, match))
+					i += len(name) + 2
+					continue
+				}
 			}
 		}
 		dst = append(dst, template[i])
@@ -971,6 +633,51 @@ func (re *PCREgexp) expand(dst []byte, template string, src []byte, match []int,
 	return dst
 }
 
+// braced returns the name in a leading "name}" of str. If it is malformed,
+// braced returns ok == false.
+func braced(str string) (name string, ok bool) {
+	i := 0
+	for i < len(str) {
+		r, size := utf8.DecodeRuneInString(str[i:])
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			break
+		}
+		i += size
+	}
+
+	if i == 0 || i >= len(str) || str[i] != '}' {
+		return "", false
+	}
+
+	return str[:i], true
+}
+
+// groupIndex returns the index of the submatch a ${name} variable refers to:
+// the number name spells, or else the first subexpression called name that
+// is set in match. It returns -1 if there is none.
+func (re *PCREgexp) groupIndex(name string, match []int) int {
+	if num, err := strconv.Atoi(name); err == nil {
+		return num
+	}
+
+	for i, n := range re.names {
+		if n == name && 2*i+1 < len(match) && match[2*i] >= 0 {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// appendGroup appends the text of submatch group in match to dst, if set.
+func appendGroup(dst, src []byte, match []int, group int) []byte {
+	if group >= 0 && 2*group+1 < len(match) && match[2*group] >= 0 {
+		dst = append(dst, src[match[2*group]:match[2*group+1]]...)
+	}
+
+	return dst
+}
+
 // LiteralPrefix returns a literal string that must begin any match of the
 // regular expression. It also returns a boolean indicating whether the literal
 // is the entire regular expression.
@@ -994,15 +701,19 @@ func (re *PCREgexp) Longest() {
 
 // MarshalText implements the encoding.TextMarshaler interface.
 func (re *PCREgexp) MarshalText() ([]byte, error) {
-	return string2BytesUnsafe(re.String()), nil
+	return []byte(re.pattern), nil
 }
 
 // UnmarshalText implements the encoding.TextUnmarshaler interface.
+//
+// The regexp previously held by re, if any, is closed.
 func (re *PCREgexp) UnmarshalText(text []byte) error {
-	r, err := Compile(bytes2StringUnsafe(text))
+	// Copy text, the caller may reuse it.
+	r, err := Compile(string(text))
 	if err != nil {
 		return err
 	}
+	re.Close()
 	*re = *r
 	return nil
 }
@@ -1011,19 +722,26 @@ func (re *PCREgexp) UnmarshalText(text []byte) error {
 // in this regexp. The name for the first sub-expression is at index 1,
 // following the same convention as index in FindSubmatch.
 //
-// TODO(dwisiswant0): Implement this method.
+// Unnamed subexpressions have an empty name. The returned slice is a copy.
 func (re *PCREgexp) SubexpNames() []string {
-	// TODO(dwisiswant0): Implement using PCRE2_INFO_NAMECOUNT and
-	// PCRE2_INFO_NAMETABLE
-	return nil
+	return append([]string(nil), re.names...)
 }
 
 // SubexpIndex returns the index of the first subexpression with the given name,
 // or -1 if there is no subexpression with that name.
 //
-// TODO(dwisiswant0): Implement this method.
+// PCRE2 allows several subexpressions to share a name with (?J) or (?|...);
+// the lowest-numbered one is returned.
 func (re *PCREgexp) SubexpIndex(name string) int {
-	// TODO(dwisiswant0): Implement using PCRE2_INFO_NAMECOUNT and
-	// PCRE2_INFO_NAMETABLE
+	if name == "" {
+		return -1
+	}
+
+	for i, n := range re.names {
+		if n == name {
+			return i
+		}
+	}
+
 	return -1
 }
@@ -388,3 +388,64 @@ func BenchmarkRuneReader(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkAppend(b *testing.B) {
+	pcre := pcregexp.MustCompile(`p([a-z]+)ch`)
+	defer pcre.Close()
+
+	text := []byte("peach punch pinch")
+	repl := []byte("X")
+
+	b.Run("AppendSubmatchIndex", func(b *testing.B) {
+		dst := make([]int, 0, 16)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			dst = pcre.AppendSubmatchIndex(dst[:0], text)
+		}
+	})
+
+	b.Run("AppendReplace", func(b *testing.B) {
+		dst := make([]byte, 0, 64)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			dst = pcre.AppendReplace(dst[:0], text, repl)
+		}
+	})
+
+	b.Run("Count", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			pcre.Count(text)
+		}
+	})
+}
+
+func BenchmarkMatchEach(b *testing.B) {
+	pcre := pcregexp.MustCompile(`p([a-z]+)ch`)
+	defer pcre.Close()
+
+	subjects := make([][]byte, 1024)
+	for i := range subjects {
+		subjects[i] = []byte("peach punch pinch")
+	}
+
+	b.Run("Match", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, s := range subjects {
+				pcre.Match(s)
+			}
+		}
+	})
+
+	b.Run("MatchEach", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			pcre.MatchEach(subjects)
+		}
+	})
+
+	b.Run("MatchEachParallel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			pcre.MatchEachParallel(subjects, 0)
+		}
+	})
+}
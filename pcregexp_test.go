@@ -3,6 +3,8 @@ package pcregexp_test
 import (
 	"bytes"
 	"reflect"
+	"regexp"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
@@ -495,6 +497,80 @@ func TestRegexp_Split(t *testing.T) {
 	}
 }
 
+func TestRegexp_SplitLikeStd(t *testing.T) {
+	// Patterns whose empty matches PCRE2 iterates over differently from
+	// the standard library are left out.
+	tests := []struct {
+		pattern, input string
+	}{
+		{`a+`, "abaabaccadaaae"},
+		{`a*`, ""},
+		{`x*`, "abc"},
+		{`,`, ",a,,b,"},
+		{`\s+`, " a  b "},
+		{`b`, "b"},
+	}
+
+	for _, tt := range tests {
+		re := pcregexp.MustCompile(tt.pattern)
+		std := regexp.MustCompile(tt.pattern)
+
+		for _, n := range []int{-1, 0, 1, 2, 5} {
+			if got, want := re.Split(tt.input, n), std.Split(tt.input, n); !reflect.DeepEqual(got, want) {
+				t.Errorf("Split(%q, %d) with pattern %q = %q, want %q", tt.input, n, tt.pattern, got, want)
+			}
+		}
+
+		re.Close()
+	}
+}
+
+func TestRegexp_FindAllFromOffsets(t *testing.T) {
+	// Successive matches are searched for in the whole subject, so that ^
+	// and lookbehind assertions see the text before each search.
+	tests := []struct {
+		pattern, input string
+		want           [][]int
+	}{
+		{`^a`, "aaa", [][]int{{0, 1}}},
+		{`(?<=a)a`, "aaa", [][]int{{1, 2}, {2, 3}}},
+		{`\bb`, "ab b", [][]int{{3, 4}}},
+	}
+
+	for _, tt := range tests {
+		re := pcregexp.MustCompile(tt.pattern)
+		if got := re.FindAllStringIndex(tt.input, -1); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FindAllStringIndex(%q) with pattern %q = %v, want %v", tt.input, tt.pattern, got, tt.want)
+		}
+		re.Close()
+	}
+}
+
+func TestRegexp_EmptySubject(t *testing.T) {
+	re := pcregexp.MustCompile(`a*`)
+	defer re.Close()
+
+	if !re.MatchString("") || !re.Match(nil) {
+		t.Error("MatchString(\"\") = false, want true")
+	}
+	if got := re.FindStringIndex(""); !reflect.DeepEqual(got, []int{0, 0}) {
+		t.Errorf("FindStringIndex(\"\") = %v, want [0 0]", got)
+	}
+	if got := re.FindAllStringIndex("", -1); !reflect.DeepEqual(got, [][]int{{0, 0}}) {
+		t.Errorf("FindAllStringIndex(\"\") = %v, want [[0 0]]", got)
+	}
+	if got := re.ReplaceAllString("", "x"); got != "x" {
+		t.Errorf("ReplaceAllString(\"\") = %q, want %q", got, "x")
+	}
+
+	re2 := pcregexp.MustCompile(`a`)
+	defer re2.Close()
+
+	if re2.MatchString("") || re2.FindStringIndex("") != nil {
+		t.Error("`a` matches the empty string")
+	}
+}
+
 func TestRegexp_Utility(t *testing.T) {
 	pattern := `p([a-z]+)ch`
 	re := pcregexp.MustCompile(pattern)
@@ -506,12 +582,12 @@ func TestRegexp_Utility(t *testing.T) {
 		}
 	})
 
-	// t.Run("NumSubexp", func(t *testing.T) {
-	// 	want := 1
-	// 	if got := re.NumSubexp(); got != want {
-	// 		t.Errorf("NumSubexp() = %d, want %d", got, want)
-	// 	}
-	// })
+	t.Run("NumSubexp", func(t *testing.T) {
+		want := 1
+		if got := re.NumSubexp(); got != want {
+			t.Errorf("NumSubexp() = %d, want %d", got, want)
+		}
+	})
 }
 
 func TestRegexp_FindAllSubmatch(t *testing.T) {
@@ -560,6 +636,32 @@ func TestRegexp_Expand(t *testing.T) {
 			t.Errorf("ExpandString() = %q, want %q", got, want)
 		}
 	})
+
+	t.Run("Templates", func(t *testing.T) {
+		re := pcregexp.MustCompile(`(?<first>\w+)\s(?<last>\w+)(x)?`)
+		defer re.Close()
+
+		src := "Ada Lovelace"
+		match := re.FindStringSubmatchIndex(src)
+
+		tests := map[string]string{
+			"${last}, ${first}": "Lovelace, Ada",
+			"$last, $first":     "$last, $first", // only ${name} refers to names
+			"$1x":               "Adax",
+			"${1}x":             "Adax",
+			"$01$2":             "AdaLovelace",
+			"$3|$9|${nope}":     "||",
+			"$$1 and $":         "$1 and $",
+			"${bad":             "${bad",
+			"${}":               "${}",
+		}
+
+		for template, want := range tests {
+			if got := re.ExpandString(nil, template, src, match); string(got) != want {
+				t.Errorf("ExpandString(%q) = %q, want %q", template, got, want)
+			}
+		}
+	})
 }
 
 func TestRegexp_Marshal(t *testing.T) {
@@ -713,3 +815,106 @@ func TestReDoS(t *testing.T) {
 		})
 	}
 }
+
+func TestRegexp_Close(t *testing.T) {
+	t.Run("idempotent", func(t *testing.T) {
+		re := pcregexp.MustCompile(`p([a-z]+)ch`)
+		re.Close()
+		re.Close()
+	})
+
+	t.Run("use after close", func(t *testing.T) {
+		re := pcregexp.MustCompile(`p([a-z]+)ch`)
+		re.Close()
+
+		if re.MatchString("peach") {
+			t.Error("MatchString() = true after Close")
+		}
+		if got := re.FindStringIndex("peach"); got != nil {
+			t.Errorf("FindStringIndex() = %v after Close, want nil", got)
+		}
+		if got := re.FindAllString("peach punch", -1); got != nil {
+			t.Errorf("FindAllString() = %v after Close, want nil", got)
+		}
+		if got := re.ReplaceAllString("peach", "X"); got != "peach" {
+			t.Errorf("ReplaceAllString() = %q after Close, want %q", got, "peach")
+		}
+	})
+
+	t.Run("finalizer", func(t *testing.T) {
+		if err := pcregexp.SetMatchContext(pcregexp.MatchContext{MatchLimit: 1000000}); err != nil {
+			t.Fatalf("SetMatchContext() error = %v", err)
+		}
+		defer pcregexp.SetMatchContext(pcregexp.MatchContext{})
+
+		keep := pcregexp.MustCompile(`(\w+)\s+\1`)
+		defer keep.Close()
+
+		// Leak patterns on purpose and let the garbage collector free them.
+		for i := 0; i < 100; i++ {
+			re := pcregexp.MustCompile(`p([a-z]+)ch`)
+			re.MatchString("peach")
+		}
+
+		runtime.GC()
+		runtime.GC()
+
+		if !keep.MatchString("hello hello") {
+			t.Error("MatchString() = false after unreachable patterns were finalized")
+		}
+	})
+
+	t.Run("UnmarshalText", func(t *testing.T) {
+		var re pcregexp.PCREgexp
+		if err := re.UnmarshalText([]byte(`foo`)); err != nil {
+			t.Fatalf("UnmarshalText() error = %v", err)
+		}
+
+		text := []byte(`p([a-z]+)ch`)
+		if err := re.UnmarshalText(text); err != nil {
+			t.Fatalf("UnmarshalText() error = %v", err)
+		}
+		defer re.Close()
+
+		// The pattern must not alias the caller's buffer.
+		copy(text, "xxxxxxxxxxx")
+
+		runtime.GC()
+		runtime.GC()
+
+		if got := re.String(); got != `p([a-z]+)ch` {
+			t.Errorf("String() = %q, want %q", got, `p([a-z]+)ch`)
+		}
+		if !re.MatchString("peach") {
+			t.Error("MatchString() = false after UnmarshalText")
+		}
+	})
+}
+
+func TestNeedsPCRE(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    bool
+	}{
+		{`p([a-z]+)ch`, false},
+		{`\x{e9}\pL\p{Greek}\A\z\f\a`, false},
+		{`(?P<word>\w+)`, false},
+		{`(?<=foo)bar`, true},
+		{`(\w+)\s+\1`, true},
+		{`a++`, true},
+		{`a\Z`, true},
+		{`(*FAIL)|a`, true},
+		{`\Qa(?=\E`, false},
+		{`(`, false},
+		{`(?<=a`, false},
+		{"a\xffb", false},
+		{"(?<=a)\xff", false},
+		{`((a{100}){100}){100}`, true},
+	}
+
+	for _, tt := range tests {
+		if got := pcregexp.NeedsPCRE(tt.pattern); got != tt.want {
+			t.Errorf("NeedsPCRE(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
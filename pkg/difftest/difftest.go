@@ -0,0 +1,580 @@
+// Package difftest runs the same patterns through the standard library
+// regexp package and PCREgexp, and reports where their results differ.
+//
+// [Compare] calls every Find, Match, Replace and Split method of both
+// engines on a pattern of the syntax they share, and returns a [Divergence]
+// for each method whose results differ, classified by its likely cause:
+// the handling of empty matches, $ before a final newline, ^ after one,
+// UTF-8, unset capture groups, empty iterations of a repetition,
+// replacement templates, the empty pattern, or a pattern only one engine
+// compiles. The cause is found by matching again in a way that removes a
+// difference: with the PCRE2 options of the difference, such as
+// PCRE2_DOLLAR_ENDONLY for $, or iterating over empty matches the way the
+// standard library does. Divergences left unexplained are [KindOther], and
+// worth a look.
+//
+// [Pattern] and [Subject] build patterns and subjects from random bytes, so
+// that native Go fuzzing explores the syntax shared by RE2 and PCRE:
+//
+//	func FuzzEngines(f *testing.F) {
+//		f.Fuzz(func(t *testing.T, pattern, subject []byte) {
+//			divs, err := difftest.Compare(difftest.Pattern(pattern), difftest.Subject(subject))
+//			if err != nil {
+//				t.Skip(err)
+//			}
+//			for _, d := range divs {
+//				if d.Kind == difftest.KindOther {
+//					t.Error(d)
+//				}
+//			}
+//		})
+//	}
+package difftest
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+// Kind is the likely cause of a divergence.
+type Kind int
+
+const (
+	// KindOther is a divergence of unknown cause.
+	KindOther Kind = iota
+
+	// KindCompile is a pattern that only the standard library compiles,
+	// such as \x{100} outside of UTF mode.
+	KindCompile
+
+	// KindEmptyMatch is a difference in which empty matches are found
+	// when iterating over matches: the standard library skips an empty
+	// match right after another match, PCREgexp does not.
+	KindEmptyMatch
+
+	// KindDollar is a difference of $, which PCRE2 also matches before a
+	// newline at the end of the subject, unless PCRE2_DOLLAR_ENDONLY.
+	KindDollar
+
+	// KindUTF8 is a difference in the handling of UTF-8: without UTF mode,
+	// PCRE2 matches bytes rather than runes, and \x{hh} is a byte.
+	KindUTF8
+
+	// KindUnsetGroups is a submatch result that PCREgexp truncates after
+	// the last group taking part in the match, where the standard library
+	// returns every group.
+	KindUnsetGroups
+
+	// KindTemplate is a replacement template, which the ReplaceAll methods
+	// of PCREgexp insert literally rather than expand.
+	KindTemplate
+
+	// KindEmptyIteration is a repetition of a group that can match empty
+	// text, such as (a*)*, which PCRE2 stops at an empty iteration the
+	// standard library does not take: the group is set to the empty text,
+	// or the match ends there where the standard library goes on.
+	KindEmptyIteration
+
+	// KindCircumflex is a difference of ^ in multiline mode, which PCRE2
+	// does not match after a newline at the end of the subject, unless
+	// PCRE2_ALT_CIRCUMFLEX.
+	KindCircumflex
+
+	// KindEmptyPattern is the empty pattern, which [pcregexp.Compile]
+	// compiles to a regexp that matches nothing.
+	KindEmptyPattern
+)
+
+var kindNames = [...]string{
+	KindOther:       "other",
+	KindCompile:     "compile",
+	KindEmptyMatch:  "empty match",
+	KindDollar:      "dollar",
+	KindUTF8:        "UTF-8",
+	KindUnsetGroups: "unset groups",
+	KindTemplate:    "template",
+
+	KindEmptyIteration: "empty iteration",
+	KindCircumflex:     "circumflex",
+	KindEmptyPattern:   "empty pattern",
+}
+
+// String returns the name of k.
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+
+	return kindNames[k]
+}
+
+// Divergence is a method whose results differ between the engines.
+type Divergence struct {
+	Kind    Kind
+	Pattern string
+	Subject string
+
+	// Method is the method called, with its arguments other than the
+	// subject, such as "FindAllStringIndex(-1)".
+	Method string
+
+	// Std and PCRE are the results of the engines, formatted with %q for
+	// strings and with nil for no match.
+	Std, PCRE string
+}
+
+// String returns a description of d.
+func (d Divergence) String() string {
+	return fmt.Sprintf("%s: %s on %q with %q: std %s, pcre %s", d.Kind, d.Method, d.Pattern, d.Subject, d.Std, d.PCRE)
+}
+
+// Template is the replacement template the Replace methods are called
+// with.
+const Template = "<$1>"
+
+// Compare runs pattern and subject through every method of both engines,
+// and returns the divergences. It returns an error if the standard library
+// does not compile pattern, or if PCRE2 cannot be loaded.
+func Compare(pattern, subject string) ([]Divergence, error) {
+	std, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pcregexp.Init(); err != nil {
+		return nil, err
+	}
+
+	re, err := pcregexp.Compile(pattern)
+	if err != nil {
+		return []Divergence{{
+			Kind:    KindCompile,
+			Pattern: pattern,
+			Subject: subject,
+			Method:  "Compile",
+			Std:     "nil",
+			PCRE:    fmt.Sprintf("%q", err.Error()),
+		}}, nil
+	}
+	defer re.Close()
+
+	return CompareWith(std, re, subject), nil
+}
+
+// CompareWith is like [Compare], but for a pattern compiled by the caller
+// for both engines, for instance with PCRE2 options. Divergences are
+// attributed by compiling the pattern of re again with more options.
+func CompareWith(std *regexp.Regexp, re *pcregexp.PCREgexp, subject string) []Divergence {
+	c := &comparison{
+		std:      std,
+		re:       re,
+		subject:  subject,
+		groups:   std.NumSubexp() + 1,
+		variants: make(map[pcregexp.CompileFlag]*pcregexp.PCREgexp),
+	}
+
+	var divs []Divergence
+	for _, m := range methods {
+		want, got := c.call(m, std), c.call(m, re)
+		if want == got {
+			continue
+		}
+
+		divs = append(divs, Divergence{
+			Kind:    c.classify(m, want, got),
+			Pattern: re.String(),
+			Subject: subject,
+			Method:  m.name,
+			Std:     want,
+			PCRE:    got,
+		})
+	}
+	c.close()
+
+	return divs
+}
+
+// engine holds the methods shared by the engines.
+type engine interface {
+	Find(b []byte) []byte
+	FindIndex(b []byte) []int
+	FindString(s string) string
+	FindStringIndex(s string) []int
+	FindSubmatch(b []byte) [][]byte
+	FindSubmatchIndex(b []byte) []int
+	FindStringSubmatch(s string) []string
+	FindStringSubmatchIndex(s string) []int
+	FindAll(b []byte, n int) [][]byte
+	FindAllIndex(b []byte, n int) [][]int
+	FindAllString(s string, n int) []string
+	FindAllStringIndex(s string, n int) [][]int
+	FindAllSubmatch(b []byte, n int) [][][]byte
+	FindAllSubmatchIndex(b []byte, n int) [][]int
+	FindAllStringSubmatch(s string, n int) [][]string
+	FindAllStringSubmatchIndex(s string, n int) [][]int
+	FindReaderIndex(r io.RuneReader) []int
+	Match(b []byte) bool
+	MatchString(s string) bool
+	ReplaceAll(src, repl []byte) []byte
+	ReplaceAllString(src, repl string) string
+	ReplaceAllLiteral(src, repl []byte) []byte
+	ReplaceAllLiteralString(src, repl string) string
+	ReplaceAllFunc(src []byte, repl func([]byte) []byte) []byte
+	ReplaceAllStringFunc(src string, repl func(string) string) string
+	Split(s string, n int) []string
+	NumSubexp() int
+	SubexpNames() []string
+}
+
+// method is a method called on both engines.
+type method struct {
+	name string
+	call func(e engine, s string) any
+
+	// Whether the method returns groups, which PCREgexp may truncate, or
+	// iterates over matches.
+	submatch, global bool
+}
+
+// The results of the Replace methods taking bytes are compared as strings:
+// the standard library returns nil rather than an empty slice when nothing
+// is left.
+var methods = []method{
+	{name: "Find", call: func(e engine, s string) any { return e.Find([]byte(s)) }},
+	{name: "FindIndex", call: func(e engine, s string) any { return e.FindIndex([]byte(s)) }},
+	{name: "FindString", call: func(e engine, s string) any { return e.FindString(s) }},
+	{name: "FindStringIndex", call: func(e engine, s string) any { return e.FindStringIndex(s) }},
+	{name: "FindSubmatch", call: func(e engine, s string) any { return e.FindSubmatch([]byte(s)) }, submatch: true},
+	{name: "FindSubmatchIndex", call: func(e engine, s string) any { return e.FindSubmatchIndex([]byte(s)) }, submatch: true},
+	{name: "FindStringSubmatch", call: func(e engine, s string) any { return e.FindStringSubmatch(s) }, submatch: true},
+	{name: "FindStringSubmatchIndex", call: func(e engine, s string) any { return e.FindStringSubmatchIndex(s) }, submatch: true},
+	{name: "FindAll(-1)", call: func(e engine, s string) any { return e.FindAll([]byte(s), -1) }, global: true},
+	{name: "FindAll(2)", call: func(e engine, s string) any { return e.FindAll([]byte(s), 2) }, global: true},
+	{name: "FindAllIndex(-1)", call: func(e engine, s string) any { return e.FindAllIndex([]byte(s), -1) }, global: true},
+	{name: "FindAllString(-1)", call: func(e engine, s string) any { return e.FindAllString(s, -1) }, global: true},
+	{name: "FindAllStringIndex(-1)", call: func(e engine, s string) any { return e.FindAllStringIndex(s, -1) }, global: true},
+	{name: "FindAllSubmatch(-1)", call: func(e engine, s string) any { return e.FindAllSubmatch([]byte(s), -1) }, submatch: true, global: true},
+	{name: "FindAllSubmatchIndex(-1)", call: func(e engine, s string) any { return e.FindAllSubmatchIndex([]byte(s), -1) }, submatch: true, global: true},
+	{name: "FindAllStringSubmatch(-1)", call: func(e engine, s string) any { return e.FindAllStringSubmatch(s, -1) }, submatch: true, global: true},
+	{name: "FindAllStringSubmatchIndex(-1)", call: func(e engine, s string) any { return e.FindAllStringSubmatchIndex(s, -1) }, submatch: true, global: true},
+	{name: "FindReaderIndex", call: func(e engine, s string) any { return e.FindReaderIndex(strings.NewReader(s)) }},
+	{name: "Match", call: func(e engine, s string) any { return e.Match([]byte(s)) }},
+	{name: "MatchString", call: func(e engine, s string) any { return e.MatchString(s) }},
+	{name: "ReplaceAll", call: func(e engine, s string) any { return string(e.ReplaceAll([]byte(s), []byte(Template))) }, global: true},
+	{name: "ReplaceAllString", call: func(e engine, s string) any { return e.ReplaceAllString(s, Template) }, global: true},
+	{name: "ReplaceAllLiteral", call: func(e engine, s string) any { return string(e.ReplaceAllLiteral([]byte(s), []byte(Template))) }, global: true},
+	{name: "ReplaceAllLiteralString", call: func(e engine, s string) any { return e.ReplaceAllLiteralString(s, Template) }, global: true},
+	{name: "ReplaceAllFunc", call: func(e engine, s string) any {
+		return string(e.ReplaceAllFunc([]byte(s), func(b []byte) []byte { return []byte("<" + string(b) + ">") }))
+	}, global: true},
+	{name: "ReplaceAllStringFunc", call: func(e engine, s string) any {
+		return e.ReplaceAllStringFunc(s, func(m string) string { return "<" + m + ">" })
+	}, global: true},
+	{name: "Split(-1)", call: func(e engine, s string) any { return e.Split(s, -1) }, global: true},
+	{name: "Split(2)", call: func(e engine, s string) any { return e.Split(s, 2) }, global: true},
+	{name: "NumSubexp", call: func(e engine, s string) any { return e.NumSubexp() }},
+	{name: "SubexpNames", call: func(e engine, s string) any { return e.SubexpNames() }},
+}
+
+// comparison holds the state of [CompareWith].
+type comparison struct {
+	std     *regexp.Regexp
+	re      *pcregexp.PCREgexp
+	subject string
+	groups  int // number of groups of the pattern, including the match
+
+	// Variants of re with the options that remove a kind of divergence,
+	// compiled on first use; nil if the pattern does not compile with them.
+	// Copies of the comparison share them.
+	variants map[pcregexp.CompileFlag]*pcregexp.PCREgexp
+}
+
+// call calls m on e, and returns its formatted result.
+func (c *comparison) call(m method, e engine) string {
+	return format(m.call(e, c.subject))
+}
+
+// padded is like call, but pads submatch results to the number of groups.
+func (c *comparison) padded(m method, e engine) string {
+	v := m.call(e, c.subject)
+	if m.submatch {
+		v = pad(v, c.groups)
+	}
+
+	return format(v)
+}
+
+// classify returns the kind of the divergence of m, whose results are want
+// with the standard library and got with PCREgexp.
+func (c *comparison) classify(m method, want, got string) Kind {
+	if c.re.String() == "" && !c.re.MatchString("") {
+		return KindEmptyPattern
+	}
+
+	if m.submatch && c.padded(m, c.re) == want {
+		return KindUnsetGroups
+	}
+
+	// PCREgexp inserts templates literally, so the results of the other
+	// options are checked against a literal replacement.
+	template := m.name == "ReplaceAll" || m.name == "ReplaceAllString"
+	if template {
+		want = format(c.std.ReplaceAllLiteralString(c.subject, Template))
+		if got == want {
+			return KindTemplate
+		}
+	}
+
+	if kind, ok := c.explain(m, want); ok {
+		return kind
+	}
+
+	if c.hasEmptyIteration() {
+		return KindEmptyIteration
+	}
+
+	// The standard library reads each byte of invalid UTF-8 as U+FFFD,
+	// which PCRE2 never matches in UTF mode, and FindReaderIndex of
+	// PCREgexp matches the runes read: the difference is one of UTF-8 if
+	// the engines agree on the subject holding U+FFFD instead.
+	if !utf8.ValidString(c.subject) {
+		f := *c
+		f.subject = replaceInvalid(c.subject)
+
+		want := f.call(m, f.std)
+		if template {
+			want = format(f.std.ReplaceAllLiteralString(f.subject, Template))
+		}
+
+		if f.padded(m, f.re) == want {
+			return KindUTF8
+		}
+		if _, ok := f.explain(m, want); ok {
+			return KindUTF8
+		}
+	}
+
+	return KindOther
+}
+
+// explain returns the kind of the variant of re whose results of m are
+// want. The variants are tried as they are first, then iterating over
+// empty matches the way the standard library does; re itself agrees that
+// way when the divergence is one of [KindEmptyMatch].
+func (c *comparison) explain(m method, want string) (Kind, bool) {
+	for _, v := range variants {
+		if re := c.compatVariant(v.flags); re != nil && c.padded(m, re) == want {
+			return v.kind, true
+		}
+	}
+
+	if !m.global {
+		return KindOther, false
+	}
+
+	if c.padded(m, goIteration{c.re}) == want {
+		return KindEmptyMatch, true
+	}
+
+	for _, v := range variants {
+		if re := c.compatVariant(v.flags); re != nil && c.padded(m, goIteration{re}) == want {
+			return v.kind, true
+		}
+	}
+
+	return KindOther, false
+}
+
+// compatVariant is like variant, but returns nil for a variant in UTF mode
+// without PCRE2_MATCH_INVALID_UTF if the subject is not valid UTF-8.
+func (c *comparison) compatVariant(flags pcregexp.CompileFlag) *pcregexp.PCREgexp {
+	if flags&pcregexp.CompileMatchInvalidUTF == 0 && flags&pcregexp.CompileUTF != 0 && !utf8.ValidString(c.subject) {
+		return nil
+	}
+
+	return c.variant(flags)
+}
+
+// replaceInvalid returns s with every byte of invalid UTF-8 replaced by
+// U+FFFD, as the standard library reads it.
+func replaceInvalid(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		r, width := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && width == 1 {
+			sb.WriteRune(utf8.RuneError)
+		} else {
+			sb.WriteString(s[i : i+width])
+		}
+		i += width
+	}
+
+	return sb.String()
+}
+
+// variants are the compile options that remove a kind of divergence, in
+// the order they are tried.
+var variants = []struct {
+	flags pcregexp.CompileFlag
+	kind  Kind
+}{
+	{pcregexp.CompileDollarEndOnly, KindDollar},
+	{pcregexp.CompileAltCircumflex, KindCircumflex},
+	{pcregexp.CompileDollarEndOnly | pcregexp.CompileAltCircumflex, KindDollar},
+	{pcregexp.CompileUTF | pcregexp.CompileMatchInvalidUTF, KindUTF8},
+	{pcregexp.CompileUTF | pcregexp.CompileMatchInvalidUTF | pcregexp.CompileDollarEndOnly | pcregexp.CompileAltCircumflex, KindUTF8},
+
+	// Without PCRE2_MATCH_INVALID_UTF, for valid subjects only.
+	{pcregexp.CompileUTF, KindUTF8},
+	{pcregexp.CompileUTF | pcregexp.CompileDollarEndOnly | pcregexp.CompileAltCircumflex, KindUTF8},
+}
+
+// variant returns the pattern compiled with the extra compile options.
+func (c *comparison) variant(flags pcregexp.CompileFlag) *pcregexp.PCREgexp {
+	if re, ok := c.variants[flags]; ok {
+		return re
+	}
+
+	re, err := pcregexp.CompileWithOptions(c.re.String(), pcregexp.CompileOptions{Flags: flags})
+	if err != nil {
+		re = nil
+	}
+
+	c.variants[flags] = re
+
+	return re
+}
+
+// hasEmptyIteration reports whether the first difference between the
+// matches of the engines is one of an empty iteration: PCREgexp either finds
+// the same match with some groups set to empty text, or a match starting at
+// the same place that ends where PCRE2 stopped at an empty iteration of a
+// repetition. The matches are iterated over the way the standard library
+// does, and those after a match of another extent may differ as well.
+func (c *comparison) hasEmptyIteration() bool {
+	if !hasNullableRepeat(c.std.String()) {
+		return false
+	}
+
+	want := c.std.FindAllStringSubmatchIndex(c.subject, -1)
+	got := pad(goIteration{c.re}.FindAllStringSubmatchIndex(c.subject, -1), c.groups).([][]int)
+
+	found := false
+	for i := 0; i < len(want) && i < len(got); i++ {
+		if want[i][0] != got[i][0] {
+			return false
+		}
+		if want[i][1] != got[i][1] {
+			return true
+		}
+
+		for j := 2; j < len(want[i]); j += 2 {
+			if want[i][j] == got[i][j] && want[i][j+1] == got[i][j+1] {
+				continue
+			}
+			if got[i][j] < 0 || got[i][j] != got[i][j+1] {
+				return false
+			}
+			found = true
+		}
+	}
+
+	return found
+}
+
+// close closes the variants.
+func (c *comparison) close() {
+	for _, re := range c.variants {
+		if re != nil {
+			re.Close()
+		}
+	}
+}
+
+// pad pads the submatch results of v to n groups, with nil, "" or -1 for
+// the groups missing.
+func pad(v any, n int) any {
+	switch v := v.(type) {
+	case [][]byte:
+		for v != nil && len(v) < n {
+			v = append(v, nil)
+		}
+		return v
+	case []string:
+		for v != nil && len(v) < n {
+			v = append(v, "")
+		}
+		return v
+	case []int:
+		for v != nil && len(v) < 2*n {
+			v = append(v, -1)
+		}
+		return v
+	case [][][]byte:
+		for i := range v {
+			v[i] = pad(v[i], n).([][]byte)
+		}
+		return v
+	case [][]string:
+		for i := range v {
+			v[i] = pad(v[i], n).([]string)
+		}
+		return v
+	case [][]int:
+		for i := range v {
+			v[i] = pad(v[i], n).([]int)
+		}
+		return v
+	}
+
+	return v
+}
+
+// format formats the result of a method, telling nil from empty results.
+func format(v any) string {
+	switch v := v.(type) {
+	case []byte:
+		if v == nil {
+			return "nil"
+		}
+		return fmt.Sprintf("%q", v)
+	case string:
+		return fmt.Sprintf("%q", v)
+	case []int:
+		if v == nil {
+			return "nil"
+		}
+		return fmt.Sprint(v)
+	case [][]byte:
+		return formatSlice(v == nil, len(v), func(i int) string { return format(v[i]) })
+	case []string:
+		return formatSlice(v == nil, len(v), func(i int) string { return format(v[i]) })
+	case [][]int:
+		return formatSlice(v == nil, len(v), func(i int) string { return format(v[i]) })
+	case [][][]byte:
+		return formatSlice(v == nil, len(v), func(i int) string { return format(v[i]) })
+	case [][]string:
+		return formatSlice(v == nil, len(v), func(i int) string { return format(v[i]) })
+	}
+
+	return fmt.Sprint(v)
+}
+
+// formatSlice formats a slice of n elements.
+func formatSlice(isNil bool, n int, elem func(i int) string) string {
+	if isNil {
+		return "nil"
+	}
+
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = elem(i)
+	}
+
+	return "[" + strings.Join(parts, " ") + "]"
+}
@@ -0,0 +1,165 @@
+package difftest_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/dwisiswant0/pcregexp"
+	"github.com/dwisiswant0/pcregexp/pkg/difftest"
+)
+
+// seeds are the known edge cases, with the kind of divergence of the method
+// checked; "" for none but templates, which PCREgexp never expands.
+var seeds = []struct {
+	pattern, subject string
+	method           string
+	kind             difftest.Kind
+}{
+	{`a*`, "baaa", "FindAllString(-1)", difftest.KindEmptyMatch},
+	{`a*`, "baaa", "ReplaceAllLiteralString", difftest.KindEmptyMatch},
+	{`a$`, "a\n", "FindStringIndex", difftest.KindDollar},
+	{`$`, "a\n", "FindAllStringIndex(-1)", difftest.KindDollar},
+	{`.`, "é", "FindString", difftest.KindUTF8},
+	{`^.$`, "é", "MatchString", difftest.KindUTF8},
+	{`(a)|(b)`, "a", "FindStringSubmatch", difftest.KindUnsetGroups},
+	{`(a)|(b)`, "a", "FindSubmatchIndex", difftest.KindUnsetGroups},
+	{`(a)`, "xay", "ReplaceAllString", difftest.KindTemplate},
+	{`(?:([^a]*))*`, "baa", "FindSubmatchIndex", difftest.KindEmptyIteration},
+	{`(?:(?:[^a]|[^a])*|a)*`, "AcAcaaA", "FindIndex", difftest.KindEmptyIteration},
+	{`(?:(?:[^a]|[^a])*|a)*`, "AcAcaaA", "FindReaderIndex", difftest.KindEmptyIteration},
+	{`(?:(?:[^a]|[^a])*|a)*`, "AcAcaaA", "FindAllString(-1)", difftest.KindEmptyIteration},
+	{`\x{100}`, "Ā", "Compile", difftest.KindCompile},
+	{`a+b`, "xaab", "", 0},
+	{`(?i)(a)(?P<x>b)?`, "AB ab", "", 0},
+	{`\bx\B`, "x xy", "", 0},
+	{``, "", "MatchString", difftest.KindEmptyPattern},
+}
+
+func TestCompare_Seeds(t *testing.T) {
+	for _, tt := range seeds {
+		divs, err := difftest.Compare(tt.pattern, tt.subject)
+		if err != nil {
+			t.Fatalf("Compare(%q, %q) error = %v", tt.pattern, tt.subject, err)
+		}
+
+		if tt.method == "" {
+			for _, d := range divs {
+				if d.Kind == difftest.KindTemplate {
+					continue
+				}
+				t.Errorf("Compare(%q, %q): unexpected divergence %v", tt.pattern, tt.subject, d)
+			}
+			continue
+		}
+
+		found := false
+		for _, d := range divs {
+			if d.Method != tt.method {
+				continue
+			}
+			found = true
+			if d.Kind != tt.kind {
+				t.Errorf("Compare(%q, %q): %s kind = %v, want %v (%v)", tt.pattern, tt.subject, d.Method, d.Kind, tt.kind, d)
+			}
+		}
+		if !found {
+			t.Errorf("Compare(%q, %q): no divergence of %s, want %v", tt.pattern, tt.subject, tt.method, tt.kind)
+		}
+	}
+}
+
+func TestCompare_Error(t *testing.T) {
+	if _, err := difftest.Compare(`(?<=a)b`, "ab"); err == nil {
+		t.Error("Compare() of a pattern std rejects error = nil, want error")
+	}
+}
+
+func TestCompareWith(t *testing.T) {
+	std := regexp.MustCompile(`a$`)
+	re, err := pcregexp.CompileWithOptions(`a$`, pcregexp.CompileOptions{Flags: pcregexp.CompileDollarEndOnly})
+	if err != nil {
+		t.Fatalf("CompileWithOptions() error = %v", err)
+	}
+	defer re.Close()
+
+	if divs := difftest.CompareWith(std, re, "a\n"); len(divs) != 0 {
+		t.Errorf("CompareWith() = %v, want no divergence", divs)
+	}
+}
+
+func TestDivergence_String(t *testing.T) {
+	d := difftest.Divergence{
+		Kind:    difftest.KindDollar,
+		Pattern: "a$",
+		Subject: "a\n",
+		Method:  "FindStringIndex",
+		Std:     "nil",
+		PCRE:    "[0 1]",
+	}
+
+	want := `dollar: FindStringIndex on "a$" with "a\n": std nil, pcre [0 1]`
+	if got := d.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	if got := difftest.Kind(42).String(); got != "Kind(42)" {
+		t.Errorf("Kind(42).String() = %q", got)
+	}
+}
+
+func TestPattern(t *testing.T) {
+	for i := 0; i < 500; i++ {
+		data := []byte(strings.Repeat(string(rune('a'+i%26)), i%7) + string(rune(i)))
+
+		p := difftest.Pattern(data)
+		if p != difftest.Pattern(data) {
+			t.Fatalf("Pattern(%q) is not deterministic", data)
+		}
+		if _, err := regexp.Compile(p); err != nil {
+			t.Errorf("Pattern(%q) = %q, which std rejects: %v", data, p, err)
+		}
+	}
+}
+
+func TestSubject(t *testing.T) {
+	if got := difftest.Subject(nil); got != "" {
+		t.Errorf("Subject(nil) = %q, want empty", got)
+	}
+	if got := difftest.Subject([]byte{0, 1, 0}); got != "aba" {
+		t.Errorf("Subject() = %q, want %q", got, "aba")
+	}
+}
+
+// checkKnown fails the test for divergences of unknown cause.
+func checkKnown(t *testing.T, pattern, subject string) {
+	divs, err := difftest.Compare(pattern, subject)
+	if err != nil {
+		t.Skip(err)
+	}
+
+	for _, d := range divs {
+		if d.Kind == difftest.KindOther {
+			t.Error(d)
+		}
+	}
+}
+
+func FuzzCompare(f *testing.F) {
+	for _, tt := range seeds {
+		f.Add(tt.pattern, tt.subject)
+	}
+
+	f.Fuzz(checkKnown)
+}
+
+func FuzzGenerated(f *testing.F) {
+	f.Add([]byte{0}, []byte{})
+	f.Add([]byte{3, 0, 0}, []byte{1, 0, 0, 0})
+	f.Add([]byte{2, 14, 4}, []byte{0, 8})
+	f.Add([]byte{1, 4, 11, 2}, []byte{12, 15, 14})
+
+	f.Fuzz(func(t *testing.T, pattern, subject []byte) {
+		checkKnown(t, difftest.Pattern(pattern), difftest.Subject(subject))
+	})
+}
@@ -0,0 +1,101 @@
+package difftest
+
+import (
+	"strconv"
+	"strings"
+)
+
+// maxDepth bounds the nesting of generated patterns.
+const maxDepth = 4
+
+// atoms are the leaves of generated patterns: literals, classes and
+// assertions of the syntax shared by RE2 and PCRE.
+var atoms = []string{
+	`a`, `b`, `ab`, `\.`, `.`, `\d`, `\w`, `\s`, `\D`, `\W`, `\S`, `\b`, `\B`,
+	`^`, `$`, `\A`, `\z`, `[ab]`, `[^a]`, `[a-c]`, `[^\n]`, `\n`, `é`,
+	`\x{e9}`, `\pL`, `\p{Greek}`, `[[:alpha:]]`, `[[:^space:]]`, `(?:)`,
+	`\Qa.\E`,
+}
+
+// generator builds a pattern from the bytes of data, so that fuzzing the
+// bytes explores patterns.
+type generator struct {
+	data   []byte
+	groups int
+}
+
+// next returns the next byte of data, or 0 once data runs out.
+func (g *generator) next() byte {
+	if len(g.data) == 0 {
+		return 0
+	}
+
+	b := g.data[0]
+	g.data = g.data[1:]
+	return b
+}
+
+// Pattern returns a pattern of the syntax common to RE2 and PCRE built from
+// data, for fuzzing: atoms such as literals, classes and assertions combined
+// with concatenation, alternation, greedy and lazy repetition, capturing,
+// named and non-capturing groups, and the i, m and s flags. The same data
+// always gives the same pattern.
+func Pattern(data []byte) string {
+	g := &generator{data: data}
+	return g.expr(0)
+}
+
+// expr generates an expression nested depth deep.
+func (g *generator) expr(depth int) string {
+	b := g.next()
+	if depth >= maxDepth || len(g.data) == 0 {
+		return atoms[int(b)%len(atoms)]
+	}
+
+	switch b % 12 {
+	case 0, 1:
+		return g.expr(depth+1) + g.expr(depth+1)
+	case 2:
+		return g.expr(depth+1) + "|" + g.expr(depth+1)
+	case 3:
+		return "(?:" + g.expr(depth+1) + ")" + g.repeat()
+	case 4:
+		g.groups++
+		return "(" + g.expr(depth+1) + ")"
+	case 5:
+		g.groups++
+		return "(?P<g" + strconv.Itoa(g.groups) + ">" + g.expr(depth+1) + ")"
+	case 6:
+		return "(?:" + g.expr(depth+1) + ")"
+	case 7:
+		flags := []string{"i", "m", "s", "im", "s-m", "-i"}
+		return "(?" + flags[int(g.next())%len(flags)] + ":" + g.expr(depth+1) + ")"
+	default:
+		return atoms[int(g.next())%len(atoms)]
+	}
+}
+
+// repeat generates a quantifier.
+func (g *generator) repeat() string {
+	quantifiers := []string{"*", "+", "?", "{2}", "{1,3}", "{0,}", "*?", "+?", "??", "{1,2}?"}
+	return quantifiers[int(g.next())%len(quantifiers)]
+}
+
+// pieces are the parts subjects are made of: ASCII letters and digits,
+// whitespace, a multi-byte character and invalid UTF-8.
+var pieces = []string{
+	"a", "b", "c", "A", "1", "_", ".", " ", "\n", "\r\n", "\t", "é", "É", "α",
+	"\xff", "\xc3",
+}
+
+// Subject returns a subject built from data, for fuzzing, made of short
+// pieces chosen to exercise the differences between the engines: ASCII and
+// non-ASCII letters, newlines and invalid UTF-8.
+func Subject(data []byte) string {
+	var sb strings.Builder
+	for _, b := range data {
+		sb.WriteString(pieces[int(b)%len(pieces)])
+	}
+
+	return sb.String()
+}
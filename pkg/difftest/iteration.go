@@ -0,0 +1,253 @@
+package difftest
+
+import (
+	"regexp/syntax"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+// goIteration is a PCREgexp whose methods iterating over matches skip
+// empty matches the way the standard library does: an empty match right
+// after another match is not reported, and the search then moves on by one
+// rune, where PCREgexp retries at the same position for a non-empty match.
+// The results of re agree with the standard library through goIteration
+// when a divergence is one of [KindEmptyMatch].
+//
+// The ReplaceAll methods insert templates literally, as PCREgexp does.
+type goIteration struct {
+	*pcregexp.PCREgexp
+}
+
+// all returns the submatch indexes of the first n matches in s, or of every
+// match if n < 0.
+func (re goIteration) all(s string, n int) [][]int {
+	if n < 0 {
+		n = len(s) + 1
+	}
+
+	var matches [][]int
+	for pos, prevMatchEnd := 0, -1; len(matches) < n && pos <= len(s); {
+		loc := re.FindStringSubmatchIndexAt(s, pos, 0)
+		if loc == nil {
+			break
+		}
+
+		accept := true
+		if loc[1] == pos {
+			// An empty match, which std skips right after another match.
+			if loc[0] == prevMatchEnd {
+				accept = false
+			}
+
+			if pos < len(s) {
+				_, width := utf8.DecodeRuneInString(s[pos:])
+				pos += width
+			} else {
+				pos++
+			}
+		} else {
+			pos = loc[1]
+		}
+		prevMatchEnd = loc[1]
+
+		if accept {
+			matches = append(matches, loc)
+		}
+	}
+
+	return matches
+}
+
+func (re goIteration) FindAll(b []byte, n int) [][]byte {
+	var matches [][]byte
+	for _, loc := range re.all(string(b), n) {
+		matches = append(matches, b[loc[0]:loc[1]:loc[1]])
+	}
+
+	return matches
+}
+
+func (re goIteration) FindAllIndex(b []byte, n int) [][]int {
+	return re.FindAllStringIndex(string(b), n)
+}
+
+func (re goIteration) FindAllString(s string, n int) []string {
+	var matches []string
+	for _, loc := range re.all(s, n) {
+		matches = append(matches, s[loc[0]:loc[1]])
+	}
+
+	return matches
+}
+
+func (re goIteration) FindAllStringIndex(s string, n int) [][]int {
+	var matches [][]int
+	for _, loc := range re.all(s, n) {
+		matches = append(matches, loc[:2])
+	}
+
+	return matches
+}
+
+func (re goIteration) FindAllSubmatch(b []byte, n int) [][][]byte {
+	var matches [][][]byte
+	for _, loc := range re.all(string(b), n) {
+		groups := make([][]byte, len(loc)/2)
+		for i := range groups {
+			if loc[2*i] >= 0 {
+				groups[i] = b[loc[2*i]:loc[2*i+1]:loc[2*i+1]]
+			}
+		}
+		matches = append(matches, groups)
+	}
+
+	return matches
+}
+
+func (re goIteration) FindAllSubmatchIndex(b []byte, n int) [][]int {
+	return re.all(string(b), n)
+}
+
+func (re goIteration) FindAllStringSubmatch(s string, n int) [][]string {
+	var matches [][]string
+	for _, loc := range re.all(s, n) {
+		groups := make([]string, len(loc)/2)
+		for i := range groups {
+			if loc[2*i] >= 0 {
+				groups[i] = s[loc[2*i]:loc[2*i+1]]
+			}
+		}
+		matches = append(matches, groups)
+	}
+
+	return matches
+}
+
+func (re goIteration) FindAllStringSubmatchIndex(s string, n int) [][]int {
+	return re.all(s, n)
+}
+
+func (re goIteration) ReplaceAll(src, repl []byte) []byte {
+	return []byte(re.ReplaceAllLiteralString(string(src), string(repl)))
+}
+
+func (re goIteration) ReplaceAllString(src, repl string) string {
+	return re.ReplaceAllLiteralString(src, repl)
+}
+
+func (re goIteration) ReplaceAllLiteral(src, repl []byte) []byte {
+	return []byte(re.ReplaceAllLiteralString(string(src), string(repl)))
+}
+
+func (re goIteration) ReplaceAllLiteralString(src, repl string) string {
+	return re.ReplaceAllStringFunc(src, func(string) string { return repl })
+}
+
+func (re goIteration) ReplaceAllFunc(src []byte, repl func([]byte) []byte) []byte {
+	return []byte(re.ReplaceAllStringFunc(string(src), func(m string) string { return string(repl([]byte(m))) }))
+}
+
+func (re goIteration) ReplaceAllStringFunc(src string, repl func(string) string) string {
+	var sb strings.Builder
+	last := 0
+	for _, loc := range re.all(src, -1) {
+		sb.WriteString(src[last:loc[0]])
+		sb.WriteString(repl(src[loc[0]:loc[1]]))
+		last = loc[1]
+	}
+	sb.WriteString(src[last:])
+
+	return sb.String()
+}
+
+func (re goIteration) Split(s string, n int) []string {
+	if n == 0 {
+		return nil
+	}
+
+	if len(re.String()) > 0 && len(s) == 0 {
+		return []string{""}
+	}
+
+	var parts []string
+	beg, end := 0, 0
+	for _, match := range re.FindAllStringIndex(s, n) {
+		if n > 0 && len(parts) == n-1 {
+			break
+		}
+
+		end = match[0]
+		if match[1] != 0 {
+			parts = append(parts, s[beg:end])
+		}
+		beg = match[1]
+	}
+
+	if end != len(s) {
+		parts = append(parts, s[beg:])
+	}
+
+	return parts
+}
+
+// hasNullableRepeat reports whether pattern repeats without bound a
+// subexpression that can match empty text, such as (a*)*, where PCRE2 stops
+// at an empty iteration.
+func hasNullableRepeat(pattern string) bool {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return false
+	}
+
+	var walk func(re *syntax.Regexp) bool
+	walk = func(re *syntax.Regexp) bool {
+		switch re.Op {
+		case syntax.OpStar, syntax.OpPlus, syntax.OpRepeat:
+			if (re.Op != syntax.OpRepeat || re.Max < 0) && nullable(re.Sub[0]) {
+				return true
+			}
+		}
+
+		for _, sub := range re.Sub {
+			if walk(sub) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return walk(re)
+}
+
+// nullable reports whether re can match empty text.
+func nullable(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpEmptyMatch, syntax.OpStar, syntax.OpQuest,
+		syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText,
+		syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return true
+	case syntax.OpRepeat:
+		return re.Min == 0 || nullable(re.Sub[0])
+	case syntax.OpPlus, syntax.OpCapture:
+		return nullable(re.Sub[0])
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if !nullable(sub) {
+				return false
+			}
+		}
+		return true
+	case syntax.OpAlternate:
+		for _, sub := range re.Sub {
+			if nullable(sub) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return false
+}
@@ -0,0 +1,26 @@
+//go:build pcregexp_embed
+// +build pcregexp_embed
+
+package embedded
+
+import (
+	_ "embed"
+	"strings"
+)
+
+var (
+	//go:embed libpcre2-8.so
+	library []byte
+
+	//go:embed libpcre2-8.so.sha256
+	librarySum string
+)
+
+func init() {
+	// Accept sha256sum(1) output, i.e. "<digest>  <file name>".
+	sum, _, _ := strings.Cut(strings.TrimSpace(librarySum), " ")
+
+	if err := Load(library, sum); err != nil {
+		panic(err)
+	}
+}
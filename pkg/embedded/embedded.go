@@ -0,0 +1,77 @@
+// Package embedded loads a PCRE2 shared library that is embedded in the
+// binary, so that [pcregexp] works on hosts where PCRE2 is not installed, such
+// as scratch containers.
+//
+// On Linux, the library is written to an anonymous, sealed memfd_create(2)
+// file and loaded from /proc/self/fd/N; nothing is written to disk. The file
+// descriptor stays open for the life of the process. Other platforms return
+// [ErrUnsupported].
+//
+// The library is typically embedded by the caller:
+//
+//	//go:embed libpcre2-8.so
+//	var libpcre2 []byte
+//
+//	func init() {
+//		if err := embedded.Load(libpcre2, "<sha256 hex digest>"); err != nil {
+//			panic(err)
+//		}
+//	}
+//
+// Alternatively, building with the "pcregexp_embed" tag embeds the
+// libpcre2-8.so and libpcre2-8.so.sha256 files placed in this package's
+// directory and loads them on initialization.
+package embedded
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnsupported is returned by [Load] on platforms without memfd_create(2).
+var ErrUnsupported = errors.New("embedded: loading from memory is not supported on this platform")
+
+// ChecksumError is returned by [Load] when the library does not match the
+// expected SHA-256 checksum.
+type ChecksumError struct {
+	// Want is the expected checksum, as passed to [Load].
+	Want string
+
+	// Got is the checksum of the library.
+	Got string
+}
+
+// Error implements the error interface.
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("embedded: library checksum mismatch: got sha256 %s, want %s", e.Got, e.Want)
+}
+
+// Load verifies that lib matches the hex-encoded SHA-256 checksum sum and
+// loads it as the PCRE2 shared library used by [pcregexp], replacing any
+// library loaded previously. It must be called before any pattern is
+// compiled.
+func Load(lib []byte, sum string) error {
+	if err := verify(lib, sum); err != nil {
+		return err
+	}
+
+	return load(lib)
+}
+
+// verify checks lib against the hex-encoded SHA-256 checksum sum.
+func verify(lib []byte, sum string) error {
+	want := strings.ToLower(strings.TrimSpace(sum))
+	if want == "" {
+		return errors.New("embedded: missing library checksum")
+	}
+
+	digest := sha256.Sum256(lib)
+	if got := hex.EncodeToString(digest[:]); got != want {
+		return &ChecksumError{Want: want, Got: got}
+	}
+
+	return nil
+}
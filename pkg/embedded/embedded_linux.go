@@ -0,0 +1,99 @@
+//go:build linux
+// +build linux
+
+package embedded
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+// memfd_create(2) syscall numbers, which the syscall package does not define.
+var sysMemfdCreate = map[string]uintptr{
+	"386":     356,
+	"amd64":   319,
+	"arm":     385,
+	"arm64":   279,
+	"loong64": 279,
+	"ppc64le": 360,
+	"riscv64": 279,
+	"s390x":   350,
+}
+
+const (
+	mfdCloexec      = 0x1 // MFD_CLOEXEC
+	mfdAllowSealing = 0x2 // MFD_ALLOW_SEALING
+
+	fAddSeals = 1033 // F_ADD_SEALS
+
+	// F_SEAL_SEAL | F_SEAL_SHRINK | F_SEAL_GROW | F_SEAL_WRITE
+	sealAll = 0x1 | 0x2 | 0x4 | 0x8
+)
+
+var (
+	// loadedMu guards loaded.
+	loadedMu sync.Mutex
+
+	// loaded holds the files of the libraries loaded so far. They stay open
+	// for the life of the process, so that the /proc/self/fd path reported by
+	// [pcregexp.LibraryPath] keeps naming the library rather than a closed or
+	// reused descriptor.
+	loaded []*os.File
+)
+
+// memfdCreate creates an anonymous memory-backed file named name.
+func memfdCreate(name string) (int, error) {
+	trap, ok := sysMemfdCreate[runtime.GOARCH]
+	if !ok {
+		return -1, ErrUnsupported
+	}
+
+	p, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return -1, err
+	}
+
+	fd, _, errno := syscall.Syscall(trap, uintptr(unsafe.Pointer(p)), mfdCloexec|mfdAllowSealing, 0)
+	if errno != 0 {
+		return -1, os.NewSyscallError("memfd_create", errno)
+	}
+
+	return int(fd), nil
+}
+
+func load(lib []byte) error {
+	fd, err := memfdCreate("libpcre2-8.so")
+	if err != nil {
+		return err
+	}
+
+	f := os.NewFile(uintptr(fd), "memfd:libpcre2-8.so")
+
+	if _, err := f.Write(lib); err != nil {
+		f.Close()
+		return fmt.Errorf("embedded: writing library: %w", err)
+	}
+
+	// Make the file immutable before handing it to the dynamic loader.
+	if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, uintptr(fd), fAddSeals, sealAll); errno != 0 {
+		f.Close()
+		return os.NewSyscallError("fcntl(F_ADD_SEALS)", errno)
+	}
+
+	if err := pcregexp.SetLibraryPath(fmt.Sprintf("/proc/self/fd/%d", fd)); err != nil {
+		f.Close()
+		return err
+	}
+
+	loadedMu.Lock()
+	loaded = append(loaded, f)
+	loadedMu.Unlock()
+
+	return nil
+}
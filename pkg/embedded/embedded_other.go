@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package embedded
+
+func load(lib []byte) error {
+	return ErrUnsupported
+}
@@ -0,0 +1,92 @@
+package embedded_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/dwisiswant0/pcregexp"
+	"github.com/dwisiswant0/pcregexp/pkg/embedded"
+)
+
+// findLibrary returns the contents of an installed PCRE2 shared library.
+func findLibrary(t *testing.T) []byte {
+	t.Helper()
+
+	patterns := []string{
+		"/usr/local/lib/libpcre2-8.so*",
+		"/usr/lib/*/libpcre2-8.so*",
+		"/lib/*/libpcre2-8.so*",
+		"/usr/lib64/libpcre2-8.so*",
+		"/usr/lib/libpcre2-8.so*",
+	}
+
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(pattern)
+		for _, path := range matches {
+			if lib, err := os.ReadFile(path); err == nil {
+				return lib
+			}
+		}
+	}
+
+	t.Skip("no installed PCRE2 library found")
+	return nil
+}
+
+func TestLoad(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("memfd_create is Linux-only")
+	}
+
+	lib := findLibrary(t)
+	digest := sha256.Sum256(lib)
+	sum := hex.EncodeToString(digest[:])
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		err := embedded.Load(lib, strings.Repeat("0", 64))
+
+		var sumErr *embedded.ChecksumError
+		if !errors.As(err, &sumErr) {
+			t.Fatalf("Load() error = %v, want *ChecksumError", err)
+		}
+		if sumErr.Got != sum {
+			t.Errorf("ChecksumError.Got = %s, want %s", sumErr.Got, sum)
+		}
+	})
+
+	t.Run("missing checksum", func(t *testing.T) {
+		if err := embedded.Load(lib, ""); err == nil {
+			t.Error("Load() error = nil without a checksum")
+		}
+	})
+
+	t.Run("load", func(t *testing.T) {
+		if err := embedded.Load(lib, strings.ToUpper(sum)); err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+
+		path := pcregexp.LibraryPath()
+		if !strings.HasPrefix(path, "/proc/self/fd/") {
+			t.Errorf("LibraryPath() = %q, want a /proc/self/fd path", path)
+		}
+
+		// The descriptor must still name the library after Load returns.
+		runtime.GC()
+		if target, err := os.Readlink(path); err != nil || !strings.HasPrefix(target, "/memfd:libpcre2-8.so") {
+			t.Errorf("Readlink(%q) = %q, %v, want the memfd", path, target, err)
+		}
+
+		re := pcregexp.MustCompile(`(?<=foo)bar`)
+		defer re.Close()
+
+		if !re.MatchString("foobar") {
+			t.Error("MatchString() = false, want true")
+		}
+	})
+}
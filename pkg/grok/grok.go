@@ -0,0 +1,285 @@
+// Package grok parses unstructured log lines with grok expressions, as known
+// from Logstash, on top of [pcregexp].
+//
+// A grok expression is a regular expression that may refer to named patterns
+// with %{NAME}, capture what they match with %{NAME:field}, and convert the
+// capture with %{NAME:field:type}, where type is int, float or string:
+//
+//	%{IPORHOST:client} \[%{HTTPDATE:ts}\] "%{WORD:verb} %{NOTSPACE:path}" %{NUMBER:status:int}
+//
+// References are expanded recursively into a single PCRE pattern, in which
+// every captured field becomes a named group. Plain parentheses in pattern
+// definitions do not capture.
+//
+// A [Grok] starts with a built-in dictionary of common patterns, such as IP,
+// HOSTNAME, NUMBER, TIMESTAMP_ISO8601, HTTPDATE, SYSLOGLINE and
+// COMBINEDAPACHELOG, to which user patterns can be added, also from pattern
+// files in the Logstash format.
+package grok
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dwisiswant0/pcregexp"
+	"github.com/dwisiswant0/pcregexp/pkg/pcresyntax"
+)
+
+//go:embed patterns/base
+var basePatterns string
+
+var (
+	// patternName matches valid pattern names.
+	patternName = regexp.MustCompile(`^\w+$`)
+
+	// reference matches %{NAME}, %{NAME:field} and %{NAME:field:type}.
+	reference = regexp.MustCompile(`%\{(\w+)(?::([^:}]+))?(?::(\w+))?\}`)
+)
+
+// Grok is a dictionary of named patterns that grok expressions refer to. It
+// is safe for concurrent use.
+type Grok struct {
+	mu       sync.RWMutex
+	patterns map[string]string
+}
+
+// New returns a Grok holding the built-in patterns.
+func New() *Grok {
+	g := NewEmpty()
+	if err := g.AddPatterns(strings.NewReader(basePatterns)); err != nil {
+		panic("grok: invalid built-in patterns: " + err.Error())
+	}
+
+	return g
+}
+
+// NewEmpty returns a Grok without any patterns.
+func NewEmpty() *Grok {
+	return &Grok{patterns: make(map[string]string)}
+}
+
+// AddPattern defines, or redefines, the pattern called name. The definition
+// may refer to other patterns, which need not be defined yet.
+func (g *Grok) AddPattern(name, pattern string) error {
+	if !patternName.MatchString(name) {
+		return fmt.Errorf("grok: invalid pattern name %q", name)
+	}
+
+	g.mu.Lock()
+	g.patterns[name] = pattern
+	g.mu.Unlock()
+
+	return nil
+}
+
+// AddPatterns reads pattern definitions in the Logstash format: one per line,
+// made of the pattern name, whitespace and the definition. Blank lines and
+// lines starting with # are ignored.
+func (g *Grok) AddPatterns(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		i := strings.IndexAny(line, " \t")
+		if i < 0 {
+			return fmt.Errorf("grok: line %d: missing definition of pattern %q", n, line)
+		}
+
+		if err := g.AddPattern(line[:i], strings.TrimSpace(line[i:])); err != nil {
+			return fmt.Errorf("grok: line %d: %w", n, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// AddPatternsFromFile reads pattern definitions from the file at path, see
+// [Grok.AddPatterns].
+func (g *Grok) AddPatternsFromFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := g.AddPatterns(f); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Patterns returns the names of the defined patterns, sorted.
+func (g *Grok) Patterns() []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	names := make([]string, 0, len(g.patterns))
+	for name := range g.patterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Expand returns the PCRE pattern expr expands to, and the fields it
+// captures.
+func (g *Grok) Expand(expr string) (string, []Field, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	e := expander{patterns: g.patterns}
+
+	pattern, err := e.expand(expr)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// (?n) disables capturing by plain parentheses, so that only fields
+	// are captured. It follows the start-of-pattern items, such as (*UTF),
+	// which must come first.
+	i := startItemsEnd(pattern)
+	return pattern[:i] + "(?n)" + pattern[i:], e.fields, nil
+}
+
+// startItemsEnd returns the offset of pattern after its start-of-pattern
+// items, such as (*UTF) or (*LIMIT_MATCH=100), or 0 if it has none.
+func startItemsEnd(pattern string) int {
+	tree, err := pcresyntax.Parse(pattern)
+	if err != nil {
+		return 0
+	}
+
+	end := 0
+	pcresyntax.Inspect(tree, func(n pcresyntax.Node) bool {
+		if opt, ok := n.(*pcresyntax.StartOption); ok && opt.End() > end {
+			end = opt.End()
+		}
+		return true
+	})
+
+	return end
+}
+
+// Compile expands expr and compiles it into a [Pattern].
+func (g *Grok) Compile(expr string) (*Pattern, error) {
+	pattern, fields, err := g.Expand(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := pcregexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("grok: compiling %q: %w", expr, err)
+	}
+
+	// Named groups written directly in expr or in pattern definitions
+	// capture too, as strings.
+	generated := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		generated[f.group] = true
+	}
+
+	for _, name := range re.SubexpNames() {
+		if name != "" && !generated[name] {
+			fields = append(fields, Field{Name: name, Type: TypeString, group: name})
+			generated[name] = true
+		}
+	}
+
+	for i := range fields {
+		fields[i].index = re.SubexpIndex(fields[i].group)
+	}
+
+	return &Pattern{expr: expr, re: re, fields: fields}, nil
+}
+
+// MustCompile is like [Grok.Compile] but panics on error.
+func (g *Grok) MustCompile(expr string) *Pattern {
+	p, err := g.Compile(expr)
+	if err != nil {
+		panic(err)
+	}
+
+	return p
+}
+
+// expander expands grok references.
+type expander struct {
+	patterns map[string]string
+	fields   []Field
+	stack    []string // patterns being expanded, to detect cycles
+}
+
+// expand replaces the references in expr with their definitions.
+func (e *expander) expand(expr string) (string, error) {
+	var sb strings.Builder
+
+	last := 0
+	for _, m := range reference.FindAllStringSubmatchIndex(expr, -1) {
+		sb.WriteString(expr[last:m[0]])
+		last = m[1]
+
+		name := expr[m[2]:m[3]]
+
+		var field, typ string
+		if m[4] >= 0 {
+			field = expr[m[4]:m[5]]
+		}
+		if m[6] >= 0 {
+			typ = expr[m[6]:m[7]]
+		}
+
+		definition, ok := e.patterns[name]
+		if !ok {
+			return "", fmt.Errorf("grok: unknown pattern %q", name)
+		}
+
+		for i, s := range e.stack {
+			if s == name {
+				cycle := append(e.stack[i:len(e.stack):len(e.stack)], name)
+				return "", fmt.Errorf("grok: pattern cycle %s", strings.Join(cycle, " -> "))
+			}
+		}
+
+		e.stack = append(e.stack, name)
+		inner, err := e.expand(definition)
+		e.stack = e.stack[:len(e.stack)-1]
+		if err != nil {
+			return "", err
+		}
+
+		if field == "" {
+			sb.WriteString("(?:" + inner + ")")
+			continue
+		}
+
+		f := Field{Name: field, Type: Type(typ), group: fmt.Sprintf("grok%d", len(e.fields)+1)}
+		switch f.Type {
+		case "":
+			f.Type = TypeString
+		case TypeString, TypeInt, TypeFloat:
+		default:
+			return "", fmt.Errorf("grok: field %q has unknown type %q", field, typ)
+		}
+		e.fields = append(e.fields, f)
+
+		sb.WriteString("(?<" + f.group + ">" + inner + ")")
+	}
+	sb.WriteString(expr[last:])
+
+	return sb.String(), nil
+}
@@ -0,0 +1,285 @@
+package grok_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dwisiswant0/pcregexp/pkg/grok"
+)
+
+const (
+	nginxLine  = `203.0.113.7 - alice [10/Oct/2024:13:55:36 +0200] "GET /index.html?q=1 HTTP/1.1" 200 2326 "https://example.com/" "Mozilla/5.0 (X11; Linux x86_64)"`
+	syslogLine = `Oct 11 22:14:15 mymachine sshd[4123]: Failed password for root from 198.51.100.2 port 22 ssh2`
+)
+
+func TestCompile_Builtins(t *testing.T) {
+	g := grok.New()
+
+	for _, name := range g.Patterns() {
+		p, err := g.Compile("%{" + name + "}")
+		if err != nil {
+			t.Errorf("Compile(%%{%s}) error = %v", name, err)
+			continue
+		}
+		p.Close()
+	}
+}
+
+func TestParse_Nginx(t *testing.T) {
+	p := grok.New().MustCompile(`%{NGINXACCESS}`)
+	defer p.Close()
+
+	got, err := p.Parse(nginxLine)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := map[string]any{
+		"clientip":    "203.0.113.7",
+		"ident":       "-",
+		"auth":        "alice",
+		"timestamp":   "10/Oct/2024:13:55:36 +0200",
+		"verb":        "GET",
+		"request":     "/index.html?q=1",
+		"httpversion": "1.1",
+		"response":    "200",
+		"bytes":       "2326",
+		"referrer":    `"https://example.com/"`,
+		"agent":       `"Mozilla/5.0 (X11; Linux x86_64)"`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %v, want %v", got, want)
+	}
+}
+
+func TestParse_Syslog(t *testing.T) {
+	p := grok.New().MustCompile(`%{SYSLOGLINE}`)
+	defer p.Close()
+
+	got, err := p.Parse(syslogLine)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := map[string]any{
+		"timestamp": "Oct 11 22:14:15",
+		"logsource": "mymachine",
+		"program":   "sshd",
+		"pid":       "4123",
+		"message":   "Failed password for root from 198.51.100.2 port 22 ssh2",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %v, want %v", got, want)
+	}
+}
+
+func TestParse_Types(t *testing.T) {
+	p := grok.New().MustCompile(`%{WORD:verb} %{INT:status:int} %{NUMBER:took:float}s(?: %{WORD:note:string})?`)
+	defer p.Close()
+
+	got, err := p.Parse("GET 404 0.25s")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := map[string]any{"verb": "GET", "status": int64(404), "took": 0.25}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %#v, want %#v", got, want)
+	}
+
+	if _, err := p.Parse("nothing here"); !errors.Is(err, grok.ErrNoMatch) {
+		t.Errorf("Parse() on a non-matching line error = %v, want ErrNoMatch", err)
+	}
+}
+
+func TestParse_NamedGroups(t *testing.T) {
+	p := grok.New().MustCompile(`(?<level>[A-Z]+): %{GREEDYDATA:msg}`)
+	defer p.Close()
+
+	got, err := p.Parse("WARN: disk almost full")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := map[string]any{"level": "WARN", "msg": "disk almost full"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %v, want %v", got, want)
+	}
+}
+
+func TestCompile_StartItems(t *testing.T) {
+	g := grok.New()
+
+	for _, expr := range []string{
+		`(*UTF)%{WORD:w} (é)`,
+		`(*LIMIT_MATCH=1000)(*UCP)%{WORD:w} (é)`,
+	} {
+		p, err := g.Compile(expr)
+		if err != nil {
+			t.Errorf("Compile(%q) error = %v", expr, err)
+			continue
+		}
+
+		got, err := p.Parse("hello é")
+		p.Close()
+		if err != nil {
+			t.Errorf("Compile(%q).Parse() error = %v", expr, err)
+			continue
+		}
+
+		// Plain parentheses still do not capture.
+		if want := map[string]any{"w": "hello"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Compile(%q).Parse() = %v, want %v", expr, got, want)
+		}
+	}
+}
+
+func TestParseInto(t *testing.T) {
+	type access struct {
+		Client string  `grok:"clientip"`
+		Verb   string  `grok:"verb"`
+		Status int     `grok:"response"`
+		Bytes  *uint64 `grok:"bytes"`
+		Raw    string  `grok:"rawrequest"`
+		Other  string
+	}
+
+	p := grok.New().MustCompile(`%{COMMONAPACHELOG}`)
+	defer p.Close()
+
+	var got access
+	if err := p.ParseInto(nginxLine, &got); err != nil {
+		t.Fatalf("ParseInto() error = %v", err)
+	}
+
+	if got.Client != "203.0.113.7" || got.Verb != "GET" || got.Status != 200 || got.Bytes == nil || *got.Bytes != 2326 || got.Raw != "" {
+		t.Errorf("ParseInto() = %+v", got)
+	}
+
+	var bad struct {
+		X string `grok:"nope"`
+	}
+	if err := p.ParseInto(nginxLine, &bad); err == nil {
+		t.Error("ParseInto() with an unknown field returned no error")
+	}
+
+	if err := p.ParseInto(nginxLine, got); err == nil {
+		t.Error("ParseInto() with a non-pointer returned no error")
+	}
+
+	took := grok.New().MustCompile(`took %{NOTSPACE:took}`)
+	defer took.Close()
+
+	var timing struct {
+		Took time.Duration `grok:"took"`
+	}
+	if err := took.ParseInto("request took 1.5s", &timing); err != nil || timing.Took != 1500*time.Millisecond {
+		t.Errorf("ParseInto() = %+v, %v, want Took=1.5s", timing, err)
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	g := grok.NewEmpty()
+	for name, pattern := range map[string]string{
+		"A":    "a%{B}",
+		"B":    "b%{C}",
+		"C":    "c%{A}",
+		"SELF": "x%{SELF}?",
+		"NUM":  `\d+`,
+	} {
+		if err := g.AddPattern(name, pattern); err != nil {
+			t.Fatalf("AddPattern(%q) error = %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"%{A}", "A -> B -> C -> A"},
+		{"%{SELF}", "SELF -> SELF"},
+		{"%{MISSING}", `unknown pattern "MISSING"`},
+		{"%{NUM:n:bool}", `unknown type "bool"`},
+		{"%{NUM:n}(", "grok: compiling"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := g.Compile(tt.expr)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Compile(%q) error = %v, want it to contain %q", tt.expr, err, tt.want)
+			}
+		})
+	}
+
+	if err := g.AddPattern("not a name", "x"); err == nil {
+		t.Error("AddPattern() with an invalid name returned no error")
+	}
+}
+
+func TestAddPatterns(t *testing.T) {
+	g := grok.New()
+
+	patterns := `
+# request ids look like req-0042
+REQID req-%{INT}
+REQLINE %{LOGLEVEL:level} \[%{REQID:id}\]	%{GREEDYDATA:msg}
+GREETING	hello %{WORD:who}
+`
+	if err := g.AddPatterns(strings.NewReader(patterns)); err != nil {
+		t.Fatalf("AddPatterns() error = %v", err)
+	}
+
+	p := g.MustCompile(`%{REQLINE}`)
+	defer p.Close()
+
+	got, err := p.Parse("ERROR [req-0042]\tupstream timed out")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := map[string]any{"level": "ERROR", "id": "req-0042", "msg": "upstream timed out"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %v, want %v", got, want)
+	}
+
+	// The name ends at the first tab, even when the definition has spaces.
+	greeting := g.MustCompile(`%{GREETING}`)
+	defer greeting.Close()
+
+	if got, err := greeting.Parse("hello world"); err != nil || got["who"] != "world" {
+		t.Errorf("Parse() = %v, %v, want who=world", got, err)
+	}
+
+	if err := g.AddPatterns(strings.NewReader("LONELY\n")); err == nil {
+		t.Error("AddPatterns() with a missing definition returned no error")
+	}
+}
+
+func TestAddPatternsFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app")
+	if err := os.WriteFile(path, []byte("DURATION %{NUMBER}ms\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	g := grok.New()
+	if err := g.AddPatternsFromFile(path); err != nil {
+		t.Fatalf("AddPatternsFromFile() error = %v", err)
+	}
+
+	p := g.MustCompile(`took %{DURATION:took}`)
+	defer p.Close()
+
+	if got, err := p.Parse("request took 12.5ms"); err != nil || got["took"] != "12.5ms" {
+		t.Errorf("Parse() = %v, %v, want took=12.5ms", got, err)
+	}
+
+	if err := g.AddPatternsFromFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("AddPatternsFromFile() with a missing file returned no error")
+	}
+}
@@ -0,0 +1,218 @@
+package grok
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/dwisiswant0/pcregexp"
+	"github.com/dwisiswant0/pcregexp/internal/unmarshal"
+)
+
+// ErrNoMatch is returned by [Pattern.Parse] and [Pattern.ParseInto] when the
+// line does not match.
+var ErrNoMatch = errors.New("grok: no match")
+
+// Type is the type a captured field is converted to.
+type Type string
+
+// Field types, as written in %{NAME:field:type}.
+const (
+	TypeString Type = "string" // string, the default
+	TypeInt    Type = "int"    // int64
+	TypeFloat  Type = "float"  // float64
+)
+
+// Field is a value captured by a grok expression.
+type Field struct {
+	// Name is the field name, as written in %{NAME:field}, or the name of a
+	// named group written directly in the expression.
+	Name string
+
+	// Type is the type the field is converted to.
+	Type Type
+
+	group string // name of the capturing group in the expanded pattern
+	index int    // index of that group
+}
+
+// convert converts text to the type of f.
+func (f Field) convert(text string) (any, error) {
+	switch f.Type {
+	case TypeInt:
+		return strconv.ParseInt(text, 10, 64)
+	case TypeFloat:
+		return strconv.ParseFloat(text, 64)
+	default:
+		return text, nil
+	}
+}
+
+// Pattern is a compiled grok expression. It is safe for concurrent use, and
+// should be released with [Pattern.Close] once no longer needed.
+type Pattern struct {
+	expr   string
+	fields []Field
+
+	mu sync.Mutex // guards re, which is not safe for concurrent use
+	re *pcregexp.PCREgexp
+}
+
+// String returns the grok expression p was compiled from.
+func (p *Pattern) String() string {
+	return p.expr
+}
+
+// Regexp returns the expanded PCRE pattern.
+func (p *Pattern) Regexp() string {
+	return p.re.String()
+}
+
+// Fields returns the fields p captures, in the order they appear in the
+// expression. Several fields may share a name, for instance in alternatives.
+func (p *Pattern) Fields() []Field {
+	return append([]Field(nil), p.fields...)
+}
+
+// Match reports whether line contains a match of p.
+func (p *Pattern) Match(line string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.re.MatchString(line)
+}
+
+// submatch returns the text captured by the groups of the leftmost match in
+// line, with nil for the groups that did not take part in the match.
+func (p *Pattern) submatch(line string) ([]*string, error) {
+	p.mu.Lock()
+	loc := p.re.FindStringSubmatchIndex(line)
+	n := p.re.NumSubexp() + 1
+	p.mu.Unlock()
+
+	if loc == nil {
+		return nil, ErrNoMatch
+	}
+
+	// loc ends at the last group that took part in the match.
+	texts := make([]*string, n)
+	for i := 0; 2*i+1 < len(loc) && i < n; i++ {
+		if loc[2*i] >= 0 {
+			text := line[loc[2*i]:loc[2*i+1]]
+			texts[i] = &text
+		}
+	}
+
+	return texts, nil
+}
+
+// Parse matches line and returns the fields of the leftmost match, converted
+// to their types: string, int64 or float64. Fields that did not take part in
+// the match are omitted; if several fields share a name, the first one that
+// took part is used.
+//
+// Parse returns [ErrNoMatch] if line does not match, and an error if a field
+// cannot be converted.
+func (p *Pattern) Parse(line string) (map[string]any, error) {
+	texts, err := p.submatch(line)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]any, len(p.fields))
+	for _, f := range p.fields {
+		text := texts[f.index]
+		if _, ok := values[f.Name]; ok || text == nil {
+			continue
+		}
+
+		v, err := f.convert(*text)
+		if err != nil {
+			return nil, fmt.Errorf("grok: field %s: %w", f.Name, err)
+		}
+		values[f.Name] = v
+	}
+
+	return values, nil
+}
+
+// ParseInto matches line and stores the fields of the leftmost match in the
+// struct v points to. A struct field receives the grok field named by its
+// `grok:"name"` tag; untagged struct fields and those tagged "-" are left
+// alone, as are those whose grok field did not take part in the match.
+//
+// The text of a grok field is converted to the type of the struct field,
+// regardless of the type given in the expression. Struct fields may be of
+// the types [pcregexp.PCREgexp.Unmarshal] supports.
+//
+// ParseInto returns [ErrNoMatch] if line does not match, and an error if a
+// tag names an unknown field or a value cannot be converted.
+func (p *Pattern) ParseInto(line string, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("grok: ParseInto needs a non-nil pointer to a struct, got %T", v)
+	}
+
+	texts, err := p.submatch(line)
+	if err != nil {
+		return err
+	}
+
+	rv = rv.Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+
+		name, ok := field.Tag.Lookup("grok")
+		if !ok || name == "-" {
+			continue
+		}
+
+		text, known := p.lookup(texts, name)
+		if !known {
+			return fmt.Errorf("grok: field %s: pattern has no field named %q", field.Name, name)
+		}
+
+		if !field.IsExported() {
+			return fmt.Errorf("grok: field %s: cannot set unexported field", field.Name)
+		}
+
+		if text == nil {
+			continue
+		}
+
+		if err := unmarshal.Text(rv.Field(i), *text); err != nil {
+			return fmt.Errorf("grok: field %s: cannot parse %q: %w", field.Name, *text, err)
+		}
+	}
+
+	return nil
+}
+
+// lookup returns the text of the first field called name that took part in
+// the match, and whether p has such a field at all.
+func (p *Pattern) lookup(texts []*string, name string) (*string, bool) {
+	known := false
+	for _, f := range p.fields {
+		if f.Name != name {
+			continue
+		}
+
+		known = true
+		if texts[f.index] != nil {
+			return texts[f.index], true
+		}
+	}
+
+	return nil, known
+}
+
+// Close releases the compiled pattern. p must not be used afterwards.
+func (p *Pattern) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.re.Close()
+}
@@ -0,0 +1,390 @@
+// Package lexer builds tokenizers from ordered lists of PCRE rules.
+//
+// The rules of each mode are compiled into a single alternation, in which
+// every rule is tagged with a (*MARK) verb. At each position, the lexer
+// matches that one pattern anchored at the current offset and reads the mark
+// to tell which rule matched, instead of trying a separate pattern per rule.
+// Like alternatives of a regexp, and unlike flex, the first rule that
+// matches wins, not the longest match.
+//
+// Modes work like exclusive flex start conditions: only the rules of the
+// current mode apply, and rules may push a mode onto a stack, or pop back to
+// the previous one, to lex strings, comments or embedded languages.
+//
+//	lx, err := lexer.Compile(lexer.Modes{
+//		lexer.DefaultMode: {
+//			{Pattern: `\s+`, Skip: true},
+//			{Type: "ident", Pattern: `[A-Za-z_]\w*`},
+//			{Type: "number", Pattern: `\d+`},
+//			{Type: "quote", Pattern: `"`, Push: "string"},
+//		},
+//		"string": {
+//			{Type: "text", Pattern: `(?:[^"\\]|\\.)+`},
+//			{Type: "quote", Pattern: `"`, Pop: true},
+//		},
+//	})
+package lexer
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+// DefaultMode is the mode lexing starts in.
+const DefaultMode = "default"
+
+// TokenType identifies the rule a token was matched by.
+type TokenType string
+
+// Rule is a lexing rule.
+type Rule struct {
+	// Type is the type of the tokens the rule produces.
+	Type TokenType
+
+	// Pattern is the PCRE pattern the rule matches at the current position.
+	// It must not match empty text. Since the rules of a mode are compiled
+	// into one pattern, backreferences should be relative, as in \g{-1}, or
+	// by name, and names must be unique within the mode.
+	Pattern string
+
+	// Skip discards the tokens of the rule, such as whitespace or comments.
+	Skip bool
+
+	// Push, if set, is the mode entered after a match of the rule. Lexing
+	// returns to the current mode on a later Pop.
+	Push string
+
+	// Pop returns to the previous mode after a match of the rule. If Push is
+	// also set, the current mode is replaced by Push, like BEGIN in flex.
+	Pop bool
+}
+
+// Modes maps mode names to their ordered rules. It must contain
+// [DefaultMode].
+type Modes map[string][]Rule
+
+// Lexer is a compiled set of rules. It is safe for concurrent use.
+type Lexer struct {
+	modes map[string]*mode
+}
+
+// mode is the compiled rules of a mode.
+type mode struct {
+	name  string
+	rules []Rule
+
+	mu sync.Mutex // guards re, which is not safe for concurrent use
+	re *pcregexp.PCREgexp
+}
+
+// Compile compiles the rules of every mode.
+func Compile(modes Modes) (*Lexer, error) {
+	if _, ok := modes[DefaultMode]; !ok {
+		return nil, fmt.Errorf("lexer: no rules for mode %q", DefaultMode)
+	}
+
+	names := make([]string, 0, len(modes))
+	for name := range modes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lx := &Lexer{modes: make(map[string]*mode, len(modes))}
+	for _, name := range names {
+		m, err := compileMode(name, modes[name], modes)
+		if err != nil {
+			lx.Close()
+			return nil, err
+		}
+		lx.modes[name] = m
+	}
+
+	return lx, nil
+}
+
+// MustCompile is like [Compile] but panics on error.
+func MustCompile(modes Modes) *Lexer {
+	lx, err := Compile(modes)
+	if err != nil {
+		panic(err)
+	}
+
+	return lx
+}
+
+// compileMode checks the rules of a mode and compiles them into an
+// alternation whose branches are marked with the rule index.
+func compileMode(name string, rules []Rule, modes Modes) (*mode, error) {
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("lexer: mode %q has no rules", name)
+	}
+
+	var sb strings.Builder
+	for i, r := range rules {
+		if r.Type == "" && !r.Skip {
+			return nil, fmt.Errorf("lexer: mode %q, rule %d: missing token type", name, i)
+		}
+
+		if _, ok := modes[r.Push]; r.Push != "" && !ok {
+			return nil, fmt.Errorf("lexer: mode %q, rule %d: unknown mode %q", name, i, r.Push)
+		}
+
+		if i > 0 {
+			sb.WriteByte('|')
+		}
+		// The mark comes last, so that it overrides marks in the rule.
+		sb.WriteString("(?:" + r.Pattern + ")(*:" + strconv.Itoa(i) + ")")
+	}
+
+	re, err := pcregexp.Compile(sb.String())
+	if err != nil {
+		// Find the rule at fault.
+		for i, r := range rules {
+			rre, rerr := pcregexp.Compile(r.Pattern)
+			if rerr != nil {
+				return nil, fmt.Errorf("lexer: mode %q, rule %d: %w", name, i, rerr)
+			}
+			rre.Close()
+		}
+
+		return nil, fmt.Errorf("lexer: mode %q: %w", name, err)
+	}
+
+	return &mode{name: name, rules: rules, re: re}, nil
+}
+
+// match matches the rules of m anchored at off. It returns the end of the
+// match and the index of the rule, or -1 if no rule matches.
+func (m *mode) match(s string, off int) (end, rule int) {
+	m.mu.Lock()
+	loc, mark := m.re.FindStringIndexMarkAt(s, off, pcregexp.MatchAnchored)
+	m.mu.Unlock()
+
+	if loc == nil {
+		return 0, -1
+	}
+
+	rule, err := strconv.Atoi(mark)
+	if err != nil {
+		return 0, -1
+	}
+
+	return loc[1], rule
+}
+
+// Close releases the compiled rules. lx must not be used afterwards.
+func (lx *Lexer) Close() {
+	for _, m := range lx.modes {
+		m.mu.Lock()
+		m.re.Close()
+		m.mu.Unlock()
+	}
+}
+
+// Scan returns a Scanner producing the tokens of input.
+func (lx *Lexer) Scan(input []byte) *Scanner {
+	return lx.ScanString(string(input))
+}
+
+// ScanString returns a Scanner producing the tokens of input.
+func (lx *Lexer) ScanString(input string) *Scanner {
+	return &Scanner{
+		lx:    lx,
+		input: input,
+		pos:   Position{Line: 1, Column: 1},
+		modes: []string{DefaultMode},
+	}
+}
+
+// Tokenize returns all the tokens of input. On error, it returns the tokens
+// lexed so far.
+func (lx *Lexer) Tokenize(input string) ([]Token, error) {
+	s := lx.ScanString(input)
+
+	var tokens []Token
+	for {
+		tok, err := s.Next()
+		if err == io.EOF {
+			return tokens, nil
+		}
+		if err != nil {
+			return tokens, err
+		}
+		tokens = append(tokens, tok)
+	}
+}
+
+// Position is a position in the input.
+type Position struct {
+	Offset int // byte offset, starting at 0
+	Line   int // line number, starting at 1
+	Column int // column number in characters, starting at 1
+}
+
+// String returns the position as line:column.
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// advance returns the position after text, which starts at p.
+func (p Position) advance(text string) Position {
+	p.Offset += len(text)
+
+	if i := strings.LastIndexByte(text, '\n'); i >= 0 {
+		p.Line += strings.Count(text, "\n")
+		p.Column = 1
+		text = text[i+1:]
+	}
+	p.Column += utf8.RuneCountInString(text)
+
+	return p
+}
+
+// Token is a lexed token.
+type Token struct {
+	Type TokenType
+	Text string
+	Pos  Position // position of the first character
+	Mode string   // mode the token was lexed in
+}
+
+// String returns the token type and text.
+func (t Token) String() string {
+	return fmt.Sprintf("%s %q", t.Type, t.Text)
+}
+
+// Error is a lexing error.
+type Error struct {
+	Pos  Position // where lexing failed
+	Mode string   // mode lexing failed in
+	Line string   // the input line Pos is on, without the newline
+	Msg  string
+}
+
+// Error returns the position and message of e.
+func (e *Error) Error() string {
+	return fmt.Sprintf("lexer: %s: %s", e.Pos, e.Msg)
+}
+
+// Context returns the input line the error is on, followed by a line with a
+// caret under the position of the error.
+func (e *Error) Context() string {
+	// Tabs are kept, so that the caret lines up however they are displayed.
+	var caret strings.Builder
+	n := 0
+	for _, r := range e.Line {
+		if n++; n >= e.Pos.Column {
+			break
+		}
+
+		if r == '\t' {
+			caret.WriteByte('\t')
+		} else {
+			caret.WriteByte(' ')
+		}
+	}
+	caret.WriteByte('^')
+
+	return e.Line + "\n" + caret.String()
+}
+
+// Scanner produces the tokens of an input. It is not safe for concurrent
+// use, but several Scanners of a Lexer may run concurrently.
+type Scanner struct {
+	lx    *Lexer
+	input string
+	pos   Position
+	modes []string // mode stack, current mode last
+	err   error
+}
+
+// Mode returns the current mode. At the end of the input, a mode other than
+// [DefaultMode] usually means the input is truncated, such as an
+// unterminated string.
+func (s *Scanner) Mode() string {
+	return s.modes[len(s.modes)-1]
+}
+
+// Pos returns the position of the next token.
+func (s *Scanner) Pos() Position {
+	return s.pos
+}
+
+// Next returns the next token. It returns [io.EOF] at the end of the input,
+// and an [*Error] if no rule of the current mode matches. Errors are sticky:
+// later calls return the same error.
+func (s *Scanner) Next() (Token, error) {
+	for s.err == nil {
+		if s.pos.Offset >= len(s.input) {
+			s.err = io.EOF
+			break
+		}
+
+		m := s.lx.modes[s.Mode()]
+
+		end, i := m.match(s.input, s.pos.Offset)
+		if i < 0 {
+			s.err = s.errorf("unexpected %q", s.next())
+			break
+		}
+
+		rule := m.rules[i]
+		if end == s.pos.Offset {
+			s.err = s.errorf("rule %d (%s) matched empty text", i, rule.Type)
+			break
+		}
+
+		if rule.Pop && rule.Push == "" && len(s.modes) == 1 {
+			s.err = s.errorf("rule %d (%s) pops the last mode", i, rule.Type)
+			break
+		}
+
+		tok := Token{Type: rule.Type, Text: s.input[s.pos.Offset:end], Pos: s.pos, Mode: m.name}
+		s.pos = s.pos.advance(tok.Text)
+
+		if rule.Pop {
+			s.modes = s.modes[:len(s.modes)-1]
+		}
+		if rule.Push != "" {
+			s.modes = append(s.modes, rule.Push)
+		}
+
+		if !rule.Skip {
+			return tok, nil
+		}
+	}
+
+	return Token{}, s.err
+}
+
+// next returns the character at the current position.
+func (s *Scanner) next() string {
+	_, size := utf8.DecodeRuneInString(s.input[s.pos.Offset:])
+	return s.input[s.pos.Offset : s.pos.Offset+size]
+}
+
+// errorf returns an *Error at the current position.
+func (s *Scanner) errorf(format string, args ...any) *Error {
+	start := strings.LastIndexByte(s.input[:s.pos.Offset], '\n') + 1
+
+	end := strings.IndexByte(s.input[s.pos.Offset:], '\n')
+	if end < 0 {
+		end = len(s.input)
+	} else {
+		end += s.pos.Offset
+	}
+
+	return &Error{
+		Pos:  s.pos,
+		Mode: s.Mode(),
+		Line: strings.TrimSuffix(s.input[start:end], "\r"),
+		Msg:  fmt.Sprintf(format, args...) + " in mode " + strconv.Quote(s.Mode()),
+	}
+}
@@ -0,0 +1,254 @@
+package lexer_test
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dwisiswant0/pcregexp/pkg/lexer"
+)
+
+// configModes lexes a small configuration language with strings,
+// interpolation and block comments.
+var configModes = lexer.Modes{
+	lexer.DefaultMode: {
+		{Pattern: `\s+`, Skip: true},
+		{Pattern: `#[^\n]*`, Skip: true},
+		{Pattern: `/\*`, Skip: true, Push: "comment"},
+		{Type: "keyword", Pattern: `(?:set|include)\b`},
+		{Type: "ident", Pattern: `[A-Za-z_][\w.]*`},
+		{Type: "number", Pattern: `-?\d+(?:\.\d+)?`},
+		{Type: "op", Pattern: `[=;{]`},
+		{Type: "quote", Pattern: `"`, Push: "string"},
+		{Type: "rbrace", Pattern: `\}`, Pop: true},
+	},
+	"string": {
+		{Type: "text", Pattern: `(?:[^"\\$]|\\.|\$(?!\{))+`},
+		{Type: "interp", Pattern: `\$\{`, Push: lexer.DefaultMode},
+		{Type: "quote", Pattern: `"`, Pop: true},
+	},
+	"comment": {
+		{Pattern: `\*/`, Skip: true, Pop: true},
+		{Pattern: `(?s:[^*]+|\*)`, Skip: true},
+	},
+}
+
+type tok struct {
+	Type lexer.TokenType
+	Text string
+}
+
+func tokens(t *testing.T, lx *lexer.Lexer, input string) []tok {
+	t.Helper()
+
+	toks, err := lx.Tokenize(input)
+	if err != nil {
+		t.Fatalf("Tokenize(%q) error = %v", input, err)
+	}
+
+	var got []tok
+	for _, tk := range toks {
+		got = append(got, tok{tk.Type, tk.Text})
+	}
+
+	return got
+}
+
+func TestLexer_Tokenize(t *testing.T) {
+	lx := lexer.MustCompile(configModes)
+	defer lx.Close()
+
+	got := tokens(t, lx, `set name = "a\"b" /* note */; # done`)
+	want := []tok{
+		{"keyword", "set"},
+		{"ident", "name"},
+		{"op", "="},
+		{"quote", `"`},
+		{"text", `a\"b`},
+		{"quote", `"`},
+		{"op", ";"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize() = %v, want %v", got, want)
+	}
+}
+
+func TestLexer_Modes(t *testing.T) {
+	modes := lexer.Modes{
+		lexer.DefaultMode: {
+			{Pattern: `\s+`, Skip: true},
+			{Type: "word", Pattern: `\w+`},
+			{Type: "open", Pattern: `"`, Push: "string"},
+			{Type: "close", Pattern: `\}`, Pop: true},
+		},
+		"string": {
+			{Type: "text", Pattern: `(?:[^"$]|\$(?!\{))+`},
+			{Type: "interp", Pattern: `\$\{`, Push: lexer.DefaultMode},
+			{Type: "close", Pattern: `"`, Pop: true},
+		},
+	}
+
+	lx := lexer.MustCompile(modes)
+	defer lx.Close()
+
+	got := tokens(t, lx, `say "hi ${name "x"} $5" now`)
+	want := []tok{
+		{"word", "say"},
+		{"open", `"`},
+		{"text", "hi "},
+		{"interp", "${"},
+		{"word", "name"},
+		{"open", `"`},
+		{"text", "x"},
+		{"close", `"`},
+		{"close", "}"},
+		{"text", " $5"},
+		{"close", `"`},
+		{"word", "now"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize() = %v, want %v", got, want)
+	}
+}
+
+func TestLexer_Begin(t *testing.T) {
+	lx := lexer.MustCompile(lexer.Modes{
+		lexer.DefaultMode: {
+			{Type: "a", Pattern: `a+`},
+			{Type: "switch", Pattern: `;`, Push: "b", Pop: true},
+		},
+		"b": {
+			{Type: "b", Pattern: `b+`},
+		},
+	})
+	defer lx.Close()
+
+	s := lx.ScanString("aa;bb")
+	for _, want := range []lexer.TokenType{"a", "switch", "b"} {
+		tk, err := s.Next()
+		if err != nil || tk.Type != want {
+			t.Fatalf("Next() = %v, %v, want a %s token", tk, err, want)
+		}
+	}
+
+	if s.Mode() != "b" {
+		t.Errorf("Mode() = %q, want %q", s.Mode(), "b")
+	}
+
+	if _, err := s.Next(); err != io.EOF {
+		t.Errorf("Next() at the end error = %v, want io.EOF", err)
+	}
+}
+
+func TestLexer_Positions(t *testing.T) {
+	lx := lexer.MustCompile(configModes)
+	defer lx.Close()
+
+	toks, err := lx.Tokenize("set\n  x = \"é\"\r\ny")
+	if err != nil {
+		t.Fatalf("Tokenize() error = %v", err)
+	}
+
+	want := []lexer.Position{
+		{Offset: 0, Line: 1, Column: 1},
+		{Offset: 6, Line: 2, Column: 3},
+		{Offset: 8, Line: 2, Column: 5},
+		{Offset: 10, Line: 2, Column: 7},
+		{Offset: 11, Line: 2, Column: 8},
+		{Offset: 13, Line: 2, Column: 9},
+		{Offset: 16, Line: 3, Column: 1},
+	}
+
+	var got []lexer.Position
+	for _, tk := range toks {
+		got = append(got, tk.Pos)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("positions = %v, want %v", got, want)
+	}
+}
+
+func TestLexer_Errors(t *testing.T) {
+	lx := lexer.MustCompile(configModes)
+	defer lx.Close()
+
+	s := lx.ScanString("set x = 1;\n\tset y = @2;")
+
+	var err error
+	for err == nil {
+		_, err = s.Next()
+	}
+
+	var lexErr *lexer.Error
+	if !errors.As(err, &lexErr) {
+		t.Fatalf("Next() error = %v, want *lexer.Error", err)
+	}
+
+	if lexErr.Pos != (lexer.Position{Offset: 20, Line: 2, Column: 10}) {
+		t.Errorf("Error.Pos = %+v", lexErr.Pos)
+	}
+
+	if want := `lexer: 2:10: unexpected "@" in mode "default"`; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+
+	if want := "\tset y = @2;\n\t        ^"; lexErr.Context() != want {
+		t.Errorf("Context() = %q, want %q", lexErr.Context(), want)
+	}
+
+	if _, again := s.Next(); again != err {
+		t.Errorf("Next() after an error = %v, want the same error", again)
+	}
+
+	for input, msg := range map[string]string{
+		"}":          "pops the last mode",
+		`"unclosed`:  "",
+		"/* endless": "",
+	} {
+		_, err := lx.Tokenize(input)
+		if msg == "" {
+			if err != nil {
+				t.Errorf("Tokenize(%q) error = %v", input, err)
+			}
+			continue
+		}
+
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("Tokenize(%q) error = %v, want it to contain %q", input, err, msg)
+		}
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		modes lexer.Modes
+		want  string
+	}{
+		{"no default mode", lexer.Modes{"x": {{Type: "a", Pattern: "a"}}}, `no rules for mode "default"`},
+		{"empty mode", lexer.Modes{lexer.DefaultMode: nil}, "has no rules"},
+		{"missing type", lexer.Modes{lexer.DefaultMode: {{Pattern: "a"}}}, "missing token type"},
+		{"unknown mode", lexer.Modes{lexer.DefaultMode: {{Type: "a", Pattern: "a", Push: "nope"}}}, `unknown mode "nope"`},
+		{"bad pattern", lexer.Modes{lexer.DefaultMode: {{Type: "a", Pattern: "a"}, {Type: "b", Pattern: "(b"}}}, "rule 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := lexer.Compile(tt.modes)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Compile() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLexer_EmptyMatch(t *testing.T) {
+	lx := lexer.MustCompile(lexer.Modes{lexer.DefaultMode: {{Type: "a", Pattern: "a*"}}})
+	defer lx.Close()
+
+	if _, err := lx.Tokenize("b"); err == nil || !strings.Contains(err.Error(), "matched empty text") {
+		t.Errorf("Tokenize() error = %v, want an empty match error", err)
+	}
+}
@@ -0,0 +1,30 @@
+// Package metrics publishes the PCRE2 resource counters of [pcregexp]
+// through [expvar], so that they can be scraped from /debug/vars.
+//
+// It lives in its own package because importing expvar registers the
+// /debug/vars handler on [net/http.DefaultServeMux].
+package metrics
+
+import (
+	"expvar"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+// DefaultName is the expvar name used by [Publish] when name is empty.
+const DefaultName = "pcregexp"
+
+// Publish exports [pcregexp.Stats] as the expvar variable name, or
+// [DefaultName] if name is empty. The snapshot is taken each time the
+// variable is read.
+//
+// Like [expvar.Publish], it panics if name is already in use.
+func Publish(name string) {
+	if name == "" {
+		name = DefaultName
+	}
+
+	expvar.Publish(name, expvar.Func(func() any {
+		return pcregexp.Stats()
+	}))
+}
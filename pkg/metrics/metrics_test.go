@@ -0,0 +1,31 @@
+package metrics_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+
+	"github.com/dwisiswant0/pcregexp"
+	"github.com/dwisiswant0/pcregexp/pkg/metrics"
+)
+
+func TestPublish(t *testing.T) {
+	metrics.Publish("")
+
+	re := pcregexp.MustCompile(`p([a-z]+)ch`)
+	defer re.Close()
+
+	v := expvar.Get(metrics.DefaultName)
+	if v == nil {
+		t.Fatalf("expvar.Get(%q) = nil", metrics.DefaultName)
+	}
+
+	var stats pcregexp.Statistics
+	if err := json.Unmarshal([]byte(v.String()), &stats); err != nil {
+		t.Fatalf("decoding %s: %v", v.String(), err)
+	}
+
+	if stats.LiveRegexps < 1 || stats.Compiles < 1 {
+		t.Errorf("published stats = %+v, want at least one live, compiled pattern", stats)
+	}
+}
@@ -0,0 +1,229 @@
+package pcre2test
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+// modifiers are the settings of a pattern or a subject line, as given by
+// their modifier lists.
+type modifiers struct {
+	compile pcregexp.CompileOptions
+
+	// Subject settings, which a pattern gives to all of its subjects.
+	flags     pcregexp.MatchFlag // match and substitution options
+	global    bool
+	afterText bool
+	mark      bool
+	replace   *string
+	offset    int
+}
+
+// compileFlags are the pattern modifiers setting compile options.
+var compileFlags = map[string]pcregexp.CompileFlag{
+	"allow_empty_class":   pcregexp.CompileAllowEmptyClass,
+	"alt_bsux":            pcregexp.CompileAltBSUX,
+	"alt_circumflex":      pcregexp.CompileAltCircumflex,
+	"alt_verbnames":       pcregexp.CompileAltVerbNames,
+	"anchored":            pcregexp.CompileAnchored,
+	"caseless":            pcregexp.CompileCaseless,
+	"dollar_endonly":      pcregexp.CompileDollarEndOnly,
+	"dotall":              pcregexp.CompileDotAll,
+	"dupnames":            pcregexp.CompileDupNames,
+	"endanchored":         pcregexp.CompileEndAnchored,
+	"extended":            pcregexp.CompileExtended,
+	"extended_more":       pcregexp.CompileExtendedMore,
+	"firstline":           pcregexp.CompileFirstLine,
+	"literal":             pcregexp.CompileLiteral,
+	"match_invalid_utf":   pcregexp.CompileMatchInvalidUTF,
+	"match_unset_backref": pcregexp.CompileMatchUnsetBackref,
+	"multiline":           pcregexp.CompileMultiline,
+	"never_backslash_c":   pcregexp.CompileNeverBackslashC,
+	"never_ucp":           pcregexp.CompileNeverUCP,
+	"never_utf":           pcregexp.CompileNeverUTF,
+	"no_auto_capture":     pcregexp.CompileNoAutoCapture,
+	"no_auto_possess":     pcregexp.CompileNoAutoPossess,
+	"no_dotstar_anchor":   pcregexp.CompileNoDotStarAnchor,
+	"no_start_optimize":   pcregexp.CompileNoStartOptimize,
+	"ucp":                 pcregexp.CompileUCP,
+	"ungreedy":            pcregexp.CompileUngreedy,
+	"utf":                 pcregexp.CompileUTF,
+	"i":                   pcregexp.CompileCaseless,
+	"m":                   pcregexp.CompileMultiline,
+	"n":                   pcregexp.CompileNoAutoCapture,
+	"s":                   pcregexp.CompileDotAll,
+	"x":                   pcregexp.CompileExtended,
+	"xx":                  pcregexp.CompileExtendedMore,
+}
+
+// extraFlags are the pattern modifiers setting extra compile options.
+var extraFlags = map[string]pcregexp.ExtraFlag{
+	"allow_lookaround_bsk":    pcregexp.ExtraAllowLookaroundBSK,
+	"allow_surrogate_escapes": pcregexp.ExtraAllowSurrogateEscapes,
+	"bad_escape_is_literal":   pcregexp.ExtraBadEscapeIsLiteral,
+	"escaped_cr_is_lf":        pcregexp.ExtraEscapedCRIsLF,
+	"extra_alt_bsux":          pcregexp.ExtraAltBSUX,
+	"match_line":              pcregexp.ExtraMatchLine,
+	"match_word":              pcregexp.ExtraMatchWord,
+}
+
+// matchFlags are the subject modifiers setting match options. Those that
+// are also pattern modifiers are given by pattern lines to all subjects.
+var matchFlags = map[string]pcregexp.MatchFlag{
+	"anchored":                    pcregexp.MatchAnchored,
+	"endanchored":                 pcregexp.MatchEndAnchored,
+	"no_utf_check":                pcregexp.MatchNoUTFCheck,
+	"notbol":                      pcregexp.MatchNotBOL,
+	"notempty":                    pcregexp.MatchNotEmpty,
+	"notempty_atstart":            pcregexp.MatchNotEmptyAtStart,
+	"noteol":                      pcregexp.MatchNotEOL,
+	"partial_hard":                pcregexp.MatchPartialHard,
+	"partial_soft":                pcregexp.MatchPartialSoft,
+	"ph":                          pcregexp.MatchPartialHard,
+	"ps":                          pcregexp.MatchPartialSoft,
+	"substitute_extended":         pcregexp.SubstituteExtended,
+	"substitute_literal":          pcregexp.SubstituteLiteral,
+	"substitute_replacement_only": pcregexp.SubstituteReplacementOnly,
+	"substitute_unknown_unset":    pcregexp.SubstituteUnknownUnset,
+	"substitute_unset_empty":      pcregexp.SubstituteUnsetEmpty,
+}
+
+// newlines are the values of the newline modifier.
+var newlines = map[string]pcregexp.Newline{
+	"cr":      pcregexp.NewlineCR,
+	"lf":      pcregexp.NewlineLF,
+	"crlf":    pcregexp.NewlineCRLF,
+	"any":     pcregexp.NewlineAny,
+	"anycrlf": pcregexp.NewlineAnyCRLF,
+	"nul":     pcregexp.NewlineNUL,
+}
+
+// ignored are the modifiers that make no difference to the output of the
+// tests supported: PCREgexp always uses the JIT when it can, and always
+// asks for the substitution length.
+var ignored = map[string]bool{
+	"jit":                        true,
+	"substitute_overflow_length": true,
+}
+
+// unsupportedError reports a modifier, escape or command that the driver
+// cannot run, which makes the test block it appears in skipped.
+type unsupportedError struct {
+	what string
+}
+
+func (e *unsupportedError) Error() string {
+	return "unsupported " + e.what
+}
+
+func unsupported(format string, args ...any) error {
+	return &unsupportedError{fmt.Sprintf(format, args...)}
+}
+
+// parse applies the comma-separated modifier list of a pattern line, or of
+// a subject line if subject is true, to m.
+func (m *modifiers) parse(list string, subject bool) error {
+	for list = strings.TrimSpace(list); list != ""; {
+		item := list
+		if i := strings.IndexByte(list, ','); i >= 0 {
+			item, list = list[:i], list[i+1:]
+		} else {
+			list = ""
+		}
+
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		if err := m.set(item, subject); err == nil {
+			continue
+		} else if subject || strings.ContainsRune(item, '=') {
+			return err
+		}
+
+		// A run of single-letter pattern modifiers, as in /abc/ig.
+		for _, c := range item {
+			if len(string(c)) != 1 || m.set(string(c), subject) != nil {
+				return unsupported("modifier %q", item)
+			}
+		}
+	}
+
+	return nil
+}
+
+// set applies the single modifier item to m.
+func (m *modifiers) set(item string, subject bool) error {
+	name, value, hasValue := strings.Cut(item, "=")
+
+	switch name {
+	case "g", "global":
+		m.global = true
+		return nil
+	case "aftertext":
+		m.afterText = true
+		return nil
+	case "mark":
+		m.mark = true
+		return nil
+	case "replace":
+		if strings.HasPrefix(value, "[") {
+			return unsupported("replacement buffer size in %q", item)
+		}
+		m.replace = &value
+		return nil
+	case "offset":
+		n, err := strconv.Atoi(value)
+		if !subject || err != nil || n < 0 {
+			return unsupported("modifier %q", item)
+		}
+		m.offset = n
+		return nil
+	case "newline":
+		nl, ok := newlines[strings.ToLower(value)]
+		if subject || !ok {
+			return unsupported("modifier %q", item)
+		}
+		m.compile.Newline = nl
+		return nil
+	case "parens_nest_limit":
+		n, err := strconv.ParseUint(value, 10, 32)
+		if subject || err != nil {
+			return unsupported("modifier %q", item)
+		}
+		m.compile.ParensNestLimit = uint32(n)
+		return nil
+	}
+
+	if hasValue {
+		return unsupported("modifier %q", item)
+	}
+
+	if ignored[name] {
+		return nil
+	}
+
+	if !subject {
+		if f, ok := compileFlags[name]; ok {
+			m.compile.Flags |= f
+			return nil
+		}
+		if f, ok := extraFlags[name]; ok {
+			m.compile.Extra |= f
+			return nil
+		}
+	}
+
+	// Pattern lines may give subject modifiers other than anchoring, which
+	// is a compile option there.
+	if f, ok := matchFlags[name]; ok && (subject || f&(pcregexp.MatchAnchored|pcregexp.MatchEndAnchored) == 0) {
+		m.flags |= f
+		return nil
+	}
+
+	return unsupported("modifier %q", item)
+}
@@ -0,0 +1,192 @@
+// Package pcre2test runs test files in the format of pcre2test, the test
+// program of PCRE2, through PCREgexp, so that its results can be checked
+// against the expected output of the PCRE2 test corpus.
+//
+// An input file holds patterns, each followed by subject lines to match
+// against it, with comma-separated modifier lists setting compile options
+// after a pattern and match options after \= in a subject. [Run] produces
+// the output pcre2test would, split into blocks at blank lines: the input
+// lines are echoed, followed by the captured groups of every match, "No
+// match", "Partial match: ..." or the result of a substitution.
+//
+// Subjects are matched with the methods of [pcregexp.PCREgexp] rather than
+// with PCRE2 directly, so the output shows where the wrapper departs from
+// upstream. In particular, global matching (the g modifier) follows
+// [pcregexp.PCREgexp.FindAllSubmatchIndex], which moves on by one character
+// after an empty match where pcre2test first looks for a non-empty match at
+// the same offset.
+//
+// Only part of pcre2test is supported: blocks using other modifiers or
+// commands, such as pattern information, DFA matching or callouts, are
+// reported as skipped rather than run. [Compare] checks the blocks against
+// an expected output file.
+package pcre2test
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Block is a group of consecutive input lines, usually a pattern and its
+// subjects, with the output they produce.
+type Block struct {
+	// Line is the number of the first input line of the block.
+	Line int
+
+	// Output is the output of the block, including the echoed input lines,
+	// with each line ending in a newline.
+	Output string
+
+	// Skip tells why the block was not run, if it uses unsupported
+	// features.
+	Skip string
+}
+
+// Report is the result of comparing blocks against expected output.
+type Report struct {
+	Passed, Failed, Skipped int
+
+	// Failures are the blocks whose output differs from the expected one.
+	Failures []Failure
+}
+
+// Failure is a block whose output differs from the expected output.
+type Failure struct {
+	Block Block
+
+	// Want is the expected output of the block.
+	Want string
+}
+
+// Diff returns a line diff of the expected output and the output of the
+// block: missing lines are prefixed with "-", lines in excess with "+", and
+// the others with a space.
+func (f *Failure) Diff() string {
+	return diffLines(splitLines(f.Want), splitLines(f.Block.Output))
+}
+
+// Compare compares the output of blocks with the expected output, split
+// into blocks at blank lines, and reports the result. The version line
+// pcre2test prints first, unless given -q, is ignored.
+func Compare(blocks []Block, expected io.Reader) (*Report, error) {
+	want, err := splitBlocks(expected)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(want) > 0 && strings.HasPrefix(want[0], "PCRE2 version ") {
+		_, want[0], _ = strings.Cut(want[0], "\n")
+		if want[0] == "" {
+			want = want[1:]
+		}
+	}
+
+	r := new(Report)
+	for i, b := range blocks {
+		var w string
+		if i < len(want) {
+			w = want[i]
+		}
+
+		switch {
+		case b.Skip != "":
+			r.Skipped++
+		case b.Output == w:
+			r.Passed++
+		default:
+			r.Failed++
+			r.Failures = append(r.Failures, Failure{Block: b, Want: w})
+		}
+	}
+
+	// Expected blocks without a counterpart fail.
+	for i := len(blocks); i < len(want); i++ {
+		r.Failed++
+		r.Failures = append(r.Failures, Failure{Want: want[i]})
+	}
+
+	return r, nil
+}
+
+// splitBlocks reads r and returns its blocks of lines separated by blank
+// lines.
+func splitBlocks(r io.Reader) ([]string, error) {
+	var (
+		blocks []string
+		cur    strings.Builder
+	)
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.TrimSpace(line) == "" {
+			if cur.Len() > 0 {
+				blocks = append(blocks, cur.String())
+				cur.Reset()
+			}
+			continue
+		}
+
+		cur.WriteString(line)
+		cur.WriteByte('\n')
+	}
+
+	if cur.Len() > 0 {
+		blocks = append(blocks, cur.String())
+	}
+
+	return blocks, sc.Err()
+}
+
+// splitLines returns the lines of s, which ends in a newline if not empty.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines returns a line diff of a and b, computed from their longest
+// common subsequence, with unchanged lines prefixed with a space.
+func diffLines(a, b []string) string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			sb.WriteString(" " + a[i] + "\n")
+			i++
+			j++
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			sb.WriteString("-" + a[i] + "\n")
+			i++
+		default:
+			sb.WriteString("+" + b[j] + "\n")
+			j++
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,118 @@
+package pcre2test_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/dwisiswant0/pcregexp/pkg/pcre2test"
+)
+
+// run runs the pcre2test input and compares it with the expected output.
+func run(t *testing.T, input, expected string) *pcre2test.Report {
+	t.Helper()
+
+	blocks, err := pcre2test.Run(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := pcre2test.Compare(blocks, strings.NewReader(expected))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return report
+}
+
+func TestRun_Corpus(t *testing.T) {
+	input, err := os.ReadFile("testdata/testinput")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := os.ReadFile("testdata/testoutput")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := run(t, string(input), string(expected))
+	for _, f := range report.Failures {
+		t.Errorf("block at line %d differs from pcre2test:\n%s", f.Block.Line, f.Diff())
+	}
+
+	if report.Passed < 80 || report.Skipped != 2 {
+		t.Errorf("%d passed, %d skipped, want over 80 and 2", report.Passed, report.Skipped)
+	}
+}
+
+func TestRun_Output(t *testing.T) {
+	input := "# Comment\n/(a)(x)?(b)/aftertext\n    zab\\=offset=1\n\\= Expect no match\n    b\n\n\n/é/utf\n  \\x{e9}\\t\n"
+	want := []string{
+		"# Comment\n/(a)(x)?(b)/aftertext\n    zab\\=offset=1\n 0: ab\n 0+ \n 1: a\n 2: <unset>\n 3: b\n\\= Expect no match\n    b\nNo match\n",
+		"/é/utf\n  \\x{e9}\\t\n 0: \\x{e9}\n",
+	}
+
+	blocks, err := pcre2test.Run(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(blocks) != len(want) {
+		t.Fatalf("got %d blocks, want %d", len(blocks), len(want))
+	}
+
+	for i, b := range blocks {
+		if b.Output != want[i] || b.Skip != "" {
+			t.Errorf("block %d = %q, skip %q, want %q", i, b.Output, b.Skip, want[i])
+		}
+	}
+
+	if blocks[1].Line != 8 {
+		t.Errorf("second block starts on line %d, want 8", blocks[1].Line)
+	}
+}
+
+func TestRun_Skip(t *testing.T) {
+	for _, input := range []string{
+		"/a/I\n    a\n",
+		"/a/\n    a\\=dfa\n",
+		"/a/\n    \\[a]{3}\n",
+		"/a/\n    \\x{100}\n",
+		"/a/replace=[10]b\n    a\n",
+		"#load tables\n",
+	} {
+		blocks, err := pcre2test.Run(strings.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(blocks) != 1 || blocks[0].Skip == "" {
+			t.Errorf("Run(%q) = %+v, want a skipped block", input, blocks)
+		}
+	}
+}
+
+func TestCompare_Divergence(t *testing.T) {
+	// pcre2test looks for a non-empty match where an empty one was found,
+	// while global matching in PCREgexp moves on.
+	input := "/|a/g\n    a\n"
+	expected := "PCRE2 version 10.42 2022-12-11\n/|a/g\n    a\n 0: \n 0: a\n 0: \n"
+
+	report := run(t, input, expected)
+	if report.Passed != 0 || report.Failed != 1 || len(report.Failures) != 1 {
+		t.Fatalf("report = %+v, want one failure", report)
+	}
+
+	want := " /|a/g\n     a\n  0: \n- 0: a\n  0: \n"
+	if got := report.Failures[0].Diff(); got != want {
+		t.Errorf("Diff() = %q, want %q", got, want)
+	}
+}
+
+func TestCompare_MissingBlocks(t *testing.T) {
+	report := run(t, "/a/\n    a\n", "/a/\n    a\n 0: a\n\n/b/\n    b\n 0: b\n")
+	if report.Passed != 1 || report.Failed != 1 || report.Failures[0].Want != "/b/\n    b\n 0: b\n" {
+		t.Errorf("report = %+v, want one pass and one missing block", report)
+	}
+}
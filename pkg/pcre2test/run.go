@@ -0,0 +1,446 @@
+package pcre2test
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+// runner runs the lines of an input file.
+type runner struct {
+	blocks []Block
+	cur    *Block
+	out    strings.Builder
+
+	// Defaults set by the #pattern and #subject commands.
+	patternDefaults modifiers
+	subjectDefaults modifiers
+
+	re   *pcregexp.PCREgexp // nil if the last pattern failed to compile
+	mods modifiers          // modifiers of the last pattern
+	utf  bool               // whether the last pattern is in UTF mode
+}
+
+// Run runs the pcre2test input read from r and returns its blocks.
+func Run(r io.Reader) ([]Block, error) {
+	var (
+		rn      runner
+		lineNum int
+	)
+	defer rn.closeRegexp()
+
+	br := bufio.NewReader(r)
+	readLine := func() (string, bool, error) {
+		line, err := br.ReadString('\n')
+		if err == io.EOF {
+			if line == "" {
+				return "", false, nil
+			}
+			err = nil
+		}
+		if err != nil {
+			return "", false, err
+		}
+
+		lineNum++
+		return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), true, nil
+	}
+
+	inSubjects := false
+	for {
+		line, ok, err := readLine()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+
+		if strings.TrimSpace(line) == "" {
+			rn.endBlock()
+			inSubjects = false
+			continue
+		}
+
+		rn.startBlock(lineNum)
+		rn.echo(line)
+
+		switch {
+		case inSubjects:
+			rn.subject(line)
+		case strings.HasPrefix(line, "#"):
+			rn.command(line)
+		default:
+			// A pattern may span lines up to its closing delimiter.
+			text := line
+			for !closed(text) {
+				next, ok, err := readLine()
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					break
+				}
+				rn.echo(next)
+				text += "\n" + next
+			}
+
+			rn.pattern(text)
+			inSubjects = true
+		}
+	}
+	rn.endBlock()
+
+	return rn.blocks, nil
+}
+
+// startBlock starts a block at line num, unless one is in progress.
+func (rn *runner) startBlock(num int) {
+	if rn.cur == nil {
+		rn.cur = &Block{Line: num}
+	}
+}
+
+// endBlock ends the block in progress, if any.
+func (rn *runner) endBlock() {
+	if rn.cur == nil {
+		return
+	}
+
+	rn.cur.Output = rn.out.String()
+	rn.blocks = append(rn.blocks, *rn.cur)
+	rn.cur = nil
+	rn.out.Reset()
+}
+
+// echo writes line to the output.
+func (rn *runner) echo(line string) {
+	rn.out.WriteString(line)
+	rn.out.WriteByte('\n')
+}
+
+// printf writes a line of output.
+func (rn *runner) printf(format string, args ...any) {
+	fmt.Fprintf(&rn.out, format, args...)
+	rn.out.WriteByte('\n')
+}
+
+// skip marks the block in progress as skipped because of err, an
+// unsupportedError, or panics for any other error.
+func (rn *runner) skip(err error) {
+	var uerr *unsupportedError
+	if !errors.As(err, &uerr) {
+		panic(err)
+	}
+
+	if rn.cur.Skip == "" {
+		rn.cur.Skip = uerr.Error()
+	}
+}
+
+// closeRegexp closes the regexp of the last pattern.
+func (rn *runner) closeRegexp() {
+	if rn.re != nil {
+		rn.re.Close()
+		rn.re = nil
+	}
+}
+
+// command runs a line starting with #: a comment, or a command setting
+// defaults.
+func (rn *runner) command(line string) {
+	name, args, _ := strings.Cut(line[1:], " ")
+
+	switch name {
+	case "pattern":
+		if err := rn.patternDefaults.parse(args, false); err != nil {
+			rn.skip(err)
+		}
+	case "subject":
+		if err := rn.subjectDefaults.parse(args, true); err != nil {
+			rn.skip(err)
+		}
+	case "forbid_utf":
+		rn.patternDefaults.compile.Flags |= pcregexp.CompileNeverUTF | pcregexp.CompileNeverUCP
+	case "perltest":
+		// Only tells that the file is also valid input to perltest.sh.
+	case "newline_default", "load", "loadtables", "save", "pop", "popcopy":
+		rn.skip(unsupported("command #%s", name))
+	}
+}
+
+// closed reports whether text holds a whole pattern line: a pattern between
+// delimiters, which may be escaped within it with a backslash.
+func closed(text string) bool {
+	text = strings.TrimLeft(text, " \t")
+	if text == "" {
+		return true
+	}
+
+	delim := text[0]
+	for i := 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case delim:
+			return true
+		}
+	}
+
+	return false
+}
+
+// pattern compiles the pattern of a pattern line, and prints the error if
+// it fails to compile.
+func (rn *runner) pattern(text string) {
+	rn.closeRegexp()
+
+	text = strings.TrimLeft(text, " \t")
+	delim := text[0]
+	if delim >= 'a' && delim <= 'z' || delim >= 'A' && delim <= 'Z' || delim >= '0' && delim <= '9' || delim == '\\' {
+		rn.skip(unsupported("pattern delimiter %q", delim))
+		return
+	}
+
+	pat, list := text[1:], ""
+	for i := 1; i < len(text); i++ {
+		if text[i] == '\\' {
+			i++
+			continue
+		}
+		if text[i] == delim {
+			pat, list = text[1:i], text[i+1:]
+			break
+		}
+	}
+
+	rn.mods = rn.patternDefaults
+	if err := rn.mods.parse(list, false); err != nil {
+		rn.skip(err)
+		return
+	}
+
+	rn.utf = rn.mods.compile.Flags&pcregexp.CompileUTF != 0 || startsUTF(pat)
+
+	re, err := pcregexp.CompileWithOptions(pat, rn.mods.compile)
+	if err != nil {
+		var cerr *pcregexp.CompileError
+		if !errors.As(err, &cerr) {
+			rn.skip(unsupported("compile error %v", err))
+			return
+		}
+
+		rn.printf("Failed: error %d at offset %d: %s", cerr.Code, cerr.Offset, pcregexp.ErrorMessage(cerr.Code))
+		return
+	}
+
+	rn.re = re
+}
+
+// startsUTF reports whether pattern starts with a (*UTF) setting.
+func startsUTF(pattern string) bool {
+	for strings.HasPrefix(pattern, "(*") {
+		end := strings.IndexByte(pattern, ')')
+		if end < 0 {
+			return false
+		}
+		if verb := pattern[2:end]; verb == "UTF" || verb == "UTF8" {
+			return true
+		}
+		pattern = pattern[end+1:]
+	}
+
+	return false
+}
+
+// subject runs a subject line against the last pattern.
+func (rn *runner) subject(line string) {
+	if strings.HasPrefix(line, `\= `) || line == `\=` {
+		// A comment, such as "\= Expect no match".
+		return
+	}
+
+	if rn.re == nil {
+		// The pattern failed to compile, or was skipped.
+		return
+	}
+
+	data, list, err := parseSubject(line, rn.utf)
+	if err != nil {
+		rn.skip(err)
+		return
+	}
+
+	m := rn.mods
+	m.flags |= rn.subjectDefaults.flags
+	m.global = m.global || rn.subjectDefaults.global
+	m.afterText = m.afterText || rn.subjectDefaults.afterText
+	m.mark = m.mark || rn.subjectDefaults.mark
+	if m.replace == nil {
+		m.replace = rn.subjectDefaults.replace
+	}
+	if err := m.parse(list, true); err != nil {
+		rn.skip(err)
+		return
+	}
+
+	if m.offset > len(data) {
+		rn.skip(unsupported("offset beyond the subject"))
+		return
+	}
+
+	if m.replace != nil {
+		rn.substitute(data, m)
+		return
+	}
+
+	if m.flags&(pcregexp.MatchPartialSoft|pcregexp.MatchPartialHard) != 0 {
+		rn.partial(data, m)
+		return
+	}
+
+	rn.match(data, m)
+}
+
+// substitute prints the result of a substitution, or its error.
+func (rn *runner) substitute(data []byte, m modifiers) {
+	flags := m.flags
+	if m.global {
+		flags |= pcregexp.SubstituteGlobal
+	}
+
+	out, n, err := rn.re.Substitute(data, []byte(*m.replace), m.offset, flags)
+	if err != nil {
+		var serr *pcregexp.SubstituteError
+		if !errors.As(err, &serr) {
+			rn.skip(unsupported("substitution error %v", err))
+			return
+		}
+
+		if serr.Offset >= 0 {
+			rn.printf("Failed: error %d at offset %d in replacement: %s", serr.Code, serr.Offset, pcregexp.ErrorMessage(serr.Code))
+		} else {
+			rn.printf("Failed: error %d: %s", serr.Code, pcregexp.ErrorMessage(serr.Code))
+		}
+		return
+	}
+
+	rn.printf("%2d: %s", n, quote(out, rn.utf))
+}
+
+// partial prints the result of a partial match.
+func (rn *runner) partial(data []byte, m modifiers) {
+	if m.global || m.mark {
+		rn.skip(unsupported("partial matching with global matching or marks"))
+		return
+	}
+
+	loc, partial := rn.re.FindSubmatchIndexPartialAt(data, m.offset, m.flags)
+	switch {
+	case loc == nil:
+		rn.printf("No match")
+	case partial:
+		rn.printf("Partial match: %s", quote(data[loc[0]:loc[1]], rn.utf))
+	default:
+		rn.printMatch(data, loc, m, "")
+	}
+}
+
+// match prints the match of the subject, or all of its matches with the g
+// modifier.
+func (rn *runner) match(data []byte, m modifiers) {
+	find := func(pos int) ([]int, string) {
+		loc := rn.re.FindSubmatchIndexAt(data, pos, m.flags)
+		if loc == nil || !m.mark {
+			return loc, ""
+		}
+
+		_, mark := rn.re.FindIndexMarkAt(data, pos, m.flags)
+		return loc, mark
+	}
+
+	// Without options, the plain methods are checked.
+	plain := m.offset == 0 && m.flags == 0 && !m.mark
+
+	if !m.global {
+		var (
+			loc  []int
+			mark string
+		)
+		if plain {
+			loc = rn.re.FindSubmatchIndex(data)
+		} else {
+			loc, mark = find(m.offset)
+		}
+
+		if loc == nil {
+			rn.printf("No match")
+			return
+		}
+		rn.printMatch(data, loc, m, mark)
+		return
+	}
+
+	if plain {
+		all := rn.re.FindAllSubmatchIndex(data, -1)
+		if all == nil {
+			rn.printf("No match")
+		}
+		for _, loc := range all {
+			rn.printMatch(data, loc, m, "")
+		}
+		return
+	}
+
+	// The same iteration as FindAllSubmatchIndex, from the offset and with
+	// the match options of the subject.
+	for pos, n := m.offset, 0; pos <= len(data); n++ {
+		loc, mark := find(pos)
+		if loc == nil {
+			if n == 0 {
+				rn.printf("No match")
+			}
+			return
+		}
+		rn.printMatch(data, loc, m, mark)
+
+		pos = loc[1]
+		if loc[0] == loc[1] {
+			if pos >= len(data) {
+				return
+			}
+			_, size := utf8.DecodeRune(data[pos:])
+			pos += size
+		}
+	}
+}
+
+// printMatch prints the groups of a match, the text following it with the
+// aftertext modifier, and the last mark passed, if any.
+func (rn *runner) printMatch(data []byte, loc []int, m modifiers, mark string) {
+	for i := 0; 2*i+1 < len(loc); i++ {
+		start, end := loc[2*i], loc[2*i+1]
+		if start < 0 {
+			rn.printf("%2d: <unset>", i)
+			continue
+		}
+		if start > end {
+			rn.skip(unsupported("match starting after its end"))
+			return
+		}
+
+		rn.printf("%2d: %s", i, quote(data[start:end], rn.utf))
+		if i == 0 && m.afterText {
+			rn.printf("%2d+ %s", i, quote(data[end:], rn.utf))
+		}
+	}
+
+	if mark != "" {
+		rn.printf("MK: %s", quote([]byte(mark), rn.utf))
+	}
+}
@@ -0,0 +1,153 @@
+package pcre2test
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// parseSubject returns the data of a subject line, with its escapes
+// processed, and its modifier list following \=, if any. Escaped code
+// points above 0x7f are encoded in UTF-8 in UTF mode, and must fit a byte
+// otherwise.
+func parseSubject(line string, utf bool) (data []byte, mods string, err error) {
+	s := strings.TrimSpace(line)
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c != '\\' {
+			data = append(data, c)
+			i++
+			continue
+		}
+
+		if i+1 == len(s) {
+			// A trailing backslash is ignored.
+			break
+		}
+
+		e := s[i+1]
+		i += 2
+
+		switch e {
+		case '=':
+			return data, s[i:], nil
+		case '\\':
+			data = append(data, '\\')
+		case 'a':
+			data = append(data, 7)
+		case 'b':
+			data = append(data, 8)
+		case 'e':
+			data = append(data, 27)
+		case 'f':
+			data = append(data, '\f')
+		case 'n':
+			data = append(data, '\n')
+		case 'r':
+			data = append(data, '\r')
+		case 't':
+			data = append(data, '\t')
+		case 'v':
+			data = append(data, '\v')
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			j := i - 1
+			for i < len(s) && i-j < 3 && s[i] >= '0' && s[i] <= '7' {
+				i++
+			}
+			n, _ := strconv.ParseUint(s[j:i], 8, 32)
+			if data, err = appendCode(data, n, utf); err != nil {
+				return nil, "", err
+			}
+		case 'o', 'x':
+			base, digits := 8, "01234567"
+			if e == 'x' {
+				base, digits = 16, "0123456789abcdefABCDEF"
+			}
+
+			var text string
+			switch {
+			case i < len(s) && s[i] == '{':
+				end := strings.IndexByte(s[i:], '}')
+				if end < 0 {
+					return nil, "", unsupported("escape in subject %q", line)
+				}
+				text, i = s[i+1:i+end], i+end+1
+			case e == 'x':
+				j := i
+				for i < len(s) && i-j < 2 && strings.IndexByte(digits, s[i]) >= 0 {
+					i++
+				}
+				text = s[j:i]
+				if text == "" {
+					text = "0"
+				}
+
+				// \xhh is a byte, even in UTF mode.
+				n, _ := strconv.ParseUint(text, 16, 8)
+				data = append(data, byte(n))
+				continue
+			default:
+				return nil, "", unsupported("escape in subject %q", line)
+			}
+
+			n, perr := strconv.ParseUint(text, base, 32)
+			if perr != nil {
+				return nil, "", unsupported("escape in subject %q", line)
+			}
+			if data, err = appendCode(data, n, utf); err != nil {
+				return nil, "", err
+			}
+		default:
+			// Escapes such as \[ for repetition.
+			return nil, "", unsupported("escape \\%c in subject", e)
+		}
+	}
+
+	return data, "", nil
+}
+
+// appendCode appends code point n to data.
+func appendCode(data []byte, n uint64, utf bool) ([]byte, error) {
+	switch {
+	case utf && n > 0x7f:
+		if n > utf8.MaxRune {
+			return nil, unsupported("code point %#x in subject", n)
+		}
+		return utf8.AppendRune(data, rune(n)), nil
+	case n > 0xff:
+		return nil, unsupported("code point %#x in subject outside of UTF mode", n)
+	default:
+		return append(data, byte(n)), nil
+	}
+}
+
+// quote returns b as pcre2test prints it: printable ASCII as is, and other
+// characters as hexadecimal escapes, which in UTF mode stand for code
+// points rather than bytes.
+func quote(b []byte, utf bool) string {
+	var sb strings.Builder
+
+	for len(b) > 0 {
+		if c := b[0]; c >= 0x20 && c < 0x7f {
+			sb.WriteByte(c)
+			b = b[1:]
+			continue
+		}
+
+		if utf {
+			r, size := utf8.DecodeRune(b)
+			if r != utf8.RuneError || size > 1 {
+				fmt.Fprintf(&sb, "\\x{%x}", r)
+				b = b[size:]
+				continue
+			}
+		}
+
+		fmt.Fprintf(&sb, "\\x%02x", b[0])
+		b = b[1:]
+	}
+
+	return sb.String()
+}
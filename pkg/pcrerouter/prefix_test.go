@@ -0,0 +1,35 @@
+package pcrerouter
+
+import "testing"
+
+func TestLiteralPrefix(t *testing.T) {
+	tests := []struct {
+		pattern string
+		prefix  string
+		literal bool
+	}{
+		{`/health`, "/health", true},
+		{`^/health$`, "/health", true},
+		{`\A/a\.b\z`, "/a.b", true},
+		{`/users/(?<id>\d+)`, "/users/", false},
+		{`^/users/\d+`, "/users/", false},
+		{`/users?/x`, "/user", false},
+		{`/a{2}`, "/", false},
+		{`/a|/b`, "", false},
+		{`/(a|b)`, "/", false},
+		{`/[|]`, "/", false},
+		{`(?i)/a`, "", false},
+		{`/a\/b`, "/a/b", true},
+		{`/a\Qb\E`, "/a", false},
+		{`/a$b`, "/a", false},
+		{`^/café?$`, "/caf", false},
+		{`^/café$`, "/café", true},
+	}
+
+	for _, tt := range tests {
+		prefix, literal := literalPrefix(tt.pattern)
+		if prefix != tt.prefix || literal != tt.literal {
+			t.Errorf("literalPrefix(%q) = %q, %v, want %q, %v", tt.pattern, prefix, literal, tt.prefix, tt.literal)
+		}
+	}
+}
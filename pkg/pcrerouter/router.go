@@ -0,0 +1,380 @@
+// Package pcrerouter provides an HTTP request router whose routes are PCRE
+// patterns, such as
+//
+//	^/users/(?<id>\d+)(?:/(?<tab>posts|likes))?$
+//
+// which [http.ServeMux] cannot express. A route matches a request if its
+// method matches and its pattern matches the whole URL path; the text of its
+// named groups is then available to the handler through [PathValue] and
+// [Params].
+//
+// Routes are tried in the order they were registered, and the first match
+// wins. Before a pattern is matched, the path is checked against the literal
+// prefix of the pattern, such as "/users/" above, so that most routes are
+// ruled out without running PCRE2.
+package pcrerouter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+// Router is an [http.Handler] dispatching requests to the handler of the
+// first route matching their method and path. It is safe for concurrent
+// use.
+type Router struct {
+	// NotFound handles requests whose path matches no route. If nil,
+	// [http.NotFound] is used.
+	NotFound http.Handler
+
+	// MethodNotAllowed handles requests whose path matches a route, but not
+	// for their method. The Allow header is set before it is called. If nil,
+	// a 405 Method Not Allowed error is replied.
+	MethodNotAllowed http.Handler
+
+	mu     sync.RWMutex
+	routes []*route
+}
+
+// New returns an empty Router.
+func New() *Router {
+	return new(Router)
+}
+
+// Handle registers h for requests with the given method whose URL path
+// matches pattern. An empty method matches any method, and a GET route also
+// serves HEAD requests.
+//
+// The pattern must match the whole path, as if it were anchored at both
+// ends; ^ and $ are allowed but not needed. It is compiled in UTF mode, so
+// that . or a quantifier applies to a whole character of the path.
+//
+// Handle returns an error if the pattern does not compile, or if it
+// conflicts with a registered route: either because both have the same
+// method and pattern, or because the new pattern is a literal path that an
+// earlier route of the same method already matches, so that it could never
+// be reached.
+func (rt *Router) Handle(method, pattern string, h http.Handler) error {
+	if h == nil {
+		return fmt.Errorf("pcrerouter: nil handler for %s %s", methodName(method), pattern)
+	}
+
+	re, err := pcregexp.CompileWithOptions(pattern, pcregexp.CompileOptions{
+		Flags: pcregexp.CompileUTF | pcregexp.CompileMatchInvalidUTF,
+	})
+	if err != nil {
+		return fmt.Errorf("pcrerouter: %s %s: %w", methodName(method), pattern, err)
+	}
+
+	r := &route{
+		method:  method,
+		pattern: pattern,
+		handler: h,
+		re:      re,
+		names:   re.SubexpNames(),
+	}
+	r.prefix, r.literal = literalPrefix(pattern)
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	for _, other := range rt.routes {
+		if conflict := r.conflicts(other); conflict != "" {
+			re.Close()
+			return fmt.Errorf("pcrerouter: %s %s %s %s %s", methodName(method), pattern, conflict, methodName(other.method), other.pattern)
+		}
+	}
+
+	rt.routes = append(rt.routes, r)
+
+	return nil
+}
+
+// HandleFunc registers the handler function f, see [Router.Handle].
+func (rt *Router) HandleFunc(method, pattern string, f func(http.ResponseWriter, *http.Request)) error {
+	if f == nil {
+		return fmt.Errorf("pcrerouter: nil handler for %s %s", methodName(method), pattern)
+	}
+
+	return rt.Handle(method, pattern, http.HandlerFunc(f))
+}
+
+// ServeHTTP dispatches the request to the handler of the first route
+// matching its method and URL path.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	rt.mu.RLock()
+	routes := rt.routes
+	rt.mu.RUnlock()
+
+	path := req.URL.Path
+
+	var allowed map[string]bool
+	for _, r := range routes {
+		if !strings.HasPrefix(path, r.prefix) {
+			continue
+		}
+
+		serves := r.method == "" || r.method == req.Method ||
+			r.method == http.MethodGet && req.Method == http.MethodHead
+
+		// Routes that cannot serve the request only need to be matched to
+		// build the Allow header of a 405 response.
+		if !serves && allowed[r.method] {
+			continue
+		}
+
+		params, ok := r.match(path)
+		if !ok {
+			continue
+		}
+
+		if serves {
+			r.handler.ServeHTTP(w, withParams(req, params))
+			return
+		}
+
+		if allowed == nil {
+			allowed = make(map[string]bool)
+		}
+		allowed[r.method] = true
+	}
+
+	if allowed != nil {
+		if allowed[http.MethodGet] {
+			allowed[http.MethodHead] = true
+		}
+
+		methods := make([]string, 0, len(allowed))
+		for m := range allowed {
+			methods = append(methods, m)
+		}
+		sort.Strings(methods)
+		w.Header().Set("Allow", strings.Join(methods, ", "))
+
+		if rt.MethodNotAllowed != nil {
+			rt.MethodNotAllowed.ServeHTTP(w, req)
+			return
+		}
+
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if rt.NotFound != nil {
+		rt.NotFound.ServeHTTP(w, req)
+		return
+	}
+
+	http.NotFound(w, req)
+}
+
+// Close releases the compiled patterns of the routes. rt must not be used
+// afterwards.
+func (rt *Router) Close() {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	for _, r := range rt.routes {
+		r.mu.Lock()
+		r.re.Close()
+		r.mu.Unlock()
+	}
+	rt.routes = nil
+}
+
+// route is a registered route.
+type route struct {
+	method  string
+	pattern string
+	handler http.Handler
+	names   []string // subexpression names of re
+
+	// prefix is a literal prefix of every path the pattern matches, and
+	// literal reports whether it is the only such path.
+	prefix  string
+	literal bool
+
+	mu sync.Mutex // guards re, which is not safe for concurrent use
+	re *pcregexp.PCREgexp
+}
+
+// match reports whether the pattern of r matches the whole path, and returns
+// the text of its named groups that took part in the match.
+func (r *route) match(path string) (map[string]string, bool) {
+	r.mu.Lock()
+	loc := r.re.FindStringSubmatchIndexAt(path, 0, pcregexp.MatchAnchored|pcregexp.MatchEndAnchored)
+	r.mu.Unlock()
+
+	if loc == nil {
+		return nil, false
+	}
+
+	var params map[string]string
+	for i, name := range r.names {
+		// loc ends at the last group that took part in the match.
+		if name == "" || 2*i+1 >= len(loc) || loc[2*i] < 0 {
+			continue
+		}
+
+		if params == nil {
+			params = make(map[string]string)
+		}
+		if _, ok := params[name]; !ok {
+			params[name] = path[loc[2*i]:loc[2*i+1]]
+		}
+	}
+
+	return params, true
+}
+
+// conflicts describes how r conflicts with the earlier route other, or
+// returns an empty string if it does not.
+func (r *route) conflicts(other *route) string {
+	if other.method != "" && other.method != r.method {
+		return ""
+	}
+
+	if other.method == r.method && other.pattern == r.pattern {
+		return "duplicates"
+	}
+
+	if r.literal && strings.HasPrefix(r.prefix, other.prefix) {
+		if _, ok := other.match(r.prefix); ok {
+			return "is shadowed by"
+		}
+	}
+
+	return ""
+}
+
+// literalPrefix returns the literal text every path matched by pattern
+// starts with, and whether pattern matches only that text. It errs on the
+// side of a shorter prefix.
+func literalPrefix(pattern string) (prefix string, literal bool) {
+	if hasTopLevelAlternation(pattern) {
+		return "", false
+	}
+
+	p := strings.TrimPrefix(pattern, "^")
+	p = strings.TrimPrefix(p, `\A`)
+
+	var sb strings.Builder
+	for len(p) > 0 {
+		var (
+			c    string // literal text of the next item
+			size int
+		)
+
+		switch ch := p[0]; {
+		case ch == '\\' && len(p) > 1 && isPunct(p[1]):
+			c, size = p[1:2], 2
+		case ch == '\\' || strings.IndexByte(".[](){}*+?|^$", ch) >= 0:
+			return sb.String(), isEnd(p)
+		default:
+			// A whole character, which a quantifier repeats in UTF mode.
+			_, size = utf8.DecodeRuneInString(p)
+			c = p[:size]
+		}
+
+		// A quantified item is optional or repeated.
+		if rest := p[size:]; rest != "" && strings.IndexByte("*+?{", rest[0]) >= 0 {
+			return sb.String(), false
+		}
+
+		sb.WriteString(c)
+		p = p[size:]
+	}
+
+	return sb.String(), true
+}
+
+// isEnd reports whether p only asserts the end of the subject.
+func isEnd(p string) bool {
+	return p == "$" || p == `\z` || p == `\Z`
+}
+
+// isPunct reports whether the escape \c stands for the ASCII punctuation c.
+func isPunct(c byte) bool {
+	return c < 0x80 && !('0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') && c > ' '
+}
+
+// hasTopLevelAlternation reports whether pattern contains a | outside of
+// parentheses and character classes, in which case no prefix is common to
+// all of its matches.
+func hasTopLevelAlternation(pattern string) bool {
+	depth, inClass := 0, false
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\':
+			i++
+		case inClass:
+			inClass = c != ']'
+		case c == '[':
+			inClass = true
+			// A ] right after [ or [^ is literal.
+			if i+1 < len(pattern) && pattern[i+1] == '^' {
+				i++
+			}
+			if i+1 < len(pattern) && pattern[i+1] == ']' {
+				i++
+			}
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == '|' && depth == 0:
+			return true
+		}
+	}
+
+	return false
+}
+
+// methodName returns the name of method in error messages.
+func methodName(method string) string {
+	if method == "" {
+		return "*"
+	}
+
+	return method
+}
+
+// paramsKey is the context key of the path parameters of a request.
+type paramsKey struct{}
+
+// withParams returns req carrying params, if there are any.
+func withParams(req *http.Request, params map[string]string) *http.Request {
+	if params == nil {
+		return req
+	}
+
+	return req.WithContext(context.WithValue(req.Context(), paramsKey{}, params))
+}
+
+// Params returns the text of the named groups of the route that matched r,
+// keyed by name. Groups that did not take part in the match are absent.
+func Params(r *http.Request) map[string]string {
+	params, _ := r.Context().Value(paramsKey{}).(map[string]string)
+
+	result := make(map[string]string, len(params))
+	for name, value := range params {
+		result[name] = value
+	}
+
+	return result
+}
+
+// PathValue returns the text of the named group called name of the route
+// that matched r, or an empty string if there is no such group or it did not
+// take part in the match.
+func PathValue(r *http.Request, name string) string {
+	params, _ := r.Context().Value(paramsKey{}).(map[string]string)
+	return params[name]
+}
@@ -0,0 +1,198 @@
+package pcrerouter_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dwisiswant0/pcregexp/pkg/pcrerouter"
+)
+
+// echo replies with the route name and the path parameters.
+func echo(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := pcrerouter.Params(r)
+
+		names := make([]string, 0, len(params))
+		for n := range params {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+
+		fmt.Fprint(w, name)
+		for _, n := range names {
+			fmt.Fprintf(w, " %s=%s", n, params[n])
+		}
+	}
+}
+
+func newRouter(t *testing.T) *pcrerouter.Router {
+	t.Helper()
+
+	rt := pcrerouter.New()
+	t.Cleanup(rt.Close)
+
+	routes := []struct {
+		method, pattern, name string
+	}{
+		{http.MethodGet, `/users/me`, "me"},
+		{http.MethodGet, `^/users/(?<id>\d+)(?:/(?<tab>posts|likes))?$`, "user"},
+		{http.MethodDelete, `/users/(?<id>\d+)`, "delete"},
+		{http.MethodGet, `/files/(?<path>.+\.(?:png|jpe?g))`, "image"},
+		{"", `/health`, "health"},
+		{http.MethodGet, `^/café?$`, "cafe"},
+	}
+	for _, r := range routes {
+		if err := rt.Handle(r.method, r.pattern, echo(r.name)); err != nil {
+			t.Fatalf("Handle(%q, %q) error = %v", r.method, r.pattern, err)
+		}
+	}
+
+	return rt
+}
+
+func TestRouter_ServeHTTP(t *testing.T) {
+	rt := newRouter(t)
+
+	tests := []struct {
+		method, path string
+		code         int
+		body         string
+		allow        string
+	}{
+		{"GET", "/users/me", 200, "me", ""},
+		{"GET", "/users/42", 200, "user id=42", ""},
+		{"GET", "/users/42/likes", 200, "user id=42 tab=likes", ""},
+		{"HEAD", "/users/42/posts", 200, "user id=42 tab=posts", ""},
+		{"DELETE", "/users/42", 200, "delete id=42", ""},
+		{"GET", "/files/a/b.jpeg", 200, "image path=a/b.jpeg", ""},
+		{"POST", "/health", 200, "health", ""},
+		{"GET", "/caf", 200, "cafe", ""},
+		{"GET", "/café", 200, "cafe", ""},
+
+		// The whole path must match.
+		{"GET", "/users/42/", 404, "404 page not found\n", ""},
+		{"GET", "/users/42/likes\n", 404, "404 page not found\n", ""},
+		{"GET", "/v1/users/42", 404, "404 page not found\n", ""},
+		{"GET", "/files/a.gif", 404, "404 page not found\n", ""},
+
+		{"PUT", "/users/42", 405, "Method Not Allowed\n", "DELETE, GET, HEAD"},
+		{"DELETE", "/users/42/posts", 405, "Method Not Allowed\n", "GET, HEAD"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, httptest.NewRequest(tt.method, "http://example.com"+strings.ReplaceAll(tt.path, "\n", "%0A"), nil))
+
+			if w.Code != tt.code || w.Body.String() != tt.body {
+				t.Errorf("response = %d %q, want %d %q", w.Code, w.Body.String(), tt.code, tt.body)
+			}
+
+			if got := w.Header().Get("Allow"); got != tt.allow {
+				t.Errorf("Allow = %q, want %q", got, tt.allow)
+			}
+		})
+	}
+}
+
+func TestRouter_CustomHandlers(t *testing.T) {
+	rt := newRouter(t)
+	rt.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	rt.MethodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "allow: ", w.Header().Get("Allow"))
+	})
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest("GET", "/nowhere", nil))
+	if w.Code != http.StatusTeapot {
+		t.Errorf("NotFound response code = %d, want %d", w.Code, http.StatusTeapot)
+	}
+
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest("POST", "/users/me", nil))
+	if got, want := w.Body.String(), "allow: GET, HEAD"; got != want {
+		t.Errorf("MethodNotAllowed response = %q, want %q", got, want)
+	}
+}
+
+func TestRouter_PathValue(t *testing.T) {
+	rt := pcrerouter.New()
+	defer rt.Close()
+
+	var got []string
+	err := rt.HandleFunc("GET", `/(?<a>x)?(?<b>y)`, func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, pcrerouter.PathValue(r, "a"), pcrerouter.PathValue(r, "b"), pcrerouter.PathValue(r, "c"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/y", nil))
+	if want := []string{"", "y", ""}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PathValue() = %q, want %q", got, want)
+	}
+}
+
+func TestRouter_Conflicts(t *testing.T) {
+	rt := newRouter(t)
+
+	tests := []struct {
+		method, pattern string
+		want            string
+	}{
+		{"GET", `^/users/(?<id>\d+)(?:/(?<tab>posts|likes))?$`, "duplicates"},
+		{"GET", `/users/7`, "is shadowed by GET ^/users/"},
+		{"GET", `/users/7/likes`, "is shadowed by"},
+		{"PATCH", `/health`, "is shadowed by * /health"},
+		{"GET", `/files/(`, "pcre2_compile failed"},
+	}
+
+	for _, tt := range tests {
+		err := rt.Handle(tt.method, tt.pattern, echo("x"))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Handle(%q, %q) error = %v, want it to contain %q", tt.method, tt.pattern, err, tt.want)
+		}
+	}
+
+	// Not conflicting: another method, or a path no route matches.
+	for _, r := range [][2]string{{"PUT", `/users/7`}, {"GET", `/users/7/friends`}, {"GET", `/files/a\.gif`}} {
+		if err := rt.Handle(r[0], r[1], echo("x")); err != nil {
+			t.Errorf("Handle(%q, %q) error = %v", r[0], r[1], err)
+		}
+	}
+
+	if err := rt.Handle("GET", "/nil", nil); err == nil {
+		t.Error("Handle() with a nil handler returned no error")
+	}
+}
+
+func TestRouter_Concurrent(t *testing.T) {
+	rt := newRouter(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 50; j++ {
+				w := httptest.NewRecorder()
+				rt.ServeHTTP(w, httptest.NewRequest("GET", fmt.Sprintf("/users/%d", i*100+j), nil))
+
+				if want := fmt.Sprintf("user id=%d", i*100+j); w.Body.String() != want {
+					t.Errorf("response = %q, want %q", w.Body.String(), want)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,374 @@
+// Package pcresyntax parses regular expressions in the syntax of PCRE2 into
+// syntax trees, as regexp/syntax does for the syntax of RE2.
+//
+// [Parse] covers the whole syntax of PCRE2 patterns: groups of every kind,
+// lookarounds, backtracking control verbs, character classes, quantifiers,
+// back references, recursion, option settings, callouts and conditional
+// groups. Every node records the byte range of its source in the pattern,
+// and prints back to a canonical form:
+//
+//	tree, err := pcresyntax.Parse(`(?<year>\d{4})-(?=\d)`)
+//	if err != nil {
+//		return err
+//	}
+//
+//	pcresyntax.Inspect(tree, func(n pcresyntax.Node) bool {
+//		if g, ok := n.(*pcresyntax.Group); ok && g.Kind.Lookaround() {
+//			fmt.Printf("lookaround %s at offset %d\n", g, g.Pos())
+//		}
+//		return true
+//	})
+//
+// Unlike strings.Contains on a pattern, the tree tells a lookahead from the
+// text "(?=" in a class or after \Q, so tools can reason about patterns.
+package pcresyntax
+
+// Node is a node of a syntax tree.
+//
+// The String method of a node returns its canonical form, which PCRE2
+// compiles to the same pattern as the source text of the node. Printable
+// characters outside ASCII print as UTF-8, which reads the same as their
+// source in UTF mode only.
+type Node interface {
+	// Pos returns the offset of the first byte of the node in the pattern.
+	Pos() int
+
+	// End returns the offset of the byte following the node.
+	End() int
+
+	String() string
+
+	node()
+}
+
+// Span is the byte range pattern[Start:Stop] of a node in the pattern it was
+// parsed from. Nodes built otherwise may leave it zero.
+type Span struct {
+	Start, Stop int
+}
+
+// Pos returns s.Start.
+func (s Span) Pos() int { return s.Start }
+
+// End returns s.Stop.
+func (s Span) End() int { return s.Stop }
+
+func (Span) node() {}
+
+// Literal is a character, written as is or escaped.
+type Literal struct {
+	Span
+	Rune rune
+
+	// Byte reports that Rune is a byte of invalid UTF-8 in the pattern,
+	// which PCRE2 only accepts outside of UTF mode.
+	Byte bool
+}
+
+// Dot is the . metacharacter.
+type Dot struct {
+	Span
+}
+
+// CharType is a character type escape: one of d, D, w, W, s, S, h, H, v, V
+// and, outside of classes, R, X, N and C.
+type CharType struct {
+	Span
+	Type byte
+}
+
+// Property is a Unicode property escape, such as \pL or \P{Greek}. The
+// name is not checked.
+type Property struct {
+	Span
+	Name    string
+	Negated bool
+}
+
+// Assertion is a simple assertion: ^ or $, the escape of one of b, B, A,
+// Z, z, G and K, with \K resetting the start of the match, or < or > for
+// the start and end of a word, [[:<:]] and [[:>:]].
+type Assertion struct {
+	Span
+	Type byte
+}
+
+// Class is a character class, such as [a-z\d].
+type Class struct {
+	Span
+	Negated bool
+
+	// Items are the *Literal, *ClassRange, *CharType, *Property and
+	// *POSIXClass nodes of the class.
+	Items []Node
+}
+
+// ClassRange is a range of characters in a class, such as a-z.
+type ClassRange struct {
+	Span
+	Lo, Hi *Literal
+}
+
+// POSIXClass is a POSIX class name in a class, such as [:alpha:].
+type POSIXClass struct {
+	Span
+	Name    string
+	Negated bool
+}
+
+// Concat is a sequence of nodes. An empty sequence matches the empty
+// string.
+type Concat struct {
+	Span
+	Items []Node
+}
+
+// Alternate is an alternation of branches.
+type Alternate struct {
+	Span
+	Alts []Node
+}
+
+// GroupKind is the kind of a [Group].
+type GroupKind int
+
+const (
+	// GroupCapture is a capture group, (...), (?<name>...), (?'name'...)
+	// or (?P<name>...).
+	GroupCapture GroupKind = iota
+
+	// GroupNonCapture is a non-capture group, (?:...), which may set
+	// options, as in (?i:...).
+	GroupNonCapture
+
+	// GroupAtomic is an atomic group, (?>...) or (*atomic:...).
+	GroupAtomic
+
+	// GroupBranchReset is a branch reset group, (?|...), whose branches
+	// number their capture groups from the same number.
+	GroupBranchReset
+
+	// GroupLookahead is (?=...) or (*pla:...).
+	GroupLookahead
+
+	// GroupNegativeLookahead is (?!...) or (*nla:...).
+	GroupNegativeLookahead
+
+	// GroupLookbehind is (?<=...) or (*plb:...).
+	GroupLookbehind
+
+	// GroupNegativeLookbehind is (?<!...) or (*nlb:...).
+	GroupNegativeLookbehind
+
+	// GroupNonAtomicLookahead is (?*...) or (*napla:...).
+	GroupNonAtomicLookahead
+
+	// GroupNonAtomicLookbehind is (?<*...) or (*naplb:...).
+	GroupNonAtomicLookbehind
+
+	// GroupScriptRun is (*sr:...).
+	GroupScriptRun
+
+	// GroupAtomicScriptRun is (*asr:...).
+	GroupAtomicScriptRun
+)
+
+// Lookaround reports whether k is a lookahead or lookbehind assertion.
+func (k GroupKind) Lookaround() bool {
+	return k >= GroupLookahead && k <= GroupNonAtomicLookbehind
+}
+
+// Group is a parenthesized group.
+type Group struct {
+	Span
+	Kind GroupKind
+
+	// Number is the number of a capture group, and Name its name, if any.
+	Number int
+	Name   string
+
+	// Options are the options set by a non-capture group, or nil.
+	Options *Options
+
+	Sub Node
+}
+
+// Options is an option setting, such as (?i-s) or (?^x), which applies up
+// to the end of the enclosing group, or the options of a [Group].
+type Options struct {
+	Span
+
+	// On and Off are the letters of the options set and unset, with xx
+	// for extended-more.
+	On, Off string
+
+	// Reset reports whether the setting starts with ^, unsetting the
+	// options imnsx first.
+	Reset bool
+}
+
+// RepeatMode is the matching mode of a [Repeat].
+type RepeatMode int
+
+const (
+	Greedy RepeatMode = iota
+	Lazy
+	Possessive
+)
+
+// Repeat is a quantified node, such as a*, a{2,3}? or a++.
+type Repeat struct {
+	Span
+	Sub Node
+
+	// Min and Max are the bounds of the repetition, Max being -1 if
+	// unbounded.
+	Min, Max int
+
+	Mode RepeatMode
+}
+
+// Backref is a back reference, such as \1, \g{-1} or \k<name>.
+type Backref struct {
+	Span
+
+	// Number is the number of the group referred to, and Name its name if
+	// referred to by name. Relative is the relative number written, such
+	// as -1 for \g{-1}, or 0.
+	Number   int
+	Name     string
+	Relative int
+}
+
+// Call is a recursion or subroutine call, such as (?R), (?1), (?-1) or
+// (?&name).
+type Call struct {
+	Span
+
+	// Number is the number of the group called, 0 for the whole pattern,
+	// and Name its name if called by name. Relative is the relative number
+	// written, such as +1 for (?+1), or 0.
+	Number   int
+	Name     string
+	Relative int
+}
+
+// Conditional is a conditional group, (?(condition)yes|no).
+type Conditional struct {
+	Span
+
+	// Cond is a *CondRef, *CondRecursion, *CondDefine, *CondVersion, or
+	// a *Group of a lookaround kind.
+	Cond Node
+
+	// Yes is the branch matched if the condition holds, and No the other
+	// one, or nil.
+	Yes, No Node
+}
+
+// CondRef is the condition that a capture group is set, such as (1),
+// (-1), (<name>) or (name).
+type CondRef struct {
+	Span
+	Number   int
+	Name     string
+	Relative int
+}
+
+// CondRecursion is the condition of being in a recursion: (R) for any
+// recursion, (R1) for a recursion into group 1, or (R&name).
+type CondRecursion struct {
+	Span
+	Number int
+	Name   string
+}
+
+// CondDefine is the (DEFINE) condition, of a group that only defines
+// groups to call.
+type CondDefine struct {
+	Span
+}
+
+// CondVersion is a condition on the version of PCRE2, such as
+// (VERSION>=10.4).
+type CondVersion struct {
+	Span
+
+	// Op is ">=" or "=".
+	Op           string
+	Major, Minor int
+}
+
+// Verb is a backtracking control verb, such as (*PRUNE) or (*MARK:name).
+type Verb struct {
+	Span
+
+	// Name is the name of the verb in upper case, MARK for (*:name) and
+	// FAIL for (*F).
+	Name string
+	Arg  string
+}
+
+// StartOption is an option setting at the start of a pattern, such as
+// (*UTF) or (*LIMIT_MATCH=100).
+type StartOption struct {
+	Span
+	Name  string
+	Value string
+}
+
+// Callout is a callout, such as (?C1) or (?C"text").
+type Callout struct {
+	Span
+	Number int
+
+	// Text is the text of a string callout, and Delim its opening
+	// delimiter, or 0 for a numbered callout.
+	Text  string
+	Delim byte
+}
+
+// Comment is a comment, (?#...).
+type Comment struct {
+	Span
+	Text string
+}
+
+// Inspect traverses the tree of node depth-first: it calls f with node,
+// then, if f returns true, inspects the children of node in order.
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Class:
+		for _, item := range n.Items {
+			Inspect(item, f)
+		}
+	case *ClassRange:
+		Inspect(n.Lo, f)
+		Inspect(n.Hi, f)
+	case *Concat:
+		for _, item := range n.Items {
+			Inspect(item, f)
+		}
+	case *Alternate:
+		for _, alt := range n.Alts {
+			Inspect(alt, f)
+		}
+	case *Group:
+		if n.Options != nil {
+			Inspect(n.Options, f)
+		}
+		Inspect(n.Sub, f)
+	case *Repeat:
+		Inspect(n.Sub, f)
+	case *Conditional:
+		Inspect(n.Cond, f)
+		Inspect(n.Yes, f)
+		if n.No != nil {
+			Inspect(n.No, f)
+		}
+	}
+}
@@ -0,0 +1,488 @@
+package pcresyntax
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// escape parses an escape sequence outside of a class, or returns nil for
+// \Q and \E.
+func (p *parser) escape() Node {
+	start := p.pos
+	p.pos++
+	if p.eof() {
+		p.fail(start, `\ at end of pattern`)
+	}
+
+	c := p.peek()
+	switch c {
+	case 'Q':
+		p.pos++
+		p.quoted = true
+		return nil
+	case 'E':
+		p.pos++
+		return nil
+	case 'd', 'D', 'w', 'W', 's', 'S', 'h', 'H', 'v', 'V', 'R', 'X', 'C':
+		p.pos++
+		return &CharType{Span: Span{start, p.pos}, Type: c}
+	case 'N':
+		if !p.lookingAt("N{U+") {
+			p.pos++
+			return &CharType{Span: Span{start, p.pos}, Type: c}
+		}
+	case 'b', 'B', 'A', 'Z', 'z', 'G', 'K':
+		if c == 'K' && p.lookarounds > 0 {
+			p.fail(start, `\K is not allowed in lookarounds`)
+		}
+		p.pos++
+		return &Assertion{Span: Span{start, p.pos}, Type: c}
+	case 'p', 'P':
+		return p.property(start)
+	case 'g':
+		return p.gEscape(start)
+	case 'k':
+		p.pos++
+		if d := p.peek(); d == '<' || d == '\'' || d == '{' {
+			p.pos++
+			name := p.name(closing(d))
+			p.pos++
+			return p.backref(&Backref{Span: Span{start, p.pos}, Name: name})
+		}
+		p.fail(p.pos, `\k is not followed by a braced, angle-bracketed, or quoted name`)
+	case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		if b := p.numericBackref(start); b != nil {
+			return b
+		}
+	}
+
+	return p.charEscape(start, false)
+}
+
+// numericBackref parses \ followed by digits as a back reference if it is
+// one, as the digits are otherwise an octal character code: for a number
+// below 10, starting with 8 or 9, or not above the number of groups so far.
+func (p *parser) numericBackref(start int) Node {
+	end := p.pos
+	for end < len(p.src) && isDigit(p.src[end]) {
+		end++
+	}
+
+	n, err := strconv.Atoi(p.src[p.pos:end])
+	if err != nil || n >= 10 && p.src[p.pos] < '8' && n > p.groups {
+		return nil
+	}
+	if n > maxRepeat {
+		p.fail(start, "subpattern number is too big")
+	}
+
+	p.pos = end
+	return p.backref(&Backref{Span: Span{start, p.pos}, Number: n})
+}
+
+// gEscape parses a \g back reference or subroutine call.
+func (p *parser) gEscape(start int) Node {
+	p.pos++
+
+	switch d := p.peek(); {
+	case d == '<' || d == '\'':
+		end := closing(d)
+		p.pos++
+
+		var c *Call
+		if e := p.peek(); isDigit(e) || e == '+' || e == '-' {
+			n, rel := p.number()
+			p.expect(string(end), `\g is not followed by a braced, angle-bracketed, or quoted name/number or by a plain number`)
+			c = &Call{Span: Span{start, p.pos}, Number: n, Relative: rel}
+		} else {
+			name := p.name(end)
+			p.pos++
+			c = &Call{Span: Span{start, p.pos}, Name: name}
+		}
+		return p.call(c)
+	case d == '{':
+		p.pos++
+		if e := p.peek(); isDigit(e) || e == '-' || e == '+' {
+			n, rel := p.number()
+			p.expect("}", `\g is not followed by a braced, angle-bracketed, or quoted name/number or by a plain number`)
+			return p.backref(&Backref{Span: Span{start, p.pos}, Number: n, Relative: rel})
+		}
+		name := p.name('}')
+		p.pos++
+		return p.backref(&Backref{Span: Span{start, p.pos}, Name: name})
+	case isDigit(d) || d == '-':
+		n, rel := p.number()
+		return p.backref(&Backref{Span: Span{start, p.pos}, Number: n, Relative: rel})
+	}
+
+	p.fail(p.pos, `\g is not followed by a braced, angle-bracketed, or quoted name/number or by a plain number`)
+	return nil
+}
+
+// property parses a \p or \P escape.
+func (p *parser) property(start int) Node {
+	prop := &Property{Negated: p.peek() == 'P'}
+	p.pos++
+
+	switch {
+	case p.eof():
+		p.fail(p.pos, `malformed \P or \p sequence`)
+	case p.peek() == '{':
+		end := strings.IndexByte(p.src[p.pos:], '}')
+		if end < 0 {
+			p.fail(p.pos, `malformed \P or \p sequence`)
+		}
+		name := p.src[p.pos+1 : p.pos+end]
+		if strings.HasPrefix(name, "^") {
+			prop.Negated = !prop.Negated
+			name = name[1:]
+		}
+		if name == "" {
+			p.fail(p.pos, `malformed \P or \p sequence`)
+		}
+		prop.Name = name
+		p.pos += end + 1
+	case !isLetter(p.peek()):
+		p.fail(p.pos, `malformed \P or \p sequence`)
+	case !strings.ContainsRune("CLMNPSZ", unicode.ToUpper(rune(p.peek()))):
+		p.fail(p.pos, `unknown property after \P or \p`)
+	default:
+		prop.Name = string(p.peek())
+		p.pos++
+	}
+
+	prop.Span = Span{start, p.pos}
+	return prop
+}
+
+// charEscape parses an escape sequence of a character, after \, in a class
+// if inClass.
+func (p *parser) charEscape(start int, inClass bool) *Literal {
+	c := p.peek()
+	p.pos++
+
+	var r rune
+	switch c {
+	case 'a':
+		r = '\a'
+	case 'e':
+		r = 0x1b
+	case 'f':
+		r = '\f'
+	case 'n':
+		r = '\n'
+	case 'r':
+		r = '\r'
+	case 't':
+		r = '\t'
+	case 'b':
+		// Only reached in classes, where \b is a backspace.
+		r = '\b'
+	case 'c':
+		if p.eof() {
+			p.fail(p.pos, `\c at end of pattern`)
+		}
+		d := p.peek()
+		if d >= 'a' && d <= 'z' {
+			d -= 'a' - 'A'
+		}
+		if d < 0x20 || d > 0x7e {
+			p.fail(p.pos, `\c must be followed by a printable ASCII character`)
+		}
+		p.pos++
+		r = rune(d ^ 0x40)
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		// Up to three octal digits.
+		p.pos--
+		end := p.pos
+		for end < len(p.src) && end < p.pos+3 && p.src[end] >= '0' && p.src[end] <= '7' {
+			end++
+		}
+		n, _ := strconv.ParseUint(p.src[p.pos:end], 8, 32)
+		p.pos = end
+		r = rune(n)
+	case 'o':
+		r = p.bracedCode(8, "{")
+	case 'x':
+		if p.peek() == '{' {
+			r = p.bracedCode(16, "{")
+			break
+		}
+		end := p.pos
+		for end < len(p.src) && end < p.pos+2 && isHex(p.src[end]) {
+			end++
+		}
+		n, _ := strconv.ParseUint(p.src[p.pos:end], 16, 32)
+		p.pos = end
+		r = rune(n)
+	case 'N':
+		r = p.bracedCode(16, "{U+")
+	case 'u', 'U', 'l', 'L':
+		p.fail(start, `PCRE2 does not support \F, \L, \l, \N{name}, \U, or \u`)
+	default:
+		if isWord(c) {
+			if inClass && strings.IndexByte("ABGKZkz", c) >= 0 {
+				p.fail(start, "escape sequence is invalid in character class")
+			}
+			p.fail(start, `unrecognized character follows \`)
+		}
+
+		// Any other character is escaped to stand for itself.
+		p.pos--
+		lit := p.literal()
+		lit.Span.Start = start
+		return lit
+	}
+
+	return &Literal{Span: Span{start, p.pos}, Rune: r}
+}
+
+// bracedCode parses the braced digits of a character code in base, such as
+// {e9} after \x, with open being the text preceding the digits.
+func (p *parser) bracedCode(base int, open string) rune {
+	start := p.pos
+	if !p.lookingAt(open) {
+		p.fail(p.pos, "missing opening brace after \\o")
+	}
+	p.pos += len(open)
+
+	end := strings.IndexByte(p.src[p.pos:], '}')
+	if end < 0 {
+		p.fail(start, "missing terminating } in braced character code")
+	}
+
+	digits := p.src[p.pos : p.pos+end]
+	if digits == "" {
+		p.fail(p.pos, "digits missing in braced character code")
+	}
+	for i := 0; i < len(digits); i++ {
+		if c := digits[i]; !isHex(c) || base == 8 && (c < '0' || c > '7') {
+			p.fail(p.pos+i, "invalid digit in braced character code")
+		}
+	}
+
+	n, err := strconv.ParseUint(digits, base, 32)
+	if err != nil || n > maxCodePoint {
+		p.fail(p.pos, "character code point value in \\x{} or \\o{} is too large")
+	}
+
+	p.pos += end + 1
+	return rune(n)
+}
+
+// class parses a character class.
+func (p *parser) class() Node {
+	start := p.pos
+
+	// [[:<:]] and [[:>:]] are the start and end of a word.
+	if p.lookingAt("[[:<:]]") || p.lookingAt("[[:>:]]") {
+		p.pos += 7
+		return &Assertion{Span: Span{start, p.pos}, Type: p.src[start+3]}
+	}
+	if p.posixSyntax() {
+		if p.src[p.pos+1] == ':' {
+			p.fail(start, "POSIX named classes are supported only within a class")
+		}
+		p.fail(start, "POSIX collating elements are not supported")
+	}
+
+	p.pos++
+
+	cls := &Class{}
+	for p.emptyQuote() > 0 {
+		p.pos += p.emptyQuote()
+	}
+	if p.lookingAt("^") {
+		cls.Negated = true
+		p.pos++
+	}
+
+	// A ] first in the class, even after \E, is a literal.
+	for first := true; ; {
+		if p.eof() {
+			p.fail(start, "missing terminating ] for character class")
+		}
+
+		if p.quoted {
+			if p.lookingAt(`\E`) {
+				p.pos += 2
+				p.quoted = false
+				continue
+			}
+		} else {
+			if c := p.peek(); p.set.extendedMore && (c == ' ' || c == '\t') {
+				p.pos++
+				continue
+			}
+			if p.peek() == ']' && !first {
+				p.pos++
+				break
+			}
+		}
+
+		item := p.classItem()
+		if item == nil {
+			continue
+		}
+		first = false
+
+		// The quotes of the item end before a hyphen, and empty ones
+		// before it are skipped.
+		if p.quoted && p.lookingAt(`\E`) {
+			p.pos += 2
+			p.quoted = false
+		}
+		for !p.quoted && p.emptyQuote() > 0 {
+			p.pos += p.emptyQuote()
+		}
+
+		lo, ok := item.(*Literal)
+		if p.quoted || !p.lookingAt("-") {
+			cls.Items = append(cls.Items, item)
+			continue
+		}
+
+		// A range, from a character to a character, unless the hyphen ends
+		// the class.
+		hyphen := p.pos
+		p.pos++
+		for p.lookingAt(`\E`) || p.lookingAt(`\Q`) {
+			p.quoted = p.lookingAt(`\Q`)
+			p.pos += 2
+		}
+		if !p.quoted && (p.eof() || p.peek() == ']') {
+			p.pos = hyphen
+			cls.Items = append(cls.Items, item)
+			continue
+		}
+		if !ok {
+			p.fail(hyphen, "invalid range in character class")
+		}
+
+		hiItem := p.classItem()
+		hi, ok := hiItem.(*Literal)
+		if !ok {
+			p.fail(hyphen+1, "invalid range in character class")
+		}
+		if hi.Rune < lo.Rune {
+			p.fail(hi.Start, "range out of order in character class")
+		}
+		cls.Items = append(cls.Items, &ClassRange{Span: Span{lo.Start, hi.Stop}, Lo: lo, Hi: hi})
+	}
+
+	cls.Span = Span{start, p.pos}
+	return cls
+}
+
+// posixClasses are the names of the POSIX classes.
+var posixClasses = map[string]bool{
+	"alnum": true, "alpha": true, "ascii": true, "blank": true,
+	"cntrl": true, "digit": true, "graph": true, "lower": true,
+	"print": true, "punct": true, "space": true, "upper": true,
+	"word": true, "xdigit": true,
+}
+
+// classItem parses an item of a class, or returns nil for \Q and \E.
+func (p *parser) classItem() Node {
+	start := p.pos
+
+	if p.quoted {
+		return p.literal()
+	}
+
+	if p.posixSyntax() {
+		return p.posixClass()
+	}
+
+	if p.peek() != '\\' {
+		return p.literal()
+	}
+
+	p.pos++
+	if p.eof() {
+		p.fail(start, `\ at end of pattern`)
+	}
+
+	switch c := p.peek(); c {
+	case 'Q':
+		p.pos++
+		p.quoted = true
+		return nil
+	case 'E':
+		p.pos++
+		return nil
+	case 'd', 'D', 'w', 'W', 's', 'S', 'h', 'H', 'v', 'V':
+		p.pos++
+		return &CharType{Span: Span{start, p.pos}, Type: c}
+	case 'p', 'P':
+		return p.property(start)
+	case 'N':
+		if p.lookingAt("N{U+") {
+			break
+		}
+		p.fail(start, "escape sequence is invalid in character class")
+	case 'B', 'R', 'X':
+		p.fail(start, "escape sequence is invalid in character class")
+	case 'g', '8', '9':
+		// These stand for themselves in classes.
+		p.pos++
+		return &Literal{Span: Span{start, p.pos}, Rune: rune(c)}
+	}
+
+	return p.charEscape(start, true)
+}
+
+// posixSyntax reports whether a POSIX class name, such as [:alpha:], or
+// collating element, such as [.a.], starts at the current position.
+func (p *parser) posixSyntax() bool {
+	if !p.lookingAt("[:") && !p.lookingAt("[.") && !p.lookingAt("[=") {
+		return false
+	}
+
+	delim := p.src[p.pos+1]
+	for i := p.pos + 2; i+1 < len(p.src); i++ {
+		switch c := p.src[i]; {
+		case c == '\\' && (p.src[i+1] == ']' || p.src[i+1] == '\\'):
+			i++
+		case c == '[' && p.src[i+1] == delim, c == ']':
+			return false
+		case c == delim && p.src[i+1] == ']':
+			return true
+		}
+	}
+
+	return false
+}
+
+// posixClass parses a POSIX class name, at a position where posixSyntax
+// reports one.
+func (p *parser) posixClass() Node {
+	start := p.pos
+	delim := p.src[p.pos+1]
+	if delim != ':' {
+		p.fail(start, "POSIX collating elements are not supported")
+	}
+
+	end := strings.Index(p.src[p.pos+2:], ":]")
+	name := p.src[p.pos+2 : p.pos+2+end]
+	class := &POSIXClass{}
+	if strings.HasPrefix(name, "^") {
+		class.Negated = true
+		name = name[1:]
+	}
+	if !posixClasses[name] {
+		p.fail(start, "unknown POSIX class name")
+	}
+
+	class.Name = name
+	p.pos += end + 4
+	class.Span = Span{start, p.pos}
+
+	return class
+}
+
+// isHex reports whether c is a hexadecimal digit.
+func isHex(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
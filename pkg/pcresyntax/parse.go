@@ -0,0 +1,1044 @@
+package pcresyntax
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Error is a syntax error of a pattern.
+type Error struct {
+	// Pattern is the pattern parsed.
+	Pattern string
+
+	// Offset is the byte offset in the pattern where the error was found.
+	Offset int
+
+	Msg string
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return fmt.Sprintf("pcresyntax: %s at offset %d", e.Msg, e.Offset)
+}
+
+// Limits of PCRE2.
+const (
+	maxRepeat    = 65535
+	maxNameLen   = 32
+	maxCallout   = 255
+	maxCodePoint = 0x10ffff
+)
+
+// settings are the options of the pattern that change how it parses.
+type settings struct {
+	extended     bool // x: whitespace and # comments are ignored
+	extendedMore bool // xx: spaces and tabs in classes are ignored too
+	noCapture    bool // n: plain parentheses do not capture
+	dupNames     bool // J: capture groups may share names
+}
+
+// ref is a reference to a group, checked once every group is known.
+type ref struct {
+	pos    int
+	number int
+	name   string
+	node   Node // whose Number is set from name
+}
+
+// parser holds the state of [Parse].
+type parser struct {
+	src    string
+	pos    int
+	set    settings
+	quoted bool // within \Q...\E
+
+	lookarounds int    // depth of lookaround groups
+	comments    []Node // skipped around a quantifier
+
+	groups int            // number of capture groups so far
+	names  map[string]int // numbers of named groups
+	refs   []ref
+}
+
+// Parse parses pattern in the syntax of PCRE2 and returns its syntax tree.
+//
+// Patterns are parsed as if compiled without options, following settings
+// in the pattern such as (?x). Parse checks the syntax and the references
+// to groups, but not everything PCRE2 checks when compiling: for instance,
+// it does not check the names of Unicode properties in braces, code points
+// against the UTF mode, or that lookbehinds have a bounded length.
+func Parse(pattern string) (node Node, err error) {
+	p := &parser{src: pattern, names: make(map[string]int)}
+
+	defer func() {
+		if r := recover(); r != nil {
+			perr, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			node, err = nil, perr
+		}
+	}()
+
+	var opts []Node
+	for {
+		opt := p.startOption()
+		if opt == nil {
+			break
+		}
+		opts = append(opts, opt)
+	}
+
+	node = p.alternation()
+	if p.pos < len(p.src) {
+		// Only an unmatched ) stops the top-level alternation early.
+		p.fail(p.pos, "unmatched closing parenthesis")
+	}
+	p.checkRefs()
+
+	if len(opts) > 0 {
+		if c, ok := node.(*Concat); ok {
+			c.Items = append(opts, c.Items...)
+			c.Start = 0
+		} else {
+			node = &Concat{Span: Span{0, len(p.src)}, Items: append(opts, node)}
+		}
+	}
+
+	return node, nil
+}
+
+// fail stops parsing with an error at offset pos.
+func (p *parser) fail(pos int, format string, args ...any) {
+	panic(&Error{Pattern: p.src, Offset: pos, Msg: fmt.Sprintf(format, args...)})
+}
+
+// eof reports whether the whole pattern has been read.
+func (p *parser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+// peek returns the next byte, or 0 at the end of the pattern.
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+
+	return p.src[p.pos]
+}
+
+// lookingAt reports whether the pattern continues with s.
+func (p *parser) lookingAt(s string) bool {
+	return strings.HasPrefix(p.src[p.pos:], s)
+}
+
+// expect consumes s, or fails.
+func (p *parser) expect(s, msg string) {
+	if !p.lookingAt(s) {
+		p.fail(p.pos, "%s", msg)
+	}
+	p.pos += len(s)
+}
+
+// startOptions are the names of the options settable at the start of a
+// pattern, with whether they take a value.
+var startOptions = map[string]bool{
+	"UTF": false, "UCP": false, "NOTEMPTY": false, "NOTEMPTY_ATSTART": false,
+	"NO_AUTO_POSSESS": false, "NO_DOTSTAR_ANCHOR": false, "NO_JIT": false,
+	"NO_START_OPT": false, "CR": false, "LF": false, "CRLF": false,
+	"ANYCRLF": false, "ANY": false, "NUL": false, "BSR_ANYCRLF": false,
+	"BSR_UNICODE": false, "LIMIT_DEPTH": true, "LIMIT_HEAP": true,
+	"LIMIT_MATCH": true, "LIMIT_RECURSION": true,
+}
+
+// startOption parses an option setting at the start of the pattern, or
+// returns nil.
+func (p *parser) startOption() Node {
+	if !p.lookingAt("(*") {
+		return nil
+	}
+
+	end := strings.IndexByte(p.src[p.pos:], ')')
+	if end < 0 {
+		return nil
+	}
+
+	text := p.src[p.pos+2 : p.pos+end]
+	name, value, hasValue := strings.Cut(text, "=")
+	takesValue, ok := startOptions[name]
+	if !ok || takesValue != hasValue || hasValue && !isDigits(value) {
+		return nil
+	}
+
+	opt := &StartOption{Span: Span{p.pos, p.pos + end + 1}, Name: name, Value: value}
+	p.pos += end + 1
+
+	return opt
+}
+
+// alternation parses branches separated by | up to a ) or the end of the
+// pattern.
+func (p *parser) alternation() Node {
+	start := p.pos
+
+	var alts []Node
+	for {
+		alts = append(alts, p.concat())
+		if p.peek() != '|' || p.quoted {
+			break
+		}
+		p.pos++
+	}
+
+	if len(alts) == 1 {
+		return alts[0]
+	}
+
+	return &Alternate{Span: Span{start, p.pos}, Alts: alts}
+}
+
+// concat parses a branch.
+func (p *parser) concat() Node {
+	start := p.pos
+
+	var items []Node
+	for {
+		p.skipExtended()
+		if p.eof() || !p.quoted && (p.peek() == '|' || p.peek() == ')') {
+			break
+		}
+
+		atom := p.atom()
+		if atom == nil {
+			continue
+		}
+
+		// A quantifier following \E applies to the last quoted character.
+		if p.quoted && p.lookingAt(`\E`) {
+			p.pos += 2
+			p.quoted = false
+		}
+
+		if !p.quoted {
+			p.skipEmpty()
+			atom = p.quantifier(atom)
+		}
+		items = append(items, atom)
+
+		// Comments skipped around the quantifier follow the item.
+		items = append(items, p.comments...)
+		p.comments = nil
+	}
+
+	// A single item stands for the branch, unless quoting or extended
+	// mode left text around it.
+	if len(items) == 1 && items[0].Pos() == start && items[0].End() == p.pos {
+		return items[0]
+	}
+
+	return &Concat{Span: Span{start, p.pos}, Items: items}
+}
+
+// skipEmpty skips the text that matches nothing and is transparent to
+// quantifiers: whitespace in extended mode, empty quotes and comments,
+// which are kept in p.comments.
+func (p *parser) skipEmpty() {
+	for {
+		p.skipExtended()
+		switch {
+		case p.lookingAt("(?#"):
+			p.comments = append(p.comments, p.atom())
+		case p.emptyQuote() > 0:
+			p.pos += p.emptyQuote()
+		default:
+			return
+		}
+	}
+}
+
+// emptyQuote returns the length of the \E or \Q\E at the current position,
+// which quote nothing, or 0.
+func (p *parser) emptyQuote() int {
+	switch {
+	case p.lookingAt(`\E`):
+		return 2
+	case p.lookingAt(`\Q\E`):
+		return 4
+	}
+
+	return 0
+}
+
+// skipExtended skips whitespace and comments in extended mode.
+func (p *parser) skipExtended() {
+	if !p.set.extended || p.quoted {
+		return
+	}
+
+	for !p.eof() {
+		switch c := p.peek(); {
+		case isSpace(c):
+			p.pos++
+		case c == '#':
+			end := strings.IndexByte(p.src[p.pos:], '\n')
+			if end < 0 {
+				p.pos = len(p.src)
+			} else {
+				p.pos += end + 1
+			}
+		default:
+			return
+		}
+	}
+}
+
+// atom parses an item of a branch, or returns nil for text that is no
+// item, such as \E.
+func (p *parser) atom() Node {
+	start := p.pos
+
+	if p.quoted {
+		if p.lookingAt(`\E`) {
+			p.pos += 2
+			p.quoted = false
+			return nil
+		}
+		return p.literal()
+	}
+
+	switch c := p.peek(); c {
+	case '(':
+		return p.group()
+	case '[':
+		return p.class()
+	case '.':
+		p.pos++
+		return &Dot{Span: Span{start, p.pos}}
+	case '^', '$':
+		p.pos++
+		return &Assertion{Span: Span{start, p.pos}, Type: c}
+	case '\\':
+		return p.escape()
+	case '*', '+', '?':
+		p.fail(start, "quantifier does not follow a repeatable item")
+	case '{':
+		if _, _, n := p.braces(); n > 0 {
+			p.fail(start, "quantifier does not follow a repeatable item")
+		}
+	}
+
+	return p.literal()
+}
+
+// literal parses a character written as is.
+func (p *parser) literal() *Literal {
+	start := p.pos
+
+	r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+	if r == utf8.RuneError && size == 1 {
+		p.pos++
+		return &Literal{Span: Span{start, p.pos}, Rune: rune(p.src[start]), Byte: true}
+	}
+
+	p.pos += size
+	return &Literal{Span: Span{start, p.pos}, Rune: r}
+}
+
+// quantifier parses the quantifier following atom, if any.
+func (p *parser) quantifier(atom Node) Node {
+	start := p.pos
+
+	var min, max int
+	switch p.peek() {
+	case '*':
+		min, max = 0, -1
+		p.pos++
+	case '+':
+		min, max = 1, -1
+		p.pos++
+	case '?':
+		min, max = 0, 1
+		p.pos++
+	case '{':
+		var n int
+		if min, max, n = p.braces(); n == 0 {
+			return atom
+		}
+		p.pos += n
+	default:
+		return atom
+	}
+
+	if !repeatable(atom) {
+		p.fail(start, "quantifier does not follow a repeatable item")
+	}
+
+	p.skipEmpty()
+
+	mode := Greedy
+	switch p.peek() {
+	case '?':
+		mode = Lazy
+		p.pos++
+	case '+':
+		mode = Possessive
+		p.pos++
+	}
+
+	return &Repeat{Span: Span{atom.Pos(), p.pos}, Sub: atom, Min: min, Max: max, Mode: mode}
+}
+
+// braces parses the {n}, {n,} or {n,m} quantifier at the current offset,
+// without consuming it, and returns its bounds and length, or a length of
+// 0 if there is none.
+func (p *parser) braces() (min, max, n int) {
+	s := p.src[p.pos:]
+	end := strings.IndexByte(s, '}')
+	if end < 0 {
+		return 0, 0, 0
+	}
+
+	lo, hi, comma := strings.Cut(s[1:end], ",")
+	if !isDigits(lo) || comma && hi != "" && !isDigits(hi) {
+		return 0, 0, 0
+	}
+
+	min = p.bound(lo)
+	switch {
+	case !comma:
+		max = min
+	case hi == "":
+		max = -1
+	default:
+		max = p.bound(hi)
+		if max < min {
+			p.fail(p.pos+end, "numbers out of order in {} quantifier")
+		}
+	}
+
+	return min, max, end + 1
+}
+
+// bound returns the value of a bound of a quantifier.
+func (p *parser) bound(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil || n > maxRepeat {
+		p.fail(p.pos, "number too big in {} quantifier")
+	}
+
+	return n
+}
+
+// repeatable reports whether node may be quantified.
+func repeatable(node Node) bool {
+	switch n := node.(type) {
+	case *Assertion, *Options, *Callout, *Comment, *StartOption:
+		return false
+	case *Verb:
+		return n.Name == "ACCEPT"
+	}
+
+	return true
+}
+
+// group parses a group, or an item written in parentheses, such as a verb
+// or a callout.
+func (p *parser) group() Node {
+	start := p.pos
+	p.pos++
+
+	if p.lookingAt("*") {
+		return p.verb(start)
+	}
+
+	if !p.lookingAt("?") {
+		if p.set.noCapture {
+			return p.groupBody(&Group{Kind: GroupNonCapture}, start)
+		}
+		p.groups++
+		return p.groupBody(&Group{Kind: GroupCapture, Number: p.groups}, start)
+	}
+	p.pos++
+
+	switch c := p.peek(); {
+	case c == '#':
+		end := strings.IndexByte(p.src[p.pos:], ')')
+		if end < 0 {
+			p.fail(len(p.src), "missing ) after (?# comment")
+		}
+		text := p.src[p.pos+1 : p.pos+end]
+		p.pos += end + 1
+		return &Comment{Span: Span{start, p.pos}, Text: text}
+	case c == ':':
+		p.pos++
+		return p.groupBody(&Group{Kind: GroupNonCapture}, start)
+	case c == '|':
+		p.pos++
+		return p.groupBody(&Group{Kind: GroupBranchReset}, start)
+	case c == '>':
+		p.pos++
+		return p.groupBody(&Group{Kind: GroupAtomic}, start)
+	case c == '=':
+		p.pos++
+		return p.groupBody(&Group{Kind: GroupLookahead}, start)
+	case c == '!':
+		p.pos++
+		return p.groupBody(&Group{Kind: GroupNegativeLookahead}, start)
+	case c == '*':
+		p.pos++
+		return p.groupBody(&Group{Kind: GroupNonAtomicLookahead}, start)
+	case p.lookingAt("<="):
+		p.pos += 2
+		return p.groupBody(&Group{Kind: GroupLookbehind}, start)
+	case p.lookingAt("<!"):
+		p.pos += 2
+		return p.groupBody(&Group{Kind: GroupNegativeLookbehind}, start)
+	case p.lookingAt("<*"):
+		p.pos += 2
+		return p.groupBody(&Group{Kind: GroupNonAtomicLookbehind}, start)
+	case c == '<' || c == '\'':
+		p.pos++
+		return p.namedGroup(start, closing(c))
+	case p.lookingAt("P<"):
+		p.pos += 2
+		return p.namedGroup(start, '>')
+	case p.lookingAt("P="):
+		p.pos += 2
+		name := p.name(')')
+		p.pos++
+		return p.backref(&Backref{Span: Span{start, p.pos}, Name: name})
+	case p.lookingAt("P>"), c == '&':
+		if c == 'P' {
+			p.pos++
+		}
+		p.pos++
+		name := p.name(')')
+		p.pos++
+		return p.call(&Call{Span: Span{start, p.pos}, Name: name})
+	case p.lookingAt("R)"):
+		p.pos += 2
+		return &Call{Span: Span{start, p.pos}}
+	case isDigit(c) || (c == '+' || c == '-') && p.pos+1 < len(p.src) && isDigit(p.src[p.pos+1]):
+		n, rel := p.number()
+		p.expect(")", "missing ) after (?n subroutine call")
+		return p.call(&Call{Span: Span{start, p.pos}, Number: n, Relative: rel})
+	case c == 'C':
+		return p.callout(start)
+	case c == '(':
+		return p.conditional(start)
+	}
+
+	return p.options(start)
+}
+
+// groupBody parses the branches of g up to its closing parenthesis.
+func (p *parser) groupBody(g *Group, start int) Node {
+	saved := p.set
+	if g.Options != nil {
+		p.apply(g.Options)
+	}
+
+	if g.Kind.Lookaround() {
+		p.lookarounds++
+		defer func() { p.lookarounds-- }()
+	}
+
+	if g.Kind == GroupBranchReset {
+		g.Sub = p.branchReset()
+	} else {
+		g.Sub = p.alternation()
+	}
+
+	if p.eof() || p.quoted {
+		p.fail(len(p.src), "missing closing parenthesis")
+	}
+	p.pos++
+	p.set = saved
+
+	g.Span = Span{start, p.pos}
+	return g
+}
+
+// branchReset parses the branches of a branch reset group, numbering the
+// capture groups of each from the same number.
+func (p *parser) branchReset() Node {
+	start, first, last := p.pos, p.groups, p.groups
+
+	var alts []Node
+	for {
+		p.groups = first
+		alts = append(alts, p.concat())
+		if p.groups > last {
+			last = p.groups
+		}
+		if p.peek() != '|' || p.quoted {
+			break
+		}
+		p.pos++
+	}
+	p.groups = last
+
+	if len(alts) == 1 {
+		return alts[0]
+	}
+
+	return &Alternate{Span: Span{start, p.pos}, Alts: alts}
+}
+
+// closing returns the delimiter closing a name opened with c.
+func closing(c byte) byte {
+	switch c {
+	case '<':
+		return '>'
+	case '{':
+		return '}'
+	}
+
+	return c
+}
+
+// namedGroup parses a named capture group, whose name ends with end.
+func (p *parser) namedGroup(start int, end byte) Node {
+	namePos := p.pos
+	name := p.name(end)
+	p.pos++
+
+	p.groups++
+	if n, ok := p.names[name]; ok && n != p.groups && !p.set.dupNames {
+		p.fail(namePos, "two named subpatterns have the same name (PCRE2_DUPNAMES not set)")
+	}
+	p.names[name] = p.groups
+
+	return p.groupBody(&Group{Kind: GroupCapture, Number: p.groups, Name: name}, start)
+}
+
+// name parses a group name followed by end, without consuming end.
+func (p *parser) name(end byte) string {
+	start := p.pos
+	for !p.eof() && isWord(p.peek()) {
+		p.pos++
+	}
+
+	name := p.src[start:p.pos]
+	switch {
+	case name == "":
+		p.fail(start, "subpattern name expected")
+	case isDigit(name[0]):
+		p.fail(start, "subpattern name must start with a non-digit")
+	case len(name) > maxNameLen:
+		p.fail(start, "subpattern name is too long (maximum 32 code units)")
+	case p.peek() != end:
+		p.fail(p.pos, "syntax error in subpattern name (missing terminator?)")
+	}
+
+	return name
+}
+
+// number parses a group number, which may be relative if signed, and
+// returns the absolute number and the relative one.
+func (p *parser) number() (n, rel int) {
+	start := p.pos
+
+	sign := p.peek()
+	if sign == '+' || sign == '-' {
+		p.pos++
+	}
+
+	digits := p.pos
+	for !p.eof() && isDigit(p.peek()) {
+		p.pos++
+	}
+	if digits == p.pos {
+		p.fail(p.pos, "digit expected after (?+ or (?-")
+	}
+
+	n, err := strconv.Atoi(p.src[digits:p.pos])
+	if err != nil || n > maxRepeat {
+		p.fail(start, "subpattern number is too big")
+	}
+
+	switch sign {
+	case '+':
+		if n == 0 {
+			p.fail(start, "a relative value of zero is not allowed")
+		}
+		return p.groups + n, n
+	case '-':
+		if n == 0 {
+			p.fail(start, "a relative value of zero is not allowed")
+		}
+		if n > p.groups {
+			p.fail(start, "reference to non-existent subpattern")
+		}
+		return p.groups - n + 1, -n
+	}
+
+	return n, 0
+}
+
+// backref records the reference of b, and returns it.
+func (p *parser) backref(b *Backref) Node {
+	if b.Name == "" && b.Number == 0 {
+		p.fail(b.Start, "reference to non-existent subpattern")
+	}
+	p.refs = append(p.refs, ref{pos: b.Start, number: b.Number, name: b.Name, node: b})
+	return b
+}
+
+// call records the reference of c, and returns it.
+func (p *parser) call(c *Call) Node {
+	p.refs = append(p.refs, ref{pos: c.Start, number: c.Number, name: c.Name, node: c})
+	return c
+}
+
+// checkRefs checks that the groups referred to exist, and numbers the
+// references by name.
+func (p *parser) checkRefs() {
+	for _, r := range p.refs {
+		if r.name != "" {
+			n, ok := p.names[r.name]
+			if !ok {
+				p.fail(r.pos, "reference to non-existent subpattern")
+			}
+
+			switch node := r.node.(type) {
+			case *Backref:
+				node.Number = n
+			case *Call:
+				node.Number = n
+			case *CondRef:
+				node.Number = n
+			case *CondRecursion:
+				node.Number = n
+			}
+			continue
+		}
+
+		if r.number > p.groups {
+			p.fail(r.pos, "reference to non-existent subpattern")
+		}
+	}
+}
+
+// verbs are the backtracking control verbs, with whether their argument
+// is required.
+var verbs = map[string]bool{
+	"ACCEPT": false, "FAIL": false, "F": false, "MARK": true, "": true,
+	"COMMIT": false, "PRUNE": false, "SKIP": false, "THEN": false,
+}
+
+// alphaGroups are the group kinds of the alphabetic assertions and group
+// names.
+var alphaGroups = map[string]GroupKind{
+	"pla": GroupLookahead, "positive_lookahead": GroupLookahead,
+	"nla": GroupNegativeLookahead, "negative_lookahead": GroupNegativeLookahead,
+	"plb": GroupLookbehind, "positive_lookbehind": GroupLookbehind,
+	"nlb": GroupNegativeLookbehind, "negative_lookbehind": GroupNegativeLookbehind,
+	"napla": GroupNonAtomicLookahead, "non_atomic_positive_lookahead": GroupNonAtomicLookahead,
+	"naplb": GroupNonAtomicLookbehind, "non_atomic_positive_lookbehind": GroupNonAtomicLookbehind,
+	"atomic": GroupAtomic,
+	"sr":     GroupScriptRun, "script_run": GroupScriptRun,
+	"asr": GroupAtomicScriptRun, "atomic_script_run": GroupAtomicScriptRun,
+}
+
+// verb parses a verb or an alphabetic group after (.
+func (p *parser) verb(start int) Node {
+	p.pos++
+
+	nameStart := p.pos
+	for !p.eof() && isWord(p.peek()) {
+		p.pos++
+	}
+	name := p.src[nameStart:p.pos]
+
+	if kind, ok := alphaGroups[name]; ok && p.peek() == ':' {
+		p.pos++
+		return p.groupBody(&Group{Kind: kind}, start)
+	}
+
+	argRequired, ok := verbs[name]
+	if !ok {
+		p.fail(nameStart, "(*VERB) not recognized or malformed")
+	}
+
+	var arg string
+	if p.peek() == ':' {
+		end := strings.IndexByte(p.src[p.pos:], ')')
+		if end < 0 {
+			p.fail(len(p.src), "missing closing parenthesis for (*VERB)")
+		}
+		arg = p.src[p.pos+1 : p.pos+end]
+		p.pos += end
+	}
+	if argRequired && arg == "" {
+		p.fail(p.pos, "(*MARK) must have an argument")
+	}
+	p.expect(")", "(*VERB) not recognized or malformed")
+
+	switch name {
+	case "F":
+		name = "FAIL"
+	case "":
+		name = "MARK"
+	}
+
+	return &Verb{Span: Span{start, p.pos}, Name: name, Arg: arg}
+}
+
+// calloutDelims are the opening delimiters of string callouts.
+const calloutDelims = "`'\"^%#${"
+
+// callout parses a callout after (?.
+func (p *parser) callout(start int) Node {
+	p.pos++
+
+	c := &Callout{}
+	switch d := p.peek(); {
+	case isDigit(d):
+		digits := p.pos
+		for !p.eof() && isDigit(p.peek()) {
+			p.pos++
+		}
+		n, err := strconv.Atoi(p.src[digits:p.pos])
+		if err != nil || n > maxCallout {
+			p.fail(digits, "number after (?C is greater than 255")
+		}
+		c.Number = n
+	case strings.IndexByte(calloutDelims, d) >= 0 && d != 0:
+		end := closing(d)
+		c.Delim = d
+		p.pos++
+
+		var text strings.Builder
+		for {
+			if p.eof() {
+				p.fail(len(p.src), "missing terminating delimiter for callout with string argument")
+			}
+			if b := p.peek(); b == end {
+				p.pos++
+				if p.peek() != end {
+					break
+				}
+			}
+			text.WriteByte(p.src[p.pos])
+			p.pos++
+		}
+		c.Text = text.String()
+	}
+
+	p.expect(")", "closing parenthesis for (?C expected")
+	c.Span = Span{start, p.pos}
+
+	return c
+}
+
+// conditional parses a conditional group after (?.
+func (p *parser) conditional(start int) Node {
+	condStart := p.pos
+	p.pos++
+
+	var cond Node
+	switch c := p.peek(); {
+	case c == '?' || c == '*':
+		p.pos = condStart
+		g, ok := p.group().(*Group)
+		if !ok || !g.Kind.Lookaround() {
+			p.fail(condStart+1, "assertion expected after (?( or (?(?C)")
+		}
+		cond = g
+	case isDigit(c) || c == '+' || c == '-':
+		n, rel := p.number()
+		if n == 0 {
+			p.fail(condStart+1, "reference to non-existent subpattern")
+		}
+		p.expect(")", "malformed number or name after (?(")
+		cond = &CondRef{Span: Span{condStart, p.pos}, Number: n, Relative: rel}
+		p.refs = append(p.refs, ref{pos: condStart + 1, number: n, node: cond})
+	case c == '<' || c == '\'':
+		p.pos++
+		name := p.name(closing(c))
+		p.pos++
+		p.expect(")", "malformed number or name after (?(")
+		cond = &CondRef{Span: Span{condStart, p.pos}, Name: name}
+		p.refs = append(p.refs, ref{pos: condStart + 2, name: name, node: cond})
+	case p.lookingAt("R&"):
+		p.pos += 2
+		name := p.name(')')
+		p.pos++
+		cond = &CondRecursion{Span: Span{condStart, p.pos}, Name: name}
+		p.refs = append(p.refs, ref{pos: condStart + 3, name: name, node: cond})
+	case p.lookingAt("R)"), c == 'R' && p.pos+1 < len(p.src) && isDigit(p.src[p.pos+1]):
+		p.pos++
+		n := 0
+		if p.peek() != ')' {
+			n, _ = p.number()
+		}
+		p.expect(")", "malformed number or name after (?(")
+		cond = &CondRecursion{Span: Span{condStart, p.pos}, Number: n}
+		p.refs = append(p.refs, ref{pos: condStart + 2, number: n})
+	case p.lookingAt("DEFINE)"):
+		p.pos += len("DEFINE)")
+		cond = &CondDefine{Span: Span{condStart, p.pos}}
+	case p.lookingAt("VERSION"):
+		cond = p.version(condStart)
+	default:
+		name := p.name(')')
+		p.pos++
+		cond = &CondRef{Span: Span{condStart, p.pos}, Name: name}
+		p.refs = append(p.refs, ref{pos: condStart + 1, name: name, node: cond})
+	}
+
+	saved := p.set
+	body := p.alternation()
+	if p.eof() {
+		p.fail(len(p.src), "missing closing parenthesis")
+	}
+	p.pos++
+	p.set = saved
+
+	cg := &Conditional{Span: Span{start, p.pos}, Cond: cond, Yes: body}
+	if alt, ok := body.(*Alternate); ok {
+		if len(alt.Alts) > 2 {
+			p.fail(start, "conditional subpattern contains more than two branches")
+		}
+		cg.Yes, cg.No = alt.Alts[0], alt.Alts[1]
+	}
+	if _, ok := cond.(*CondDefine); ok && cg.No != nil {
+		p.fail(start, "DEFINE subpattern contains more than one branch")
+	}
+
+	return cg
+}
+
+// version parses a (VERSION...) condition.
+func (p *parser) version(start int) Node {
+	p.pos += len("VERSION")
+
+	op := "="
+	if p.lookingAt(">=") {
+		op = ">="
+	}
+	p.expect(op, "syntax error or number too big in (?(VERSION condition")
+
+	end := strings.IndexByte(p.src[p.pos:], ')')
+	if end < 0 {
+		p.fail(len(p.src), "syntax error or number too big in (?(VERSION condition")
+	}
+
+	major, minor, hasMinor := strings.Cut(p.src[p.pos:p.pos+end], ".")
+	if !isDigits(major) || hasMinor && !isDigits(minor) || len(minor) > 2 {
+		p.fail(p.pos, "syntax error or number too big in (?(VERSION condition")
+	}
+	p.pos += end + 1
+
+	v := &CondVersion{Span: Span{start, p.pos}, Op: op}
+	v.Major, _ = strconv.Atoi(major)
+	if hasMinor {
+		v.Minor, _ = strconv.Atoi(minor)
+	}
+
+	return v
+}
+
+// options parses an option setting, or a non-capture group setting
+// options, after (?.
+func (p *parser) options(start int) Node {
+	opts := &Options{}
+	if p.peek() == '^' {
+		opts.Reset = true
+		p.pos++
+	}
+
+	var on, off strings.Builder
+	letters := &on
+	for {
+		switch c := p.peek(); c {
+		case 'i', 'm', 'n', 's', 'x', 'J', 'U':
+			letters.WriteByte(c)
+			p.pos++
+		case '-':
+			if letters == &off || opts.Reset {
+				p.fail(p.pos, "invalid hyphen in option setting")
+			}
+			letters = &off
+			p.pos++
+		case ':', ')':
+			opts.On, opts.Off = on.String(), off.String()
+			p.pos++
+			opts.Span = Span{start, p.pos}
+
+			if c == ':' {
+				return p.groupBody(&Group{Kind: GroupNonCapture, Options: opts}, start)
+			}
+			p.apply(opts)
+			return opts
+		default:
+			p.fail(p.pos, "unrecognized character after (? or (?-")
+		}
+	}
+}
+
+// apply applies the options set by o.
+func (p *parser) apply(o *Options) {
+	if o.Reset {
+		p.set.extended, p.set.extendedMore, p.set.noCapture = false, false, false
+	}
+
+	for _, letters := range []struct {
+		s  string
+		on bool
+	}{{o.On, true}, {o.Off, false}} {
+		switch x := strings.Count(letters.s, "x"); {
+		case x >= 2:
+			p.set.extended, p.set.extendedMore = letters.on, letters.on
+		case x == 1:
+			p.set.extended = letters.on
+			if !letters.on {
+				p.set.extendedMore = false
+			}
+		}
+		if strings.Contains(letters.s, "n") {
+			p.set.noCapture = letters.on
+		}
+		if strings.Contains(letters.s, "J") {
+			p.set.dupNames = letters.on
+		}
+	}
+}
+
+// isDigit reports whether c is a decimal digit.
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isDigits reports whether s is a non-empty string of decimal digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		if !isDigit(s[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isWord reports whether c may be part of a group name.
+func isWord(c byte) bool {
+	return c == '_' || isDigit(c) || isLetter(c)
+}
+
+// isLetter reports whether c is an ASCII letter.
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// isSpace reports whether c is whitespace in extended mode.
+func isSpace(c byte) bool {
+	return c == ' ' || c >= '\t' && c <= '\r'
+}
@@ -0,0 +1,328 @@
+package pcresyntax_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/dwisiswant0/pcregexp"
+	"github.com/dwisiswant0/pcregexp/pkg/pcresyntax"
+)
+
+// canonical pairs patterns with their canonical forms.
+var canonical = []struct {
+	pattern, want string
+}{
+	{`abc`, `abc`},
+	{`a|b|c`, `a|b|c`},
+	{`(a|b)c`, `(a|b)c`},
+	{`(?:a|b)*+`, `(?:a|b)*+`},
+	{`a{0,}b{1,}?c{0,1}+`, `a*b+?c?+`},
+	{`a{2}b{2,}c{2,3}`, `a{2}b{2,}c{2,3}`},
+	{`a{,3}`, `a\{,3\}`},
+	{`x{`, `x\{`},
+	{`[^a-z\d[:^alpha:]\]-]`, `[^a-z\d[:^alpha:]\]\-]`},
+	{`[]a]`, `[\]a]`},
+	{`[\Q]\E]`, `[\]]`},
+	{`[(?<]`, `[(?<]`},
+	{`\Q(?=\E`, `\(\?=`},
+	{`\Qa\E+`, `a+`},
+	{`(?x) a + b # c`, `(?x)a+b`},
+	{`(?x)a\ b\#`, `(?x)a\ b\#`},
+	{`(?x:a b)\ `, `(?x:ab) `},
+	{`(?xx)[a b]`, `(?xx)[ab]`},
+	{`(?x)[ ]`, `(?x)[ ]`},
+	{`a(?#x)*`, `a*(?#x)`},
+	{`(?i)a(?-i:b)c`, `(?i)a(?-i:b)c`},
+	{`(?^x: a)`, `(?^x:a)`},
+	{`\t\n\x1b\x{263a}\o{101}\101\0`, `\t\n\e☺AA\x{0}`},
+	{`\cA\x7f`, `\x{1}\x{7f}`},
+	{`\d\W\h\R\X`, `\d\W\h\R\X`},
+	{`\pL\p{Greek}\P{Lu}\p{^Lu}`, `\pL\p{Greek}\P{Lu}\P{Lu}`},
+	{`^\bfoo\B\K$\z`, `^\bfoo\B\K$\z`},
+	{`(a)\1\g1\g{-1}`, `(a)\g{1}\g{1}\g{-1}`},
+	{`(?<n>a)(?&n)\k<n>(?P=n)(?P>n)\k{n}\g{n}`, `(?<n>a)(?&n)\k<n>\k<n>(?&n)\k<n>\k<n>`},
+	{`(?'n'a)(?P<m>b)`, `(?<n>a)(?<m>b)`},
+	{`(?|(a)|(b))\1`, `(?|(a)|(b))\g{1}`},
+	{`(a(?R)?b)(?1)(?-1)(?+1)(c)`, `(a(?R)?b)(?1)(?-1)(?+1)(c)`},
+	{`(?=a)(?!b)(?<=c)(?<!d)(?>e)`, `(?=a)(?!b)(?<=c)(?<!d)(?>e)`},
+	{`(*pla:a)(*negative_lookbehind:b)(*atomic:c)(*napla:d)`, `(?=a)(?<!b)(?>c)(?*d)`},
+	{`(*sr:\d+)(*asr:x)`, `(*sr:\d+)(*asr:x)`},
+	{`(a)(?(1)b|c)`, `(a)(?(1)b|c)`},
+	{`(?<n>a)(?(<n>)b)(?('n')c)(?(n)d)`, `(?<n>a)(?(<n>)b)(?(<n>)c)(?(<n>)d)`},
+	{`(?(?=a)ab|c)`, `(?(?=a)ab|c)`},
+	{`(a)(?(R)a|b)(?(R1)c)`, `(a)(?(R)a|b)(?(R1)c)`},
+	{`(?(DEFINE)(?<d>\d))(?&d)`, `(?(DEFINE)(?<d>\d))(?&d)`},
+	{`(?(VERSION>=10.4)a)`, `(?(VERSION>=10.4)a)`},
+	{`(*UTF)(*LIMIT_MATCH=10)a`, `(*UTF)(*LIMIT_MATCH=10)a`},
+	{`a(*SKIP)(*F)|(*:x)b(*PRUNE:y)`, `a(*SKIP)(*FAIL)|(*MARK:x)b(*PRUNE:y)`},
+	{`(?C)(?C12)(?C"a""b")(?C{x}}y})`, `(?C0)(?C12)(?C"a""b")(?C{x}}y})`},
+	{`(?#hi)x`, `(?#hi)x`},
+	{`(?=a)*`, `(?=a)*`},
+	{`(?R)?`, `(?R)?`},
+	{`\1*(a)`, `\g{1}*(a)`},
+	{`(a)\g{+1}(b)`, `(a)\g{+1}(b)`},
+	{`a\E*b\Q\E+\E?`, `a*b+?`},
+	{`a{2}(?#x)?`, `a{2}?(?#x)`},
+	{`(?x)a+ #c` + "\n" + `?`, `(?x)a+?`},
+	{`[\g\8][\E]]`, `[g8][\]]`},
+	{`[\Qa-\Ez][\E^a][^\E]a][a-\E]`, `[a\-z][^a][^\]a][a\-]`},
+	{`[[:<:]]a[[:>:]]`, `[[:<:]]a[[:>:]]`},
+	{`[\..]`, `[\..]`},
+	{`[\=a=]`, `[\=a=]`},
+	{`[\:a:]`, `[\:a:]`},
+	{`[\.-z.]`, `[\.-z.]`},
+	{`[^.a.]`, `[^.a.]`},
+	{`\pl\PZ`, `\pl\PZ`},
+}
+
+func TestParse_Canonical(t *testing.T) {
+	for _, tt := range canonical {
+		tree, err := pcresyntax.Parse(tt.pattern)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.pattern, err)
+			continue
+		}
+
+		got := tree.String()
+		if got != tt.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.pattern, got, tt.want)
+		}
+
+		again, err := pcresyntax.Parse(got)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", got, err)
+		} else if again.String() != got {
+			t.Errorf("Parse(%q).String() = %q, want it unchanged", got, again.String())
+		}
+
+		if tree.Pos() != 0 || tree.End() != len(tt.pattern) {
+			t.Errorf("Parse(%q) spans [%d:%d], want [0:%d]", tt.pattern, tree.Pos(), tree.End(), len(tt.pattern))
+		}
+	}
+}
+
+// TestParse_CanonicalCompiles checks that PCRE2 compiles the canonical
+// forms to patterns that match alike, in UTF mode.
+func TestParse_CanonicalCompiles(t *testing.T) {
+	opts := pcregexp.CompileOptions{Flags: pcregexp.CompileUTF}
+	subjects := []string{"", "abc", "ab c", "aab", "x{", "]a", "(?=", "foo bar", "☺AA\x00", "1234"}
+
+	for _, tt := range canonical {
+		orig, err := pcregexp.CompileWithOptions(tt.pattern, opts)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", tt.pattern, err)
+			continue
+		}
+		defer orig.Close()
+
+		canon, err := pcregexp.CompileWithOptions(tt.want, opts)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", tt.want, err)
+			continue
+		}
+		defer canon.Close()
+
+		if orig.NumSubexp() != canon.NumSubexp() {
+			t.Errorf("%q has %d groups, %q has %d", tt.pattern, orig.NumSubexp(), tt.want, canon.NumSubexp())
+		}
+		for _, s := range subjects {
+			got, want := canon.FindStringSubmatchIndex(s), orig.FindStringSubmatchIndex(s)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%q on %q = %v, %q = %v", tt.want, s, got, tt.pattern, want)
+			}
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		pattern string
+		offset  int
+	}{
+		{`(`, 1},
+		{`)`, 0},
+		{`(?<=a`, 5},
+		{`*`, 0},
+		{`^*`, 1},
+		{`\b+`, 2},
+		{`(?i)*`, 4},
+		{`x{3}{2}`, 4},
+		{`x{2,1}`, 5},
+		{`a{70000}`, 1},
+		{`[z-a]`, 3},
+		{`[\d-z]`, 3},
+		{`[a`, 0},
+		{`[[:foo:]]`, 1},
+		{`\8`, 0},
+		{`(a)\2`, 3},
+		{`\g{-1}(a)`, 3},
+		{`\g{-0}`, 3},
+		{`\k<n>`, 0},
+		{`(?<n>a)(?<n>b)`, 10},
+		{`(?(1)a|b)`, 3},
+		{`(?(1)a|b|c)(a)`, 0},
+		{`(?(DEFINE)a|b)`, 0},
+		{`(*FOO)`, 2},
+		{`(?C256)`, 3},
+		{`(?C{x)`, 6},
+		{`\`, 0},
+		{`\c`, 2},
+		{`\x{110000`, 2},
+		{`(?z)`, 2},
+		{`(?(0)a)`, 3},
+		{`[1\E-(]`, 5},
+		{`[\Q1\E-\Q(\E]`, 9},
+		{`\g0`, 0},
+		{`\p8`, 2},
+		{`\pY`, 2},
+		{`[:alpha:]`, 0},
+		{`[.a.]`, 0},
+		{`[\E]`, 0},
+		{`[\k]`, 1},
+		{`(?=\K)`, 3},
+		{`(?(?=a\K)b)`, 6},
+	}
+
+	for _, tt := range tests {
+		_, err := pcresyntax.Parse(tt.pattern)
+
+		var serr *pcresyntax.Error
+		if !errors.As(err, &serr) {
+			t.Errorf("Parse(%q) error = %v, want *Error", tt.pattern, err)
+			continue
+		}
+		if serr.Pattern != tt.pattern {
+			t.Errorf("Parse(%q) error pattern = %q", tt.pattern, serr.Pattern)
+		}
+		if serr.Offset != tt.offset {
+			t.Errorf("Parse(%q) error %q at offset %d, want %d", tt.pattern, serr.Msg, serr.Offset, tt.offset)
+		}
+
+		if re, err := pcregexp.Compile(tt.pattern); err == nil {
+			re.Close()
+			t.Errorf("Compile(%q) succeeded, want an error", tt.pattern)
+		}
+	}
+}
+
+func TestParse_Tree(t *testing.T) {
+	tree, err := pcresyntax.Parse(`(?<y>\d{4})-(?=[a-])|\1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alt, ok := tree.(*pcresyntax.Alternate)
+	if !ok || len(alt.Alts) != 2 {
+		t.Fatalf("tree = %#v, want an alternation of 2 branches", tree)
+	}
+
+	concat, ok := alt.Alts[0].(*pcresyntax.Concat)
+	if !ok || len(concat.Items) != 3 {
+		t.Fatalf("first branch = %#v, want 3 items", alt.Alts[0])
+	}
+
+	group, ok := concat.Items[0].(*pcresyntax.Group)
+	if !ok || group.Kind != pcresyntax.GroupCapture || group.Number != 1 || group.Name != "y" {
+		t.Fatalf("first item = %#v, want capture group 1 named y", concat.Items[0])
+	}
+	if group.Pos() != 0 || group.End() != 11 {
+		t.Errorf("group spans [%d:%d], want [0:11]", group.Pos(), group.End())
+	}
+
+	repeat, ok := group.Sub.(*pcresyntax.Repeat)
+	if !ok || repeat.Min != 4 || repeat.Max != 4 || repeat.Mode != pcresyntax.Greedy {
+		t.Fatalf("group sub = %#v, want {4}", group.Sub)
+	}
+	if ct, ok := repeat.Sub.(*pcresyntax.CharType); !ok || ct.Type != 'd' || ct.Pos() != 5 || ct.End() != 7 {
+		t.Errorf("repeat sub = %#v, want \\d at [5:7]", repeat.Sub)
+	}
+
+	if lit, ok := concat.Items[1].(*pcresyntax.Literal); !ok || lit.Rune != '-' || lit.Pos() != 11 {
+		t.Errorf("second item = %#v, want - at 11", concat.Items[1])
+	}
+
+	look, ok := concat.Items[2].(*pcresyntax.Group)
+	if !ok || look.Kind != pcresyntax.GroupLookahead || !look.Kind.Lookaround() {
+		t.Fatalf("third item = %#v, want a lookahead", concat.Items[2])
+	}
+	class, ok := look.Sub.(*pcresyntax.Class)
+	if !ok || len(class.Items) != 2 {
+		t.Fatalf("lookahead sub = %#v, want a class of 2 items", look.Sub)
+	}
+	if lit, ok := class.Items[1].(*pcresyntax.Literal); !ok || lit.Rune != '-' {
+		t.Errorf("last class item = %#v, want a literal -", class.Items[1])
+	}
+
+	if ref, ok := alt.Alts[1].(*pcresyntax.Backref); !ok || ref.Number != 1 || ref.Pos() != 21 {
+		t.Errorf("second branch = %#v, want \\1 at 21", alt.Alts[1])
+	}
+}
+
+func TestInspect(t *testing.T) {
+	tree, err := pcresyntax.Parse(`\(?=[(?<]\Q(?<=\E(?<!a)b(?:(?=c))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []int
+	pcresyntax.Inspect(tree, func(n pcresyntax.Node) bool {
+		if g, ok := n.(*pcresyntax.Group); ok && g.Kind.Lookaround() {
+			got = append(got, g.Pos())
+		}
+		return true
+	})
+
+	if want := []int{17, 27}; !reflect.DeepEqual(got, want) {
+		t.Errorf("lookarounds at %v, want %v", got, want)
+	}
+}
+
+func TestInspect_Prune(t *testing.T) {
+	tree, err := pcresyntax.Parse(`a(b(c))d`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var groups int
+	pcresyntax.Inspect(tree, func(n pcresyntax.Node) bool {
+		if _, ok := n.(*pcresyntax.Group); ok {
+			groups++
+			return false
+		}
+		return true
+	})
+
+	if groups != 1 {
+		t.Errorf("inspected %d groups, want 1", groups)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, tt := range canonical {
+		f.Add(tt.pattern)
+	}
+
+	f.Fuzz(func(t *testing.T, pattern string) {
+		tree, err := pcresyntax.Parse(pattern)
+		if err != nil {
+			return
+		}
+
+		// Bytes of invalid UTF-8 print as \x{hh}, which reads back as a
+		// character, so only the canonical form prints unchanged.
+		canon, err := pcresyntax.Parse(tree.String())
+		if err != nil {
+			t.Fatalf("Parse(%q) = %q, which fails to parse: %v", pattern, tree, err)
+		}
+
+		got := canon.String()
+		again, err := pcresyntax.Parse(got)
+		if err != nil {
+			t.Fatalf("Parse(%q) = %q, which fails to parse: %v", pattern, got, err)
+		}
+		if again.String() != got {
+			t.Fatalf("Parse(%q) = %q, which prints as %q", pattern, got, again.String())
+		}
+	})
+}
@@ -0,0 +1,410 @@
+package pcresyntax
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// printer writes the canonical form of nodes.
+type printer struct {
+	sb strings.Builder
+
+	// Whether whitespace is ignored where the printer is, so that it must
+	// be escaped.
+	extended, extendedMore bool
+}
+
+// toString returns the canonical form of node.
+func toString(node Node) string {
+	var p printer
+	p.node(node)
+
+	return p.sb.String()
+}
+
+func (n *Literal) String() string       { return toString(n) }
+func (n *Dot) String() string           { return toString(n) }
+func (n *CharType) String() string      { return toString(n) }
+func (n *Property) String() string      { return toString(n) }
+func (n *Assertion) String() string     { return toString(n) }
+func (n *Class) String() string         { return toString(n) }
+func (n *ClassRange) String() string    { return toString(n) }
+func (n *POSIXClass) String() string    { return toString(n) }
+func (n *Concat) String() string        { return toString(n) }
+func (n *Alternate) String() string     { return toString(n) }
+func (n *Group) String() string         { return toString(n) }
+func (n *Options) String() string       { return toString(n) }
+func (n *Repeat) String() string        { return toString(n) }
+func (n *Backref) String() string       { return toString(n) }
+func (n *Call) String() string          { return toString(n) }
+func (n *Conditional) String() string   { return toString(n) }
+func (n *CondRef) String() string       { return toString(n) }
+func (n *CondRecursion) String() string { return toString(n) }
+func (n *CondDefine) String() string    { return toString(n) }
+func (n *CondVersion) String() string   { return toString(n) }
+func (n *Verb) String() string          { return toString(n) }
+func (n *StartOption) String() string   { return toString(n) }
+func (n *Callout) String() string       { return toString(n) }
+func (n *Comment) String() string       { return toString(n) }
+
+// groupPrefixes are the openings of the groups of each kind, but capture
+// and non-capture groups.
+var groupPrefixes = map[GroupKind]string{
+	GroupAtomic:              "(?>",
+	GroupBranchReset:         "(?|",
+	GroupLookahead:           "(?=",
+	GroupNegativeLookahead:   "(?!",
+	GroupLookbehind:          "(?<=",
+	GroupNegativeLookbehind:  "(?<!",
+	GroupNonAtomicLookahead:  "(?*",
+	GroupNonAtomicLookbehind: "(?<*",
+	GroupScriptRun:           "(*sr:",
+	GroupAtomicScriptRun:     "(*asr:",
+}
+
+// node writes node.
+func (p *printer) node(node Node) {
+	switch n := node.(type) {
+	case *Literal:
+		p.literal(n, false)
+	case *Dot:
+		p.sb.WriteByte('.')
+	case *CharType:
+		p.sb.WriteString(`\` + string(n.Type))
+	case *Property:
+		p.property(n)
+	case *Assertion:
+		switch n.Type {
+		case '^', '$':
+			p.sb.WriteByte(n.Type)
+		case '<', '>':
+			p.sb.WriteString("[[:" + string(n.Type) + ":]]")
+		default:
+			p.sb.WriteString(`\` + string(n.Type))
+		}
+	case *Class:
+		p.class(n)
+	case *ClassRange:
+		p.literal(n.Lo, true)
+		p.sb.WriteByte('-')
+		p.literal(n.Hi, true)
+	case *POSIXClass:
+		p.sb.WriteString("[:")
+		if n.Negated {
+			p.sb.WriteByte('^')
+		}
+		p.sb.WriteString(n.Name + ":]")
+	case *Concat:
+		for _, item := range n.Items {
+			if _, ok := item.(*Alternate); ok {
+				p.wrapped(item)
+			} else {
+				p.node(item)
+			}
+		}
+	case *Alternate:
+		for i, alt := range n.Alts {
+			if i > 0 {
+				p.sb.WriteByte('|')
+			}
+			p.node(alt)
+		}
+	case *Group:
+		p.group(n)
+	case *Options:
+		p.sb.WriteString("(?")
+		p.options(n)
+		p.sb.WriteByte(')')
+		p.apply(n)
+	case *Repeat:
+		p.repeat(n)
+	case *Backref:
+		switch {
+		case n.Name != "":
+			p.sb.WriteString(`\k<` + n.Name + ">")
+		case n.Relative > 0:
+			p.sb.WriteString(`\g{+` + strconv.Itoa(n.Relative) + "}")
+		case n.Relative < 0:
+			p.sb.WriteString(`\g{` + strconv.Itoa(n.Relative) + "}")
+		default:
+			p.sb.WriteString(`\g{` + strconv.Itoa(n.Number) + "}")
+		}
+	case *Call:
+		switch {
+		case n.Name != "":
+			p.sb.WriteString("(?&" + n.Name + ")")
+		case n.Relative > 0:
+			p.sb.WriteString("(?+" + strconv.Itoa(n.Relative) + ")")
+		case n.Relative < 0:
+			p.sb.WriteString("(?" + strconv.Itoa(n.Relative) + ")")
+		case n.Number == 0:
+			p.sb.WriteString("(?R)")
+		default:
+			p.sb.WriteString("(?" + strconv.Itoa(n.Number) + ")")
+		}
+	case *Conditional:
+		p.conditional(n)
+	case *CondRef:
+		switch {
+		case n.Name != "":
+			p.sb.WriteString("(<" + n.Name + ">)")
+		case n.Relative > 0:
+			p.sb.WriteString("(+" + strconv.Itoa(n.Relative) + ")")
+		case n.Relative < 0:
+			p.sb.WriteString("(" + strconv.Itoa(n.Relative) + ")")
+		default:
+			p.sb.WriteString("(" + strconv.Itoa(n.Number) + ")")
+		}
+	case *CondRecursion:
+		switch {
+		case n.Name != "":
+			p.sb.WriteString("(R&" + n.Name + ")")
+		case n.Number != 0:
+			p.sb.WriteString("(R" + strconv.Itoa(n.Number) + ")")
+		default:
+			p.sb.WriteString("(R)")
+		}
+	case *CondDefine:
+		p.sb.WriteString("(DEFINE)")
+	case *CondVersion:
+		p.sb.WriteString("(VERSION" + n.Op + strconv.Itoa(n.Major) + "." + strconv.Itoa(n.Minor) + ")")
+	case *Verb:
+		p.sb.WriteString("(*" + n.Name)
+		if n.Arg != "" {
+			p.sb.WriteString(":" + n.Arg)
+		}
+		p.sb.WriteByte(')')
+	case *StartOption:
+		p.sb.WriteString("(*" + n.Name)
+		if n.Value != "" {
+			p.sb.WriteString("=" + n.Value)
+		}
+		p.sb.WriteByte(')')
+	case *Callout:
+		p.callout(n)
+	case *Comment:
+		p.sb.WriteString("(?#" + n.Text + ")")
+	}
+}
+
+// wrapped writes node in a non-capture group.
+func (p *printer) wrapped(node Node) {
+	p.sb.WriteString("(?:")
+	p.node(node)
+	p.sb.WriteByte(')')
+}
+
+// literal writes a character, escaped if needed.
+func (p *printer) literal(n *Literal, inClass bool) {
+	r := n.Rune
+
+	switch {
+	case n.Byte || r > unicode.MaxRune || r < 0:
+		p.code(r)
+	case r == '\t':
+		p.sb.WriteString(`\t`)
+	case r == '\n':
+		p.sb.WriteString(`\n`)
+	case r == '\r':
+		p.sb.WriteString(`\r`)
+	case r == '\f':
+		p.sb.WriteString(`\f`)
+	case r == 0x1b:
+		p.sb.WriteString(`\e`)
+	case r == '\a':
+		p.sb.WriteString(`\a`)
+	case r < ' ' || r == 0x7f:
+		p.code(r)
+	case inClass && strings.ContainsRune(`\]^-[`, r),
+		!inClass && strings.ContainsRune(`\^$.|?*+()[]{}`, r),
+		r == ' ' && (p.extended && !inClass || p.extendedMore && inClass),
+		r == '#' && p.extended && !inClass:
+		p.sb.WriteByte('\\')
+		p.sb.WriteRune(r)
+	case r >= 0x80 && !unicode.IsPrint(r):
+		p.code(r)
+	default:
+		p.sb.WriteRune(r)
+	}
+}
+
+// code writes the \x{...} escape of a character code.
+func (p *printer) code(r rune) {
+	p.sb.WriteString(`\x{` + strconv.FormatInt(int64(r), 16) + "}")
+}
+
+// property writes a Unicode property escape.
+func (p *printer) property(n *Property) {
+	if n.Negated {
+		p.sb.WriteString(`\P`)
+	} else {
+		p.sb.WriteString(`\p`)
+	}
+
+	if len(n.Name) == 1 && strings.ContainsAny(n.Name, "CLMNPSZclmnpsz") {
+		p.sb.WriteString(n.Name)
+	} else {
+		p.sb.WriteString("{" + n.Name + "}")
+	}
+}
+
+// class writes a character class.
+func (p *printer) class(n *Class) {
+	p.sb.WriteByte('[')
+	if n.Negated {
+		p.sb.WriteByte('^')
+	}
+
+	for i, item := range n.Items {
+		first := i == 0 && !n.Negated
+
+		switch item := item.(type) {
+		case *Literal:
+			p.classLiteral(item, first)
+		case *ClassRange:
+			p.classLiteral(item.Lo, first)
+			p.sb.WriteByte('-')
+			p.literal(item.Hi, true)
+		default:
+			p.node(item)
+		}
+	}
+
+	p.sb.WriteByte(']')
+}
+
+// classLiteral writes a character of a class. The first one is escaped if
+// it is '.', '=' or ':', which would read as POSIX syntax, such as [.a.].
+func (p *printer) classLiteral(n *Literal, first bool) {
+	if first && !n.Byte && strings.ContainsRune(".=:", n.Rune) {
+		p.sb.WriteByte('\\')
+		p.sb.WriteRune(n.Rune)
+		return
+	}
+
+	p.literal(n, true)
+}
+
+// group writes a group, in the options it sets.
+func (p *printer) group(n *Group) {
+	extended, extendedMore := p.extended, p.extendedMore
+
+	switch {
+	case n.Kind == GroupCapture && n.Name != "":
+		p.sb.WriteString("(?<" + n.Name + ">")
+	case n.Kind == GroupCapture:
+		p.sb.WriteByte('(')
+	case n.Kind == GroupNonCapture:
+		p.sb.WriteString("(?")
+		if n.Options != nil {
+			p.options(n.Options)
+			p.apply(n.Options)
+		}
+		p.sb.WriteByte(':')
+	default:
+		p.sb.WriteString(groupPrefixes[n.Kind])
+	}
+
+	if n.Sub != nil {
+		p.node(n.Sub)
+	}
+	p.sb.WriteByte(')')
+
+	p.extended, p.extendedMore = extended, extendedMore
+}
+
+// options writes the letters of an option setting.
+func (p *printer) options(n *Options) {
+	if n.Reset {
+		p.sb.WriteByte('^')
+	}
+	p.sb.WriteString(n.On)
+	if n.Off != "" {
+		p.sb.WriteString("-" + n.Off)
+	}
+}
+
+// apply applies the options set by n to the printer.
+func (p *printer) apply(n *Options) {
+	if n.Reset {
+		p.extended, p.extendedMore = false, false
+	}
+
+	if x := strings.Count(n.On, "x"); x > 0 {
+		p.extended, p.extendedMore = true, x >= 2
+	}
+	if strings.Contains(n.Off, "x") {
+		p.extended, p.extendedMore = false, false
+	}
+}
+
+// repeat writes a quantified node.
+func (p *printer) repeat(n *Repeat) {
+	switch n.Sub.(type) {
+	case *Concat, *Alternate, *Repeat, *Assertion, *Options, *Callout, *Comment, *StartOption:
+		p.wrapped(n.Sub)
+	default:
+		p.node(n.Sub)
+	}
+
+	switch {
+	case n.Min == 0 && n.Max == -1:
+		p.sb.WriteByte('*')
+	case n.Min == 1 && n.Max == -1:
+		p.sb.WriteByte('+')
+	case n.Min == 0 && n.Max == 1:
+		p.sb.WriteByte('?')
+	case n.Max == -1:
+		p.sb.WriteString("{" + strconv.Itoa(n.Min) + ",}")
+	case n.Min == n.Max:
+		p.sb.WriteString("{" + strconv.Itoa(n.Min) + "}")
+	default:
+		p.sb.WriteString("{" + strconv.Itoa(n.Min) + "," + strconv.Itoa(n.Max) + "}")
+	}
+
+	switch n.Mode {
+	case Lazy:
+		p.sb.WriteByte('?')
+	case Possessive:
+		p.sb.WriteByte('+')
+	}
+}
+
+// conditional writes a conditional group.
+func (p *printer) conditional(n *Conditional) {
+	extended, extendedMore := p.extended, p.extendedMore
+
+	p.sb.WriteString("(?")
+	p.node(n.Cond)
+
+	if _, ok := n.Yes.(*Alternate); ok {
+		p.wrapped(n.Yes)
+	} else {
+		p.node(n.Yes)
+	}
+	if n.No != nil {
+		p.sb.WriteByte('|')
+		if _, ok := n.No.(*Alternate); ok {
+			p.wrapped(n.No)
+		} else {
+			p.node(n.No)
+		}
+	}
+	p.sb.WriteByte(')')
+
+	p.extended, p.extendedMore = extended, extendedMore
+}
+
+// callout writes a callout.
+func (p *printer) callout(n *Callout) {
+	if n.Delim == 0 {
+		p.sb.WriteString("(?C" + strconv.Itoa(n.Number) + ")")
+		return
+	}
+
+	end := string(closing(n.Delim))
+	p.sb.WriteString("(?C" + string(n.Delim))
+	p.sb.WriteString(strings.ReplaceAll(n.Text, end, end+end))
+	p.sb.WriteString(end + ")")
+}
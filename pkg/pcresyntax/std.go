@@ -0,0 +1,41 @@
+package pcresyntax
+
+import (
+	"errors"
+	"regexp/syntax"
+)
+
+// unsupported are the codes of the errors of regexp/syntax that may be
+// caused by a PCRE feature the standard library does not support.
+var unsupported = map[syntax.ErrorCode]bool{
+	syntax.ErrInvalidCharRange:      true, // [[:<:]]
+	syntax.ErrInvalidEscape:         true, // \1, \h, \K
+	syntax.ErrInvalidNamedCapture:   true, // (?<=
+	syntax.ErrInvalidPerlOp:         true, // (?=, (?>, (?R)
+	syntax.ErrInvalidRepeatOp:       true, // a++
+	syntax.ErrInvalidRepeatSize:     true, // a{1001}
+	syntax.ErrMissingRepeatArgument: true, // (*PRUNE)
+}
+
+// ParseBeyondStd parses pattern as PCRE2 syntax if the standard library
+// does not support it: regexp/syntax rejects it with an error, returned as
+// stdErr, that a PCRE2 construct such as a lookaround or a back reference
+// may cause. tree and err are then those of [Parse].
+//
+// If regexp/syntax accepts pattern, stdErr and err are nil. If it rejects
+// it for another reason, such as invalid UTF-8, stdErr is nil and err is
+// the error of regexp/syntax.
+func ParseBeyondStd(pattern string) (tree Node, stdErr *syntax.Error, err error) {
+	_, err = syntax.Parse(pattern, syntax.Perl)
+	if err == nil {
+		return nil, nil, nil
+	}
+
+	if !errors.As(err, &stdErr) || !unsupported[stdErr.Code] {
+		return nil, nil, err
+	}
+
+	tree, err = Parse(pattern)
+
+	return tree, stdErr, err
+}
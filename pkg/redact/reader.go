@@ -0,0 +1,53 @@
+package redact
+
+import (
+	"io"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+// Reader is an [io.ReadCloser] redacting what is read from its source. It is
+// not safe for concurrent use, except for [Reader.Counts].
+type Reader struct {
+	r   *pcregexp.ReplacingReader
+	red *redactor
+}
+
+// NewRedactingReader returns a Reader redacting the matches of rules, or of
+// [DefaultRules] if there are none, in the text read from r. It returns an
+// error if a rule does not compile.
+func NewRedactingReader(r io.Reader, rules ...Rule) (*Reader, error) {
+	red, err := newRedactor(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reader{r: pcregexp.NewReplacingReader(r, red.re, red), red: red}, nil
+}
+
+// Read reads redacted text into p.
+func (rr *Reader) Read(p []byte) (int, error) {
+	if rr.red.re == nil {
+		return 0, ErrClosed
+	}
+
+	return rr.r.Read(p)
+}
+
+// Close releases the compiled rules. It does not close the source.
+func (rr *Reader) Close() error {
+	if rr.red.re == nil {
+		return ErrClosed
+	}
+
+	rr.red.re.Close()
+	rr.red.re = nil
+
+	return nil
+}
+
+// Counts returns the number of matches redacted by each rule so far, keyed by
+// rule name.
+func (rr *Reader) Counts() map[string]uint64 {
+	return rr.red.snapshot()
+}
@@ -0,0 +1,254 @@
+// Package redact scrubs secrets and personal data from streams, such as
+// logs, as they are written or read.
+//
+// A [Writer] or [Reader] applies a set of rules, each a PCRE pattern whose
+// matches are replaced, to the bytes streaming through it. The rules are
+// compiled into a single pattern, and matched with PCRE2 partial matching:
+// when a chunk of input ends in what may be the beginning of a match, that
+// text is held back until more input arrives, so that a secret split across
+// writes is still caught. A [Writer] is built on [pcregexp.ReplacingWriter]
+// and a [Reader] on [pcregexp.ReplacingReader], whose limits on the text
+// held back and on how far lookbehind assertions see apply.
+//
+// With no rules given, the built-in rules of [DefaultRules] apply, covering
+// credit card numbers (validated with the Luhn checksum), email addresses,
+// bearer tokens and AWS credentials. Each redactor counts the matches of its
+// rules, see [Writer.Counts].
+package redact
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+// Rule describes what to redact.
+type Rule struct {
+	// Name identifies the rule in counters and in the default replacement.
+	Name string
+
+	// Pattern is the PCRE pattern of the text to redact. Since the rules are
+	// compiled into one pattern, backreferences should be relative, as in
+	// \g{-1}, or by name, and names must be unique across rules. \K may be
+	// used to keep a leading part of the match.
+	Pattern string
+
+	// Replacement is the literal text matches are replaced with. If empty,
+	// it is "[REDACTED:" + Name + "]".
+	Replacement string
+
+	// Validate, if set, is called with every match, which is only redacted
+	// if it returns true. It lets rules reject false positives, like card
+	// numbers failing their checksum. It is called while matching, so that
+	// shorter matches of the rule, and the other rules, are tried in place
+	// of a match it rejects; it may be called more than once with the same
+	// text.
+	Validate func(match []byte) bool
+}
+
+// ErrClosed is returned when writing to a closed [Writer] or reading from a
+// closed [Reader].
+var ErrClosed = errors.New("redact: use of closed redactor")
+
+// replacement returns the text matches of r are replaced with.
+func (r Rule) replacement() string {
+	if r.Replacement != "" {
+		return r.Replacement
+	}
+
+	return "[REDACTED:" + r.Name + "]"
+}
+
+// redactor is the [pcregexp.Replacer] shared by Writer and Reader. It is
+// not safe for concurrent use, except for counts.
+type redactor struct {
+	re     *pcregexp.PCREgexp
+	rules  []Rule
+	groups []int // group of each rule in re
+	counts []atomic.Uint64
+}
+
+// maxValidated is the number of rules that may have a Validate function,
+// which is called from a numbered callout.
+const maxValidated = 255
+
+// newRedactor compiles rules, or [DefaultRules] if there are none.
+func newRedactor(rules []Rule) (*redactor, error) {
+	if len(rules) == 0 {
+		rules = DefaultRules()
+	}
+
+	var (
+		sb       strings.Builder
+		groups   = make([]int, len(rules))
+		group    = 1
+		validate bool
+	)
+
+	for i, r := range rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("redact: rule %d has no name", i)
+		}
+
+		if r.Validate != nil && i >= maxValidated {
+			return nil, fmt.Errorf("redact: rule %s: only the first %d rules may have a Validate function", r.Name, maxValidated)
+		}
+
+		re, err := pcregexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redact: rule %s: %w", r.Name, err)
+		}
+		n := re.NumSubexp()
+		re.Close()
+
+		if i > 0 {
+			sb.WriteByte('|')
+		}
+		sb.WriteString("(" + r.Pattern + ")")
+		if r.Validate != nil {
+			// Rule i+1 is validated by callout number i+1.
+			sb.WriteString("(?C" + strconv.Itoa(i+1) + ")")
+			validate = true
+		}
+
+		groups[i] = group
+		group += n + 1
+	}
+
+	pattern := sb.String()
+	if validate {
+		// Let the callouts see every match attempt, and shorter matches
+		// than the ones they reject.
+		pattern = "(*NO_AUTO_POSSESS)(*NO_START_OPT)" + pattern
+	}
+
+	re, err := pcregexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("redact: %w", err)
+	}
+
+	red := &redactor{
+		re:     re,
+		rules:  append([]Rule(nil), rules...),
+		groups: groups,
+		counts: make([]atomic.Uint64, len(rules)),
+	}
+
+	if validate {
+		re.SetCallout(red.validate)
+	}
+
+	return red, nil
+}
+
+// validate is the callout of re, calling the Validate function of the rule
+// numbered by the callout with the text it matched.
+func (r *redactor) validate(c *pcregexp.Callout) bool {
+	return r.rules[c.Number-1].Validate(c.Subject[c.Start:c.Position])
+}
+
+// AppendReplacement implements [pcregexp.Replacer].
+func (r *redactor) AppendReplacement(dst []byte, _ *pcregexp.PCREgexp, src []byte, loc []int) []byte {
+	return r.redact(dst, src[loc[0]:loc[1]], loc)
+}
+
+// redact appends the replacement of match, located by loc, to out.
+func (r *redactor) redact(out, match []byte, loc []int) []byte {
+	for i, g := range r.groups {
+		// loc ends at the last group that took part in the match.
+		if 2*g >= len(loc) || loc[2*g] < 0 {
+			continue
+		}
+
+		r.counts[i].Add(1)
+
+		return append(out, r.rules[i].replacement()...)
+	}
+
+	return append(out, match...)
+}
+
+// snapshot returns the number of matches of each rule, keyed by name.
+func (r *redactor) snapshot() map[string]uint64 {
+	counts := make(map[string]uint64, len(r.rules))
+	for i, rule := range r.rules {
+		counts[rule.Name] += r.counts[i].Load()
+	}
+
+	return counts
+}
+
+// Built-in rules.
+var (
+	// CreditCard redacts payment card numbers of 13 to 19 digits, possibly
+	// grouped with spaces or dashes, that pass the Luhn checksum.
+	CreditCard = Rule{
+		Name:     "credit-card",
+		Pattern:  `(?<!\d)\d(?:[ -]?\d){12,18}(?!\d)`,
+		Validate: Luhn,
+	}
+
+	// Email redacts email addresses.
+	Email = Rule{
+		Name:    "email",
+		Pattern: `[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}(?![A-Za-z0-9-])`,
+	}
+
+	// BearerToken redacts the token of bearer authorization credentials, as
+	// found in Authorization headers, keeping the scheme.
+	BearerToken = Rule{
+		Name:    "bearer-token",
+		Pattern: `(?i)\bbearer\s+\K[A-Za-z0-9._~+/-]+=*`,
+	}
+
+	// AWSAccessKeyID redacts AWS access key IDs.
+	AWSAccessKeyID = Rule{
+		Name:    "aws-access-key-id",
+		Pattern: `\b(?:AKIA|ASIA|AIDA|AROA|AGPA|ANPA|ANVA|AIPA)[A-Z0-9]{16}\b`,
+	}
+
+	// AWSSecretAccessKey redacts AWS secret access keys assigned to a key
+	// named like aws_secret_access_key, keeping the name.
+	AWSSecretAccessKey = Rule{
+		Name:    "aws-secret-access-key",
+		Pattern: `(?i)\baws_?secret_?access_?key\b["']?\s*[:=]\s*["']?\K[A-Za-z0-9/+=]{40}(?![A-Za-z0-9/+=])`,
+	}
+)
+
+// DefaultRules returns the built-in rules.
+func DefaultRules() []Rule {
+	return []Rule{CreditCard, Email, BearerToken, AWSAccessKeyID, AWSSecretAccessKey}
+}
+
+// Luhn reports whether the digits of b, ignoring spaces and dashes, form a
+// number of 13 to 19 digits passing the Luhn checksum.
+func Luhn(b []byte) bool {
+	sum, n := 0, 0
+	for i := len(b) - 1; i >= 0; i-- {
+		c := b[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+
+		if c < '0' || c > '9' {
+			return false
+		}
+
+		// Every second digit from the right is doubled.
+		d := int(c - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+
+		sum += d
+		n++
+	}
+
+	return n >= 13 && n <= 19 && sum%10 == 0
+}
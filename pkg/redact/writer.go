@@ -0,0 +1,68 @@
+package redact
+
+import (
+	"io"
+	"sync"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+// Writer is an [io.WriteCloser] redacting what is written to it before
+// passing it on. It is safe for concurrent use.
+//
+// The text at the end of a write that may start a match is held back until
+// the next write or [Writer.Close], which must be called to flush it.
+type Writer struct {
+	mu  sync.Mutex
+	w   *pcregexp.ReplacingWriter
+	red *redactor
+}
+
+// NewRedactingWriter returns a Writer redacting the matches of rules, or of
+// [DefaultRules] if there are none, before writing to w. It returns an
+// error if a rule does not compile.
+func NewRedactingWriter(w io.Writer, rules ...Rule) (*Writer, error) {
+	red, err := newRedactor(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Writer{w: pcregexp.NewReplacingWriter(w, red.re, red), red: red}, nil
+}
+
+// Write redacts p and writes what can be decided to the underlying writer.
+// It returns len(p) unless the underlying writer fails.
+func (rw *Writer) Write(p []byte) (int, error) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if rw.red.re == nil {
+		return 0, ErrClosed
+	}
+
+	return rw.w.Write(p)
+}
+
+// Close writes the text held back, if any, and releases the compiled rules.
+// It does not close the underlying writer.
+func (rw *Writer) Close() error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if rw.red.re == nil {
+		return ErrClosed
+	}
+
+	err := rw.w.Close()
+
+	rw.red.re.Close()
+	rw.red.re = nil
+
+	return err
+}
+
+// Counts returns the number of matches redacted by each rule so far, keyed by
+// rule name.
+func (rw *Writer) Counts() map[string]uint64 {
+	return rw.red.snapshot()
+}
@@ -0,0 +1,183 @@
+package regexp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dwisiswant0/pcregexp/pkg/pcresyntax"
+)
+
+// Engine is the regular expression engine a [Regexp] runs on.
+type Engine int
+
+const (
+	// EngineStd is the standard library regexp package, which matches in
+	// time linear in the length of the subject.
+	EngineStd Engine = iota
+
+	// EnginePCRE is PCRE2, through the pcregexp package. It backtracks, so
+	// matching may take time exponential in the length of the subject.
+	EnginePCRE
+)
+
+// String returns "std" or "pcre".
+func (e Engine) String() string {
+	switch e {
+	case EngineStd:
+		return "std"
+	case EnginePCRE:
+		return "pcre"
+	}
+
+	return fmt.Sprintf("Engine(%d)", int(e))
+}
+
+// Explain reports the engine [Compile] selects for pattern and, for the
+// PCRE engine, why: the first construct of the pattern the standard
+// library does not support, such as "lookbehind at offset 5".
+//
+// The pattern is parsed by regexp/syntax first. Only if it rejects the
+// pattern for a reason such as an unsupported escape or group, and the
+// pattern parses as PCRE2 syntax, is the PCRE engine selected. Explain
+// returns the error of the parser of the engine that would compile the
+// pattern, but neither compiles it nor loads PCRE2: PCRE2 may still
+// reject a pattern Explain accepts, for instance a lookbehind of unbounded
+// length.
+func Explain(pattern string) (engine Engine, reason string, err error) {
+	tree, serr, err := pcresyntax.ParseBeyondStd(pattern)
+	if serr == nil {
+		return EngineStd, "", err
+	}
+	if err != nil {
+		return EnginePCRE, "", err
+	}
+
+	if what, offset := firstFeature(pattern, tree); what != "" {
+		return EnginePCRE, fmt.Sprintf("%s at offset %d", what, offset), nil
+	}
+
+	// The offset of the text regexp/syntax rejected is known only if the
+	// text appears once in the pattern.
+	if strings.Count(pattern, serr.Expr) != 1 {
+		return EnginePCRE, fmt.Sprintf("%s `%s`", serr.Code, serr.Expr), nil
+	}
+
+	return EnginePCRE, fmt.Sprintf("%s `%s` at offset %d", serr.Code, serr.Expr, strings.Index(pattern, serr.Expr)), nil
+}
+
+// groupFeatures describe the kinds of groups the standard library does not
+// support.
+var groupFeatures = map[pcresyntax.GroupKind]string{
+	pcresyntax.GroupAtomic:              "atomic group",
+	pcresyntax.GroupBranchReset:         "branch reset group",
+	pcresyntax.GroupLookahead:           "lookahead",
+	pcresyntax.GroupNegativeLookahead:   "negative lookahead",
+	pcresyntax.GroupLookbehind:          "lookbehind",
+	pcresyntax.GroupNegativeLookbehind:  "negative lookbehind",
+	pcresyntax.GroupNonAtomicLookahead:  "non-atomic lookahead",
+	pcresyntax.GroupNonAtomicLookbehind: "non-atomic lookbehind",
+	pcresyntax.GroupScriptRun:           "script run",
+	pcresyntax.GroupAtomicScriptRun:     "atomic script run",
+}
+
+// firstFeature returns the description and offset of the first node of
+// tree the standard library does not support, or "".
+func firstFeature(pattern string, tree pcresyntax.Node) (what string, offset int) {
+	pcresyntax.Inspect(tree, func(n pcresyntax.Node) bool {
+		if what == "" {
+			what, offset = feature(pattern, n)
+		}
+		return what == ""
+	})
+
+	return what, offset
+}
+
+// feature returns the description and offset of n if the standard library
+// does not support it, or "".
+func feature(pattern string, n pcresyntax.Node) (string, int) {
+	switch n := n.(type) {
+	case *pcresyntax.Group:
+		return groupFeatures[n.Kind], n.Pos()
+	case *pcresyntax.Options:
+		if n.Reset {
+			return "option ^", n.Pos()
+		}
+		if i := strings.IndexAny(n.On+n.Off, "nxJ"); i >= 0 {
+			return "option " + string((n.On + n.Off)[i]), n.Pos()
+		}
+	case *pcresyntax.Repeat:
+		switch {
+		case n.Mode == pcresyntax.Possessive:
+			return "possessive quantifier", n.Sub.End()
+		case n.Min > 1000 || n.Max > 1000:
+			return "repeat count over 1000", n.Sub.End()
+		case !repeatValid(n, 1000) && repeatValid(n.Sub, 1000):
+			// The innermost repeat whose nested counts are too large, as
+			// regexp/syntax reports it.
+			return "nested repeat count over 1000", n.Sub.End()
+		}
+	case *pcresyntax.Backref:
+		return "back reference", n.Pos()
+	case *pcresyntax.Call:
+		if n.Number == 0 {
+			return "recursion", n.Pos()
+		}
+		return "subroutine call", n.Pos()
+	case *pcresyntax.Conditional:
+		return "conditional group", n.Pos()
+	case *pcresyntax.Verb:
+		return "(*" + n.Name + ") verb", n.Pos()
+	case *pcresyntax.StartOption:
+		return "(*" + n.Name + ") option", n.Pos()
+	case *pcresyntax.Callout:
+		return "callout", n.Pos()
+	case *pcresyntax.Comment:
+		return "comment", n.Pos()
+	case *pcresyntax.Assertion:
+		if strings.IndexByte("GKZ<>", n.Type) >= 0 {
+			return pattern[n.Pos():n.End()] + " assertion", n.Pos()
+		}
+	case *pcresyntax.CharType:
+		if strings.IndexByte("hHVRXN", n.Type) >= 0 {
+			return `\` + string(n.Type) + " escape", n.Pos()
+		}
+	case *pcresyntax.Literal:
+		if text := pattern[n.Pos():n.End()]; len(text) > 1 && text[0] == '\\' && strings.IndexByte("ceoN", text[1]) >= 0 {
+			return text[:2] + " escape", n.Pos()
+		}
+	}
+
+	return "", 0
+}
+
+// repeatValid reports whether the counts of the repeats nested in n
+// multiply to at most limit, as regexp/syntax requires.
+func repeatValid(n pcresyntax.Node, limit int) bool {
+	valid := true
+	pcresyntax.Inspect(n, func(n pcresyntax.Node) bool {
+		r, ok := n.(*pcresyntax.Repeat)
+		if !valid || !ok {
+			return valid
+		}
+
+		m := r.Max
+		switch {
+		case m == 0:
+			return false
+		case m < 0:
+			m = r.Min
+		}
+		if m > limit {
+			valid = false
+		} else if m > 1 {
+			valid = repeatValid(r.Sub, limit/m)
+		} else {
+			return true
+		}
+
+		return false
+	})
+
+	return valid
+}
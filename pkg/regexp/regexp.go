@@ -3,15 +3,28 @@
 //
 // This package automatically selects between using the standard library's
 // regexp engine and a PCRE-based engine (pcregexp package) based on the
-// features used in the regular expression. If the pattern contains PCRE-
-// specific constructs such as lookahead/lookbehind assertions or
-// backreferences, the PCRE engine is employed; otherwise, the standard library
-// implementation is used.
+// features used in the regular expression. Patterns are parsed by
+// regexp/syntax first: if the standard library rejects a pattern for using
+// PCRE-specific constructs such as lookahead/lookbehind assertions or
+// backreferences, the PCRE engine is employed; otherwise, the standard
+// library implementation is used. [Explain] and [Regexp.Reason] tell which
+// construct required PCRE, to audit the patterns that do not match in linear
+// time, and [CompileStd] and [CompilePCRE] force an engine.
+//
+// Patterns are compiled for the PCRE engine with [pcregexp.GoCompatible], so
+// that the syntax shared by both engines keeps the semantics of the standard
+// library: $ does not match before a final newline, subjects are UTF-8, and
+// \d, \w and \s only match ASCII characters. The differences left are listed
+// there.
 //
 // The [Regexp] type represents a compiled regular expression and wraps either a
 // standard [regexp.Regexp] or a [pcregexp.PCREgexp], exposing a unified API for
 // matching, searching, replacing, and more.
 //
+// The PCRE2 shared library is only needed, and loaded, once a pattern that
+// requires the PCRE engine is compiled. Patterns supported by the standard
+// library keep working on hosts where PCRE2 is not installed.
+//
 // Use this package when you require advanced regex features not supported by
 // the standard library's regexp package.
 package regexp
@@ -20,6 +33,7 @@ import (
 	"fmt"
 	"io"
 	"regexp"
+	"sync"
 
 	"github.com/dwisiswant0/pcregexp"
 )
@@ -29,6 +43,16 @@ type Regexp struct {
 	regexp   *regexp.Regexp
 	pcregexp *pcregexp.PCREgexp
 	pattern  string
+	reason   string
+	full     *fullRegexp // std engine only
+}
+
+// fullRegexp lazily compiles the pattern wrapped to match whole subjects,
+// for [Regexp.FullMatch] on the std engine.
+type fullRegexp struct {
+	once sync.Once
+	re   *regexp.Regexp
+	err  error // set if re is the leftmost-longest fallback
 }
 
 // IsPCRE reports whether the [Regexp] is a PCRE.
@@ -36,20 +60,65 @@ func (r *Regexp) IsPCRE() bool {
 	return r.pcregexp != nil
 }
 
+// Engine returns the engine the [Regexp] runs on.
+func (r *Regexp) Engine() Engine {
+	if r.pcregexp != nil {
+		return EnginePCRE
+	}
+	return EngineStd
+}
+
+// Reason returns why [Compile] selected the PCRE engine, as reported by
+// [Explain], such as "lookbehind at offset 5". It returns "forced" for a
+// [Regexp] compiled by [CompileStd] or [CompilePCRE], and "" for one
+// compiled for the std engine by [Compile].
+func (r *Regexp) Reason() string {
+	return r.reason
+}
+
+// Compile parses a regular expression and returns, if successful, a
+// [Regexp] running on the engine selected by [Explain].
 func Compile(pattern string) (*Regexp, error) {
-	if pcregexp.NeedsPCRE(pattern) {
-		pcre, err := pcregexp.Compile(pattern)
-		if err != nil {
-			return nil, err
-		}
-		return &Regexp{pattern: pattern, pcregexp: pcre}, nil
+	engine, reason, err := Explain(pattern)
+	if err != nil {
+		return nil, err
 	}
 
+	if engine == EngineStd {
+		return compileStd(pattern, reason)
+	}
+	return compilePCRE(pattern, reason)
+}
+
+// CompileStd is like [Compile], but always uses the std engine.
+func CompileStd(pattern string) (*Regexp, error) {
+	return compileStd(pattern, "forced")
+}
+
+// CompilePCRE is like [Compile], but always uses the PCRE engine, with the
+// [pcregexp.GoCompatible] options.
+func CompilePCRE(pattern string) (*Regexp, error) {
+	return compilePCRE(pattern, "forced")
+}
+
+func compileStd(pattern, reason string) (*Regexp, error) {
 	re, err := regexp.Compile(pattern)
 	if err != nil {
 		return nil, err
 	}
-	return &Regexp{pattern: pattern, regexp: re}, nil
+	return &Regexp{pattern: pattern, reason: reason, regexp: re, full: &fullRegexp{}}, nil
+}
+
+func compilePCRE(pattern, reason string) (*Regexp, error) {
+	if err := pcregexp.Init(); err != nil {
+		return nil, fmt.Errorf("regexp: pattern %q requires the PCRE engine (%s), which is unavailable: %w", pattern, reason, err)
+	}
+
+	pcre, err := pcregexp.CompileWithOptions(pattern, pcregexp.GoCompatible())
+	if err != nil {
+		return nil, err
+	}
+	return &Regexp{pattern: pattern, reason: reason, pcregexp: pcre}, nil
 }
 
 func MustCompile(pattern string) *Regexp {
@@ -69,6 +138,7 @@ func Match(pattern string, b []byte) (matched bool, err error) {
 	if err != nil {
 		return false, err
 	}
+	defer re.Close()
 	return re.Match(b), nil
 }
 
@@ -80,6 +150,7 @@ func MatchReader(pattern string, r io.RuneReader) (matched bool, err error) {
 	if err != nil {
 		return false, err
 	}
+	defer re.Close()
 	return re.MatchReader(r), nil
 }
 
@@ -91,6 +162,7 @@ func MatchString(pattern string, s string) (matched bool, err error) {
 	if err != nil {
 		return false, err
 	}
+	defer re.Close()
 	return re.MatchString(s), nil
 }
 
@@ -199,6 +271,48 @@ func (r *Regexp) MatchString(s string) bool {
 	return r.regexp.MatchString(s)
 }
 
+// FullMatch reports whether the regexp matches the whole of b.
+//
+// The PCRE engine matches with PCRE2_ANCHORED and PCRE2_ENDANCHORED, see
+// [pcregexp.PCREgexp.FullMatch]. The std engine uses the pattern wrapped in
+// \A(?:...)\z, compiled on first use.
+func (r *Regexp) FullMatch(b []byte) bool {
+	if r.pcregexp != nil {
+		return r.pcregexp.FullMatch(b)
+	}
+	return r.full.match(r.pattern, b)
+}
+
+// FullMatchString reports whether the regexp matches the whole of s, like
+// [Regexp.FullMatch].
+func (r *Regexp) FullMatchString(s string) bool {
+	if r.pcregexp != nil {
+		return r.pcregexp.FullMatchString(s)
+	}
+	return r.full.match(r.pattern, []byte(s))
+}
+
+// match reports whether pattern matches the whole of b.
+func (f *fullRegexp) match(pattern string, b []byte) bool {
+	f.once.Do(func() {
+		f.re, f.err = regexp.Compile(`\A(?:` + pattern + `)\z`)
+		if f.err != nil {
+			// The wrapper can only exceed a size limit the pattern alone
+			// did not. Fall back to a leftmost-longest copy of the pattern:
+			// if a full match exists, its match at 0 spans the subject.
+			f.re = regexp.MustCompile(pattern)
+			f.re.Longest()
+		}
+	})
+
+	if f.err != nil {
+		loc := f.re.FindIndex(b)
+		return loc != nil && loc[0] == 0 && loc[1] == len(b)
+	}
+
+	return f.re.Match(b)
+}
+
 func (r *Regexp) MatchReader(reader io.RuneReader) bool {
 	if r.pcregexp != nil {
 		return r.pcregexp.MatchReader(reader)
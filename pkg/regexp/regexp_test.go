@@ -2,9 +2,14 @@ package regexp
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"os/exec"
 	"testing"
+
+	"github.com/dwisiswant0/pcregexp"
 )
 
 func TestCompile(t *testing.T) {
@@ -290,3 +295,214 @@ func stringsEqual(a, b []string) bool {
 	}
 	return true
 }
+
+func TestCompile_WithoutPCRE(t *testing.T) {
+	if os.Getenv("PCREGEXP_TEST_NOLIB_CHILD") == "1" {
+		re, err := Compile(`p([a-z]+)ch`)
+		if err != nil {
+			t.Fatalf("Compile() error = %v for a std-compatible pattern", err)
+		}
+		if !re.MatchString("peach") {
+			t.Error("MatchString() = false, want true")
+		}
+
+		if ok, err := MatchString(`hello`, "hello world"); err != nil || !ok {
+			t.Errorf("MatchString() = %v, %v, want true, nil", ok, err)
+		}
+
+		if _, err := Compile(`[(?<]\(?=`); err != nil {
+			t.Errorf("Compile() error = %v for PCRE tokens in a class and escaped", err)
+		}
+
+		_, err = Compile(`foo(?=bar)`)
+		if err == nil {
+			t.Fatal("Compile() error = nil for a PCRE-only pattern")
+		}
+
+		var loadErr *pcregexp.LoadError
+		if !errors.As(err, &loadErr) {
+			t.Errorf("Compile() error = %v, want it to wrap *pcregexp.LoadError", err)
+		}
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestCompile_WithoutPCRE$", "-test.v")
+	cmd.Env = append(os.Environ(), "PCREGEXP_TEST_NOLIB_CHILD=1", pcregexp.EnvLibraryPath+"=/nonexistent/libpcre2-8.so")
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("child process failed: %v\n%s", err, out)
+	}
+}
+
+func TestRegexp_FullMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		input   string
+		want    bool
+	}{
+		{`\d+`, "12345", true},
+		{`\d+`, "123\n", false},
+		{`\d+`, "a123", false},
+		{`a|ab`, "ab", true},
+		{`(?i)hello`, "HELLO", true},
+		{`x*`, "", true},
+		{`(foo)\1`, "foofoo", true},
+		{`(foo)\1`, "foofoo\n", false},
+		{`foo(?=bar)`, "foo", false},
+		{`(?<=a)b|b`, "b", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			re := MustCompile(tt.pattern)
+			defer re.Close()
+
+			if got := re.FullMatchString(tt.input); got != tt.want {
+				t.Errorf("FullMatchString(%q) = %v, want %v (PCRE: %v)", tt.input, got, tt.want, re.IsPCRE())
+			}
+			if got := re.FullMatch([]byte(tt.input)); got != tt.want {
+				t.Errorf("FullMatch(%q) = %v, want %v (PCRE: %v)", tt.input, got, tt.want, re.IsPCRE())
+			}
+		})
+	}
+}
+
+func TestCompile_GoCompatible(t *testing.T) {
+	tests := []struct {
+		pattern string
+		input   string
+		want    []int
+	}{
+		{`(?=a)a$`, "a\n", nil},
+		{`(?<=x).`, "xé", []int{1, 3}},
+		{`(?<=x)\w+`, "xhéllo", []int{1, 2}},
+		{`(?m)(?<=\n)^`, "a\n", []int{2, 2}},
+	}
+
+	for _, tt := range tests {
+		re := MustCompile(tt.pattern)
+		if !re.IsPCRE() {
+			t.Fatalf("Compile(%q) uses the std engine", tt.pattern)
+		}
+
+		if got := re.FindStringIndex(tt.input); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("Compile(%q).FindStringIndex(%q) = %v, want %v", tt.pattern, tt.input, got, tt.want)
+		}
+		re.Close()
+	}
+}
+
+func TestExplain(t *testing.T) {
+	tests := []struct {
+		pattern string
+		engine  Engine
+		reason  string
+		wantErr bool
+	}{
+		{`p([a-z]+)ch`, EngineStd, "", false},
+		{`\(?=x`, EngineStd, "", false},
+		{`[(?<]+`, EngineStd, "", false},
+		{`\Q(?<=\E`, EngineStd, "", false},
+		{`(?P<name>a)(?<other>b)`, EngineStd, "", false},
+		{`x{2,1}`, 0, "", true},
+		{`[`, 0, "", true},
+		{`ab(?<=c)`, EnginePCRE, "lookbehind at offset 2", false},
+		{`foo(?=bar)`, EnginePCRE, "lookahead at offset 3", false},
+		{`[(](?!x)`, EnginePCRE, "negative lookahead at offset 3", false},
+		{`(foo)\1`, EnginePCRE, "back reference at offset 5", false},
+		{`(?>abc)`, EnginePCRE, "atomic group at offset 0", false},
+		{`(?R)`, EnginePCRE, "recursion at offset 0", false},
+		{`(a)(?1)`, EnginePCRE, "subroutine call at offset 3", false},
+		{`ab++`, EnginePCRE, "possessive quantifier at offset 2", false},
+		{`a{1001}`, EnginePCRE, "repeat count over 1000 at offset 1", false},
+		{`a(*SKIP)(*F)|b`, EnginePCRE, "(*SKIP) verb at offset 1", false},
+		{`(*UTF)a`, EnginePCRE, "(*UTF) option at offset 0", false},
+		{`(?x) a b`, EnginePCRE, "option x at offset 0", false},
+		{`a\hb`, EnginePCRE, `\h escape at offset 1`, false},
+		{`a\Z`, EnginePCRE, `\Z assertion at offset 1`, false},
+		{`\e`, EnginePCRE, `\e escape at offset 0`, false},
+		{`(?'n'a)`, EnginePCRE, "invalid or unsupported Perl syntax `(?'` at offset 0", false},
+		{`(?'n'a)(?'m'b)`, EnginePCRE, "invalid or unsupported Perl syntax `(?'`", false},
+		{`((a{100}){100}){100}`, EnginePCRE, "nested repeat count over 1000 at offset 9", false},
+		{`(a{2}b*){600}`, EnginePCRE, "nested repeat count over 1000 at offset 8", false},
+		{`(a{0}){1000}`, EngineStd, "", false},
+		{"a\xffb", EngineStd, "", true},
+		{`(?<=a`, 0, "", true},
+		{`(?(1)a|b)`, 0, "", true},
+	}
+
+	for _, tt := range tests {
+		engine, reason, err := Explain(tt.pattern)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Explain(%q) error = %v, wantErr %v", tt.pattern, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if engine != tt.engine || reason != tt.reason {
+			t.Errorf("Explain(%q) = %v, %q, want %v, %q", tt.pattern, engine, reason, tt.engine, tt.reason)
+		}
+	}
+}
+
+func TestCompile_Reason(t *testing.T) {
+	re := MustCompile(`(?<=\$)\d+`)
+	defer re.Close()
+
+	if re.Engine() != EnginePCRE || !re.IsPCRE() {
+		t.Fatalf("Engine() = %v, want pcre", re.Engine())
+	}
+	if got, want := re.Reason(), "lookbehind at offset 0"; got != want {
+		t.Errorf("Reason() = %q, want %q", got, want)
+	}
+
+	std := MustCompile(`\(?=\d+`)
+	if std.Engine() != EngineStd || std.Reason() != "" {
+		t.Errorf("Engine(), Reason() = %v, %q, want std, \"\"", std.Engine(), std.Reason())
+	}
+	if got := std.FindString("(=12"); got != "(=12" {
+		t.Errorf("FindString() = %q, want %q", got, "(=12")
+	}
+}
+
+func TestCompileStd(t *testing.T) {
+	re, err := CompileStd(`a+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if re.Engine() != EngineStd || re.Reason() != "forced" {
+		t.Errorf("Engine(), Reason() = %v, %q, want std, forced", re.Engine(), re.Reason())
+	}
+
+	if _, err := CompileStd(`(?=a)`); err == nil {
+		t.Error("CompileStd() error = nil for a PCRE-only pattern")
+	}
+}
+
+func TestCompilePCRE(t *testing.T) {
+	re, err := CompilePCRE(`a+$`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer re.Close()
+
+	if re.Engine() != EnginePCRE || re.Reason() != "forced" {
+		t.Errorf("Engine(), Reason() = %v, %q, want pcre, forced", re.Engine(), re.Reason())
+	}
+	if re.MatchString("aa\n") {
+		t.Error("MatchString() = true, want $ not to match before a final newline")
+	}
+
+	if _, err := CompilePCRE(`(?<=a+)b`); err == nil {
+		t.Error("CompilePCRE() error = nil for an unbounded lookbehind")
+	}
+}
+
+func TestEngine_String(t *testing.T) {
+	for e, want := range map[Engine]string{EngineStd: "std", EnginePCRE: "pcre", 7: "Engine(7)"} {
+		if got := e.String(); got != want {
+			t.Errorf("Engine(%d).String() = %q, want %q", int(e), got, want)
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package pcregexp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+const (
+	// replacingReadSize is the size of the chunks a ReplacingReader reads
+	// from its source.
+	replacingReadSize = 32 * 1024
+
+	// replacingMaxPending is the most input a ReplacingReader or a
+	// ReplacingWriter holds back waiting for a partial match to complete.
+	// Past that, the match is decided on the input at hand, so memory stays
+	// bounded.
+	replacingMaxPending = 64 * 1024
+
+	// replacingHistory is how much input already replaced a ReplacingReader
+	// or a ReplacingWriter keeps for lookbehind assertions and \b.
+	replacingHistory = 256
+)
+
+// Replacer computes the replacement of a match for a [ReplacingReader].
+type Replacer interface {
+	// AppendReplacement appends the replacement of the match of re in src
+	// to dst and returns the result. match holds the index pairs of the
+	// match and its submatches, as returned by [PCREgexp.FindSubmatchIndex].
+	AppendReplacement(dst []byte, re *PCREgexp, src []byte, match []int) []byte
+}
+
+// TemplateReplacer returns a Replacer expanding template like
+// [PCREgexp.Expand], so that $1 or ${name} stand for submatches.
+func TemplateReplacer(template string) Replacer {
+	return templateReplacer(template)
+}
+
+type templateReplacer string
+
+func (t templateReplacer) AppendReplacement(dst []byte, re *PCREgexp, src []byte, match []int) []byte {
+	return re.expand(dst, string(t), src, match, false)
+}
+
+// LiteralReplacer returns a Replacer substituting repl literally.
+func LiteralReplacer(repl string) Replacer {
+	return literalReplacer(repl)
+}
+
+type literalReplacer string
+
+func (l literalReplacer) AppendReplacement(dst []byte, _ *PCREgexp, _ []byte, _ []int) []byte {
+	return append(dst, l...)
+}
+
+// ReplacerFunc is a Replacer calling a function with the submatches of every
+// match, as returned by [PCREgexp.FindSubmatch], and substituting its result.
+// Submatches that did not take part in the match are nil. The submatches are
+// only valid during the call.
+type ReplacerFunc func(submatches [][]byte) []byte
+
+// AppendReplacement implements [Replacer].
+func (f ReplacerFunc) AppendReplacement(dst []byte, re *PCREgexp, src []byte, match []int) []byte {
+	// match ends at the last group that took part in the match.
+	submatches := make([][]byte, re.NumSubexp()+1)
+	for i := range submatches {
+		if 2*i+1 < len(match) && match[2*i] >= 0 {
+			submatches[i] = src[match[2*i]:match[2*i+1]]
+		}
+	}
+
+	return append(dst, f(submatches)...)
+}
+
+// streamReplacer replaces the matches of a regexp in text that arrives in
+// chunks. It is the engine shared by ReplacingReader and ReplacingWriter.
+type streamReplacer struct {
+	re   *PCREgexp
+	repl Replacer
+
+	buf   []byte // kept history followed by pending input
+	start int    // start of the pending input in buf
+	bol   bool   // whether buf starts at the beginning of the input
+	count int
+
+	diff *lineDiff
+}
+
+// ReplacingReader is an [io.Reader] replacing the matches of a regexp in the
+// text read from a source, for rewriting large inputs on the fly. See
+// [NewReplacingReader].
+type ReplacingReader struct {
+	src   io.Reader
+	s     streamReplacer
+	chunk []byte
+	out   []byte // replaced text, read up to off
+	off   int
+	err   error // error of the source, returned once out is drained
+}
+
+// NewReplacingReader returns a ReplacingReader reading from r and replacing
+// the successive, non-overlapping matches of re, as found by
+// [PCREgexp.ReplaceAllFunc], with what repl computes.
+//
+// The input is matched as it streams, in bounded memory. Matches are not
+// split at the boundaries of the chunks read from r: the text at the end of
+// a chunk that may start a match, or extend one, is held back, using
+// [MatchPartialHard], until more input arrives. A match that would span
+// more than 64 KiB of input held back is decided on the input at hand,
+// and lookbehind assertions see at most 256 bytes before the text being
+// matched; within those limits, the result is that of replacing all the
+// matches at once.
+//
+// re must not be used concurrently while the ReplacingReader is read.
+func NewReplacingReader(r io.Reader, re *PCREgexp, repl Replacer) *ReplacingReader {
+	return &ReplacingReader{src: r, s: streamReplacer{re: re, repl: repl, bol: true}}
+}
+
+// SetDiff makes rr write a line-level diff of its changes to w as it is
+// read: every run of input lines touched by a replacement is written in the
+// unified diff style, as a "@@ -n +m @@" header with the line numbers in
+// the input and output, the input lines prefixed by "-", and the output
+// lines prefixed by "+". It must be called before the first Read.
+func (rr *ReplacingReader) SetDiff(w io.Writer) {
+	rr.s.diff = newLineDiff(w)
+}
+
+// Count returns the number of replacements made so far.
+func (rr *ReplacingReader) Count() int {
+	return rr.s.count
+}
+
+// Read reads replaced text into p.
+func (rr *ReplacingReader) Read(p []byte) (int, error) {
+	for rr.off == len(rr.out) {
+		if rr.err != nil {
+			return 0, rr.err
+		}
+
+		if rr.chunk == nil {
+			rr.chunk = make([]byte, replacingReadSize)
+		}
+
+		n, err := rr.src.Read(rr.chunk)
+		rr.out, rr.off = rr.s.process(rr.out[:0], rr.chunk[:n], err != nil), 0
+		rr.err = err
+
+		if err != nil && rr.s.diff != nil {
+			if derr := rr.s.diff.close(); derr != nil && err == io.EOF {
+				rr.err = derr
+			}
+		}
+	}
+
+	n := copy(p, rr.out[rr.off:])
+	rr.off += n
+
+	return n, nil
+}
+
+// errWriterClosed is returned when writing to a closed ReplacingWriter.
+var errWriterClosed = errors.New("pcregexp: write to closed ReplacingWriter")
+
+// ReplacingWriter is an [io.WriteCloser] replacing the matches of a regexp in
+// the text written to it before passing it on. See [NewReplacingWriter].
+type ReplacingWriter struct {
+	w      io.Writer
+	s      streamReplacer
+	out    []byte
+	closed bool
+}
+
+// NewReplacingWriter returns a ReplacingWriter replacing the matches of re in
+// what is written to it with what repl computes, and writing the result to
+// w. The matches are those [NewReplacingReader] finds: the text at the end
+// of a write that may start or extend a match is held back until the next
+// write or [ReplacingWriter.Close], which must be called to flush it.
+//
+// re must not be used concurrently while the ReplacingWriter is written to.
+func NewReplacingWriter(w io.Writer, re *PCREgexp, repl Replacer) *ReplacingWriter {
+	return &ReplacingWriter{w: w, s: streamReplacer{re: re, repl: repl, bol: true}}
+}
+
+// SetDiff makes rw write a line-level diff of its changes to w, like
+// [ReplacingReader.SetDiff]. It must be called before the first Write.
+func (rw *ReplacingWriter) SetDiff(w io.Writer) {
+	rw.s.diff = newLineDiff(w)
+}
+
+// Count returns the number of replacements made so far.
+func (rw *ReplacingWriter) Count() int {
+	return rw.s.count
+}
+
+// Write replaces the matches in p and writes what can be decided to the
+// underlying writer. It returns len(p) unless the underlying writer fails.
+func (rw *ReplacingWriter) Write(p []byte) (int, error) {
+	if rw.closed {
+		return 0, errWriterClosed
+	}
+
+	rw.out = rw.s.process(rw.out[:0], p, false)
+	if len(rw.out) > 0 {
+		if _, err := rw.w.Write(rw.out); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Close writes the text held back, if any, and the end of the diff. It does
+// not close the underlying writer.
+func (rw *ReplacingWriter) Close() error {
+	if rw.closed {
+		return errWriterClosed
+	}
+	rw.closed = true
+
+	var err error
+	if rw.out = rw.s.process(rw.out[:0], nil, true); len(rw.out) > 0 {
+		_, err = rw.w.Write(rw.out)
+	}
+
+	if rw.s.diff != nil {
+		if derr := rw.s.diff.close(); err == nil {
+			err = derr
+		}
+	}
+
+	return err
+}
+
+// process appends the replaced form of p to out. Unless final is set, the
+// end of the input that may start or extend a match is held back.
+func (s *streamReplacer) process(out, p []byte, final bool) []byte {
+	s.buf = append(s.buf, p...)
+
+	var flags MatchFlag
+	if !s.bol {
+		flags |= MatchNotBOL
+	}
+
+	subject := s.buf
+	if !final {
+		flags |= MatchPartialHard
+		subject = s.buf[:fullRunes(s.buf)]
+	}
+
+	pos, keep := s.start, len(subject)
+	for pos <= len(subject) {
+		loc, partial := s.re.execPartial(subject, pos, flags)
+
+		if partial && len(subject)-loc[0] > replacingMaxPending {
+			// Decide on the input at hand rather than grow without bound.
+			loc, partial = s.re.execPartial(subject, pos, flags&^MatchPartialHard)
+		}
+
+		if loc == nil {
+			break
+		}
+
+		if partial {
+			keep = loc[0]
+			break
+		}
+
+		start, end := loc[0], loc[1]
+		if start == end && !final && end == len(subject) {
+			// An empty match must not be decided before the text after it,
+			// which it is stepped over, has been read.
+			keep = start
+			break
+		}
+
+		out = s.emit(out, s.buf[pos:start])
+
+		before := len(out)
+		out = s.repl.AppendReplacement(out, s.re, s.buf, loc)
+		s.count++
+		if s.diff != nil {
+			s.diff.replace(s.buf[start:end], out[before:])
+		}
+
+		pos = end
+		if start == end {
+			// Step over one UTF-8 sequence, as ReplaceAllFunc does.
+			if pos == len(subject) {
+				break
+			}
+
+			_, size := utf8.DecodeRune(subject[pos:])
+			out = s.emit(out, subject[pos:pos+size])
+			pos += size
+		}
+	}
+
+	if pos < keep {
+		out = s.emit(out, s.buf[pos:keep])
+		pos = keep
+	}
+
+	// Keep some history before the pending input.
+	h := pos - replacingHistory
+	if h < 0 {
+		h = 0
+	}
+	if h > 0 {
+		s.buf = s.buf[:copy(s.buf, s.buf[h:])]
+		s.bol = false
+	}
+	s.start = pos - h
+
+	return out
+}
+
+// emit appends unchanged text to out.
+func (s *streamReplacer) emit(out, text []byte) []byte {
+	if s.diff != nil {
+		s.diff.keep(text)
+	}
+
+	return append(out, text...)
+}
+
+// lineDiff builds a line-level diff from the text kept and replaced by a
+// streamReplacer.
+type lineDiff struct {
+	w       io.Writer
+	err     error
+	inLine  int          // number of the first line in in
+	outLine int          // number of the first line in out
+	in, out bytes.Buffer // text of the current lines
+	changed bool         // whether in and out differ
+}
+
+// newLineDiff returns a lineDiff writing to w.
+func newLineDiff(w io.Writer) *lineDiff {
+	return &lineDiff{w: w, inLine: 1, outLine: 1}
+}
+
+// keep adds unchanged text.
+func (d *lineDiff) keep(text []byte) {
+	for len(text) > 0 {
+		i := bytes.IndexByte(text, '\n')
+		if i < 0 {
+			d.in.Write(text)
+			d.out.Write(text)
+			return
+		}
+
+		d.in.Write(text[:i+1])
+		d.out.Write(text[:i+1])
+		d.flush()
+		text = text[i+1:]
+	}
+}
+
+// replace adds a replacement of old by new.
+func (d *lineDiff) replace(old, new []byte) {
+	d.in.Write(old)
+	d.out.Write(new)
+	d.changed = d.changed || !bytes.Equal(old, new)
+}
+
+// flush writes the current lines if they changed, and starts new ones.
+func (d *lineDiff) flush() {
+	if d.changed && d.err == nil {
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "@@ -%d +%d @@\n", d.inLine, d.outLine)
+		writeLines(&b, '-', d.in.Bytes())
+		writeLines(&b, '+', d.out.Bytes())
+		_, d.err = d.w.Write(b.Bytes())
+	}
+
+	d.inLine += bytes.Count(d.in.Bytes(), []byte{'\n'})
+	d.outLine += bytes.Count(d.out.Bytes(), []byte{'\n'})
+	d.in.Reset()
+	d.out.Reset()
+	d.changed = false
+}
+
+// close flushes the last line and returns the first write error.
+func (d *lineDiff) close() error {
+	d.flush()
+	return d.err
+}
+
+// writeLines writes the lines of text to b, each prefixed with prefix and
+// terminated by a newline.
+func writeLines(b *bytes.Buffer, prefix byte, text []byte) {
+	for len(text) > 0 {
+		line := text
+		if i := bytes.IndexByte(text, '\n'); i >= 0 {
+			line = text[:i]
+			text = text[i+1:]
+		} else {
+			text = nil
+		}
+
+		b.WriteByte(prefix)
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+}
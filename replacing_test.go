@@ -0,0 +1,235 @@
+package pcregexp_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+// readReplacing reads all of text through a ReplacingReader, with the source
+// returning one byte at a time if oneByte is set.
+func readReplacing(t *testing.T, re *pcregexp.PCREgexp, repl pcregexp.Replacer, text string, oneByte bool) (string, *pcregexp.ReplacingReader) {
+	t.Helper()
+
+	var src io.Reader = strings.NewReader(text)
+	if oneByte {
+		src = iotest.OneByteReader(src)
+	}
+
+	rr := pcregexp.NewReplacingReader(src, re, repl)
+	got, err := io.ReadAll(rr)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+
+	return string(got), rr
+}
+
+// expandAll replaces all the matches of re in text with the expansion of
+// template, and returns the result and the number of matches.
+func expandAll(re *pcregexp.PCREgexp, text, template string) (string, int) {
+	var (
+		dst  []byte
+		last int
+	)
+
+	matches := re.FindAllStringSubmatchIndex(text, -1)
+	for _, m := range matches {
+		dst = append(dst, text[last:m[0]]...)
+		dst = re.ExpandString(dst, template, text, m)
+		last = m[1]
+	}
+
+	return string(append(dst, text[last:]...)), len(matches)
+}
+
+func TestReplacingReader(t *testing.T) {
+	tests := []struct {
+		pattern string
+		repl    string
+		text    string
+	}{
+		{`(?<user>\w+)@(?<host>[\w.]+)`, "${host}/${user}", "mail ada@example.com, bob@test.org and carol@host."},
+		{`a+`, "<$0>", "baaac aa a"},
+		{`x*`, "-", "abc"},
+		{`\b`, "|", "héllo wörld"},
+		{`(?m)^`, "> ", "one\ntwo\n\nthree"},
+		{`(?m)$`, ";", "one\ntwo\n"},
+		{`(?<=ab)c`, "C", "abcabc"},
+		{`foo(bar)?`, "[$1]", "foo foobar fo"},
+		{`é`, "e", "ééé"},
+		{`(*UTF)\p{Lu}\p{Ll}*`, "[$0]", "Élan and Öl"},
+	}
+
+	for _, tt := range tests {
+		re := pcregexp.MustCompile(tt.pattern)
+		want, wantCount := expandAll(re, tt.text, tt.repl)
+
+		for _, oneByte := range []bool{false, true} {
+			got, rr := readReplacing(t, re, pcregexp.TemplateReplacer(tt.repl), tt.text, oneByte)
+			if got != want {
+				t.Errorf("%q on %q (one byte: %v) = %q, want %q", tt.pattern, tt.text, oneByte, got, want)
+			}
+
+			if rr.Count() != wantCount {
+				t.Errorf("%q on %q (one byte: %v): Count() = %d, want %d", tt.pattern, tt.text, oneByte, rr.Count(), wantCount)
+			}
+		}
+
+		re.Close()
+	}
+}
+
+func TestReplacingWriter(t *testing.T) {
+	tests := []struct {
+		pattern string
+		repl    string
+		text    string
+	}{
+		{`(?<user>\w+)@(?<host>[\w.]+)`, "${host}/${user}", "mail ada@example.com, bob@test.org and carol@host."},
+		{`x*`, "-", "abc"},
+		{`(?m)^`, "> ", "one\ntwo\n\nthree"},
+		{`(?<=ab)c`, "C", "abcabc"},
+		{`(*UTF)\p{Lu}\p{Ll}*`, "[$0]", "Élan and Öl"},
+	}
+
+	for _, tt := range tests {
+		re := pcregexp.MustCompile(tt.pattern)
+		want, wantCount := expandAll(re, tt.text, tt.repl)
+
+		for _, size := range []int{1, 3, len(tt.text)} {
+			var out bytes.Buffer
+			rw := pcregexp.NewReplacingWriter(&out, re, pcregexp.TemplateReplacer(tt.repl))
+
+			for text := tt.text; text != ""; {
+				n := size
+				if n > len(text) {
+					n = len(text)
+				}
+
+				if _, err := rw.Write([]byte(text[:n])); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+				text = text[n:]
+			}
+
+			if err := rw.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			if got := out.String(); got != want {
+				t.Errorf("%q on %q (writes of %d bytes) = %q, want %q", tt.pattern, tt.text, size, got, want)
+			}
+			if rw.Count() != wantCount {
+				t.Errorf("%q on %q (writes of %d bytes): Count() = %d, want %d", tt.pattern, tt.text, size, rw.Count(), wantCount)
+			}
+
+			if _, err := rw.Write([]byte("x")); err == nil {
+				t.Error("Write() after Close returned no error")
+			}
+		}
+
+		re.Close()
+	}
+}
+
+func TestReplacingReader_Replacers(t *testing.T) {
+	re := pcregexp.MustCompile(`(\d+)(?:\.(\d+))?`)
+	defer re.Close()
+
+	text := "v1.2 and 10"
+
+	got, _ := readReplacing(t, re, pcregexp.LiteralReplacer("$1"), text, true)
+	if want := "v$1 and $1"; got != want {
+		t.Errorf("LiteralReplacer: output = %q, want %q", got, want)
+	}
+
+	var unset int
+	f := pcregexp.ReplacerFunc(func(m [][]byte) []byte {
+		if len(m) != 3 {
+			t.Fatalf("ReplacerFunc called with %d submatches, want 3", len(m))
+		}
+		if m[2] == nil {
+			unset++
+		}
+
+		return bytes.ToUpper(append([]byte("#"), m[1]...))
+	})
+
+	got, rr := readReplacing(t, re, f, text, true)
+	if want := "v#1 and #10"; got != want {
+		t.Errorf("ReplacerFunc: output = %q, want %q", got, want)
+	}
+
+	if unset != 1 || rr.Count() != 2 {
+		t.Errorf("ReplacerFunc: %d unset submatches and Count() = %d, want 1 and 2", unset, rr.Count())
+	}
+}
+
+func TestReplacingReader_Diff(t *testing.T) {
+	re := pcregexp.MustCompile(`colou?r`)
+	defer re.Close()
+
+	text := "red\nthe colour\nof\nthe colour\nand color\nblue"
+
+	var diff bytes.Buffer
+	rr := pcregexp.NewReplacingReader(iotest.OneByteReader(strings.NewReader(text)), re, pcregexp.TemplateReplacer("hue\nshade"))
+	rr.SetDiff(&diff)
+
+	if _, err := io.ReadAll(rr); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `@@ -2 +2 @@
+-the colour
++the hue
++shade
+@@ -4 +5 @@
+-the colour
++the hue
++shade
+@@ -5 +7 @@
+-and color
++and hue
++shade
+`
+	if diff.String() != want {
+		t.Errorf("diff =\n%s\nwant\n%s", diff.String(), want)
+	}
+}
+
+func TestReplacingReader_Long(t *testing.T) {
+	re := pcregexp.MustCompile(`BEGIN(?s:.*?)END|x+`)
+	defer re.Close()
+
+	// A run of x longer than a read is replaced whole; an unterminated block
+	// is decided once it exceeds the bound on pending input.
+	text := "a " + strings.Repeat("x", 40*1024) + " b BEGIN " + strings.Repeat("y", 100*1024)
+
+	got, rr := readReplacing(t, re, pcregexp.LiteralReplacer("X"), text, false)
+	if want := "a X b BEGIN " + strings.Repeat("y", 100*1024); got != want {
+		t.Errorf("output of %d bytes differs from the expected %d", len(got), len(want))
+	}
+
+	if rr.Count() != 1 {
+		t.Errorf("Count() = %d, want 1", rr.Count())
+	}
+}
+
+func TestReplacingReader_Error(t *testing.T) {
+	re := pcregexp.MustCompile(`b+`)
+	defer re.Close()
+
+	errSource := errors.New("source failed")
+	src := io.MultiReader(strings.NewReader("abb"), iotest.ErrReader(errSource))
+
+	got, err := io.ReadAll(pcregexp.NewReplacingReader(src, re, pcregexp.LiteralReplacer("B")))
+	if !errors.Is(err, errSource) || string(got) != "aB" {
+		t.Errorf("ReadAll() = %q, %v, want %q and the source error", got, err, "aB")
+	}
+}
@@ -0,0 +1,114 @@
+package pcregexp
+
+import (
+	"bufio"
+	"unicode/utf8"
+)
+
+// SplitOn returns a [bufio.SplitFunc] for a [bufio.Scanner] returning the text
+// between the matches of re, which delimit records, like [PCREgexp.Split].
+// With a zero-width delimiter, such as `(?m)^(?=\d{4}-\d\d-\d\d )`, records
+// are split before the delimiter: multi-line stack traces following a
+// timestamped line are kept with it.
+//
+// A delimiter that may span the end of the data buffered by the scanner,
+// found with [MatchPartialHard], makes the scanner read more data before it
+// is decided. The last record ends at EOF; it is not returned if empty, so
+// input ending with a delimiter has no trailing empty record. An empty
+// delimiter at the start of a record does not split it.
+//
+// Every call matches the data buffered from the current record on, as if it
+// were the start of the input: ^, \A and lookbehind assertions do not see
+// the text before it. re must not be used concurrently while scanning.
+func SplitOn(re *PCREgexp) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+
+		subject, flags := data, MatchPartialHard
+		if atEOF {
+			flags = 0
+		} else {
+			subject = data[:fullRunes(data)]
+		}
+
+		for off := 0; off <= len(subject); {
+			loc, partial := re.execPartial(subject, off, flags)
+			if loc == nil {
+				break
+			}
+
+			start, end := loc[0], loc[1]
+			if partial || !atEOF && end == len(subject) && start == end {
+				// The delimiter is not complete yet.
+				return 0, nil, nil
+			}
+
+			if end == 0 {
+				// Do not split before the first rune of the record.
+				_, size := utf8.DecodeRune(subject)
+				off = size
+				continue
+			}
+
+			return end, data[:start], nil
+		}
+
+		if atEOF {
+			return len(data), data, nil
+		}
+
+		return 0, nil, nil
+	}
+}
+
+// ScanMatches returns a [bufio.SplitFunc] for a [bufio.Scanner] returning the
+// successive, non-overlapping matches of re, skipping the text between them
+// and any empty matches.
+//
+// A match that may span the end of the data buffered by the scanner, found
+// with [MatchPartialHard], makes the scanner read more data before it is
+// decided, so that matches are never cut short. Text that cannot be part of
+// a match is discarded without waiting for more data.
+//
+// Every call matches the data buffered from the end of the last match on, as
+// if it were the start of the input: ^, \A and lookbehind assertions do not
+// see the text before it. re must not be used concurrently while scanning.
+func ScanMatches(re *PCREgexp) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		subject, flags := data, MatchPartialHard
+		if atEOF {
+			flags = 0
+		} else {
+			subject = data[:fullRunes(data)]
+		}
+
+		for off := 0; off <= len(subject); {
+			loc, partial := re.execPartial(subject, off, flags)
+			if loc == nil {
+				break
+			}
+
+			start, end := loc[0], loc[1]
+			if partial || !atEOF && start == end && end == len(subject) {
+				// The match is not complete yet; drop the text before it.
+				return start, nil, nil
+			}
+
+			if start == end {
+				if end == len(subject) {
+					break
+				}
+
+				_, size := utf8.DecodeRune(subject[end:])
+				off = end + size
+				continue
+			}
+
+			return end, data[start:end], nil
+		}
+
+		return len(subject), nil, nil
+	}
+}
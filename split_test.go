@@ -0,0 +1,112 @@
+package pcregexp_test
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+// scanAll scans text with split, reading it one byte at a time if oneByte is
+// set, and returns the tokens.
+func scanAll(t *testing.T, text string, split bufio.SplitFunc, oneByte bool) []string {
+	t.Helper()
+
+	var r io.Reader = strings.NewReader(text)
+	if oneByte {
+		r = iotest.OneByteReader(r)
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 4), 1024)
+	sc.Split(split)
+
+	var tokens []string
+	for sc.Scan() {
+		tokens = append(tokens, sc.Text())
+	}
+
+	if err := sc.Err(); err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	return tokens
+}
+
+func TestSplitOn(t *testing.T) {
+	tests := []struct {
+		pattern string
+		text    string
+		want    []string
+	}{
+		{`\s*,\s*`, "a , b,c", []string{"a", "b", "c"}},
+		{`\s*,\s*`, "a,b,", []string{"a", "b"}},
+		{`\s*,\s*`, ",a", []string{"", "a"}},
+		{`\s*,\s*`, "abc", []string{"abc"}},
+		{`--END--\n`, "one--END--\ntwo--EN--three--END--\n", []string{"one", "two--EN--three"}},
+		{`\r?\n`, "a\r\nb\nc", []string{"a", "b", "c"}},
+		{
+			`(?m)^(?=\d{4}-\d\d-\d\d )`,
+			"2024-05-01 panic: boom\n\tat main.go:1\n\tat x.go:2\n2024-05-01 ok\n2024-5 not a date\n",
+			[]string{"2024-05-01 panic: boom\n\tat main.go:1\n\tat x.go:2\n", "2024-05-01 ok\n2024-5 not a date\n"},
+		},
+		{`(?=é)`, "aébé", []string{"a", "éb", "é"}},
+	}
+
+	for _, tt := range tests {
+		re := pcregexp.MustCompile(tt.pattern)
+
+		for _, oneByte := range []bool{false, true} {
+			if got := scanAll(t, tt.text, pcregexp.SplitOn(re), oneByte); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitOn(%q) on %q (one byte: %v) = %q, want %q", tt.pattern, tt.text, oneByte, got, tt.want)
+			}
+		}
+
+		re.Close()
+	}
+}
+
+func TestScanMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		text    string
+		want    []string
+	}{
+		{`\d+`, "a1 b22 c333", []string{"1", "22", "333"}},
+		{`\d*`, "a1b22", []string{"1", "22"}},
+		{`"(?:[^"\\]|\\.)*"`, `say "hi \"there\"" and "bye"`, []string{`"hi \"there\""`, `"bye"`}},
+		{`foobar|foo`, "foobar foo foob", []string{"foobar", "foo", "foo"}},
+		{`x`, "none", nil},
+		{`(*UTF)\p{L}+`, "héllo wörld", []string{"héllo", "wörld"}},
+	}
+
+	for _, tt := range tests {
+		re := pcregexp.MustCompile(tt.pattern)
+
+		for _, oneByte := range []bool{false, true} {
+			if got := scanAll(t, tt.text, pcregexp.ScanMatches(re), oneByte); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ScanMatches(%q) on %q (one byte: %v) = %q, want %q", tt.pattern, tt.text, oneByte, got, tt.want)
+			}
+		}
+
+		re.Close()
+	}
+}
+
+func TestScanMatches_SkipsText(t *testing.T) {
+	// Text that cannot match is discarded, so the scanner does not need a
+	// buffer holding all of it.
+	re := pcregexp.MustCompile(`KEY=\w+`)
+	defer re.Close()
+
+	text := strings.Repeat("filler ", 1000) + "KEY=abc " + strings.Repeat("more ", 1000)
+
+	got := scanAll(t, text, pcregexp.ScanMatches(re), false)
+	if want := []string{"KEY=abc"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ScanMatches() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,200 @@
+package pcregexp
+
+import (
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Statistics reports the PCRE2 resources held by the package, to confirm
+// that compiled patterns are actually freed.
+type Statistics struct {
+	// LiveRegexps is the number of compiled patterns not freed yet.
+	LiveRegexps int64
+
+	// LiveMatchData is the number of match data objects not freed yet.
+	LiveMatchData int64
+
+	// LiveJITStacks is the number of JIT stacks not freed yet.
+	LiveJITStacks int64
+
+	// LiveMatchContexts is the number of match contexts not freed yet.
+	LiveMatchContexts int64
+
+	// CompiledBytes is the memory used by the live compiled patterns, as
+	// reported by PCRE2_INFO_SIZE plus PCRE2_INFO_JITSIZE.
+	CompiledBytes int64
+
+	// Compiles is the cumulative number of compiled patterns.
+	Compiles uint64
+
+	// Frees is the cumulative number of freed patterns.
+	Frees uint64
+
+	// Finalized is the cumulative number of patterns freed by the garbage
+	// collector because they were never closed. It is included in Frees.
+	Finalized uint64
+}
+
+var (
+	liveRegexps       atomic.Int64
+	liveMatchData     atomic.Int64
+	liveJITStacks     atomic.Int64
+	liveMatchContexts atomic.Int64
+	compiledBytes     atomic.Int64
+	totalCompiles     atomic.Uint64
+	totalFrees        atomic.Uint64
+	totalFinalized    atomic.Uint64
+)
+
+// Stats returns a snapshot of the PCRE2 resource counters.
+func Stats() Statistics {
+	return Statistics{
+		LiveRegexps:       liveRegexps.Load(),
+		LiveMatchData:     liveMatchData.Load(),
+		LiveJITStacks:     liveJITStacks.Load(),
+		LiveMatchContexts: liveMatchContexts.Load(),
+		CompiledBytes:     compiledBytes.Load(),
+		Compiles:          totalCompiles.Load(),
+		Frees:             totalFrees.Load(),
+		Finalized:         totalFinalized.Load(),
+	}
+}
+
+// Leak describes a compiled pattern that has not been closed.
+type Leak struct {
+	// Pattern is the source text of the pattern.
+	Pattern string
+
+	// Stack is the formatted call stack of the [Compile] call that created
+	// the pattern, starting at its caller outside of this package.
+	Stack string
+
+	// Finalized reports whether the garbage collector has since freed the
+	// pattern, which was never closed.
+	Finalized bool
+}
+
+var (
+	// leakTracking reports whether creation stacks are being recorded.
+	leakTracking atomic.Bool
+
+	// leakMu guards leaks and leakSeq.
+	leakMu sync.Mutex
+
+	// leaks maps the id of every tracked handle that was not closed to its
+	// creation site.
+	// It is keyed by id rather than by *handle so that tracking does not keep
+	// handles reachable and prevent their finalizers from running.
+	leaks   = make(map[uint64]Leak)
+	leakSeq uint64
+)
+
+// SetLeakTracking enables or disables recording the call stack of every
+// subsequent [Compile], so that [Leaks] can report where unclosed patterns
+// were created. Recording stacks is expensive; it is meant for debugging.
+//
+// Disabling tracking forgets the stacks recorded so far.
+func SetLeakTracking(enabled bool) {
+	leakTracking.Store(enabled)
+
+	if !enabled {
+		leakMu.Lock()
+		leaks = make(map[uint64]Leak)
+		leakMu.Unlock()
+	}
+}
+
+// Leaks returns the patterns compiled while leak tracking was enabled that
+// have not been closed, oldest first. Patterns the garbage collector freed
+// remain listed, with Finalized set.
+func Leaks() []Leak {
+	leakMu.Lock()
+	defer leakMu.Unlock()
+
+	ids := make([]uint64, 0, len(leaks))
+	for id := range leaks {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	result := make([]Leak, len(ids))
+	for i, id := range ids {
+		result[i] = leaks[id]
+	}
+
+	return result
+}
+
+// trackLeak records the creation site of a pattern and returns its id.
+func trackLeak(pattern string) uint64 {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(5, pcs) // skip Callers, trackLeak, track, compile, Compile
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var stack []byte
+	for inPackage := true; ; {
+		frame, more := frames.Next()
+
+		// Skip the wrappers of this package, such as MustCompile, so that
+		// the stack starts at the caller.
+		if inPackage && more && isPackageFunc(frame.Function) {
+			continue
+		}
+		inPackage = false
+
+		stack = append(stack, frame.Function...)
+		stack = append(stack, "\n\t"...)
+		stack = append(stack, frame.File...)
+		stack = append(stack, ':')
+		stack = strconv.AppendInt(stack, int64(frame.Line), 10)
+		stack = append(stack, '\n')
+
+		if !more {
+			break
+		}
+	}
+
+	leakMu.Lock()
+	defer leakMu.Unlock()
+
+	leakSeq++
+	leaks[leakSeq] = Leak{Pattern: pattern, Stack: string(stack)}
+
+	return leakSeq
+}
+
+// finalizeLeak marks the creation site recorded for id as finalized.
+func finalizeLeak(id uint64) {
+	leakMu.Lock()
+	if leak, ok := leaks[id]; ok {
+		leak.Finalized = true
+		leaks[id] = leak
+	}
+	leakMu.Unlock()
+}
+
+// packagePrefix is the prefix of the functions of this package, as
+// reported by [runtime.Frame].
+var packagePrefix = func() string {
+	pc, _, _, _ := runtime.Caller(0)
+	name := runtime.FuncForPC(pc).Name()
+	slash := strings.LastIndex(name, "/")
+	return name[:slash+strings.Index(name[slash:], ".")+1]
+}()
+
+// isPackageFunc reports whether function, as reported by [runtime.Frame],
+// belongs to this package.
+func isPackageFunc(function string) bool {
+	return strings.HasPrefix(function, packagePrefix)
+}
+
+// untrackLeak forgets the creation site recorded for id.
+func untrackLeak(id uint64) {
+	leakMu.Lock()
+	delete(leaks, id)
+	leakMu.Unlock()
+}
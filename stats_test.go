@@ -0,0 +1,129 @@
+package pcregexp_test
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+// settle runs the garbage collector until no more patterns are finalized,
+// so that patterns leaked by other tests do not skew the live counters.
+func settle() {
+	for last := uint64(0); ; {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+
+		finalized := pcregexp.Stats().Finalized
+		if finalized == last {
+			return
+		}
+		last = finalized
+	}
+}
+
+func TestStats(t *testing.T) {
+	settle()
+	before := pcregexp.Stats()
+
+	re := pcregexp.MustCompile(`p([a-z]+)ch`)
+	re.MatchString("peach")
+
+	during := pcregexp.Stats()
+	if got := during.LiveRegexps - before.LiveRegexps; got != 1 {
+		t.Errorf("LiveRegexps grew by %d, want 1", got)
+	}
+	if got := during.LiveMatchData - before.LiveMatchData; got != 1 {
+		t.Errorf("LiveMatchData grew by %d, want 1", got)
+	}
+	if during.CompiledBytes <= before.CompiledBytes {
+		t.Errorf("CompiledBytes = %d, want more than %d", during.CompiledBytes, before.CompiledBytes)
+	}
+	if got := during.Compiles - before.Compiles; got != 1 {
+		t.Errorf("Compiles grew by %d, want 1", got)
+	}
+	if pcregexp.Supports(pcregexp.FeatureJIT) {
+		if got := during.LiveJITStacks - before.LiveJITStacks; got != 1 {
+			t.Errorf("LiveJITStacks grew by %d, want 1", got)
+		}
+		if got := during.LiveMatchContexts - before.LiveMatchContexts; got != 1 {
+			t.Errorf("LiveMatchContexts grew by %d, want 1", got)
+		}
+	}
+
+	re.Close()
+	re.Close()
+
+	after := pcregexp.Stats()
+	if after.LiveRegexps != before.LiveRegexps ||
+		after.LiveMatchData != before.LiveMatchData ||
+		after.LiveJITStacks != before.LiveJITStacks ||
+		after.LiveMatchContexts != before.LiveMatchContexts ||
+		after.CompiledBytes != before.CompiledBytes {
+		t.Errorf("Stats() after Close = %+v, want live counters back to %+v", after, before)
+	}
+	if got := after.Frees - before.Frees; got != 1 {
+		t.Errorf("Frees grew by %d, want 1", got)
+	}
+}
+
+func TestStats_Finalized(t *testing.T) {
+	before := pcregexp.Stats()
+
+	for i := 0; i < 10; i++ {
+		pcregexp.MustCompile(`p([a-z]+)ch`).MatchString("peach")
+	}
+
+	// Finalizers run asynchronously after a collection.
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		runtime.GC()
+		if pcregexp.Stats().Finalized-before.Finalized >= 10 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Errorf("Finalized grew by %d, want 10", pcregexp.Stats().Finalized-before.Finalized)
+}
+
+func TestLeaks(t *testing.T) {
+	pcregexp.SetLeakTracking(true)
+	defer pcregexp.SetLeakTracking(false)
+
+	closed := pcregexp.MustCompile(`closed`)
+	closed.Close()
+
+	leaked := pcregexp.MustCompile(`leaked`)
+	defer leaked.Close()
+
+	leaks := pcregexp.Leaks()
+	if len(leaks) != 1 {
+		t.Fatalf("Leaks() = %v, want a single leak", leaks)
+	}
+	if leaks[0].Pattern != "leaked" {
+		t.Errorf("Leaks()[0].Pattern = %q, want %q", leaks[0].Pattern, "leaked")
+	}
+	if !strings.HasPrefix(leaks[0].Stack, "github.com/dwisiswant0/pcregexp_test.TestLeaks\n") {
+		t.Errorf("Leaks()[0].Stack does not start at the caller:\n%s", leaks[0].Stack)
+	}
+
+	leaked.Close()
+	if leaks := pcregexp.Leaks(); len(leaks) != 0 {
+		t.Errorf("Leaks() = %v after Close, want none", leaks)
+	}
+
+	// A pattern freed by the garbage collector was still never closed.
+	func() {
+		re := pcregexp.MustCompile(`finalized`)
+		re.MatchString("finalized")
+	}()
+	settle()
+
+	leaks = pcregexp.Leaks()
+	if len(leaks) != 1 || leaks[0].Pattern != "finalized" || !leaks[0].Finalized {
+		t.Errorf("Leaks() = %v after finalization, want a finalized leak of %q", leaks, "finalized")
+	}
+}
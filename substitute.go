@@ -0,0 +1,133 @@
+package pcregexp
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Substitution options of [PCREgexp.Substitute], to be combined with the
+// match flags.
+// Ref: https://pcre2project.github.io/pcre2/doc/pcre2_substitute/
+const (
+	// SubstituteGlobal replaces every match rather than the first one only
+	// (PCRE2_SUBSTITUTE_GLOBAL).
+	SubstituteGlobal MatchFlag = 0x00000100
+
+	// SubstituteExtended enables the extended replacement syntax, with
+	// escapes and ${n:+set:unset} conditionals (PCRE2_SUBSTITUTE_EXTENDED).
+	SubstituteExtended MatchFlag = 0x00000200
+
+	// SubstituteUnsetEmpty replaces unset groups with the empty string
+	// rather than failing (PCRE2_SUBSTITUTE_UNSET_EMPTY).
+	SubstituteUnsetEmpty MatchFlag = 0x00000400
+
+	// SubstituteUnknownUnset treats references to unknown groups as unset
+	// groups (PCRE2_SUBSTITUTE_UNKNOWN_UNSET).
+	SubstituteUnknownUnset MatchFlag = 0x00000800
+
+	// SubstituteLiteral inserts the replacement as is, without
+	// interpreting $ (PCRE2_SUBSTITUTE_LITERAL).
+	SubstituteLiteral MatchFlag = 0x00008000
+
+	// SubstituteReplacementOnly returns the replacements only, without the
+	// text around the matches (PCRE2_SUBSTITUTE_REPLACEMENT_ONLY).
+	SubstituteReplacementOnly MatchFlag = 0x00020000
+
+	// substituteOverflowLength makes pcre2_substitute report the output
+	// length it needs when the buffer is too small
+	// (PCRE2_SUBSTITUTE_OVERFLOW_LENGTH).
+	substituteOverflowLength = 0x00001000
+
+	// errorNoMemory is PCRE2_ERROR_NOMEMORY, returned when the output
+	// buffer is too small.
+	errorNoMemory = -48
+)
+
+// SubstituteError is returned when PCRE2 fails to substitute, for instance
+// for a malformed replacement or a match error.
+type SubstituteError struct {
+	// Code is the PCRE2 error code.
+	Code int
+
+	// Offset is the offset in the replacement of an error in it, or -1 for
+	// errors of the match, such as invalid UTF-8 in the subject.
+	Offset int
+}
+
+// Error implements the error interface.
+func (e *SubstituteError) Error() string {
+	return fmt.Sprintf("pcre2_substitute failed at offset %d: %s (error code %d)", e.Offset, ErrorMessage(e.Code), e.Code)
+}
+
+// Substitute replaces the first match of re in src from byte offset off, or
+// every match with [SubstituteGlobal], with replacement, using the native
+// PCRE2 substitution. It returns the result and the number of substitutions
+// made. With no match, src is returned as is.
+//
+// Unlike in [PCREgexp.Expand], $n, ${n} and ${name} are references and $$
+// is a literal $, and [SubstituteExtended] enables escapes such as \n and
+// case forcing with \U and \L. A reference to an unknown or unset group is
+// an error unless [SubstituteUnknownUnset] or [SubstituteUnsetEmpty] is
+// given. Match flags such as [MatchNotBOL] can be combined with the
+// substitution options in flags.
+//
+// After an empty match, global substitution first looks for a non-empty
+// match at the same offset, as PCRE2 does, before moving on.
+func (re *PCREgexp) Substitute(src, replacement []byte, off int, flags MatchFlag) ([]byte, int, error) {
+	if off < 0 || off > len(src) {
+		return nil, 0, &SubstituteError{Code: -33, Offset: off} // PCRE2_ERROR_BADOFFSET
+	}
+
+	if re.h == nil || re.h.code == 0 {
+		return src, 0, nil
+	}
+
+	defer runtime.KeepAlive(re.h)
+
+	subjectPtr := &emptySubject[0]
+	if len(src) > 0 {
+		subjectPtr = &src[0]
+	}
+
+	replacementPtr := &emptySubject[0]
+	if len(replacement) > 0 {
+		replacementPtr = &replacement[0]
+	}
+
+	out := make([]byte, len(src)+len(replacement)+64)
+	for {
+		outlen := uint64(len(out))
+		ret := pcre2_substitute(re.h.code, subjectPtr, uint64(len(src)), uint64(off),
+			uint32(flags)|substituteOverflowLength, re.h.matchDataPtr(), re.h.matchContext(),
+			replacementPtr, uint64(len(replacement)), &out[0], &outlen)
+
+		switch {
+		case ret == errorNoMemory && int(outlen) > len(out):
+			out = make([]byte, outlen)
+		case ret < 0:
+			serr := &SubstituteError{Code: int(ret), Offset: -1}
+			if outlen != ^uint64(0) { // PCRE2_UNSET
+				serr.Offset = int(outlen)
+			}
+			return nil, 0, serr
+		case ret == 0 && flags&SubstituteReplacementOnly == 0:
+			return src, 0, nil
+		default:
+			return out[:outlen], int(ret), nil
+		}
+	}
+}
+
+// SubstituteString is like [PCREgexp.Substitute] but for strings.
+func (re *PCREgexp) SubstituteString(src, replacement string, off int, flags MatchFlag) (string, int, error) {
+	b, n, err := re.Substitute(string2BytesUnsafe(src), string2BytesUnsafe(replacement), off, flags)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if n == 0 && flags&SubstituteReplacementOnly == 0 {
+		return src, 0, nil
+	}
+
+	return string(b), n, nil
+}
@@ -0,0 +1,89 @@
+package pcregexp_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dwisiswant0/pcregexp"
+)
+
+func TestRegexp_SubstituteString(t *testing.T) {
+	tests := []struct {
+		pattern     string
+		src         string
+		replacement string
+		off         int
+		flags       pcregexp.MatchFlag
+		want        string
+		wantN       int
+	}{
+		{`(\w+)@(\w+)`, "me@host, you@there", "$2 at $1", 0, 0, "host at me, you@there", 1},
+		{`(\w+)@(\w+)`, "me@host, you@there", "$2 at $1", 0, pcregexp.SubstituteGlobal, "host at me, there at you", 2},
+		{`(\w+)@(\w+)`, "me@host, you@there", "${2}!", 3, pcregexp.SubstituteGlobal, "me@host, there!", 1},
+		{`(?<user>\w+)@`, "me@host", "<${user}>", 0, 0, "<me>host", 1},
+		{`a`, "banana", "$$", 0, pcregexp.SubstituteGlobal, "b$n$n$", 3},
+		{`x*`, "abc", "-", 0, pcregexp.SubstituteGlobal, "-a-b-c-", 4},
+		{`\w+`, "hello world", `\u$0`, 0, pcregexp.SubstituteGlobal | pcregexp.SubstituteExtended, "Hello World", 2},
+		{`(a)|(b)`, "ab", "[$2]", 0, pcregexp.SubstituteGlobal | pcregexp.SubstituteUnsetEmpty, "[][b]", 2},
+		{`\d+`, "a1b22", "<$0>", 0, pcregexp.SubstituteGlobal | pcregexp.SubstituteReplacementOnly, "<1><22>", 2},
+		{`\d`, "a1b2", "$0$0", 0, pcregexp.SubstituteGlobal | pcregexp.SubstituteLiteral, "a$0$0b$0$0", 2},
+		{`^a`, "aa", "x", 0, pcregexp.MatchNotBOL, "aa", 0},
+		{`z`, "abc", "x", 0, pcregexp.SubstituteGlobal, "abc", 0},
+	}
+
+	for _, tt := range tests {
+		re := pcregexp.MustCompile(tt.pattern)
+
+		got, n, err := re.SubstituteString(tt.src, tt.replacement, tt.off, tt.flags)
+		if err != nil || got != tt.want || n != tt.wantN {
+			t.Errorf("%q.SubstituteString(%q, %q, %d, %#x) = %q, %d, %v, want %q, %d", tt.pattern, tt.src, tt.replacement, tt.off, tt.flags, got, n, err, tt.want, tt.wantN)
+		}
+
+		b, n, err := re.Substitute([]byte(tt.src), []byte(tt.replacement), tt.off, tt.flags)
+		if err != nil || string(b) != tt.want || n != tt.wantN {
+			t.Errorf("%q.Substitute(%q, %q, %d, %#x) = %q, %d, %v, want %q, %d", tt.pattern, tt.src, tt.replacement, tt.off, tt.flags, b, n, err, tt.want, tt.wantN)
+		}
+
+		re.Close()
+	}
+}
+
+func TestRegexp_Substitute_Grows(t *testing.T) {
+	re := pcregexp.MustCompile(`.`)
+	defer re.Close()
+
+	src := make([]byte, 1000)
+	for i := range src {
+		src[i] = 'a'
+	}
+
+	got, n, err := re.Substitute(src, []byte("[$0]"), 0, pcregexp.SubstituteGlobal)
+	if err != nil || n != 1000 || len(got) != 3000 || string(got[:6]) != "[a][a]" {
+		t.Errorf("Substitute() = %d bytes, %d, %v, want 3000 bytes and 1000 substitutions", len(got), n, err)
+	}
+}
+
+func TestRegexp_Substitute_Errors(t *testing.T) {
+	re := pcregexp.MustCompile(`(a)|(b)`)
+	defer re.Close()
+
+	var serr *pcregexp.SubstituteError
+
+	_, _, err := re.SubstituteString("b", "$1", 0, 0)
+	if !errors.As(err, &serr) || serr.Code != -55 {
+		t.Errorf("reference to an unset group: %v, want error -55", err)
+	}
+
+	_, _, err = re.SubstituteString("a", "${nope}", 0, 0)
+	if !errors.As(err, &serr) || serr.Code != -49 {
+		t.Errorf("reference to an unknown group: %v, want error -49", err)
+	}
+
+	if got, _, err := re.SubstituteString("a", "${nope}", 0, pcregexp.SubstituteUnknownUnset|pcregexp.SubstituteUnsetEmpty); err != nil || got != "" {
+		t.Errorf("unknown group with SubstituteUnknownUnset = %q, %v", got, err)
+	}
+
+	if _, _, err := re.SubstituteString("a", "x", 5, 0); err == nil {
+		t.Error("Substitute with an offset out of range succeeded")
+	}
+}
@@ -17,12 +17,41 @@ var (
 	//       pcre2_compile_context *ccontext);
 	pcre2_compile func(pattern *uint8, length uint64, options uint32, errorcode *int32, erroroffset *uint64, compileContext uintptr) uintptr
 
+	// pcre2_get_error_message_8:
+	//    int pcre2_get_error_message_8(int errorcode, PCRE2_UCHAR *buffer,
+	//        PCRE2_SIZE bufflen);
+	pcre2_get_error_message func(errorcode int32, buffer *uint8, bufflen uint64) int32
+
+	// pcre2_compile_context_create_8:
+	//    pcre2_compile_context *pcre2_compile_context_create_8(
+	//        pcre2_general_context *gcontext);
+	pcre2_compile_context_create func(generalContext uintptr) uintptr
+
+	// pcre2_compile_context_free_8:
+	//    void pcre2_compile_context_free_8(pcre2_compile_context *ccontext);
+	pcre2_compile_context_free func(compileContext uintptr)
+
+	// pcre2_set_compile_extra_options_8:
+	//    int pcre2_set_compile_extra_options_8(
+	//        pcre2_compile_context *ccontext, uint32_t extra_options);
+	pcre2_set_compile_extra_options func(compileContext uintptr, extraOptions uint32) int32
+
+	// pcre2_set_newline_8:
+	//    int pcre2_set_newline_8(pcre2_compile_context *ccontext,
+	//        uint32_t value);
+	pcre2_set_newline func(compileContext uintptr, value uint32) int32
+
+	// pcre2_set_parens_nest_limit_8:
+	//    int pcre2_set_parens_nest_limit_8(pcre2_compile_context *ccontext,
+	//        uint32_t value);
+	pcre2_set_parens_nest_limit func(compileContext uintptr, value uint32) int32
+
 	// pcre2_code_free_8: void pcre2_code_free_8(pcre2_code *code);
 	pcre2_code_free func(code uintptr)
 
 	// pcre2_pattern_info_8: int pcre2_pattern_info_8(const pcre2_code *code,
 	//    uint32_t what, void *where);
-	pcre2_pattern_info func(code uintptr, what uint32, where uintptr) int32
+	pcre2_pattern_info func(code uintptr, what uint32, where ptr) int32
 
 	// pcre2_match_8: int pcre2_match_8(const pcre2_code *code,
 	//    PCRE2_SPTR subject, PCRE2_SIZE length, PCRE2_SIZE startoffset,
@@ -30,6 +59,11 @@ var (
 	// 	  pcre2_match_context *mcontext);
 	pcre2_match matchFunc
 
+	// pcre2_match_addr is the address of pcre2_match_8. The matching hot path
+	// calls it with purego.SyscallN, which allocates far less per call than a
+	// function bound with purego.RegisterFunc.
+	pcre2_match_addr uintptr
+
 	// pcre2_match_data_create_from_pattern_8:
 	// 	  pcre2_match_data *pcre2_match_data_create_from_pattern_8(
 	// 	  	  const pcre2_code *code, pcre2_general_context *gcontext);
@@ -43,6 +77,18 @@ var (
 	// 	  PCRE2_SIZE *pcre2_get_ovector_pointer_8(pcre2_match_data *match_data);
 	pcre2_get_ovector_pointer func(matchData uintptr) *uint64
 
+	// pcre2_substitute_8:
+	//    int pcre2_substitute_8(const pcre2_code *code, PCRE2_SPTR subject,
+	//        PCRE2_SIZE length, PCRE2_SIZE startoffset, uint32_t options,
+	//        pcre2_match_data *match_data, pcre2_match_context *mcontext,
+	//        PCRE2_SPTR replacement, PCRE2_SIZE rlength,
+	//        PCRE2_UCHAR *outputbuffer, PCRE2_SIZE *outlengthptr);
+	pcre2_substitute func(code uintptr, subject *uint8, length uint64, startoffset uint64, options uint32, matchData uintptr, matchContext uintptr, replacement *uint8, rlength uint64, outputbuffer *uint8, outlength *uint64) int32
+
+	// pcre2_get_mark_8:
+	// 	  PCRE2_SPTR pcre2_get_mark_8(pcre2_match_data *match_data);
+	pcre2_get_mark func(matchData uintptr) *uint8
+
 	// Match context functions for timeout support
 	// pcre2_match_context_create_8:
 	//    pcre2_match_context *pcre2_match_context_create_8(
@@ -81,6 +127,12 @@ var (
 	//        uint32_t value);
 	pcre2_set_depth_limit func(matchContext uintptr, value uint32) int32
 
+	// pcre2_set_callout_8:
+	//    int pcre2_set_callout_8(pcre2_match_context *mcontext,
+	//        int (*callout_function)(pcre2_callout_block *, void *),
+	//        void *callout_data);
+	pcre2_set_callout func(matchContext uintptr, callout uintptr, data uintptr) int32
+
 	// pcre2_jit_compile:
 	//    int pcre2_jit_compile_8(pcre2_code *code, uint32_t options);
 	pcre2_jit_compile func(code uintptr, options uint32) int32
@@ -91,6 +143,10 @@ var (
 	// 	  pcre2_match_context *mcontext);
 	pcre2_jit_match matchFunc
 
+	// pcre2_jit_match_addr is the address of pcre2_jit_match_8, see
+	// pcre2_match_addr.
+	pcre2_jit_match_addr uintptr
+
 	// pcre2_jit_stack_create:
 	//    pcre2_jit_stack *pcre2_jit_stack_create_8(PCRE2_SIZE startsize,
 	//        PCRE2_SIZE maxsize, pcre2_general_context *gcontext);
@@ -104,4 +160,26 @@ var (
 	//    int pcre2_jit_stack_assign_8(pcre2_match_context *mcontext,
 	//        pcre2_jit_callback callback, void *callback_data)
 	pcre2_jit_stack_assign func(matchContext uintptr, callback uintptr, data uintptr) int32
+
+	// pcre2_config_8: int pcre2_config_8(uint32_t what, void *where);
+	pcre2_config func(what uint32, where ptr) int32
+
+	// Optional functions, only present in newer PCRE2 releases. They are left
+	// nil when the loaded library does not export them, see [Supports].
+
+	// pcre2_next_match_8 (PCRE2 10.46):
+	//    int pcre2_next_match_8(pcre2_match_data *match_data,
+	//        PCRE2_SIZE *pstart_offset, uint32_t *poptions);
+	pcre2_next_match func(matchData uintptr, startOffset *uint64, options *uint32) int32
+
+	// pcre2_set_max_varlookbehind_8 (PCRE2 10.43):
+	//    int pcre2_set_max_varlookbehind_8(pcre2_compile_context *ccontext,
+	//        uint32_t value);
+	pcre2_set_max_varlookbehind func(compileContext uintptr, value uint32) int32
+
+	// pcre2_set_substitute_case_callout_8 (PCRE2 10.45):
+	//    int pcre2_set_substitute_case_callout_8(pcre2_match_context *mcontext,
+	//        PCRE2_SIZE (*callout)(PCRE2_SPTR, PCRE2_SIZE, PCRE2_UCHAR *,
+	//        PCRE2_SIZE, int, void *), void *callout_data);
+	pcre2_set_substitute_case_callout func(matchContext uintptr, callout uintptr, data uintptr) int32
 )